@@ -0,0 +1,194 @@
+package accesscontrol
+
+import (
+	"os"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/rancher/steve/pkg/metrics"
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/utils/lru"
+)
+
+const (
+	// AccessSetCacheSizeEnvVar sets how many AccessSets are cached, it should be at least the number of active users
+	AccessSetCacheSizeEnvVar  = "CATTLE_ACCESS_SET_CACHE_SIZE"
+	defaultAccessSetCacheSize = 50
+)
+
+// accessSetCacheSize returns the size of the AccessSet cache set in the environment, or the default one
+func accessSetCacheSize() int {
+	value := os.Getenv(AccessSetCacheSizeEnvVar)
+	if value == "" {
+		return defaultAccessSetCacheSize
+	}
+	size, err := strconv.Atoi(value)
+	if err != nil || size <= 0 {
+		logrus.Errorf("invalid %s %q, using the default of %d", AccessSetCacheSizeEnvVar, value, defaultAccessSetCacheSize)
+		return defaultAccessSetCacheSize
+	}
+	return size
+}
+
+// indexedCache is an accessStoreCache whose entries can be invalidated by the roles and subjects they were computed
+// from, see grantsIndexKeys
+type indexedCache interface {
+	accessStoreCache
+	addIndexed(key string, value *AccessSet, ttl time.Duration, indexKeys sets.Set[string])
+	invalidate(indexKeys sets.Set[string])
+}
+
+// accessSetCache is an LRU cache of AccessSets, indexed by the roles and subjects of the grants they were computed from
+type accessSetCache struct {
+	lock    sync.Mutex
+	entries *lru.Cache
+	index   map[string]sets.Set[string]
+	// removalReason is the reason of entries removed by the cache itself, as opposed to evicted by the LRU
+	removalReason string
+}
+
+type accessSetCacheEntry struct {
+	value     any
+	expires   time.Time
+	indexKeys sets.Set[string]
+}
+
+var _ indexedCache = (*accessSetCache)(nil)
+
+func newAccessSetCache(size int) *accessSetCache {
+	c := &accessSetCache{index: map[string]sets.Set[string]{}}
+	// the eviction func runs within the cache's calls, with c.lock held
+	c.entries = lru.NewWithEvictionFunc(size, c.evicted)
+	return c
+}
+
+func (c *accessSetCache) Add(key any, value any, ttl time.Duration) {
+	c.add(key, value, ttl, nil)
+}
+
+func (c *accessSetCache) addIndexed(key string, value *AccessSet, ttl time.Duration, indexKeys sets.Set[string]) {
+	c.add(key, value, ttl, indexKeys)
+}
+
+func (c *accessSetCache) add(key any, value any, ttl time.Duration, indexKeys sets.Set[string]) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	// replaced entries are not evicted
+	if previous, ok := c.entries.Get(key); ok {
+		c.unindex(key, previous.(*accessSetCacheEntry))
+	}
+	c.entries.Add(key, &accessSetCacheEntry{value: value, expires: time.Now().Add(ttl), indexKeys: indexKeys})
+	if k, ok := key.(string); ok {
+		for indexKey := range indexKeys {
+			if c.index[indexKey] == nil {
+				c.index[indexKey] = sets.New[string]()
+			}
+			c.index[indexKey].Insert(k)
+		}
+	}
+	metrics.SetAccessSetCacheSize(c.entries.Len())
+}
+
+func (c *accessSetCache) Get(key any) (any, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	value, ok := c.entries.Get(key)
+	if !ok {
+		metrics.IncAccessSetCacheRequests(metrics.AccessSetCacheMiss)
+		return nil, false
+	}
+	entry := value.(*accessSetCacheEntry)
+	if time.Now().After(entry.expires) {
+		c.removeLocked(key, metrics.AccessSetCacheEvictedExpired)
+		metrics.IncAccessSetCacheRequests(metrics.AccessSetCacheMiss)
+		return nil, false
+	}
+	metrics.IncAccessSetCacheRequests(metrics.AccessSetCacheHit)
+	return entry.value, true
+}
+
+func (c *accessSetCache) Remove(key any) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.removeLocked(key, metrics.AccessSetCacheEvictedInvalidated)
+}
+
+// invalidate removes the entries computed from any of indexKeys
+func (c *accessSetCache) invalidate(indexKeys sets.Set[string]) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	for indexKey := range indexKeys {
+		for key := range c.index[indexKey] {
+			c.removeLocked(key, metrics.AccessSetCacheEvictedInvalidated)
+		}
+	}
+}
+
+func (c *accessSetCache) removeLocked(key any, reason string) {
+	if _, ok := c.entries.Get(key); !ok {
+		return
+	}
+	c.removalReason = reason
+	c.entries.Remove(key)
+	c.removalReason = ""
+	metrics.SetAccessSetCacheSize(c.entries.Len())
+}
+
+// evicted unindexes entries removed from the LRU, either by removeLocked or because the cache is full
+func (c *accessSetCache) evicted(key lru.Key, value any) {
+	reason := c.removalReason
+	if reason == "" {
+		reason = metrics.AccessSetCacheEvictedCapacity
+	}
+	metrics.IncAccessSetCacheEvictions(reason)
+	c.unindex(key, value.(*accessSetCacheEntry))
+}
+
+func (c *accessSetCache) unindex(key any, entry *accessSetCacheEntry) {
+	k, ok := key.(string)
+	if !ok {
+		return
+	}
+	for indexKey := range entry.indexKeys {
+		if keys := c.index[indexKey]; keys != nil {
+			keys.Delete(k)
+			if keys.Len() == 0 {
+				delete(c.index, indexKey)
+			}
+		}
+	}
+}
+
+// subjectIndexKey and roleIndexKey are the index keys of the subjects and roles of grants
+func subjectIndexKey(kind, name string) string {
+	return kind + ":" + name
+}
+
+func roleIndexKey(kind, namespace, name string) string {
+	if kind == clusterRoleKind {
+		return clusterRoleKind + ":" + name
+	}
+	return kind + ":" + namespace + "/" + name
+}
+
+// grantsIndexKeys returns the index keys of the user and groups of u, of the groups resolved in grants, and of the
+// roles granted to them in grants
+func grantsIndexKeys(u user.Info, grants userGrants) sets.Set[string] {
+	result := sets.New(subjectIndexKey(userKind, u.GetName()))
+	for _, group := range append(slices.Clone(u.GetGroups()), grants.groupNames...) {
+		result.Insert(subjectIndexKey(groupKind, group))
+	}
+	for _, subject := range append([]subjectGrants{grants.user}, grants.groups...) {
+		for _, binding := range subject.roleBindings {
+			result.Insert(roleIndexKey(binding.roleKind, binding.namespace, binding.roleName))
+		}
+		for _, binding := range subject.clusterRoleBindings {
+			result.Insert(roleIndexKey(binding.roleKind, "", binding.roleName))
+		}
+	}
+	return result
+}
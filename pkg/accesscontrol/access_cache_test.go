@@ -0,0 +1,88 @@
+package accesscontrol
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/sync/singleflight"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apiserver/pkg/authentication/user"
+)
+
+func TestAccessSetCache(t *testing.T) {
+	c := newAccessSetCache(2)
+	alice, bob, carol := &AccessSet{ID: "alice"}, &AccessSet{ID: "bob"}, &AccessSet{ID: "carol"}
+	c.addIndexed("alice", alice, time.Hour, sets.New("User:alice", "ClusterRole:view"))
+	c.addIndexed("bob", bob, time.Hour, sets.New("User:bob", "ClusterRole:view", "Role:dev/edit"))
+
+	value, ok := c.Get("alice")
+	assert.True(t, ok)
+	assert.Same(t, alice, value)
+
+	c.invalidate(sets.New("Role:dev/edit"))
+	_, ok = c.Get("bob")
+	assert.False(t, ok, "entries using an invalidated role are removed")
+	_, ok = c.Get("alice")
+	assert.True(t, ok, "other entries are kept")
+	assert.Equal(t, map[string]sets.Set[string]{
+		"User:alice":       sets.New("alice"),
+		"ClusterRole:view": sets.New("alice"),
+	}, c.index)
+
+	c.addIndexed("bob", bob, time.Hour, sets.New("User:bob"))
+	c.addIndexed("carol", carol, time.Hour, sets.New("User:carol"))
+	_, ok = c.Get("alice")
+	assert.False(t, ok, "the least recently used entry is evicted")
+	assert.Equal(t, map[string]sets.Set[string]{
+		"User:bob":   sets.New("bob"),
+		"User:carol": sets.New("carol"),
+	}, c.index, "evicted entries are unindexed")
+
+	c.addIndexed("alice", alice, -time.Second, sets.New("User:alice"))
+	_, ok = c.Get("alice")
+	assert.False(t, ok, "expired entries are not returned")
+	assert.NotContains(t, c.index, "User:alice")
+}
+
+func TestAccessSetCacheSize(t *testing.T) {
+	t.Setenv(AccessSetCacheSizeEnvVar, "")
+	assert.Equal(t, defaultAccessSetCacheSize, accessSetCacheSize())
+	t.Setenv(AccessSetCacheSizeEnvVar, "5000")
+	assert.Equal(t, 5000, accessSetCacheSize())
+	t.Setenv(AccessSetCacheSizeEnvVar, "none")
+	assert.Equal(t, defaultAccessSetCacheSize, accessSetCacheSize())
+}
+
+func TestAccessStore_AccessFor_invalidation(t *testing.T) {
+	testUser := &user.DefaultInfo{Name: "test-user", Groups: []string{"devs"}}
+	asCache := newAccessSetCache(10)
+	store := &AccessStore{
+		concurrentAccessFor: new(singleflight.Group),
+		usersPolicyRules:    &policyRulesMock{},
+		groupsPolicyRules: &policyRulesMock{
+			roleRefs: map[string]subjectGrants{
+				"devs": {
+					roleBindings: []roleRef{{
+						namespace: "dev", roleKind: clusterRoleKind, roleName: "edit", kind: roleKind,
+						rules: []rbacv1.PolicyRule{{Verbs: []string{"get"}, APIGroups: []string{""}, Resources: []string{"pods"}}},
+					}},
+				},
+			},
+		},
+		cache: asCache,
+	}
+
+	as := store.AccessFor(testUser)
+	_, ok := asCache.Get(as.ID)
+	assert.True(t, ok)
+
+	asCache.invalidate(sets.New("ClusterRole:view", "Group:admins"))
+	_, ok = asCache.Get(as.ID)
+	assert.True(t, ok, "unrelated changes keep the entry")
+
+	asCache.invalidate(sets.New("ClusterRole:edit"))
+	_, ok = asCache.Get(as.ID)
+	assert.False(t, ok, "the cluster role bound in a namespace invalidates the entry")
+}
@@ -0,0 +1,147 @@
+package accesscontrol
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rancher/steve/pkg/debounce"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apiserver/pkg/authentication/user"
+)
+
+const (
+	// accessChangeDelay batches the RBAC changes happening together, eg when an application is installed
+	accessChangeDelay = 100 * time.Millisecond
+	// accessResyncPeriod is how often all subscriptions are checked, in case a change was missed
+	accessResyncPeriod = 5 * time.Minute
+)
+
+// AccessChangeNotifier is implemented by AccessSetLookups which tell when the access of a user changes, so that it
+// doesn't need to be polled
+type AccessChangeNotifier interface {
+	// AccessChanged returns a channel which is closed once the AccessSet of user changes. The subscription ends with ctx.
+	AccessChanged(ctx context.Context, user user.Info) <-chan struct{}
+}
+
+var _ AccessChangeNotifier = (*AccessStore)(nil)
+
+// AccessChanged returns a channel which is closed once the AccessSet of user changes. It is never closed if the
+// AccessStore doesn't watch RBAC resources.
+func (l *AccessStore) AccessChanged(ctx context.Context, user user.Info) <-chan struct{} {
+	if l.notifier == nil {
+		return nil
+	}
+	return l.notifier.subscribe(ctx, user)
+}
+
+// accessNotifier tracks the users with subscriptions, and checks the grants of the ones affected by RBAC changes.
+// Subscriptions of users with the same name and groups share the same check.
+type accessNotifier struct {
+	grantsFor func(user.Info) userGrants
+	refresher *debounce.DebounceableRefresher
+
+	lock sync.Mutex
+	// subscriptions are keyed by userKey
+	subscriptions map[string]*accessSubscription
+	nextID        int
+	// pending has the index keys changed since the last check
+	pending sets.Set[string]
+}
+
+type accessSubscription struct {
+	user user.Info
+	// hash and indexKeys are the hash and index keys of the user's grants at the last check
+	hash      string
+	indexKeys sets.Set[string]
+	channels  map[int]chan struct{}
+}
+
+func newAccessNotifier(ctx context.Context, grantsFor func(user.Info) userGrants) *accessNotifier {
+	n := &accessNotifier{
+		grantsFor:     grantsFor,
+		subscriptions: map[string]*accessSubscription{},
+		pending:       sets.New[string](),
+	}
+	n.refresher = debounce.NewDebounceableRefresher(ctx, n, accessResyncPeriod)
+	return n
+}
+
+// userKey identifies users with the same grants
+func userKey(u user.Info) string {
+	groups := slices.Clone(u.GetGroups())
+	slices.Sort(groups)
+	return u.GetName() + "\x00" + strings.Join(groups, "\x00")
+}
+
+// changed schedules a check of the subscriptions affected by changes to indexKeys
+func (n *accessNotifier) changed(indexKeys sets.Set[string]) {
+	n.lock.Lock()
+	n.pending = n.pending.Union(indexKeys)
+	n.lock.Unlock()
+	n.refresher.Schedule(accessChangeDelay)
+}
+
+func (n *accessNotifier) subscribe(ctx context.Context, u user.Info) <-chan struct{} {
+	ch := make(chan struct{})
+	key := userKey(u)
+
+	n.lock.Lock()
+	sub, ok := n.subscriptions[key]
+	if !ok {
+		grants := n.grantsFor(u)
+		sub = &accessSubscription{user: u, hash: grants.hash(), indexKeys: grantsIndexKeys(u, grants), channels: map[int]chan struct{}{}}
+		n.subscriptions[key] = sub
+	}
+	id := n.nextID
+	n.nextID++
+	sub.channels[id] = ch
+	n.lock.Unlock()
+
+	context.AfterFunc(ctx, func() {
+		n.lock.Lock()
+		defer n.lock.Unlock()
+		delete(sub.channels, id)
+		if len(sub.channels) == 0 && n.subscriptions[key] == sub {
+			delete(n.subscriptions, key)
+		}
+	})
+	return ch
+}
+
+// Refresh checks the grants of the subscribers affected by the pending changes, and notifies the ones which changed.
+// All subscribers are checked if there are no pending changes, as it is then a periodic resync.
+func (n *accessNotifier) Refresh() error {
+	n.lock.Lock()
+	pending, all := n.pending, n.pending.Len() == 0
+	n.pending = sets.New[string]()
+	pendingKeys := pending.UnsortedList()
+	affected := map[string]*accessSubscription{}
+	for key, sub := range n.subscriptions {
+		if all || sub.indexKeys.HasAny(pendingKeys...) {
+			affected[key] = sub
+		}
+	}
+	n.lock.Unlock()
+
+	// grants are read from the informer caches, outside of the lock so that subscriptions are not held up
+	hashes := make(map[string]string, len(affected))
+	for key, sub := range affected {
+		hashes[key] = n.grantsFor(sub.user).hash()
+	}
+
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	for key, sub := range affected {
+		if hashes[key] == sub.hash || n.subscriptions[key] != sub {
+			continue
+		}
+		for _, ch := range sub.channels {
+			close(ch)
+		}
+		delete(n.subscriptions, key)
+	}
+	return nil
+}
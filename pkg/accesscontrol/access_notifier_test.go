@@ -0,0 +1,92 @@
+package accesscontrol
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apiserver/pkg/authentication/user"
+)
+
+func TestAccessNotifier(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var lock sync.Mutex
+	roles := map[string]string{"alice": "own", "bob": "view", "devs": "edit"}
+	versions := map[string]string{}
+	grantsFor := func(u user.Info) userGrants {
+		lock.Lock()
+		defer lock.Unlock()
+		roleRefFor := func(subject string) subjectGrants {
+			return subjectGrants{clusterRoleBindings: []roleRef{{
+				roleKind: clusterRoleKind, roleName: roles[subject], resourceVersion: versions[roles[subject]],
+			}}}
+		}
+		result := userGrants{user: roleRefFor(u.GetName())}
+		for _, group := range u.GetGroups() {
+			result.groups = append(result.groups, roleRefFor(group))
+		}
+		return result
+	}
+	update := func(m map[string]string, k, v string) {
+		lock.Lock()
+		defer lock.Unlock()
+		m[k] = v
+	}
+	closed := func(ch <-chan struct{}) bool {
+		select {
+		case <-ch:
+			return true
+		case <-time.After(500 * time.Millisecond):
+			return false
+		}
+	}
+	n := newAccessNotifier(ctx, grantsFor)
+	alice := &user.DefaultInfo{Name: "alice", Groups: []string{"devs"}}
+	bob := &user.DefaultInfo{Name: "bob"}
+
+	t.Run("affected users are notified", func(t *testing.T) {
+		aliceChanged, bobChanged := n.subscribe(ctx, alice), n.subscribe(ctx, bob)
+		update(roles, "devs", "admin")
+		n.changed(sets.New("Group:devs"))
+		assert.True(t, closed(aliceChanged))
+		assert.False(t, closed(bobChanged))
+	})
+
+	t.Run("changes not affecting grants are ignored", func(t *testing.T) {
+		aliceChanged := n.subscribe(ctx, alice)
+		n.changed(sets.New("Group:devs"))
+		assert.False(t, closed(aliceChanged))
+	})
+
+	t.Run("users of changed roles are notified", func(t *testing.T) {
+		aliceChanged, bobChanged := n.subscribe(ctx, alice), n.subscribe(ctx, bob)
+		update(versions, "view", "2")
+		n.changed(sets.New("ClusterRole:view"))
+		assert.True(t, closed(bobChanged))
+		assert.False(t, closed(aliceChanged))
+	})
+
+	t.Run("all users are checked on resync", func(t *testing.T) {
+		aliceChanged := n.subscribe(ctx, alice)
+		update(versions, "own", "2")
+		assert.NoError(t, n.Refresh())
+		assert.True(t, closed(aliceChanged))
+	})
+
+	t.Run("subscriptions end with their context", func(t *testing.T) {
+		subCtx, subCancel := context.WithCancel(ctx)
+		n.subscribe(subCtx, bob)
+		subCancel()
+		assert.Eventually(t, func() bool {
+			n.lock.Lock()
+			defer n.lock.Unlock()
+			_, ok := n.subscriptions[userKey(bob)]
+			return !ok
+		}, time.Second, 10*time.Millisecond)
+	})
+}
@@ -274,3 +274,59 @@ func GetAccessListMap(s *types.APISchema) AccessListByVerb {
 	v, _ := attributes.Access(s).(AccessListByVerb)
 	return v
 }
+
+// ResourceAccess is the access granted by an AccessSet for a verb on a group and resource
+type ResourceAccess struct {
+	Verb          string
+	GroupResource schema.GroupResource
+	Access        AccessList
+}
+
+// NonResourceAccess is a verb granted by an AccessSet on a non-resource URL
+type NonResourceAccess struct {
+	Verb string
+	URL  string
+}
+
+// ListResourceAccess returns the access granted by the set, sorted by verb, group, resource, namespace and name
+func (a *AccessSet) ListResourceAccess() []ResourceAccess {
+	result := make([]ResourceAccess, 0, len(a.set))
+	for k, accessSet := range a.set {
+		access := make(AccessList, 0, len(accessSet))
+		for item := range accessSet {
+			access = append(access, item)
+		}
+		sort.Slice(access, func(i, j int) bool {
+			if access[i].Namespace != access[j].Namespace {
+				return access[i].Namespace < access[j].Namespace
+			}
+			return access[i].ResourceName < access[j].ResourceName
+		})
+		result = append(result, ResourceAccess{Verb: k.verb, GroupResource: k.gr, Access: access})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Verb != result[j].Verb {
+			return result[i].Verb < result[j].Verb
+		}
+		if result[i].GroupResource.Group != result[j].GroupResource.Group {
+			return result[i].GroupResource.Group < result[j].GroupResource.Group
+		}
+		return result[i].GroupResource.Resource < result[j].GroupResource.Resource
+	})
+	return result
+}
+
+// ListNonResourceAccess returns the non-resource URLs granted by the set, sorted by verb and URL
+func (a *AccessSet) ListNonResourceAccess() []NonResourceAccess {
+	result := make([]NonResourceAccess, 0, len(a.nonResourceSet))
+	for k := range a.nonResourceSet {
+		result = append(result, NonResourceAccess{Verb: k.verb, URL: k.url})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Verb != result[j].Verb {
+			return result[i].Verb < result[j].Verb
+		}
+		return result[i].URL < result[j].URL
+	})
+	return result
+}
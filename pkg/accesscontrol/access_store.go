@@ -2,13 +2,10 @@ package accesscontrol
 
 import (
 	"context"
-	"slices"
-	"sort"
 	"time"
 
 	v1 "github.com/rancher/wrangler/v3/pkg/generated/controllers/rbac/v1"
 	"golang.org/x/sync/singleflight"
-	"k8s.io/apimachinery/pkg/util/cache"
 	"k8s.io/apiserver/pkg/authentication/user"
 )
 
@@ -30,22 +27,33 @@ type accessStoreCache interface {
 	Remove(key interface{})
 }
 
+// accessSetCacheTTL is how long AccessSets are cached, as they are also invalidated on changes
+const accessSetCacheTTL = 24 * time.Hour
+
 type AccessStore struct {
 	usersPolicyRules    policyRules
 	groupsPolicyRules   policyRules
 	cache               accessStoreCache
 	concurrentAccessFor *singleflight.Group
+	notifier            *accessNotifier
+	groupResolver       GroupResolver
 }
 
-func NewAccessStore(_ context.Context, cacheResults bool, rbac v1.Interface) *AccessStore {
+func NewAccessStore(ctx context.Context, cacheResults bool, rbac v1.Interface) *AccessStore {
 	as := &AccessStore{
 		usersPolicyRules:    newPolicyRuleIndex(true, rbac),
 		groupsPolicyRules:   newPolicyRuleIndex(false, rbac),
 		concurrentAccessFor: new(singleflight.Group),
 	}
+	changes := newRBACChanges()
 	if cacheResults {
-		as.cache = cache.NewLRUExpireCache(50)
+		c := newAccessSetCache(accessSetCacheSize())
+		as.cache = c
+		changes.handlers = append(changes.handlers, c.invalidate)
 	}
+	as.notifier = newAccessNotifier(ctx, as.userGrantsFor)
+	changes.handlers = append(changes.handlers, as.notifier.changed)
+	changes.register(ctx, rbac)
 	return as
 }
 
@@ -65,7 +73,12 @@ func (l *AccessStore) AccessFor(user user.Info) *AccessSet {
 
 		result := l.newAccessSet(info)
 		result.ID = cacheKey
-		l.cache.Add(cacheKey, result, 24*time.Hour)
+		if indexed, ok := l.cache.(indexedCache); ok {
+			// indexed entries are invalidated as soon as the roles or bindings they were computed from change
+			indexed.addIndexed(cacheKey, result, accessSetCacheTTL, grantsIndexKeys(user, info))
+		} else {
+			l.cache.Add(cacheKey, result, accessSetCacheTTL)
+		}
 
 		return result, nil
 	})
@@ -88,11 +101,9 @@ func (l *AccessStore) PurgeUserData(id string) {
 func (l *AccessStore) userGrantsFor(user user.Info) userGrants {
 	var res userGrants
 
-	groups := slices.Clone(user.GetGroups())
-	sort.Strings(groups)
-
 	res.user = l.usersPolicyRules.getRoleRefs(user.GetName())
-	for _, group := range groups {
+	res.groupNames = l.resolvedGroups(user)
+	for _, group := range res.groupNames {
 		res.groups = append(res.groups, l.groupsPolicyRules.getRoleRefs(group))
 	}
 
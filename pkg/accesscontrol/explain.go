@@ -0,0 +1,94 @@
+package accesscontrol
+
+import (
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apiserver/pkg/authentication/user"
+)
+
+const (
+	roleBindingKind        = "RoleBinding"
+	clusterRoleBindingKind = "ClusterRoleBinding"
+)
+
+// Grant is a rule of a Role or ClusterRole granting access to a user, directly or through one of its groups
+type Grant struct {
+	// SubjectKind and SubjectName are the subject of the binding matching the user, either the user or a group
+	SubjectKind      string            `json:"subjectKind"`
+	SubjectName      string            `json:"subjectName"`
+	BindingKind      string            `json:"bindingKind"`
+	BindingName      string            `json:"bindingName"`
+	BindingNamespace string            `json:"bindingNamespace,omitempty"`
+	RoleKind         string            `json:"roleKind"`
+	RoleName         string            `json:"roleName"`
+	Rule             rbacv1.PolicyRule `json:"rule"`
+}
+
+// AccessExplainer finds the rules granting access to users, as computed in their AccessSet
+type AccessExplainer interface {
+	// Explain returns the rules granting verb on the named object of a group and resource
+	Explain(user user.Info, verb string, gr schema.GroupResource, namespace, name string) []Grant
+	// ExplainNonResource returns the rules granting verb on a non-resource URL
+	ExplainNonResource(user user.Info, verb, url string) []Grant
+}
+
+var _ AccessExplainer = (*AccessStore)(nil)
+
+func (l *AccessStore) Explain(user user.Info, verb string, gr schema.GroupResource, namespace, name string) []Grant {
+	return l.explain(user, func(accessSet *AccessSet) bool {
+		return accessSet.Grants(verb, gr, namespace, name)
+	})
+}
+
+func (l *AccessStore) ExplainNonResource(user user.Info, verb, url string) []Grant {
+	return l.explain(user, func(accessSet *AccessSet) bool {
+		return accessSet.GrantsNonResource(verb, url)
+	})
+}
+
+// explain returns the rules whose AccessSet, on their own, satisfy grants. The subjects are the user, then its
+// groups, in the same order as userGrantsFor.
+func (l *AccessStore) explain(user user.Info, grants func(*AccessSet) bool) []Grant {
+	result := l.usersPolicyRules.getRoleRefs(user.GetName()).explain(userKind, user.GetName(), grants)
+	for _, group := range l.resolvedGroups(user) {
+		result = append(result, l.groupsPolicyRules.getRoleRefs(group).explain(groupKind, group, grants)...)
+	}
+	return result
+}
+
+func (b subjectGrants) explain(subjectKind, subjectName string, grants func(*AccessSet) bool) []Grant {
+	var result []Grant
+	for _, binding := range b.roleBindings {
+		result = append(result, binding.explain(subjectKind, subjectName, roleBindingKind, binding.namespace, grants)...)
+	}
+	for _, binding := range b.clusterRoleBindings {
+		result = append(result, binding.explain(subjectKind, subjectName, clusterRoleBindingKind, All, grants)...)
+	}
+	return result
+}
+
+func (r roleRef) explain(subjectKind, subjectName, bindingKind, namespace string, grants func(*AccessSet) bool) []Grant {
+	var result []Grant
+	for _, rule := range r.rules {
+		// each rule is checked with the same logic as when building the user's AccessSet
+		accessSet := new(AccessSet)
+		addAccess(accessSet, namespace, roleRef{kind: r.kind, rules: []rbacv1.PolicyRule{rule}})
+		if !grants(accessSet) {
+			continue
+		}
+		grant := Grant{
+			SubjectKind: subjectKind,
+			SubjectName: subjectName,
+			BindingKind: bindingKind,
+			BindingName: r.bindingName,
+			RoleKind:    r.roleKind,
+			RoleName:    r.roleName,
+			Rule:        rule,
+		}
+		if namespace != All {
+			grant.BindingNamespace = namespace
+		}
+		result = append(result, grant)
+	}
+	return result
+}
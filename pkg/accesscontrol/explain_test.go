@@ -0,0 +1,59 @@
+package accesscontrol
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apiserver/pkg/authentication/user"
+)
+
+func TestAccessStore_Explain(t *testing.T) {
+	testUser := &user.DefaultInfo{Name: "test-user", Groups: []string{"devs"}}
+	readConfigMaps := rbacv1.PolicyRule{Verbs: []string{"get", "list"}, APIGroups: []string{""}, Resources: []string{"configmaps"}}
+	readSecret := rbacv1.PolicyRule{Verbs: []string{"get"}, APIGroups: []string{""}, Resources: []string{"secrets"}, ResourceNames: []string{"token"}}
+	healthz := rbacv1.PolicyRule{Verbs: []string{"get"}, NonResourceURLs: []string{"/healthz"}}
+	store := &AccessStore{
+		usersPolicyRules: &policyRulesMock{
+			roleRefs: map[string]subjectGrants{
+				testUser.Name: {
+					clusterRoleBindings: []roleRef{{
+						bindingName: "viewers", roleKind: "ClusterRole", roleName: "viewer", kind: "ClusterRole",
+						rules: []rbacv1.PolicyRule{readConfigMaps, healthz},
+					}},
+				},
+			},
+		},
+		groupsPolicyRules: &policyRulesMock{
+			roleRefs: map[string]subjectGrants{
+				"devs": {
+					roleBindings: []roleRef{{
+						namespace: "dev", bindingName: "dev-secrets", roleKind: "Role", roleName: "secret-reader", kind: "Role",
+						rules: []rbacv1.PolicyRule{readSecret, readConfigMaps},
+					}},
+				},
+			},
+		},
+	}
+
+	configMaps := schema.GroupResource{Resource: "configmaps"}
+	assert.Equal(t, []Grant{
+		{SubjectKind: "User", SubjectName: "test-user", BindingKind: "ClusterRoleBinding", BindingName: "viewers",
+			RoleKind: "ClusterRole", RoleName: "viewer", Rule: readConfigMaps},
+		{SubjectKind: "Group", SubjectName: "devs", BindingKind: "RoleBinding", BindingName: "dev-secrets",
+			BindingNamespace: "dev", RoleKind: "Role", RoleName: "secret-reader", Rule: readConfigMaps},
+	}, store.Explain(testUser, "list", configMaps, "dev", ""))
+	assert.Len(t, store.Explain(testUser, "list", configMaps, "prod", ""), 1, "role bindings only apply to their namespace")
+
+	secrets := schema.GroupResource{Resource: "secrets"}
+	assert.Len(t, store.Explain(testUser, "get", secrets, "dev", "token"), 1)
+	assert.Empty(t, store.Explain(testUser, "get", secrets, "dev", "other"))
+	assert.Empty(t, store.Explain(testUser, "delete", configMaps, "dev", ""))
+
+	assert.Equal(t, []Grant{
+		{SubjectKind: "User", SubjectName: "test-user", BindingKind: "ClusterRoleBinding", BindingName: "viewers",
+			RoleKind: "ClusterRole", RoleName: "viewer", Rule: healthz},
+	}, store.ExplainNonResource(testUser, "get", "/healthz"))
+	assert.Empty(t, store.ExplainNonResource(testUser, "get", "/metrics"))
+}
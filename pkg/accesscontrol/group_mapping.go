@@ -0,0 +1,227 @@
+package accesscontrol
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rancher/steve/pkg/configfile"
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+)
+
+const (
+	// GroupMappingFileEnvVar is the path to a YAML or JSON file containing the GroupMapping of users
+	GroupMappingFileEnvVar = "CATTLE_GROUP_MAPPING_FILE"
+	// GroupMappingConfigMapEnvVar is the namespace/name of a ConfigMap containing the GroupMapping of users in its
+	// GroupMappingConfigMapKey
+	GroupMappingConfigMapEnvVar = "CATTLE_GROUP_MAPPING_CONFIGMAP"
+	// GroupMappingConfigMapKey is the key of the GroupMapping in its ConfigMap
+	GroupMappingConfigMapKey = "groups.yaml"
+
+	// groupMappingReloadInterval is how often the GroupMapping file is checked for changes
+	groupMappingReloadInterval = 10 * time.Second
+)
+
+// groupMappingSyncTimeout is how long the GroupMapping ConfigMap is waited for, which is set to a var so that it can
+// be overridden by test code
+var groupMappingSyncTimeout = 30 * time.Second
+
+// GroupMapping adds groups to users, by name or through the groups they are members of
+type GroupMapping struct {
+	// Users are the groups added to users, by user name
+	Users map[string][]string `json:"users,omitempty"`
+	// Groups are the groups added to the members of a group, by group name. They are expanded recursively, so that
+	// members of a group are also members of the groups it is a member of.
+	Groups map[string][]string `json:"groups,omitempty"`
+}
+
+func (m GroupMapping) validate() error {
+	for name, groups := range m.Users {
+		if slices.Contains(groups, "") {
+			return fmt.Errorf("user %q: empty group name", name)
+		}
+	}
+	for name, groups := range m.Groups {
+		if slices.Contains(groups, "") {
+			return fmt.Errorf("group %q: empty group name", name)
+		}
+	}
+	return nil
+}
+
+// resolve returns the groups added to a user named name, member of groups
+func (m GroupMapping) resolve(name string, groups []string) []string {
+	result := sets.New(m.Users[name]...)
+	pending := append(slices.Clone(groups), m.Users[name]...)
+	for len(pending) > 0 {
+		group := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+		for _, parent := range m.Groups[group] {
+			if !result.Has(parent) {
+				result.Insert(parent)
+				pending = append(pending, parent)
+			}
+		}
+	}
+	return sets.List(result)
+}
+
+// changes returns the users and groups whose groups are different in m and other
+func (m GroupMapping) changes(other GroupMapping) (users, groups []string) {
+	for name := range sets.KeySet(m.Users).Union(sets.KeySet(other.Users)) {
+		if !slices.Equal(m.Users[name], other.Users[name]) {
+			users = append(users, name)
+		}
+	}
+	for name := range sets.KeySet(m.Groups).Union(sets.KeySet(other.Groups)) {
+		if !slices.Equal(m.Groups[name], other.Groups[name]) {
+			groups = append(groups, name)
+		}
+	}
+	// members of groups expanding to a changed group are affected as well
+	changed := sets.New(groups...)
+	affected := changed.Clone()
+	for _, mapping := range []GroupMapping{m, other} {
+		for name := range mapping.Groups {
+			if changed.HasAny(mapping.resolve("", []string{name})...) {
+				affected.Insert(name)
+			}
+		}
+	}
+	return users, sets.List(affected)
+}
+
+// MappingGroupResolver is a GroupResolver adding groups to users according to a GroupMapping, which may be replaced
+// when its source changes
+type MappingGroupResolver struct {
+	lock     sync.RWMutex
+	mapping  GroupMapping
+	handlers []func(users, groups []string)
+}
+
+var (
+	_ GroupResolver        = (*MappingGroupResolver)(nil)
+	_ GroupsChangeNotifier = (*MappingGroupResolver)(nil)
+)
+
+// NewMappingGroupResolver returns a MappingGroupResolver adding groups according to mapping
+func NewMappingGroupResolver(mapping GroupMapping) *MappingGroupResolver {
+	return &MappingGroupResolver{mapping: mapping}
+}
+
+func (r *MappingGroupResolver) ResolveGroups(u user.Info) ([]string, error) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	return r.mapping.resolve(u.GetName(), u.GetGroups()), nil
+}
+
+func (r *MappingGroupResolver) OnGroupsChanged(handler func(users, groups []string)) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.handlers = append(r.handlers, handler)
+}
+
+// SetMapping replaces the GroupMapping, and notifies the handlers of the users and groups it changes
+func (r *MappingGroupResolver) SetMapping(mapping GroupMapping) {
+	r.lock.Lock()
+	users, groups := r.mapping.changes(mapping)
+	r.mapping = mapping
+	handlers := slices.Clone(r.handlers)
+	r.lock.Unlock()
+
+	if len(users) == 0 && len(groups) == 0 {
+		return
+	}
+	for _, handler := range handlers {
+		handler(users, groups)
+	}
+}
+
+// NewFileGroupResolver returns a MappingGroupResolver with the GroupMapping in the file in path, reloaded when it
+// changes until ctx is done. A file which can't be loaded is logged and the previous GroupMapping is kept.
+func NewFileGroupResolver(ctx context.Context, path string) (*MappingGroupResolver, error) {
+	r := NewMappingGroupResolver(GroupMapping{})
+	err := configfile.Watch(ctx, path, "group mapping", groupMappingReloadInterval, func(content []byte) error {
+		mapping, err := configfile.Parse(content, "group mapping", GroupMapping.validate)
+		if err != nil {
+			return err
+		}
+		r.SetMapping(mapping)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// NewConfigMapGroupResolver returns a MappingGroupResolver with the GroupMapping in the GroupMappingConfigMapKey of
+// a ConfigMap, watched until ctx is done. Users get no additional groups while the ConfigMap doesn't exist, and an
+// invalid GroupMapping is logged and the previous one is kept.
+func NewConfigMapGroupResolver(ctx context.Context, client kubernetes.Interface, namespace, name string) (*MappingGroupResolver, error) {
+	r := NewMappingGroupResolver(GroupMapping{})
+	factory := informers.NewSharedInformerFactoryWithOptions(client, 0,
+		informers.WithNamespace(namespace),
+		informers.WithTweakListOptions(func(options *metav1.ListOptions) {
+			options.FieldSelector = fields.OneTermEqualSelector("metadata.name", name).String()
+		}))
+	informer := factory.Core().V1().ConfigMaps().Informer()
+	update := func(obj any) {
+		configMap, ok := obj.(*corev1.ConfigMap)
+		if !ok {
+			return
+		}
+		mapping, err := configfile.Parse([]byte(configMap.Data[GroupMappingConfigMapKey]), "group mapping of configmap "+namespace+"/"+name, GroupMapping.validate)
+		if err != nil {
+			logrus.Error(err)
+			return
+		}
+		r.SetMapping(mapping)
+	}
+	if _, err := informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    update,
+		UpdateFunc: func(_, obj any) { update(obj) },
+		DeleteFunc: func(any) { r.SetMapping(GroupMapping{}) },
+	}); err != nil {
+		return nil, err
+	}
+	factory.Start(ctx.Done())
+	syncCtx, cancel := context.WithTimeout(ctx, groupMappingSyncTimeout)
+	defer cancel()
+	if !cache.WaitForCacheSync(syncCtx.Done(), informer.HasSynced) {
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("waiting for the group mapping configmap %s/%s: %w", namespace, name, ctx.Err())
+		}
+		return nil, fmt.Errorf("group mapping configmap %s/%s not synced after %s, check that configmaps can be listed and watched in %s",
+			namespace, name, groupMappingSyncTimeout, namespace)
+	}
+	return r, nil
+}
+
+// GroupResolverFromEnv returns a MappingGroupResolver with the GroupMapping in the file of GroupMappingFileEnvVar,
+// or in the ConfigMap of GroupMappingConfigMapEnvVar, or nil if neither is set
+func GroupResolverFromEnv(ctx context.Context, client kubernetes.Interface) (GroupResolver, error) {
+	if path := os.Getenv(GroupMappingFileEnvVar); path != "" {
+		return NewFileGroupResolver(ctx, path)
+	}
+	if ref := os.Getenv(GroupMappingConfigMapEnvVar); ref != "" {
+		namespace, name, ok := strings.Cut(ref, "/")
+		if !ok || namespace == "" || name == "" {
+			return nil, fmt.Errorf("invalid %s %q, expected namespace/name", GroupMappingConfigMapEnvVar, ref)
+		}
+		return NewConfigMapGroupResolver(ctx, client, namespace, name)
+	}
+	return nil, nil
+}
@@ -0,0 +1,124 @@
+package accesscontrol
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/rancher/steve/pkg/configfile"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/client-go/kubernetes/fake"
+	clienttesting "k8s.io/client-go/testing"
+)
+
+func TestGroupMappingResolve(t *testing.T) {
+	mapping := GroupMapping{
+		Users: map[string][]string{"alice": {"admins"}},
+		Groups: map[string][]string{
+			"admins": {"devs"},
+			"devs":   {"everyone", "admins"},
+			"ops":    {"oncall"},
+		},
+	}
+	tests := []struct {
+		name   string
+		user   string
+		groups []string
+		want   []string
+	}{
+		{name: "user mapping is expanded, cycles included", user: "alice", want: []string{"admins", "devs", "everyone"}},
+		{name: "groups are expanded", user: "bob", groups: []string{"ops"}, want: []string{"oncall"}},
+		{name: "unknown user and groups", user: "carol", groups: []string{"guests"}, want: []string{}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.want, mapping.resolve(test.user, test.groups))
+		})
+	}
+}
+
+func TestGroupMappingChanges(t *testing.T) {
+	old := GroupMapping{
+		Users:  map[string][]string{"alice": {"admins"}, "bob": {"devs"}},
+		Groups: map[string][]string{"admins": {"devs"}, "devs": {"everyone"}, "ops": {"oncall"}},
+	}
+	updated := GroupMapping{
+		Users:  map[string][]string{"alice": {"admins"}, "carol": {"ops"}},
+		Groups: map[string][]string{"admins": {"devs"}, "devs": {"readers"}, "ops": {"oncall"}},
+	}
+	users, groups := old.changes(updated)
+	assert.ElementsMatch(t, []string{"bob", "carol"}, users)
+	// members of admins are affected through devs
+	assert.Equal(t, []string{"admins", "devs"}, groups)
+}
+
+func TestParseGroupMapping(t *testing.T) {
+	_, err := configfile.Parse([]byte(`users: {alice: [""]}`), "group mapping", GroupMapping.validate)
+	assert.Error(t, err)
+	_, err = configfile.Parse([]byte(`user: {}`), "group mapping", GroupMapping.validate)
+	assert.Error(t, err)
+}
+
+func TestFileGroupResolver(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "groups.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("groups:\n  truncated: [devs]\n"), 0o600))
+
+	resolver, err := NewFileGroupResolver(context.Background(), path)
+	require.NoError(t, err)
+	groups, err := resolver.ResolveGroups(&user.DefaultInfo{Name: "alice", Groups: []string{"truncated"}})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"devs"}, groups)
+
+	_, err = NewFileGroupResolver(context.Background(), filepath.Join(t.TempDir(), "missing.yaml"))
+	assert.Error(t, err)
+}
+
+func TestConfigMapGroupResolver(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	client := fake.NewSimpleClientset(&corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "cattle-system", Name: "groups"},
+		Data:       map[string]string{GroupMappingConfigMapKey: "users:\n  alice: [admins]\n"},
+	})
+
+	resolver, err := NewConfigMapGroupResolver(ctx, client, "cattle-system", "groups")
+	require.NoError(t, err)
+	alice := &user.DefaultInfo{Name: "alice"}
+	groups, err := resolver.ResolveGroups(alice)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"admins"}, groups)
+
+	notified := make(chan []string, 1)
+	resolver.OnGroupsChanged(func(users, _ []string) { notified <- users })
+	require.NoError(t, client.CoreV1().ConfigMaps("cattle-system").Delete(ctx, "groups", metav1.DeleteOptions{}))
+	select {
+	case users := <-notified:
+		assert.Equal(t, []string{"alice"}, users)
+	case <-time.After(5 * time.Second):
+		t.Fatal("deleting the configmap was not notified")
+	}
+	groups, err = resolver.ResolveGroups(alice)
+	require.NoError(t, err)
+	assert.Empty(t, groups)
+}
+
+func TestConfigMapGroupResolverTimeout(t *testing.T) {
+	defer func(timeout time.Duration) { groupMappingSyncTimeout = timeout }(groupMappingSyncTimeout)
+	groupMappingSyncTimeout = 100 * time.Millisecond
+	client := fake.NewSimpleClientset()
+	client.PrependReactor("list", "configmaps", func(clienttesting.Action) (bool, runtime.Object, error) {
+		return true, nil, apierrors.NewForbidden(corev1.Resource("configmaps"), "", errors.New("no RBAC"))
+	})
+
+	_, err := NewConfigMapGroupResolver(t.Context(), client, "cattle-system", "groups")
+	assert.ErrorContains(t, err, "check that configmaps can be listed and watched in cattle-system")
+}
@@ -0,0 +1,114 @@
+package accesscontrol
+
+import (
+	"net/http"
+	"slices"
+
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/apiserver/pkg/endpoints/request"
+)
+
+// GroupResolver adds groups to users for RBAC evaluation, on top of the ones they were authenticated with, for example
+// because the identity provider truncates them. It is called for each AccessSet lookup, so it should not block.
+type GroupResolver interface {
+	// ResolveGroups returns the groups of u missing from u.GetGroups(). The result may include groups of u.
+	ResolveGroups(u user.Info) ([]string, error)
+}
+
+type GroupResolverFunc func(u user.Info) ([]string, error)
+
+func (f GroupResolverFunc) ResolveGroups(u user.Info) ([]string, error) {
+	return f(u)
+}
+
+// GroupsChangeNotifier is implemented by GroupResolvers whose resolved groups change over time, so that the AccessSets
+// of the affected users are checked again
+type GroupsChangeNotifier interface {
+	// OnGroupsChanged registers handler to be called with the users, and the members of the groups, whose resolved
+	// groups changed
+	OnGroupsChanged(handler func(users, groups []string))
+}
+
+// SetGroupResolver makes the AccessStore grant users the access of the groups added by resolver. It must be called
+// before the AccessStore is used.
+func (l *AccessStore) SetGroupResolver(resolver GroupResolver) {
+	l.groupResolver = resolver
+	notifier, ok := resolver.(GroupsChangeNotifier)
+	if !ok || l.notifier == nil {
+		return
+	}
+	// AccessSets are cached by the hash of their grants, which are computed from the resolved groups, so only the
+	// subscriptions need to be told about the change
+	notifier.OnGroupsChanged(func(users, groups []string) {
+		indexKeys := sets.New[string]()
+		for _, name := range users {
+			indexKeys.Insert(subjectIndexKey(userKind, name))
+		}
+		for _, group := range groups {
+			indexKeys.Insert(subjectIndexKey(groupKind, group))
+		}
+		if indexKeys.Len() > 0 {
+			l.notifier.changed(indexKeys)
+		}
+	})
+}
+
+// resolvedGroups returns the groups of u, with the ones added by the GroupResolver, sorted and without duplicates. If
+// the resolver fails, only the groups of u are returned. Users put in requests by GroupResolverMiddleware are resolved
+// again from the groups they were authenticated with, so that long-lived requests see changes of their groups.
+func (l *AccessStore) resolvedGroups(u user.Info) []string {
+	return resolveGroups(l.groupResolver, AuthenticatedUser(u))
+}
+
+// AuthenticatedUser returns the user u was authenticated as, without the groups added by GroupResolverMiddleware.
+// Kubernetes doesn't know about the resolved groups, so users are impersonated with the groups they were
+// authenticated with.
+func AuthenticatedUser(u user.Info) user.Info {
+	if resolved, ok := u.(*resolvedUser); ok {
+		return resolved.Info
+	}
+	return u
+}
+
+func resolveGroups(resolver GroupResolver, u user.Info) []string {
+	groups := slices.Clone(u.GetGroups())
+	if resolver != nil {
+		resolved, err := resolver.ResolveGroups(u)
+		if err != nil {
+			logrus.Errorf("resolving the groups of user %s: %v", u.GetName(), err)
+		}
+		groups = append(groups, resolved...)
+	}
+	slices.Sort(groups)
+	return slices.Compact(groups)
+}
+
+// resolvedUser is an authenticated user whose groups include the ones added by a GroupResolver
+type resolvedUser struct {
+	user.Info
+	groups []string
+}
+
+func (u *resolvedUser) GetGroups() []string {
+	return u.groups
+}
+
+// GroupResolverMiddleware returns a middleware replacing the user of requests with one having the groups added by
+// resolver, so that the rate limits, redactions and audit log see the groups the access of the user is computed with.
+// It must be chained after authentication. A nil resolver leaves requests unchanged.
+func GroupResolverMiddleware(resolver GroupResolver) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if resolver == nil {
+			return next
+		}
+		return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			if u, ok := request.UserFrom(req.Context()); ok {
+				resolved := &resolvedUser{Info: u, groups: resolveGroups(resolver, u)}
+				req = req.WithContext(request.WithUser(req.Context(), resolved))
+			}
+			next.ServeHTTP(rw, req)
+		})
+	}
+}
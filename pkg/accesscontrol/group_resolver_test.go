@@ -0,0 +1,93 @@
+package accesscontrol
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/apiserver/pkg/endpoints/request"
+)
+
+func TestAccessStoreGroupResolver(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	podReaders := subjectGrants{clusterRoleBindings: []roleRef{{
+		kind: clusterRoleKind, roleKind: clusterRoleKind, roleName: "pod-reader", resourceVersion: "1", bindingName: "pod-readers",
+		rules: []rbacv1.PolicyRule{{Verbs: []string{"get"}, APIGroups: []string{""}, Resources: []string{"pods"}}},
+	}}}
+	store := &AccessStore{
+		usersPolicyRules:  &policyRulesMock{},
+		groupsPolicyRules: &policyRulesMock{roleRefs: map[string]subjectGrants{"pod-readers": podReaders}},
+	}
+	store.notifier = newAccessNotifier(ctx, store.userGrantsFor)
+	alice := &user.DefaultInfo{Name: "alice", Groups: []string{"truncated"}}
+	pods := schema.GroupResource{Resource: "pods"}
+
+	withoutResolver := store.userGrantsFor(alice).hash()
+	assert.False(t, store.AccessFor(alice).Grants("get", pods, "default", "web"))
+
+	resolver := NewMappingGroupResolver(GroupMapping{Groups: map[string][]string{"truncated": {"pod-readers"}}})
+	store.SetGroupResolver(resolver)
+	grants := store.userGrantsFor(alice)
+	assert.NotEqual(t, withoutResolver, grants.hash(), "resolved groups must change the cache key")
+	assert.Equal(t, []string{"pod-readers", "truncated"}, grants.groupNames)
+	assert.Contains(t, grantsIndexKeys(alice, grants), subjectIndexKey(groupKind, "pod-readers"))
+	assert.True(t, store.AccessFor(alice).Grants("get", pods, "default", "web"))
+	explained := store.Explain(alice, "get", pods, "default", "web")
+	if assert.Len(t, explained, 1) {
+		assert.Equal(t, "pod-readers", explained[0].SubjectName)
+	}
+
+	// subscriptions are notified once the resolved groups change their access
+	changed := store.AccessChanged(ctx, alice)
+	resolver.SetMapping(GroupMapping{})
+	select {
+	case <-changed:
+	case <-time.After(time.Second):
+		t.Fatal("subscription was not notified of the group mapping change")
+	}
+	assert.Equal(t, withoutResolver, store.userGrantsFor(alice).hash())
+}
+
+func TestAccessStoreGroupResolverError(t *testing.T) {
+	store := &AccessStore{
+		usersPolicyRules:  &policyRulesMock{},
+		groupsPolicyRules: &policyRulesMock{},
+	}
+	store.SetGroupResolver(GroupResolverFunc(func(user.Info) ([]string, error) {
+		return []string{"partial"}, errors.New("unavailable")
+	}))
+	// the groups of the user are kept when resolving fails
+	assert.Equal(t, []string{"a", "b", "partial"}, store.resolvedGroups(&user.DefaultInfo{Name: "alice", Groups: []string{"b", "a", "b"}}))
+}
+
+func TestGroupResolverMiddleware(t *testing.T) {
+	resolver := NewMappingGroupResolver(GroupMapping{Groups: map[string][]string{"truncated": {"devs"}}})
+	var got user.Info
+	handler := GroupResolverMiddleware(resolver)(http.HandlerFunc(func(_ http.ResponseWriter, req *http.Request) {
+		got, _ = request.UserFrom(req.Context())
+	}))
+	alice := &user.DefaultInfo{Name: "alice", Groups: []string{"truncated"}}
+	req := httptest.NewRequest(http.MethodGet, "/v1/pods", nil)
+	handler.ServeHTTP(httptest.NewRecorder(), req.WithContext(request.WithUser(req.Context(), alice)))
+	assert.Equal(t, "alice", got.GetName())
+	assert.Equal(t, []string{"devs", "truncated"}, got.GetGroups())
+	assert.Same(t, alice, AuthenticatedUser(got), "the authenticated user is impersonated")
+	assert.Same(t, alice, AuthenticatedUser(alice))
+
+	// the AccessStore resolves the groups the user was authenticated with again, as they may change during a watch
+	store := &AccessStore{}
+	store.SetGroupResolver(resolver)
+	resolver.SetMapping(GroupMapping{Groups: map[string][]string{"truncated": {"ops"}}})
+	assert.Equal(t, []string{"ops", "truncated"}, store.resolvedGroups(got))
+
+	assert.Nil(t, GroupResolverMiddleware(nil)(nil), "requests are unchanged without a resolver")
+}
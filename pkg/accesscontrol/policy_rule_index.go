@@ -190,6 +190,8 @@ func (p *policyRuleIndex) getRoleRefs(subjectName string) subjectGrants {
 			resourceVersion: resourceVersion,
 			rules:           rules,
 			kind:            clusterRoleKind,
+			bindingName:     crb.Name,
+			roleKind:        crb.RoleRef.Kind,
 		}
 	}
 
@@ -203,6 +205,8 @@ func (p *policyRuleIndex) getRoleRefs(subjectName string) subjectGrants {
 			resourceVersion: resourceVersion,
 			rules:           rules,
 			kind:            roleKind,
+			bindingName:     rb.Name,
+			roleKind:        rb.RoleRef.Kind,
 		}
 	}
 
@@ -0,0 +1,92 @@
+package accesscontrol
+
+import (
+	"context"
+	"sync"
+
+	v1 "github.com/rancher/wrangler/v3/pkg/generated/controllers/rbac/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+const rbacChangesHandler = "accesscontrol-rbac-changes"
+
+// rbacChanges turns the changes of RBAC resources into the index keys of the subjects and roles they affect, see
+// grantsIndexKeys, and passes them to its handlers
+type rbacChanges struct {
+	handlers []func(indexKeys sets.Set[string])
+
+	lock sync.Mutex
+	// bindingSubjects keeps the subjects of each binding, to find the ones affected when it is changed or deleted
+	bindingSubjects map[string]sets.Set[string]
+}
+
+func newRBACChanges(handlers ...func(indexKeys sets.Set[string])) *rbacChanges {
+	return &rbacChanges{
+		handlers:        handlers,
+		bindingSubjects: map[string]sets.Set[string]{},
+	}
+}
+
+// register calls the handlers of r on changes to RBAC resources
+func (r *rbacChanges) register(ctx context.Context, rbac v1.Interface) {
+	rbac.ClusterRoleBinding().OnChange(ctx, rbacChangesHandler, func(key string, crb *rbacv1.ClusterRoleBinding) (*rbacv1.ClusterRoleBinding, error) {
+		var subjects []rbacv1.Subject
+		if crb != nil {
+			subjects = crb.Subjects
+		}
+		r.bindingChanged(clusterRoleBindingKind+"/"+key, subjects)
+		return crb, nil
+	})
+	rbac.RoleBinding().OnChange(ctx, rbacChangesHandler, func(key string, rb *rbacv1.RoleBinding) (*rbacv1.RoleBinding, error) {
+		var subjects []rbacv1.Subject
+		if rb != nil {
+			subjects = rb.Subjects
+		}
+		r.bindingChanged(roleBindingKind+"/"+key, subjects)
+		return rb, nil
+	})
+	rbac.ClusterRole().OnChange(ctx, rbacChangesHandler, func(key string, cr *rbacv1.ClusterRole) (*rbacv1.ClusterRole, error) {
+		r.changed(sets.New(roleIndexKey(clusterRoleKind, "", key)))
+		return cr, nil
+	})
+	rbac.Role().OnChange(ctx, rbacChangesHandler, func(key string, role *rbacv1.Role) (*rbacv1.Role, error) {
+		// namespaced keys are namespace/name, as the index key of roles
+		r.changed(sets.New(roleKind + ":" + key))
+		return role, nil
+	})
+}
+
+func (r *rbacChanges) bindingChanged(key string, subjects []rbacv1.Subject) {
+	indexKeys := subjectKeys(subjects)
+	r.lock.Lock()
+	previous := r.bindingSubjects[key]
+	if indexKeys.Len() == 0 {
+		delete(r.bindingSubjects, key)
+	} else {
+		r.bindingSubjects[key] = indexKeys
+	}
+	r.lock.Unlock()
+	r.changed(indexKeys.Union(previous))
+}
+
+func (r *rbacChanges) changed(indexKeys sets.Set[string]) {
+	if indexKeys.Len() == 0 {
+		return
+	}
+	for _, handler := range r.handlers {
+		handler(indexKeys)
+	}
+}
+
+// subjectKeys returns the index keys of the users and groups bound by subjects, service accounts being users
+func subjectKeys(subjects []rbacv1.Subject) sets.Set[string] {
+	result := sets.New[string]()
+	for _, name := range indexSubjects(userKind, subjects) {
+		result.Insert(subjectIndexKey(userKind, name))
+	}
+	for _, name := range indexSubjects(groupKind, subjects) {
+		result.Insert(subjectIndexKey(groupKind, name))
+	}
+	return result
+}
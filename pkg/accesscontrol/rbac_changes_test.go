@@ -0,0 +1,34 @@
+package accesscontrol
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+func TestRBACChanges_bindingChanged(t *testing.T) {
+	var changes []sets.Set[string]
+	r := newRBACChanges(func(indexKeys sets.Set[string]) {
+		changes = append(changes, indexKeys)
+	})
+
+	r.bindingChanged("RoleBinding/dev/edit", []rbacv1.Subject{
+		{APIGroup: rbacGroup, Kind: userKind, Name: "alice"},
+		{APIGroup: rbacGroup, Kind: groupKind, Name: "devs"},
+		{Kind: svcAccountKind, Namespace: "dev", Name: "deployer"},
+	})
+	r.bindingChanged("RoleBinding/dev/edit", []rbacv1.Subject{
+		{APIGroup: rbacGroup, Kind: userKind, Name: "bob"},
+	})
+	r.bindingChanged("RoleBinding/dev/edit", nil)
+	r.bindingChanged("RoleBinding/dev/other", nil)
+
+	assert.Equal(t, []sets.Set[string]{
+		sets.New("User:alice", "Group:devs", "User:system:serviceaccount:dev:deployer"),
+		sets.New("User:alice", "Group:devs", "User:system:serviceaccount:dev:deployer", "User:bob"),
+		sets.New("User:bob"),
+	}, changes, "subjects removed from a binding are affected, bindings without subjects are ignored")
+	assert.Empty(t, r.bindingSubjects)
+}
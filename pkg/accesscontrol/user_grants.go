@@ -12,6 +12,8 @@ import (
 type userGrants struct {
 	user   subjectGrants
 	groups []subjectGrants
+	// groupNames are the sorted groups of the user, including the resolved ones, in the order of groups
+	groupNames []string
 }
 
 // subjectGrants defines role references granted to a given subject through RoleBindings and ClusterRoleBindings
@@ -24,6 +26,8 @@ type subjectGrants struct {
 type roleRef struct {
 	namespace, roleName, resourceVersion, kind string
 	rules                                      []rbacv1.PolicyRule
+	// bindingName and roleKind identify the binding and the kind of role it references, to explain grants
+	bindingName, roleKind string
 }
 
 // hash calculates a unique identifier from all the grants for a user
@@ -0,0 +1,146 @@
+// Package audit records who listed, read, created, updated, patched or deleted what through /v1, and which
+// subscriptions were watched, as JSON events written to sinks according to a Policy.
+package audit
+
+import (
+	"encoding/json"
+	"net/http"
+	"slices"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/uuid"
+)
+
+// Level is how much of a request is recorded
+type Level string
+
+const (
+	// LevelNone doesn't record the request
+	LevelNone Level = "None"
+	// LevelMetadata records who did what, and the outcome
+	LevelMetadata Level = "Metadata"
+	// LevelRequest also records the request body
+	LevelRequest Level = "Request"
+	// LevelRequestResponse also records the response body
+	LevelRequestResponse Level = "RequestResponse"
+)
+
+var levels = []Level{LevelNone, LevelMetadata, LevelRequest, LevelRequestResponse}
+
+func (l Level) valid() bool {
+	return slices.Contains(levels, l)
+}
+
+// atLeast tells whether l records as much as other
+func (l Level) atLeast(other Level) bool {
+	return slices.Index(levels, l) >= slices.Index(levels, other)
+}
+
+// Outcomes of the audited requests
+const (
+	OutcomeSuccess = "success"
+	// OutcomeDenied is the outcome of requests which failed with 401 or 403
+	OutcomeDenied  = "denied"
+	OutcomeFailure = "failure"
+)
+
+// Verbs which are not derived from the HTTP method
+const (
+	VerbList  = "list"
+	VerbGet   = "get"
+	VerbWatch = "watch"
+)
+
+// Event is an audit record of a request, or of a watch opened by a subscription
+type Event struct {
+	AuditID                  string    `json:"auditID"`
+	Level                    Level     `json:"level"`
+	RequestReceivedTimestamp time.Time `json:"requestReceivedTimestamp"`
+	CompletedTimestamp       time.Time `json:"completedTimestamp"`
+	User                     User      `json:"user"`
+	SourceIP                 string    `json:"sourceIP,omitempty"`
+	UserAgent                string    `json:"userAgent,omitempty"`
+	Verb                     string    `json:"verb"`
+	Method                   string    `json:"method,omitempty"`
+	RequestURI               string    `json:"requestURI"`
+	Schema                   string    `json:"schema,omitempty"`
+	Namespace                string    `json:"namespace,omitempty"`
+	Name                     string    `json:"name,omitempty"`
+	Action                   string    `json:"action,omitempty"`
+	Link                     string    `json:"link,omitempty"`
+	ResponseCode             int       `json:"responseCode,omitempty"`
+	Outcome                  string    `json:"outcome"`
+	Error                    string    `json:"error,omitempty"`
+	// RequestObject and ResponseObject are the bodies as JSON, or as a JSON string if they are not JSON or were
+	// truncated
+	RequestObject           json.RawMessage `json:"requestObject,omitempty"`
+	RequestObjectTruncated  bool            `json:"requestObjectTruncated,omitempty"`
+	ResponseObject          json.RawMessage `json:"responseObject,omitempty"`
+	ResponseObjectTruncated bool            `json:"responseObjectTruncated,omitempty"`
+}
+
+// User is the requester of an Event
+type User struct {
+	Name   string   `json:"name"`
+	Groups []string `json:"groups,omitempty"`
+}
+
+// Sink writes events. Write must not block the request for long nor keep the event, which it doesn't own.
+type Sink interface {
+	Write(event *Event)
+}
+
+// defaultMaxBodyBytes is how much of the request and response bodies is recorded by default
+const defaultMaxBodyBytes = 64 * 1024
+
+// Logger writes the events of the requests selected by its Policy to its sinks. A nil Logger records nothing.
+type Logger struct {
+	policy       Policy
+	maxBodyBytes int
+	sinks        []Sink
+}
+
+// New returns a Logger recording at most maxBodyBytes of the bodies, or 64KiB if it is not positive
+func New(policy Policy, maxBodyBytes int, sinks ...Sink) *Logger {
+	if maxBodyBytes <= 0 {
+		maxBodyBytes = defaultMaxBodyBytes
+	}
+	return &Logger{
+		policy:       policy,
+		maxBodyBytes: maxBodyBytes,
+		sinks:        sinks,
+	}
+}
+
+func (l *Logger) write(event *Event) {
+	if event.AuditID == "" {
+		event.AuditID = string(uuid.NewUUID())
+	}
+	for _, sink := range l.sinks {
+		sink.Write(event)
+	}
+}
+
+// outcome returns the outcome of a request which completed with code
+func outcome(code int) string {
+	switch {
+	case code == http.StatusUnauthorized || code == http.StatusForbidden:
+		return OutcomeDenied
+	case code >= http.StatusBadRequest:
+		return OutcomeFailure
+	default:
+		return OutcomeSuccess
+	}
+}
+
+// body returns data as JSON, quoting it if it is not JSON or was truncated
+func body(data []byte, truncated bool) json.RawMessage {
+	if len(data) == 0 {
+		return nil
+	}
+	if !truncated && json.Valid(data) {
+		return json.RawMessage(data)
+	}
+	quoted, _ := json.Marshal(string(data))
+	return quoted
+}
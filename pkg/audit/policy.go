@@ -0,0 +1,134 @@
+package audit
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"slices"
+
+	"github.com/rancher/steve/pkg/configfile"
+)
+
+// ConfigEnvVar is the path to a YAML or JSON file containing the Config of the audit log
+const ConfigEnvVar = "CATTLE_AUDIT_CONFIG"
+
+// Config configures the audit log
+type Config struct {
+	Policy Policy `json:"policy"`
+	// MaxBodyBytes is how much of the request and response bodies is recorded, 64KiB by default
+	MaxBodyBytes int        `json:"maxBodyBytes,omitempty"`
+	Sinks        SinkConfig `json:"sinks"`
+}
+
+// SinkConfig selects where events are written, at least one sink is required
+type SinkConfig struct {
+	// Stdout writes events as JSON lines to the standard output
+	Stdout  bool               `json:"stdout,omitempty"`
+	File    *FileSinkConfig    `json:"file,omitempty"`
+	Webhook *WebhookSinkConfig `json:"webhook,omitempty"`
+}
+
+// Policy selects the Level of requests. Rules are evaluated in order, the first matching one setting the level.
+type Policy struct {
+	// Level is the level of requests matching no rule, Metadata by default
+	Level Level        `json:"level,omitempty"`
+	Rules []PolicyRule `json:"rules,omitempty"`
+}
+
+// PolicyRule matches requests on all of its non-empty fields, a field matching if any of its values matches
+type PolicyRule struct {
+	Level      Level    `json:"level"`
+	Users      []string `json:"users,omitempty"`
+	Groups     []string `json:"groups,omitempty"`
+	Verbs      []string `json:"verbs,omitempty"`
+	Schemas    []string `json:"schemas,omitempty"`
+	Namespaces []string `json:"namespaces,omitempty"`
+}
+
+// LoadConfig reads the Config of the audit log in a YAML or JSON file, rejecting unknown levels, configs without
+// sinks and sinks without a path or URL
+func LoadConfig(path string) (Config, error) {
+	return configfile.Load(path, "audit config", Config.validate)
+}
+
+func (c Config) validate() error {
+	if err := c.Policy.validate(); err != nil {
+		return err
+	}
+	if !c.Sinks.Stdout && c.Sinks.File == nil && c.Sinks.Webhook == nil {
+		return fmt.Errorf("no sinks configured")
+	}
+	if c.Sinks.File != nil && c.Sinks.File.Path == "" {
+		return fmt.Errorf("file sink: path is required")
+	}
+	if c.Sinks.Webhook != nil && c.Sinks.Webhook.URL == "" {
+		return fmt.Errorf("webhook sink: url is required")
+	}
+	return nil
+}
+
+func (p Policy) validate() error {
+	if p.Level != "" && !p.Level.valid() {
+		return fmt.Errorf("invalid level %q", p.Level)
+	}
+	for i, rule := range p.Rules {
+		if !rule.Level.valid() {
+			return fmt.Errorf("rule %d: invalid level %q", i, rule.Level)
+		}
+	}
+	return nil
+}
+
+// FromEnv returns a Logger configured by the file in ConfigEnvVar, or nil if it is not set. Its sinks run until ctx
+// is done.
+func FromEnv(ctx context.Context) (*Logger, error) {
+	path := os.Getenv(ConfigEnvVar)
+	if path == "" {
+		return nil, nil
+	}
+	config, err := LoadConfig(path)
+	if err != nil {
+		return nil, err
+	}
+	return NewFromConfig(ctx, config), nil
+}
+
+// NewFromConfig returns a Logger writing to the sinks of config, which run until ctx is done
+func NewFromConfig(ctx context.Context, config Config) *Logger {
+	var sinks []Sink
+	if config.Sinks.Stdout {
+		sinks = append(sinks, NewWriterSink(ctx, os.Stdout))
+	}
+	if config.Sinks.File != nil {
+		sinks = append(sinks, NewFileSink(ctx, *config.Sinks.File))
+	}
+	if config.Sinks.Webhook != nil {
+		sinks = append(sinks, NewWebhookSink(ctx, *config.Sinks.Webhook))
+	}
+	return New(config.Policy, config.MaxBodyBytes, sinks...)
+}
+
+// levelFor returns the level of the first rule matching event, or the policy's level
+func (p Policy) levelFor(event *Event) Level {
+	for _, rule := range p.Rules {
+		if rule.matches(event) {
+			return rule.Level
+		}
+	}
+	if p.Level == "" {
+		return LevelMetadata
+	}
+	return p.Level
+}
+
+func (r PolicyRule) matches(event *Event) bool {
+	return matchesAny(r.Users, event.User.Name) &&
+		(len(r.Groups) == 0 || slices.ContainsFunc(event.User.Groups, func(group string) bool { return slices.Contains(r.Groups, group) })) &&
+		matchesAny(r.Verbs, event.Verb) &&
+		matchesAny(r.Schemas, event.Schema) &&
+		matchesAny(r.Namespaces, event.Namespace)
+}
+
+func matchesAny(values []string, value string) bool {
+	return len(values) == 0 || slices.Contains(values, value)
+}
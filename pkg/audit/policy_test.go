@@ -0,0 +1,135 @@
+package audit
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPolicyLevelFor(t *testing.T) {
+	policy := Policy{
+		Level: LevelRequest,
+		Rules: []PolicyRule{
+			{Level: LevelNone, Verbs: []string{VerbWatch}},
+			{Level: LevelRequestResponse, Groups: []string{"auditors"}, Schemas: []string{"secret"}},
+			{Level: LevelMetadata, Users: []string{"system:admin"}, Namespaces: []string{"kube-system", ""}},
+		},
+	}
+	tests := []struct {
+		name  string
+		event Event
+		want  Level
+	}{
+		{
+			name:  "first matching rule wins",
+			event: Event{User: User{Name: "alice", Groups: []string{"auditors"}}, Verb: VerbWatch, Schema: "secret"},
+			want:  LevelNone,
+		},
+		{
+			name:  "any group matches",
+			event: Event{User: User{Name: "alice", Groups: []string{"devs", "auditors"}}, Verb: VerbGet, Schema: "secret"},
+			want:  LevelRequestResponse,
+		},
+		{
+			name:  "all fields must match",
+			event: Event{User: User{Name: "alice", Groups: []string{"auditors"}}, Verb: VerbGet, Schema: "configmap"},
+			want:  LevelRequest,
+		},
+		{
+			name:  "cluster scoped namespace",
+			event: Event{User: User{Name: "system:admin"}, Verb: "delete", Schema: "namespace"},
+			want:  LevelMetadata,
+		},
+		{
+			name:  "no rule matches",
+			event: Event{User: User{Name: "system:admin"}, Verb: "delete", Schema: "pod", Namespace: "default"},
+			want:  LevelRequest,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.want, policy.levelFor(&test.event))
+		})
+	}
+
+	assert.Equal(t, LevelMetadata, Policy{}.levelFor(&Event{}), "Metadata is the default level")
+}
+
+func TestLoadConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr string
+	}{
+		{
+			name: "valid",
+			content: `
+policy:
+  level: None
+  rules:
+  - level: RequestResponse
+    schemas: [secret]
+maxBodyBytes: 1024
+sinks:
+  stdout: true
+  file:
+    path: /var/log/steve/audit.log
+    maxSizeMB: 10
+  webhook:
+    url: https://audit.example.com
+`,
+		},
+		{
+			name:    "invalid level",
+			content: "policy:\n  rules:\n  - level: Everything\nsinks:\n  stdout: true\n",
+			wantErr: `rule 0: invalid level "Everything"`,
+		},
+		{
+			name:    "no sinks",
+			content: "policy:\n  level: Metadata\n",
+			wantErr: "no sinks configured",
+		},
+		{
+			name:    "file without path",
+			content: "sinks:\n  file:\n    maxSizeMB: 10\n",
+			wantErr: "path is required",
+		},
+		{
+			name:    "unknown field",
+			content: "sinks:\n  stdout: true\n  syslog: true\n",
+			wantErr: "unknown field",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "audit.yaml")
+			require.NoError(t, os.WriteFile(path, []byte(test.content), 0o600))
+			config, err := LoadConfig(path)
+			if test.wantErr != "" {
+				assert.ErrorContains(t, err, test.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, LevelNone, config.Policy.Level)
+			assert.Equal(t, 1024, config.MaxBodyBytes)
+			assert.Equal(t, "/var/log/steve/audit.log", config.Sinks.File.Path)
+		})
+	}
+}
+
+func TestFromEnv(t *testing.T) {
+	t.Setenv(ConfigEnvVar, "")
+	logger, err := FromEnv(t.Context())
+	require.NoError(t, err)
+	assert.Nil(t, logger)
+
+	path := filepath.Join(t.TempDir(), "audit.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("sinks:\n  file:\n    path: "+filepath.Join(t.TempDir(), "audit.log")+"\n"), 0o600))
+	t.Setenv(ConfigEnvVar, path)
+	logger, err = FromEnv(t.Context())
+	require.NoError(t, err)
+	assert.Len(t, logger.sinks, 1)
+}
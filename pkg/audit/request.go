@@ -0,0 +1,316 @@
+package audit
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/rancher/apiserver/pkg/parse"
+	"github.com/rancher/apiserver/pkg/types"
+	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/apiserver/pkg/endpoints/request"
+)
+
+// Request records a /v1 request while it is handled. The schema, namespace and name of a request are only known once
+// its URL is parsed, which is when its level is decided by the Parser of the Logger. Bodies are only recorded at the
+// levels which include them.
+type Request struct {
+	logger   *Logger
+	apiOp    *types.APIRequest
+	received time.Time
+	// event is set once the level is decided
+	event    *Event
+	body     *capturingBody
+	response *responseRecorder
+}
+
+type requestKey struct{}
+
+// StartRequest starts recording apiOp, replacing its response writer to record the response code. Finish must be
+// called once apiOp was handled. It returns nil if the Logger is nil.
+func (l *Logger) StartRequest(apiOp *types.APIRequest) *Request {
+	if l == nil {
+		return nil
+	}
+	r := &Request{
+		logger:   l,
+		apiOp:    apiOp,
+		received: time.Now(),
+		response: &responseRecorder{ResponseWriter: apiOp.Response},
+	}
+	if rejected, ok := apiOp.Request.Context().Value(rejectionKey{}).(*rejection); ok {
+		rejected.started = true
+	}
+	apiOp.Request = apiOp.Request.WithContext(context.WithValue(apiOp.Request.Context(), requestKey{}, r))
+	apiOp.Response = r.response
+	return r
+}
+
+// rejection follows a request through Middleware, which records it unless it reached StartRequest
+type rejection struct {
+	user    user.Info
+	started bool
+}
+
+type rejectionKey struct{}
+
+// Middleware records the requests rejected before they reach StartRequest, and so are never parsed, such as those
+// failing authentication or impersonation with a 401 or 403, or exceeding rate limits with a 429. Authenticated must be
+// chained after the authentication middleware for their user to be recorded. It returns next itself if the Logger is
+// nil.
+func (l *Logger) Middleware(next http.Handler) http.Handler {
+	if l == nil {
+		return next
+	}
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		received := time.Now()
+		rejected := &rejection{}
+		response := &responseRecorder{ResponseWriter: rw}
+		next.ServeHTTP(response, req.WithContext(context.WithValue(req.Context(), rejectionKey{}, rejected)))
+
+		code := response.statusCode()
+		if rejected.started || code < http.StatusBadRequest {
+			return
+		}
+		event := &Event{
+			RequestReceivedTimestamp: received,
+			CompletedTimestamp:       time.Now(),
+			SourceIP:                 sourceIP(req),
+			UserAgent:                req.UserAgent(),
+			Verb:                     methodVerb(req.Method),
+			Method:                   req.Method,
+			RequestURI:               req.RequestURI,
+			ResponseCode:             code,
+			Outcome:                  outcome(code),
+		}
+		if rejected.user != nil {
+			event.User = User{Name: rejected.user.GetName(), Groups: rejected.user.GetGroups()}
+		}
+		// bodies are not captured before requests are parsed
+		if event.Level = l.policy.levelFor(event); event.Level.atLeast(LevelRequest) {
+			event.Level = LevelMetadata
+		}
+		if event.Level != LevelNone {
+			l.write(event)
+		}
+	})
+}
+
+// Authenticated records the user of the requests followed by Middleware, and must be chained after the
+// authentication middleware. It returns next itself if the Logger is nil.
+func (l *Logger) Authenticated(next http.Handler) http.Handler {
+	if l == nil {
+		return next
+	}
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if rejected, ok := req.Context().Value(rejectionKey{}).(*rejection); ok {
+			rejected.user, _ = request.UserFrom(req.Context())
+		}
+		next.ServeHTTP(rw, req)
+	})
+}
+
+// Parser returns next, deciding the level of the requests started by the Logger once next parsed their URL. It
+// returns next itself if the Logger is nil.
+func (l *Logger) Parser(next parse.Parser) parse.Parser {
+	if l == nil {
+		return next
+	}
+	return func(apiOp *types.APIRequest, urlParser parse.URLParser) error {
+		err := next(apiOp, urlParser)
+		if r, ok := apiOp.Request.Context().Value(requestKey{}).(*Request); ok && r.logger == l {
+			r.decideLevel()
+		}
+		return err
+	}
+}
+
+// decideLevel selects the level of the request with the policy, and starts recording the bodies it includes
+func (r *Request) decideLevel() {
+	if r.event != nil {
+		return
+	}
+	apiOp := r.apiOp
+	r.event = &Event{
+		RequestReceivedTimestamp: r.received,
+		SourceIP:                 sourceIP(apiOp.Request),
+		UserAgent:                apiOp.Request.UserAgent(),
+		Verb:                     verb(apiOp),
+		Method:                   apiOp.Request.Method,
+		RequestURI:               apiOp.Request.RequestURI,
+		Schema:                   apiOp.Type,
+		Namespace:                apiOp.Namespace,
+		Name:                     apiOp.Name,
+		Action:                   apiOp.Action,
+		Link:                     apiOp.Link,
+	}
+	if u, ok := request.UserFrom(apiOp.Context()); ok {
+		r.event.User = User{Name: u.GetName(), Groups: u.GetGroups()}
+	}
+	r.event.Level = r.logger.policy.levelFor(r.event)
+
+	if r.event.Level.atLeast(LevelRequest) && apiOp.Request.Body != nil && apiOp.Request.Body != http.NoBody {
+		r.body = &capturingBody{ReadCloser: apiOp.Request.Body, buffer: limitedBuffer{max: r.logger.maxBodyBytes}}
+		apiOp.Request.Body = r.body
+	}
+	if r.event.Level.atLeast(LevelRequestResponse) {
+		r.response.buffer = &limitedBuffer{max: r.logger.maxBodyBytes}
+	}
+}
+
+// Finish writes the event of the request if the policy selects it
+func (r *Request) Finish() {
+	if r == nil {
+		return
+	}
+	// requests whose URL could not be parsed are recorded with what is known of them
+	r.decideLevel()
+	event := r.event
+	if event.Level == LevelNone {
+		return
+	}
+	event.CompletedTimestamp = time.Now()
+	event.ResponseCode = r.response.statusCode()
+	event.Outcome = outcome(event.ResponseCode)
+	if r.body != nil {
+		event.RequestObject = body(r.body.buffer.data, r.body.buffer.truncated)
+		event.RequestObjectTruncated = r.body.buffer.truncated
+	}
+	if buffer := r.response.buffer; buffer != nil {
+		event.ResponseObject = body(buffer.data, buffer.truncated)
+		event.ResponseObjectTruncated = buffer.truncated
+	}
+	r.logger.write(event)
+}
+
+// methodVerb returns the Kubernetes-like verb of a request which was not parsed, where lists can't be told apart from
+// gets
+func methodVerb(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead:
+		return VerbGet
+	case http.MethodPost:
+		return "create"
+	case http.MethodPut:
+		return "update"
+	default:
+		return strings.ToLower(method)
+	}
+}
+
+// verb returns the Kubernetes-like verb of a parsed request
+func verb(apiOp *types.APIRequest) string {
+	if apiOp.Type == "subscribe" {
+		return VerbWatch
+	}
+	switch apiOp.Method {
+	case http.MethodGet, http.MethodHead:
+		if apiOp.Name == "" {
+			return VerbList
+		}
+		return VerbGet
+	case http.MethodPost:
+		return "create"
+	case http.MethodPut:
+		return "update"
+	case "":
+		return strings.ToLower(apiOp.Request.Method)
+	default:
+		return strings.ToLower(apiOp.Method)
+	}
+}
+
+func sourceIP(req *http.Request) string {
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		return req.RemoteAddr
+	}
+	return host
+}
+
+// limitedBuffer keeps the first max bytes written to it
+type limitedBuffer struct {
+	max       int
+	data      []byte
+	truncated bool
+}
+
+func (b *limitedBuffer) write(p []byte) {
+	if room := b.max - len(b.data); len(p) > room {
+		p = p[:room]
+		b.truncated = true
+	}
+	b.data = append(b.data, p...)
+}
+
+// capturingBody records the request body as it is read by the handler
+type capturingBody struct {
+	io.ReadCloser
+	buffer limitedBuffer
+}
+
+func (c *capturingBody) Read(p []byte) (int, error) {
+	n, err := c.ReadCloser.Read(p)
+	c.buffer.write(p[:n])
+	return n, err
+}
+
+// responseRecorder records the status code of the response, and its body if buffer is set. It can be hijacked for
+// websockets.
+type responseRecorder struct {
+	http.ResponseWriter
+	code   int
+	buffer *limitedBuffer
+}
+
+func (r *responseRecorder) WriteHeader(code int) {
+	if r.code == 0 {
+		r.code = code
+	}
+	r.ResponseWriter.WriteHeader(code)
+}
+
+func (r *responseRecorder) Write(p []byte) (int, error) {
+	if r.code == 0 {
+		r.code = http.StatusOK
+	}
+	if r.buffer != nil {
+		r.buffer.write(p)
+	}
+	return r.ResponseWriter.Write(p)
+}
+
+func (r *responseRecorder) statusCode() int {
+	if r.code == 0 {
+		return http.StatusOK
+	}
+	return r.code
+}
+
+func (r *responseRecorder) Flush() {
+	if flusher, ok := r.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (r *responseRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := r.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("response writer does not support hijacking")
+	}
+	conn, rw, err := hijacker.Hijack()
+	if err == nil && r.code == 0 {
+		r.code = http.StatusSwitchingProtocols
+	}
+	return conn, rw, err
+}
+
+// Unwrap allows http.ResponseController to reach the underlying writer
+func (r *responseRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
@@ -0,0 +1,242 @@
+package audit
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/rancher/apiserver/pkg/parse"
+	"github.com/rancher/apiserver/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/apiserver/pkg/endpoints/request"
+)
+
+type fakeSink struct {
+	lock   sync.Mutex
+	events []*Event
+}
+
+func (f *fakeSink) Write(event *Event) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.events = append(f.events, event)
+}
+
+func newAPIRequest(method, target, body string) (*types.APIRequest, *httptest.ResponseRecorder) {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	req = req.WithContext(request.WithUser(req.Context(), &user.DefaultInfo{Name: "alice", Groups: []string{"devs"}}))
+	rw := httptest.NewRecorder()
+	return &types.APIRequest{Request: req, Response: rw}, rw
+}
+
+func TestRequest(t *testing.T) {
+	tests := []struct {
+		name         string
+		level        Level
+		method       string
+		body         string
+		apiName      string
+		code         int
+		response     string
+		maxBodyBytes int
+		wantVerb     string
+		wantOutcome  string
+		wantRequest  string
+		wantResponse string
+		wantEvent    bool
+	}{
+		{
+			name:        "list at metadata level",
+			level:       LevelMetadata,
+			method:      http.MethodGet,
+			code:        http.StatusOK,
+			response:    `{"data":[]}`,
+			wantVerb:    VerbList,
+			wantOutcome: OutcomeSuccess,
+			wantEvent:   true,
+		},
+		{
+			name:        "denied get",
+			level:       LevelRequestResponse,
+			method:      http.MethodGet,
+			apiName:     "cm1",
+			code:        http.StatusForbidden,
+			response:    `{"code":"PermissionDenied"}`,
+			wantVerb:    VerbGet,
+			wantOutcome: OutcomeDenied,
+			// the response is not JSON once truncated
+			maxBodyBytes: 8,
+			wantResponse: `"{\"code\":"`,
+			wantEvent:    true,
+		},
+		{
+			name:        "create at request level",
+			level:       LevelRequest,
+			method:      http.MethodPost,
+			body:        `{"metadata":{"name":"cm1"}}`,
+			code:        http.StatusCreated,
+			response:    `{"id":"default/cm1"}`,
+			wantVerb:    "create",
+			wantOutcome: OutcomeSuccess,
+			wantRequest: `{"metadata":{"name":"cm1"}}`,
+			wantEvent:   true,
+		},
+		{
+			name:         "failed patch with everything",
+			level:        LevelRequestResponse,
+			method:       http.MethodPatch,
+			apiName:      "cm1",
+			body:         `not json`,
+			code:         http.StatusUnprocessableEntity,
+			response:     `{"code":"InvalidBodyContent"}`,
+			wantVerb:     "patch",
+			wantOutcome:  OutcomeFailure,
+			wantRequest:  `"not json"`,
+			wantResponse: `{"code":"InvalidBodyContent"}`,
+			wantEvent:    true,
+		},
+		{
+			name:    "ignored delete",
+			level:   LevelNone,
+			method:  http.MethodDelete,
+			apiName: "cm1",
+			code:    http.StatusNoContent,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			sink := &fakeSink{}
+			logger := New(Policy{Level: test.level}, test.maxBodyBytes, sink)
+			apiOp, rw := newAPIRequest(test.method, "/v1/configmaps/default", test.body)
+
+			auditRequest := logger.StartRequest(apiOp)
+			parser := logger.Parser(func(apiOp *types.APIRequest, _ parse.URLParser) error {
+				// as done by the apiserver when parsing the URL
+				apiOp.Type, apiOp.Namespace, apiOp.Name, apiOp.Method = "configmap", "default", test.apiName, test.method
+				return nil
+			})
+			require.NoError(t, parser(apiOp, nil))
+			_, capturingRequest := apiOp.Request.Body.(*capturingBody)
+			assert.Equal(t, test.level.atLeast(LevelRequest), capturingRequest, "the request body is only captured when recorded")
+			assert.Equal(t, test.level.atLeast(LevelRequestResponse), auditRequest.response.buffer != nil, "the response body is only captured when recorded")
+			if apiOp.Request.Body != nil {
+				_, err := io.ReadAll(apiOp.Request.Body)
+				require.NoError(t, err)
+			}
+			apiOp.Response.WriteHeader(test.code)
+			if test.response != "" {
+				_, err := apiOp.Response.Write([]byte(test.response))
+				require.NoError(t, err)
+			}
+			auditRequest.Finish()
+
+			assert.Equal(t, test.code, rw.Code)
+			assert.Equal(t, test.response, rw.Body.String())
+			if !test.wantEvent {
+				assert.Empty(t, sink.events)
+				return
+			}
+			require.Len(t, sink.events, 1)
+			event := sink.events[0]
+			assert.NotEmpty(t, event.AuditID)
+			assert.Equal(t, test.level, event.Level)
+			assert.Equal(t, User{Name: "alice", Groups: []string{"devs"}}, event.User)
+			assert.Equal(t, test.wantVerb, event.Verb)
+			assert.Equal(t, "/v1/configmaps/default", event.RequestURI)
+			assert.Equal(t, "configmap", event.Schema)
+			assert.Equal(t, "default", event.Namespace)
+			assert.Equal(t, test.apiName, event.Name)
+			assert.Equal(t, test.code, event.ResponseCode)
+			assert.Equal(t, test.wantOutcome, event.Outcome)
+			assert.Equal(t, test.wantRequest, string(event.RequestObject))
+			assert.Equal(t, test.wantResponse, string(event.ResponseObject))
+			assert.Equal(t, test.maxBodyBytes > 0, event.ResponseObjectTruncated)
+
+			_, err := json.Marshal(event)
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestRequestSubscribe(t *testing.T) {
+	sink := &fakeSink{}
+	logger := New(Policy{}, 0, sink)
+	apiOp, _ := newAPIRequest(http.MethodGet, "/v1/subscribe", "")
+
+	auditRequest := logger.StartRequest(apiOp)
+	parser := logger.Parser(func(apiOp *types.APIRequest, _ parse.URLParser) error {
+		apiOp.Type, apiOp.Method = "subscribe", http.MethodGet
+		return nil
+	})
+	require.NoError(t, parser(apiOp, nil))
+	// httptest.ResponseRecorder can't be hijacked
+	_, _, err := apiOp.Response.(http.Hijacker).Hijack()
+	assert.Error(t, err)
+	auditRequest.Finish()
+
+	require.Len(t, sink.events, 1)
+	assert.Equal(t, VerbWatch, sink.events[0].Verb)
+}
+
+func TestNilLogger(t *testing.T) {
+	var logger *Logger
+	apiOp, rw := newAPIRequest(http.MethodGet, "/v1/configmaps", "")
+	auditRequest := logger.StartRequest(apiOp)
+	assert.Nil(t, auditRequest)
+	assert.Same(t, rw, apiOp.Response)
+	auditRequest.Finish()
+}
+
+func TestMiddleware(t *testing.T) {
+	sink := &fakeSink{}
+	logger := New(Policy{}, 0, sink)
+	authenticate := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			if req.Header.Get("Authorization") == "" {
+				http.Error(rw, "unauthorized", http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(rw, req.WithContext(request.WithUser(req.Context(), &user.DefaultInfo{Name: "alice", Groups: []string{"devs"}})))
+		})
+	}
+	limited := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if req.URL.Query().Has("limited") {
+			http.Error(rw, "too many requests", http.StatusTooManyRequests)
+			return
+		}
+		apiOp := &types.APIRequest{Request: req, Response: rw}
+		auditRequest := logger.StartRequest(apiOp)
+		apiOp.Response.WriteHeader(http.StatusNotFound)
+		auditRequest.Finish()
+	})
+	handler := logger.Middleware(authenticate(logger.Authenticated(limited)))
+	serve := func(target string, authenticated bool) {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		if authenticated {
+			req.Header.Set("Authorization", "Bearer token")
+		}
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	serve("/v1/secrets", false)
+	require.Len(t, sink.events, 1)
+	assert.Equal(t, http.StatusUnauthorized, sink.events[0].ResponseCode)
+	assert.Equal(t, OutcomeDenied, sink.events[0].Outcome)
+	assert.Equal(t, VerbGet, sink.events[0].Verb)
+	assert.Empty(t, sink.events[0].User)
+
+	serve("/v1/secrets?limited", true)
+	require.Len(t, sink.events, 2)
+	assert.Equal(t, http.StatusTooManyRequests, sink.events[1].ResponseCode)
+	assert.Equal(t, User{Name: "alice", Groups: []string{"devs"}}, sink.events[1].User)
+
+	serve("/v1/secrets", true)
+	require.Len(t, sink.events, 3, "requests reaching StartRequest are only recorded once")
+	assert.Equal(t, http.StatusNotFound, sink.events[2].ResponseCode)
+}
@@ -0,0 +1,276 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/rancher/steve/pkg/metrics"
+	"github.com/sirupsen/logrus"
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+// FileSinkConfig configures a local file which is rotated once it reaches MaxSizeMB
+type FileSinkConfig struct {
+	Path string `json:"path"`
+	// MaxSizeMB defaults to 100
+	MaxSizeMB int `json:"maxSizeMB,omitempty"`
+	// MaxBackups and MaxAgeDays limit the rotated files which are kept, all of them being kept by default
+	MaxBackups int  `json:"maxBackups,omitempty"`
+	MaxAgeDays int  `json:"maxAgeDays,omitempty"`
+	Compress   bool `json:"compress,omitempty"`
+	// BufferSize is how many events wait to be written before new ones are dropped, 10000 by default
+	BufferSize int `json:"bufferSize,omitempty"`
+}
+
+// WebhookSinkConfig configures an HTTP endpoint which receives batches of events as an EventList in POST requests
+type WebhookSinkConfig struct {
+	URL     string            `json:"url"`
+	Headers map[string]string `json:"headers,omitempty"`
+	// TimeoutSeconds defaults to 10
+	TimeoutSeconds int `json:"timeoutSeconds,omitempty"`
+	// BufferSize is how many events wait to be sent before new ones are dropped, 10000 by default
+	BufferSize int `json:"bufferSize,omitempty"`
+	// BatchSize is the maximum number of events per request, 100 by default
+	BatchSize int `json:"batchSize,omitempty"`
+	// MaxRetries is how many times a failed batch is sent again before it is dropped, 3 by default
+	MaxRetries int `json:"maxRetries,omitempty"`
+}
+
+// EventList is the body of the requests of the webhook sink
+type EventList struct {
+	Items []*Event `json:"items"`
+}
+
+const (
+	defaultWriterBufferSize  = 10000
+	defaultWebhookTimeout    = 10 * time.Second
+	defaultWebhookBufferSize = 10000
+	defaultWebhookBatchSize  = 100
+	defaultWebhookMaxRetries = 3
+	// webhookBatchDelay is how long the webhook sink waits for more events before sending a partial batch
+	webhookBatchDelay = time.Second
+	// webhookRetryDelay is how long the webhook sink waits before sending a failed batch again, doubled after each
+	// failure up to maxWebhookRetryDelay
+	webhookRetryDelay    = time.Second
+	maxWebhookRetryDelay = 30 * time.Second
+)
+
+// writerSink buffers events and writes them as JSON lines from a single goroutine, so that requests are not held up
+// by slow writes. Events are dropped when the buffer is full.
+type writerSink struct {
+	name   string
+	writer io.Writer
+	events chan []byte
+}
+
+// NewWriterSink returns a Sink writing events as JSON lines to w until ctx is done
+func NewWriterSink(ctx context.Context, w io.Writer) Sink {
+	return newWriterSink(ctx, "writer", w, defaultWriterBufferSize, nil)
+}
+
+// NewFileSink returns a Sink writing events as JSON lines to a rotated file, which is closed once ctx is done
+func NewFileSink(ctx context.Context, config FileSinkConfig) Sink {
+	file := &lumberjack.Logger{
+		Filename:   config.Path,
+		MaxSize:    config.MaxSizeMB,
+		MaxBackups: config.MaxBackups,
+		MaxAge:     config.MaxAgeDays,
+		Compress:   config.Compress,
+	}
+	if config.BufferSize <= 0 {
+		config.BufferSize = defaultWriterBufferSize
+	}
+	return newWriterSink(ctx, "file", file, config.BufferSize, func() {
+		if err := file.Close(); err != nil {
+			logrus.Errorf("closing audit log %s: %v", config.Path, err)
+		}
+	})
+}
+
+// newWriterSink starts writing to w, calling done once ctx is done and the buffered events are written
+func newWriterSink(ctx context.Context, name string, w io.Writer, bufferSize int, done func()) *writerSink {
+	s := &writerSink{
+		name:   name,
+		writer: w,
+		events: make(chan []byte, bufferSize),
+	}
+	go func() {
+		s.run(ctx)
+		if done != nil {
+			done()
+		}
+	}()
+	return s
+}
+
+func (s *writerSink) Write(event *Event) {
+	// events are encoded right away, as they are not owned by the sink
+	data, err := json.Marshal(event)
+	if err != nil {
+		logrus.Errorf("encoding audit event: %v", err)
+		metrics.IncAuditEventsDropped(s.name, 1)
+		return
+	}
+	select {
+	case s.events <- append(data, '\n'):
+	default:
+		metrics.IncAuditEventsDropped(s.name, 1)
+	}
+}
+
+func (s *writerSink) run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			// write what was buffered before stopping
+			for {
+				select {
+				case data := <-s.events:
+					s.write(data)
+				default:
+					return
+				}
+			}
+		case data := <-s.events:
+			s.write(data)
+		}
+	}
+}
+
+func (s *writerSink) write(data []byte) {
+	if _, err := s.writer.Write(data); err != nil {
+		logrus.Errorf("writing audit event: %v", err)
+		metrics.IncAuditEventsDropped(s.name, 1)
+	}
+}
+
+// webhookSink buffers events and sends them in batches from a single goroutine, so that requests are not held up
+// by the webhook. Failed batches are retried with an exponential backoff, while new events keep being buffered.
+// Events are dropped when the buffer is full or the retries of their batch are exhausted.
+type webhookSink struct {
+	config     WebhookSinkConfig
+	client     *http.Client
+	events     chan []byte
+	retryDelay time.Duration
+}
+
+// NewWebhookSink returns a Sink sending batches of events to a webhook until ctx is done
+func NewWebhookSink(ctx context.Context, config WebhookSinkConfig) Sink {
+	return newWebhookSink(ctx, config, webhookRetryDelay)
+}
+
+func newWebhookSink(ctx context.Context, config WebhookSinkConfig, retryDelay time.Duration) *webhookSink {
+	timeout := defaultWebhookTimeout
+	if config.TimeoutSeconds > 0 {
+		timeout = time.Duration(config.TimeoutSeconds) * time.Second
+	}
+	if config.BufferSize <= 0 {
+		config.BufferSize = defaultWebhookBufferSize
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = defaultWebhookBatchSize
+	}
+	if config.MaxRetries <= 0 {
+		config.MaxRetries = defaultWebhookMaxRetries
+	}
+	s := &webhookSink{
+		config:     config,
+		client:     &http.Client{Timeout: timeout},
+		events:     make(chan []byte, config.BufferSize),
+		retryDelay: retryDelay,
+	}
+	go s.run(ctx)
+	return s
+}
+
+func (s *webhookSink) Write(event *Event) {
+	// events are encoded right away, as they are not owned by the sink
+	data, err := json.Marshal(event)
+	if err != nil {
+		logrus.Errorf("encoding audit event: %v", err)
+		metrics.IncAuditEventsDropped("webhook", 1)
+		return
+	}
+	select {
+	case s.events <- data:
+	default:
+		metrics.IncAuditEventsDropped("webhook", 1)
+	}
+}
+
+func (s *webhookSink) run(ctx context.Context) {
+	var batch []json.RawMessage
+	timer := time.NewTimer(webhookBatchDelay)
+	timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case data := <-s.events:
+			if len(batch) == 0 {
+				timer.Reset(webhookBatchDelay)
+			}
+			batch = append(batch, data)
+			if len(batch) < s.config.BatchSize {
+				continue
+			}
+			timer.Stop()
+		case <-timer.C:
+		}
+		if err := s.sendWithRetries(ctx, batch); err != nil {
+			logrus.Errorf("sending %d audit events to the webhook: %v", len(batch), err)
+			metrics.IncAuditEventsDropped("webhook", len(batch))
+		}
+		batch = nil
+	}
+}
+
+// sendWithRetries sends batch, retrying up to MaxRetries times with an exponential backoff until ctx is done
+func (s *webhookSink) sendWithRetries(ctx context.Context, batch []json.RawMessage) error {
+	delay := s.retryDelay
+	for retries := 0; ; retries++ {
+		err := s.send(ctx, batch)
+		if err == nil || retries >= s.config.MaxRetries {
+			return err
+		}
+		logrus.Debugf("sending %d audit events to the webhook, retrying in %s: %v", len(batch), delay, err)
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(delay):
+		}
+		delay = min(2*delay, maxWebhookRetryDelay)
+	}
+}
+
+func (s *webhookSink) send(ctx context.Context, batch []json.RawMessage) error {
+	data, err := json.Marshal(struct {
+		Items []json.RawMessage `json:"items"`
+	}{Items: batch})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.config.URL, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range s.config.Headers {
+		req.Header.Set(key, value)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode >= http.StatusBadRequest {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,153 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// lockedBuffer is a bytes.Buffer which can be read while a sink writes to it
+type lockedBuffer struct {
+	lock   sync.Mutex
+	buffer bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.buffer.Write(p)
+}
+
+func (b *lockedBuffer) String() string {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.buffer.String()
+}
+
+func TestWriterSink(t *testing.T) {
+	var buffer lockedBuffer
+	sink := NewWriterSink(t.Context(), &buffer)
+	sink.Write(&Event{AuditID: "1", Verb: VerbList})
+	sink.Write(&Event{AuditID: "2", Verb: VerbGet})
+
+	var lines []string
+	require.Eventually(t, func() bool {
+		lines = strings.Split(strings.TrimSpace(buffer.String()), "\n")
+		return len(lines) == 2
+	}, time.Second, 10*time.Millisecond)
+	var event Event
+	require.NoError(t, json.Unmarshal([]byte(lines[1]), &event))
+	assert.Equal(t, "2", event.AuditID)
+	assert.Equal(t, VerbGet, event.Verb)
+}
+
+func TestWriterSinkDropsWhenFull(t *testing.T) {
+	s := &writerSink{name: "writer", events: make(chan []byte, 1)}
+	s.Write(&Event{AuditID: "1"})
+	s.Write(&Event{AuditID: "2"})
+	assert.Len(t, s.events, 1)
+}
+
+func TestFileSink(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	ctx, cancel := context.WithCancel(t.Context())
+	sink := NewFileSink(ctx, FileSinkConfig{Path: path})
+	sink.Write(&Event{AuditID: "1"})
+	// buffered events are written before the file is closed
+	cancel()
+
+	assert.Eventually(t, func() bool {
+		content, err := os.ReadFile(path)
+		return err == nil && strings.Contains(string(content), `"auditID":"1"`)
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestWebhookSink(t *testing.T) {
+	received := make(chan EventList, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, "Bearer token", req.Header.Get("Authorization"))
+		var list EventList
+		assert.NoError(t, json.NewDecoder(req.Body).Decode(&list))
+		received <- list
+	}))
+	defer server.Close()
+
+	sink := NewWebhookSink(t.Context(), WebhookSinkConfig{
+		URL:       server.URL,
+		Headers:   map[string]string{"Authorization": "Bearer token"},
+		BatchSize: 2,
+	})
+	sink.Write(&Event{AuditID: "1"})
+	sink.Write(&Event{AuditID: "2"})
+	sink.Write(&Event{AuditID: "3"})
+
+	select {
+	case list := <-received:
+		require.Len(t, list.Items, 2, "full batches are sent right away")
+		assert.Equal(t, "1", list.Items[0].AuditID)
+	case <-time.After(webhookBatchDelay / 2):
+		t.Fatal("full batch was not sent")
+	}
+	select {
+	case list := <-received:
+		require.Len(t, list.Items, 1, "partial batches are sent after a delay")
+		assert.Equal(t, "3", list.Items[0].AuditID)
+	case <-time.After(2 * webhookBatchDelay):
+		t.Fatal("partial batch was not sent")
+	}
+}
+
+func TestWebhookSinkDropsWhenFull(t *testing.T) {
+	s := &webhookSink{events: make(chan []byte, 1)}
+	s.Write(&Event{AuditID: "1"})
+	s.Write(&Event{AuditID: "2"})
+	assert.Len(t, s.events, 1)
+}
+
+func TestWebhookSinkRetries(t *testing.T) {
+	var attempts atomic.Int32
+	received := make(chan EventList, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		// the first batch fails twice, the second one always does
+		var list EventList
+		assert.NoError(t, json.NewDecoder(req.Body).Decode(&list))
+		if n := attempts.Add(1); n <= 2 || list.Items[0].AuditID == "2" {
+			rw.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		received <- list
+	}))
+	defer server.Close()
+
+	sink := newWebhookSink(t.Context(), WebhookSinkConfig{URL: server.URL, BatchSize: 1, MaxRetries: 2}, time.Millisecond)
+	sink.Write(&Event{AuditID: "1"})
+	select {
+	case list := <-received:
+		assert.Equal(t, "1", list.Items[0].AuditID)
+	case <-time.After(webhookBatchDelay):
+		t.Fatal("batch was not retried")
+	}
+	assert.EqualValues(t, 3, attempts.Load())
+
+	sink.Write(&Event{AuditID: "2"})
+	sink.Write(&Event{AuditID: "3"})
+	select {
+	case list := <-received:
+		assert.Equal(t, "3", list.Items[0].AuditID, "batches are dropped once their retries are exhausted")
+	case <-time.After(webhookBatchDelay):
+		t.Fatal("batch was not sent")
+	}
+	assert.EqualValues(t, 7, attempts.Load())
+}
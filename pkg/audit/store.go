@@ -0,0 +1,57 @@
+package audit
+
+import (
+	"time"
+
+	"github.com/rancher/apiserver/pkg/types"
+	"k8s.io/apiserver/pkg/endpoints/request"
+)
+
+// Store records the watches opened on the wrapped store, which are the resource subscriptions of /v1/subscribe
+type Store struct {
+	types.Store
+	logger *Logger
+}
+
+// NewStore returns store recording its watches with logger, or store itself if logger is nil
+func NewStore(store types.Store, logger *Logger) types.Store {
+	if logger == nil || store == nil {
+		return store
+	}
+	return &Store{
+		Store:  store,
+		logger: logger,
+	}
+}
+
+// Watch opens a watch and records it
+func (s *Store) Watch(apiOp *types.APIRequest, schema *types.APISchema, wr types.WatchRequest) (chan types.APIEvent, error) {
+	received := time.Now()
+	c, err := s.Store.Watch(apiOp, schema, wr)
+
+	event := &Event{
+		RequestReceivedTimestamp: received,
+		CompletedTimestamp:       time.Now(),
+		Verb:                     VerbWatch,
+		Schema:                   schema.ID,
+		Namespace:                apiOp.Namespace,
+		Name:                     wr.ID,
+		Outcome:                  OutcomeSuccess,
+	}
+	if req := apiOp.Request; req != nil {
+		event.SourceIP = sourceIP(req)
+		event.UserAgent = req.UserAgent()
+		event.RequestURI = req.RequestURI
+		if u, ok := request.UserFrom(req.Context()); ok {
+			event.User = User{Name: u.GetName(), Groups: u.GetGroups()}
+		}
+	}
+	if err != nil {
+		event.Outcome = OutcomeFailure
+		event.Error = err.Error()
+	}
+	if event.Level = s.logger.policy.levelFor(event); event.Level != LevelNone {
+		s.logger.write(event)
+	}
+	return c, err
+}
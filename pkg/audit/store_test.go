@@ -0,0 +1,59 @@
+package audit
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/rancher/apiserver/pkg/store/empty"
+	"github.com/rancher/apiserver/pkg/types"
+	"github.com/rancher/wrangler/v3/pkg/schemas"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeWatchStore struct {
+	empty.Store
+	err error
+}
+
+func (f *fakeWatchStore) Watch(_ *types.APIRequest, _ *types.APISchema, _ types.WatchRequest) (chan types.APIEvent, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	return make(chan types.APIEvent), nil
+}
+
+func TestStoreWatch(t *testing.T) {
+	sink := &fakeSink{}
+	logger := New(Policy{Rules: []PolicyRule{{Level: LevelNone, Schemas: []string{"event"}}}}, 0, sink)
+	schema := &types.APISchema{Schema: &schemas.Schema{ID: "configmap"}}
+
+	store := NewStore(&fakeWatchStore{}, logger)
+	apiOp, _ := newAPIRequest(http.MethodGet, "/v1/subscribe", "")
+	apiOp.Namespace = "default"
+	c, err := store.Watch(apiOp, schema, types.WatchRequest{ID: "cm1"})
+	require.NoError(t, err)
+	assert.NotNil(t, c)
+
+	failing := NewStore(&fakeWatchStore{err: fmt.Errorf("forbidden")}, logger)
+	_, err = failing.Watch(apiOp, schema, types.WatchRequest{})
+	assert.Error(t, err)
+
+	schema.ID = "event"
+	_, err = store.Watch(apiOp, schema, types.WatchRequest{})
+	require.NoError(t, err)
+
+	require.Len(t, sink.events, 2)
+	assert.Equal(t, VerbWatch, sink.events[0].Verb)
+	assert.Equal(t, "configmap", sink.events[0].Schema)
+	assert.Equal(t, "default", sink.events[0].Namespace)
+	assert.Equal(t, "cm1", sink.events[0].Name)
+	assert.Equal(t, "alice", sink.events[0].User.Name)
+	assert.Equal(t, OutcomeSuccess, sink.events[0].Outcome)
+	assert.Equal(t, OutcomeFailure, sink.events[1].Outcome)
+	assert.Equal(t, "forbidden", sink.events[1].Error)
+
+	inner := &fakeWatchStore{}
+	assert.Same(t, inner, NewStore(inner, nil))
+}
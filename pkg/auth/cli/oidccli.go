@@ -0,0 +1,35 @@
+package cli
+
+import (
+	"context"
+
+	"github.com/rancher/steve/pkg/auth"
+	"github.com/urfave/cli/v2"
+)
+
+type OIDCConfig struct {
+	OIDCConfigFile string
+}
+
+// OIDCAuthenticator returns the OIDC Authenticator of the issuers in the config file, or nil if there is none
+func (o *OIDCConfig) OIDCAuthenticator(ctx context.Context) (auth.Authenticator, error) {
+	if o.OIDCConfigFile == "" {
+		return nil, nil
+	}
+	config, err := auth.LoadOIDCConfig(o.OIDCConfigFile)
+	if err != nil {
+		return nil, err
+	}
+	return auth.NewOIDCAuthenticator(ctx, config)
+}
+
+func OIDCFlags(config *OIDCConfig) []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:        "oidc-config",
+			EnvVars:     []string{"OIDC_CONFIG"},
+			Usage:       "Path to a YAML or JSON file configuring the issuers of the JWT bearer tokens to authenticate",
+			Destination: &config.OIDCConfigFile,
+		},
+	}
+}
@@ -26,6 +26,15 @@ func (w *WebhookConfig) MustWebhookMiddleware() auth.Middleware {
 }
 
 func (w *WebhookConfig) WebhookMiddleware() (auth.Middleware, error) {
+	authenticator, err := w.WebhookAuthenticator()
+	if err != nil || authenticator == nil {
+		return nil, err
+	}
+	return auth.ToMiddleware(authenticator), nil
+}
+
+// WebhookAuthenticator returns the webhook Authenticator, or nil if webhook authentication is disabled
+func (w *WebhookConfig) WebhookAuthenticator() (auth.Authenticator, error) {
 	if !w.WebhookAuthentication {
 		return nil, nil
 	}
@@ -45,7 +54,7 @@ func (w *WebhookConfig) WebhookMiddleware() (auth.Middleware, error) {
 		return nil, err
 	}
 
-	return auth.NewWebhookMiddleware(time.Duration(w.CacheTTLSeconds)*time.Second, kubeConfig)
+	return auth.NewWebhookAuthenticator(time.Duration(w.CacheTTLSeconds)*time.Second, kubeConfig)
 }
 
 func Flags(config *WebhookConfig) []cli.Flag {
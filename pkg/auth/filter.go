@@ -1,6 +1,7 @@
 package auth
 
 import (
+	"errors"
 	"io/ioutil"
 	"net/http"
 	"strings"
@@ -142,6 +143,25 @@ func (w *webhookAuth) Authenticate(req *http.Request) (user.Info, bool, error) {
 	return resp.User, ok, err
 }
 
+// UnionAuthenticator returns the user of the first authenticator which authenticates the request, errors being
+// only returned if none does
+func UnionAuthenticator(authenticators ...Authenticator) Authenticator {
+	return AuthenticatorFunc(func(req *http.Request) (user.Info, bool, error) {
+		var errs []error
+		for _, auth := range authenticators {
+			info, ok, err := auth.Authenticate(req)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			if ok {
+				return info, true, nil
+			}
+		}
+		return nil, false, errors.Join(errs...)
+	})
+}
+
 func ToMiddleware(auth Authenticator) Middleware {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
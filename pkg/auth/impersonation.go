@@ -0,0 +1,217 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/rancher/steve/pkg/accesscontrol"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apiserver/pkg/authentication/serviceaccount"
+	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/apiserver/pkg/endpoints/request"
+	authorizationv1client "k8s.io/client-go/kubernetes/typed/authorization/v1"
+	"k8s.io/client-go/transport"
+)
+
+// ImpersonateUIDHeader impersonates the UID of the impersonated user
+const ImpersonateUIDHeader = transport.ImpersonateUIDHeader
+
+const (
+	impersonateVerb     = "impersonate"
+	authenticationGroup = "authentication.k8s.io"
+)
+
+// ImpersonationAttributes is what a caller must be allowed to impersonate, as in Kubernetes: users, groups and
+// serviceaccounts in the core group, and uids and userextras, with the extra's key as subresource, in the
+// authentication.k8s.io group
+type ImpersonationAttributes struct {
+	Group       string
+	Resource    string
+	Subresource string
+	Namespace   string
+	Name        string
+}
+
+func (a ImpersonationAttributes) String() string {
+	resource := a.Resource
+	if a.Subresource != "" {
+		resource += "/" + a.Subresource
+	}
+	if a.Namespace != "" {
+		return fmt.Sprintf("resource %q in API group %q in the namespace %q", resource, a.Group, a.Namespace)
+	}
+	return fmt.Sprintf("resource %q in API group %q", resource, a.Group)
+}
+
+// ImpersonationAuthorizer tells whether caller is granted the impersonate verb on attributes
+type ImpersonationAuthorizer interface {
+	AuthorizeImpersonation(ctx context.Context, caller user.Info, attributes ImpersonationAttributes) (bool, error)
+}
+
+type ImpersonationAuthorizerFunc func(ctx context.Context, caller user.Info, attributes ImpersonationAttributes) (bool, error)
+
+func (f ImpersonationAuthorizerFunc) AuthorizeImpersonation(ctx context.Context, caller user.Info, attributes ImpersonationAttributes) (bool, error) {
+	return f(ctx, caller, attributes)
+}
+
+// NewAccessSetImpersonationAuthorizer authorizes impersonation with the AccessSet of the caller. Extras are checked
+// on the userextras/<key> resource, so rules must name it rather than use a wildcard subresource.
+func NewAccessSetImpersonationAuthorizer(asl accesscontrol.AccessSetLookup) ImpersonationAuthorizer {
+	return ImpersonationAuthorizerFunc(func(_ context.Context, caller user.Info, attributes ImpersonationAttributes) (bool, error) {
+		resource := attributes.Resource
+		if attributes.Subresource != "" {
+			resource += "/" + attributes.Subresource
+		}
+		gr := schema.GroupResource{Group: attributes.Group, Resource: resource}
+		return asl.AccessFor(caller).Grants(impersonateVerb, gr, attributes.Namespace, attributes.Name), nil
+	})
+}
+
+// NewSubjectAccessReviewImpersonationAuthorizer authorizes impersonation by asking Kubernetes with a
+// SubjectAccessReview
+func NewSubjectAccessReviewImpersonationAuthorizer(client authorizationv1client.SubjectAccessReviewInterface) ImpersonationAuthorizer {
+	return ImpersonationAuthorizerFunc(func(ctx context.Context, caller user.Info, attributes ImpersonationAttributes) (bool, error) {
+		extra := map[string]authorizationv1.ExtraValue{}
+		for key, values := range caller.GetExtra() {
+			extra[key] = values
+		}
+		review, err := client.Create(ctx, &authorizationv1.SubjectAccessReview{
+			Spec: authorizationv1.SubjectAccessReviewSpec{
+				User:   caller.GetName(),
+				UID:    caller.GetUID(),
+				Groups: caller.GetGroups(),
+				Extra:  extra,
+				ResourceAttributes: &authorizationv1.ResourceAttributes{
+					Verb:        impersonateVerb,
+					Group:       attributes.Group,
+					Resource:    attributes.Resource,
+					Subresource: attributes.Subresource,
+					Namespace:   attributes.Namespace,
+					Name:        attributes.Name,
+				},
+			},
+		}, metav1.CreateOptions{})
+		if err != nil {
+			return false, err
+		}
+		return review.Status.Allowed, nil
+	})
+}
+
+// AuthorizedImpersonation returns a Middleware replacing the authenticated user of requests with the one in their
+// Impersonate-User, Impersonate-Uid, Impersonate-Group and Impersonate-Extra-* headers, as Kubernetes does. The
+// authenticated user must be allowed to impersonate each of them, otherwise the request is forbidden. It must be
+// chained after the authentication middleware.
+func AuthorizedImpersonation(authorizer ImpersonationAuthorizer) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			impersonated, ok, err := impersonatedUser(req)
+			if err != nil {
+				http.Error(rw, err.Error(), http.StatusBadRequest)
+				return
+			}
+			if !ok {
+				next.ServeHTTP(rw, req)
+				return
+			}
+			caller, ok := request.UserFrom(req.Context())
+			if !ok {
+				http.Error(rw, "impersonation requires an authenticated user", http.StatusUnauthorized)
+				return
+			}
+
+			for _, attributes := range impersonationAttributes(impersonated) {
+				allowed, err := authorizer.AuthorizeImpersonation(req.Context(), caller, attributes)
+				if err != nil {
+					http.Error(rw, fmt.Sprintf("authorizing impersonation: %v", err), http.StatusInternalServerError)
+					return
+				}
+				if !allowed {
+					http.Error(rw, fmt.Sprintf("user %q cannot impersonate %s", caller.GetName(), attributes), http.StatusForbidden)
+					return
+				}
+			}
+
+			addAuthenticatedGroups(impersonated)
+			req = req.Clone(request.WithUser(req.Context(), impersonated))
+			for key := range req.Header {
+				if strings.HasPrefix(key, "Impersonate-") {
+					delete(req.Header, key)
+				}
+			}
+			next.ServeHTTP(rw, req)
+		})
+	}
+}
+
+// impersonatedUser returns the user in the impersonation headers of req, if any
+func impersonatedUser(req *http.Request) (*user.DefaultInfo, bool, error) {
+	result := &user.DefaultInfo{
+		Name:   req.Header.Get(transport.ImpersonateUserHeader),
+		UID:    req.Header.Get(ImpersonateUIDHeader),
+		Groups: req.Header[transport.ImpersonateGroupHeader],
+		Extra:  map[string][]string{},
+	}
+	for k, v := range req.Header {
+		if !strings.HasPrefix(k, transport.ImpersonateUserExtraHeaderPrefix) {
+			continue
+		}
+		// keys are lower-cased and may be percent-encoded, as headers can't hold all characters of keys
+		key, err := url.PathUnescape(strings.ToLower(k[len(transport.ImpersonateUserExtraHeaderPrefix):]))
+		if err != nil {
+			return nil, false, fmt.Errorf("invalid impersonation extra header %q: %w", k, err)
+		}
+		result.Extra[key] = append(result.Extra[key], v...)
+	}
+
+	if result.Name == "" {
+		if result.UID != "" || len(result.Groups) > 0 || len(result.Extra) > 0 {
+			return nil, false, fmt.Errorf("impersonating a uid, groups or extras requires impersonating a user")
+		}
+		return nil, false, nil
+	}
+	return result, true, nil
+}
+
+// impersonationAttributes returns what must be allowed to impersonate u
+func impersonationAttributes(u *user.DefaultInfo) []ImpersonationAttributes {
+	var result []ImpersonationAttributes
+	if namespace, name, err := serviceaccount.SplitUsername(u.Name); err == nil {
+		result = append(result, ImpersonationAttributes{Resource: "serviceaccounts", Namespace: namespace, Name: name})
+	} else {
+		result = append(result, ImpersonationAttributes{Resource: "users", Name: u.Name})
+	}
+	if u.UID != "" {
+		result = append(result, ImpersonationAttributes{Group: authenticationGroup, Resource: "uids", Name: u.UID})
+	}
+	for _, group := range u.Groups {
+		result = append(result, ImpersonationAttributes{Resource: "groups", Name: group})
+	}
+	for key, values := range u.Extra {
+		for _, value := range values {
+			result = append(result, ImpersonationAttributes{Group: authenticationGroup, Resource: "userextras", Subresource: key, Name: value})
+		}
+	}
+	return result
+}
+
+// addAuthenticatedGroups adds the groups Kubernetes adds to impersonated users: those of service accounts when no
+// groups are impersonated, and system:authenticated or system:unauthenticated for anonymous
+func addAuthenticatedGroups(u *user.DefaultInfo) {
+	if namespace, _, err := serviceaccount.SplitUsername(u.Name); err == nil && len(u.Groups) == 0 {
+		u.Groups = serviceaccount.MakeGroupNames(namespace)
+	}
+	group := user.AllAuthenticated
+	if u.Name == user.Anonymous {
+		group = user.AllUnauthenticated
+	}
+	if !slices.Contains(u.Groups, group) {
+		u.Groups = append(slices.Clone(u.Groups), group)
+	}
+}
@@ -0,0 +1,232 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/rancher/steve/pkg/accesscontrol"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/apiserver/pkg/endpoints/request"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestAuthorizedImpersonation(t *testing.T) {
+	caller := &user.DefaultInfo{Name: "proxy", Groups: []string{"system:authenticated"}}
+	tests := []struct {
+		name          string
+		headers       map[string][]string
+		caller        user.Info
+		denied        string
+		authorizerErr error
+		wantCode      int
+		wantUser      *user.DefaultInfo
+		wantChecked   []ImpersonationAttributes
+	}{
+		{
+			name:     "no impersonation",
+			caller:   caller,
+			wantCode: http.StatusOK,
+			wantUser: caller,
+		},
+		{
+			name: "allowed",
+			headers: map[string][]string{
+				"Impersonate-User":                     {"alice"},
+				"Impersonate-Uid":                      {"1234"},
+				"Impersonate-Group":                    {"devs"},
+				"Impersonate-Extra-Scopes":             {"view"},
+				"Impersonate-Extra-Acme.com%2Fproject": {"p1"},
+			},
+			caller:   caller,
+			wantCode: http.StatusOK,
+			wantUser: &user.DefaultInfo{
+				Name:   "alice",
+				UID:    "1234",
+				Groups: []string{"devs", "system:authenticated"},
+				Extra:  map[string][]string{"scopes": {"view"}, "acme.com/project": {"p1"}},
+			},
+			wantChecked: []ImpersonationAttributes{
+				{Resource: "users", Name: "alice"},
+				{Group: "authentication.k8s.io", Resource: "uids", Name: "1234"},
+				{Resource: "groups", Name: "devs"},
+				{Group: "authentication.k8s.io", Resource: "userextras", Subresource: "scopes", Name: "view"},
+				{Group: "authentication.k8s.io", Resource: "userextras", Subresource: "acme.com/project", Name: "p1"},
+			},
+		},
+		{
+			name:     "service account",
+			headers:  map[string][]string{"Impersonate-User": {"system:serviceaccount:default:builder"}},
+			caller:   caller,
+			wantCode: http.StatusOK,
+			wantUser: &user.DefaultInfo{
+				Name:   "system:serviceaccount:default:builder",
+				Groups: []string{"system:serviceaccounts", "system:serviceaccounts:default", "system:authenticated"},
+				Extra:  map[string][]string{},
+			},
+			wantChecked: []ImpersonationAttributes{
+				{Resource: "serviceaccounts", Namespace: "default", Name: "builder"},
+			},
+		},
+		{
+			name:     "denied group",
+			headers:  map[string][]string{"Impersonate-User": {"alice"}, "Impersonate-Group": {"devs", "system:masters"}},
+			caller:   caller,
+			denied:   "system:masters",
+			wantCode: http.StatusForbidden,
+		},
+		{
+			name:     "groups without user",
+			headers:  map[string][]string{"Impersonate-Group": {"system:masters"}},
+			caller:   caller,
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:     "unauthenticated caller",
+			headers:  map[string][]string{"Impersonate-User": {"alice"}},
+			wantCode: http.StatusUnauthorized,
+		},
+		{
+			name:          "authorizer error",
+			headers:       map[string][]string{"Impersonate-User": {"alice"}},
+			caller:        caller,
+			authorizerErr: fmt.Errorf("timeout"),
+			wantCode:      http.StatusInternalServerError,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var checked []ImpersonationAttributes
+			authorizer := ImpersonationAuthorizerFunc(func(_ context.Context, u user.Info, attributes ImpersonationAttributes) (bool, error) {
+				assert.Equal(t, test.caller, u)
+				checked = append(checked, attributes)
+				return attributes.Name != test.denied, test.authorizerErr
+			})
+			var got user.Info
+			var headers http.Header
+			next := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				got, _ = request.UserFrom(req.Context())
+				headers = req.Header
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/v1/pods", nil)
+			for key, values := range test.headers {
+				req.Header[key] = values
+			}
+			if test.caller != nil {
+				req = req.WithContext(request.WithUser(req.Context(), test.caller))
+			}
+			rw := httptest.NewRecorder()
+			AuthorizedImpersonation(authorizer)(next).ServeHTTP(rw, req)
+
+			assert.Equal(t, test.wantCode, rw.Code)
+			if test.wantCode != http.StatusOK {
+				assert.Nil(t, got)
+				return
+			}
+			if test.wantUser == caller {
+				assert.Same(t, caller, got)
+				return
+			}
+			assert.Equal(t, test.wantUser, got)
+			assert.ElementsMatch(t, test.wantChecked, checked)
+			for key := range headers {
+				assert.NotContains(t, key, "Impersonate-")
+			}
+		})
+	}
+}
+
+func TestImpersonation(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/v1/pods", nil)
+	info, ok, err := Impersonation(req)
+	assert.NoError(t, err)
+	assert.False(t, ok)
+	assert.Nil(t, info)
+
+	// the uid is ignored and extra keys are kept as they are, as they always were
+	req.Header.Set("Impersonate-User", "alice")
+	req.Header.Set("Impersonate-Uid", "1234")
+	req.Header.Add("Impersonate-Group", "devs")
+	req.Header.Add("Impersonate-Extra-Scopes%2fread", "all")
+	info, ok, err = Impersonation(req)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, &user.DefaultInfo{Name: "alice", Groups: []string{"devs"}, Extra: map[string][]string{"Scopes%2fread": {"all"}}}, info)
+}
+
+type fakeAccessSetLookup struct {
+	accessSet *accesscontrol.AccessSet
+}
+
+func (f *fakeAccessSetLookup) AccessFor(_ user.Info) *accesscontrol.AccessSet {
+	return f.accessSet
+}
+
+func (f *fakeAccessSetLookup) PurgeUserData(_ string) {}
+
+func TestAccessSetImpersonationAuthorizer(t *testing.T) {
+	accessSet := &accesscontrol.AccessSet{}
+	accessSet.Add("impersonate", schema.GroupResource{Resource: "users"}, accesscontrol.Access{Namespace: accesscontrol.All, ResourceName: "alice"})
+	accessSet.Add("impersonate", schema.GroupResource{Group: "authentication.k8s.io", Resource: "userextras/scopes"}, accesscontrol.Access{Namespace: accesscontrol.All, ResourceName: accesscontrol.All})
+	authorizer := NewAccessSetImpersonationAuthorizer(&fakeAccessSetLookup{accessSet: accessSet})
+	caller := &user.DefaultInfo{Name: "proxy"}
+
+	tests := []struct {
+		attributes ImpersonationAttributes
+		want       bool
+	}{
+		{attributes: ImpersonationAttributes{Resource: "users", Name: "alice"}, want: true},
+		{attributes: ImpersonationAttributes{Resource: "users", Name: "bob"}, want: false},
+		{attributes: ImpersonationAttributes{Resource: "groups", Name: "devs"}, want: false},
+		{attributes: ImpersonationAttributes{Group: "authentication.k8s.io", Resource: "userextras", Subresource: "scopes", Name: "view"}, want: true},
+		{attributes: ImpersonationAttributes{Group: "authentication.k8s.io", Resource: "userextras", Subresource: "other", Name: "view"}, want: false},
+	}
+	for _, test := range tests {
+		t.Run(test.attributes.String(), func(t *testing.T) {
+			allowed, err := authorizer.AuthorizeImpersonation(t.Context(), caller, test.attributes)
+			require.NoError(t, err)
+			assert.Equal(t, test.want, allowed)
+		})
+	}
+}
+
+func TestSubjectAccessReviewImpersonationAuthorizer(t *testing.T) {
+	client := fake.NewClientset()
+	var reviewed *authorizationv1.SubjectAccessReview
+	client.PrependReactor("create", "subjectaccessreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		reviewed = action.(k8stesting.CreateAction).GetObject().(*authorizationv1.SubjectAccessReview)
+		result := reviewed.DeepCopy()
+		result.Status.Allowed = reviewed.Spec.ResourceAttributes.Name == "alice"
+		return true, result, nil
+	})
+	authorizer := NewSubjectAccessReviewImpersonationAuthorizer(client.AuthorizationV1().SubjectAccessReviews())
+	caller := &user.DefaultInfo{Name: "proxy", UID: "1", Groups: []string{"proxies"}, Extra: map[string][]string{"scopes": {"all"}}}
+
+	allowed, err := authorizer.AuthorizeImpersonation(t.Context(), caller, ImpersonationAttributes{Resource: "users", Name: "alice"})
+	require.NoError(t, err)
+	assert.True(t, allowed)
+	assert.Equal(t, authorizationv1.SubjectAccessReviewSpec{
+		User:   "proxy",
+		UID:    "1",
+		Groups: []string{"proxies"},
+		Extra:  map[string]authorizationv1.ExtraValue{"scopes": {"all"}},
+		ResourceAttributes: &authorizationv1.ResourceAttributes{
+			Verb:     "impersonate",
+			Resource: "users",
+			Name:     "alice",
+		},
+	}, reviewed.Spec)
+
+	allowed, err = authorizer.AuthorizeImpersonation(t.Context(), caller, ImpersonationAttributes{Resource: "users", Name: "bob"})
+	require.NoError(t, err)
+	assert.False(t, allowed)
+}
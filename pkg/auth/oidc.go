@@ -0,0 +1,448 @@
+package auth
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	jose "github.com/go-jose/go-jose/v4"
+	"github.com/go-jose/go-jose/v4/jwt"
+	"github.com/rancher/steve/pkg/configfile"
+	"github.com/sirupsen/logrus"
+	"k8s.io/apiserver/pkg/authentication/user"
+)
+
+const (
+	defaultUsernameClaim = "sub"
+	// noUsernamePrefix disables the default prefix of usernames
+	noUsernamePrefix = "-"
+	// reservedPrefix is reserved for the users and groups of Kubernetes components, which tokens can't claim
+	reservedPrefix     = "system:"
+	defaultJWKSRefresh = time.Hour
+	// minJWKSReload limits how often keys are reloaded when a token is signed by an unknown key, which happens when
+	// the issuer rotates its keys
+	minJWKSReload = 30 * time.Second
+	jwksTimeout   = 30 * time.Second
+	// oidcLeeway is the clock skew allowed when checking the expiry of tokens
+	oidcLeeway = time.Minute
+)
+
+// oidcSignatureAlgorithms are the asymmetric algorithms tokens may be signed with
+var oidcSignatureAlgorithms = []jose.SignatureAlgorithm{
+	jose.RS256, jose.RS384, jose.RS512,
+	jose.ES256, jose.ES384, jose.ES512,
+	jose.PS256, jose.PS384, jose.PS512,
+	jose.EdDSA,
+}
+
+// OIDCConfig configures the issuers of the JWTs accepted by the OIDC authenticator
+type OIDCConfig struct {
+	Issuers []OIDCIssuer `json:"issuers"`
+}
+
+// OIDCIssuer validates the JWTs of an issuer with its JSON Web Key Set, and maps their claims to a user
+type OIDCIssuer struct {
+	// Issuer must match the iss claim
+	Issuer string `json:"issuer"`
+	// Audiences must contain one of the values of the aud claim
+	Audiences []string `json:"audiences"`
+	// JWKSURL or JWKSFile holds the keys of the issuer, which are reloaded every JWKSRefreshSeconds, one hour by
+	// default, and when a token is signed with an unknown key
+	JWKSURL            string `json:"jwksURL,omitempty"`
+	JWKSFile           string `json:"jwksFile,omitempty"`
+	JWKSRefreshSeconds int    `json:"jwksRefreshSeconds,omitempty"`
+	// CAFile verifies the certificate of JWKSURL, instead of the system's CAs
+	CAFile string `json:"caFile,omitempty"`
+
+	// UsernameClaim defaults to sub. If it is email, tokens without a true email_verified claim are rejected.
+	UsernameClaim string `json:"usernameClaim,omitempty"`
+	// UsernamePrefix defaults to the issuer followed by #, as in Kubernetes, unless UsernameClaim is email. It is
+	// disabled with "-".
+	UsernamePrefix string `json:"usernamePrefix,omitempty"`
+	UIDClaim       string `json:"uidClaim,omitempty"`
+	// GroupsClaim is a string or a list of strings, no groups are mapped if it is empty
+	GroupsClaim  string `json:"groupsClaim,omitempty"`
+	GroupsPrefix string `json:"groupsPrefix,omitempty"`
+	// ExtraClaims are strings or lists of strings, mapped to the extra named after the claim with ExtraPrefix
+	ExtraClaims []string `json:"extraClaims,omitempty"`
+	ExtraPrefix string   `json:"extraPrefix,omitempty"`
+	// RequiredClaims must have the given string values
+	RequiredClaims map[string]string `json:"requiredClaims,omitempty"`
+}
+
+// LoadOIDCConfig reads the OIDCConfig in a YAML or JSON file, rejecting configs without issuers, duplicate issuers
+// and issuers without audiences or a single source of keys
+func LoadOIDCConfig(path string) (OIDCConfig, error) {
+	return configfile.Load(path, "OIDC config", OIDCConfig.validate)
+}
+
+func (c OIDCConfig) validate() error {
+	if len(c.Issuers) == 0 {
+		return fmt.Errorf("no issuers configured")
+	}
+	seen := map[string]bool{}
+	for i, issuer := range c.Issuers {
+		switch {
+		case issuer.Issuer == "":
+			return fmt.Errorf("issuer %d: issuer is required", i)
+		case seen[issuer.Issuer]:
+			return fmt.Errorf("issuer %d: duplicate issuer %s", i, issuer.Issuer)
+		case len(issuer.Audiences) == 0:
+			return fmt.Errorf("issuer %d: audiences are required", i)
+		case (issuer.JWKSURL == "") == (issuer.JWKSFile == ""):
+			return fmt.Errorf("issuer %d: exactly one of jwksURL and jwksFile is required", i)
+		case issuer.CAFile != "" && issuer.JWKSURL == "":
+			return fmt.Errorf("issuer %d: caFile requires jwksURL", i)
+		}
+		seen[issuer.Issuer] = true
+	}
+	return nil
+}
+
+// oidcAuthenticator authenticates the bearer tokens which are JWTs of its issuers, other tokens are left to other
+// authenticators
+type oidcAuthenticator struct {
+	issuers map[string]*oidcIssuer
+	now     func() time.Time
+}
+
+type oidcIssuer struct {
+	config OIDCIssuer
+	keys   *jwks
+}
+
+// NewOIDCAuthenticator returns an Authenticator validating JWTs against the issuers of config. Keys are refreshed
+// until ctx is done.
+func NewOIDCAuthenticator(ctx context.Context, config OIDCConfig) (Authenticator, error) {
+	if err := config.validate(); err != nil {
+		return nil, err
+	}
+	a := &oidcAuthenticator{issuers: map[string]*oidcIssuer{}, now: time.Now}
+	for _, issuerConfig := range config.Issuers {
+		keys, err := newJWKS(issuerConfig)
+		if err != nil {
+			return nil, fmt.Errorf("issuer %s: %w", issuerConfig.Issuer, err)
+		}
+		if err := keys.load(ctx); err != nil {
+			if issuerConfig.JWKSFile != "" {
+				return nil, fmt.Errorf("issuer %s: %w", issuerConfig.Issuer, err)
+			}
+			// the URL may not be reachable yet, keys are loaded again with the first token
+			logrus.Errorf("loading the keys of OIDC issuer %s: %v", issuerConfig.Issuer, err)
+		}
+		go keys.run(ctx)
+		a.issuers[issuerConfig.Issuer] = &oidcIssuer{config: issuerConfig, keys: keys}
+	}
+	return a, nil
+}
+
+func (a *oidcAuthenticator) Authenticate(req *http.Request) (user.Info, bool, error) {
+	token, ok := strings.CutPrefix(req.Header.Get("Authorization"), "Bearer ")
+	if !ok || token == "" {
+		return nil, false, nil
+	}
+	parsed, err := jwt.ParseSigned(token, oidcSignatureAlgorithms)
+	if err != nil {
+		// not a JWT
+		return nil, false, nil
+	}
+	var unverified jwt.Claims
+	if err := parsed.UnsafeClaimsWithoutVerification(&unverified); err != nil {
+		return nil, false, nil
+	}
+	issuer, ok := a.issuers[unverified.Issuer]
+	if !ok {
+		return nil, false, nil
+	}
+	info, err := issuer.authenticate(req.Context(), parsed, a.now())
+	if err != nil {
+		return nil, false, fmt.Errorf("OIDC token of issuer %s: %w", issuer.config.Issuer, err)
+	}
+	return info, true, nil
+}
+
+func (i *oidcIssuer) authenticate(ctx context.Context, token *jwt.JSONWebToken, now time.Time) (user.Info, error) {
+	var (
+		registered jwt.Claims
+		claims     map[string]any
+		verified   bool
+	)
+	for _, key := range i.keys.keysFor(ctx, token.Headers[0].KeyID) {
+		if err := token.Claims(key.Key, &registered, &claims); err == nil {
+			verified = true
+			break
+		}
+	}
+	if !verified {
+		return nil, fmt.Errorf("invalid signature")
+	}
+	if registered.Expiry == nil {
+		return nil, fmt.Errorf("missing exp claim")
+	}
+	expected := jwt.Expected{Issuer: i.config.Issuer, AnyAudience: i.config.Audiences, Time: now}
+	if err := registered.ValidateWithLeeway(expected, oidcLeeway); err != nil {
+		return nil, err
+	}
+	for claim, value := range i.config.RequiredClaims {
+		if actual, _ := claims[claim].(string); actual != value {
+			return nil, fmt.Errorf("claim %s must be %q", claim, value)
+		}
+	}
+	return i.userFor(claims)
+}
+
+// userFor maps the claims of a verified token to a user
+func (i *oidcIssuer) userFor(claims map[string]any) (user.Info, error) {
+	usernameClaim := i.config.UsernameClaim
+	if usernameClaim == "" {
+		usernameClaim = defaultUsernameClaim
+	}
+	username, _ := claims[usernameClaim].(string)
+	if username == "" {
+		return nil, fmt.Errorf("missing %s claim", usernameClaim)
+	}
+	if usernameClaim == "email" {
+		// a missing email_verified claim doesn't vouch for the email
+		if verified, _ := claims["email_verified"].(bool); !verified {
+			return nil, fmt.Errorf("email is not verified")
+		}
+	}
+	info := &user.DefaultInfo{Name: i.usernamePrefix(usernameClaim) + username}
+	if strings.HasPrefix(info.Name, reservedPrefix) {
+		return nil, fmt.Errorf("username %q is reserved", info.Name)
+	}
+
+	if i.config.UIDClaim != "" {
+		uid, _ := claims[i.config.UIDClaim].(string)
+		if uid == "" {
+			return nil, fmt.Errorf("missing %s claim", i.config.UIDClaim)
+		}
+		info.UID = uid
+	}
+	if i.config.GroupsClaim != "" {
+		groups, err := stringsClaim(claims, i.config.GroupsClaim)
+		if err != nil {
+			return nil, err
+		}
+		for _, group := range groups {
+			group = i.config.GroupsPrefix + group
+			if strings.HasPrefix(group, reservedPrefix) {
+				return nil, fmt.Errorf("group %q is reserved", group)
+			}
+			info.Groups = append(info.Groups, group)
+		}
+	}
+	for _, claim := range i.config.ExtraClaims {
+		values, err := stringsClaim(claims, claim)
+		if err != nil {
+			return nil, err
+		}
+		if len(values) == 0 {
+			continue
+		}
+		if info.Extra == nil {
+			info.Extra = map[string][]string{}
+		}
+		info.Extra[i.config.ExtraPrefix+claim] = values
+	}
+	return info, nil
+}
+
+// usernamePrefix returns the prefix of the usernames mapped from usernameClaim, which defaults to the issuer
+// followed by # so that the users of different issuers can't be confused, except for emails
+func (i *oidcIssuer) usernamePrefix(usernameClaim string) string {
+	switch {
+	case i.config.UsernamePrefix == noUsernamePrefix:
+		return ""
+	case i.config.UsernamePrefix != "":
+		return i.config.UsernamePrefix
+	case usernameClaim == "email":
+		return ""
+	default:
+		return i.config.Issuer + "#"
+	}
+}
+
+// stringsClaim returns the value of a claim which is a string or a list of strings
+func stringsClaim(claims map[string]any, claim string) ([]string, error) {
+	switch value := claims[claim].(type) {
+	case nil:
+		return nil, nil
+	case string:
+		return []string{value}, nil
+	case []any:
+		result := make([]string, 0, len(value))
+		for _, item := range value {
+			s, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("claim %s must be a string or a list of strings", claim)
+			}
+			result = append(result, s)
+		}
+		return result, nil
+	default:
+		return nil, fmt.Errorf("claim %s must be a string or a list of strings", claim)
+	}
+}
+
+// jwks caches the keys of an issuer, loaded from a URL or a file
+type jwks struct {
+	url     string
+	file    string
+	client  *http.Client
+	refresh time.Duration
+
+	// loadLock serializes loads, while lock protects the keys
+	loadLock sync.Mutex
+	lock     sync.RWMutex
+	keys     jose.JSONWebKeySet
+	loaded   time.Time
+}
+
+func newJWKS(config OIDCIssuer) (*jwks, error) {
+	k := &jwks{
+		url:     config.JWKSURL,
+		file:    config.JWKSFile,
+		client:  &http.Client{Timeout: jwksTimeout},
+		refresh: defaultJWKSRefresh,
+	}
+	if config.JWKSRefreshSeconds > 0 {
+		k.refresh = time.Duration(config.JWKSRefreshSeconds) * time.Second
+	}
+	if config.CAFile != "" {
+		pem, err := os.ReadFile(config.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("reading CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates in CA file %s", config.CAFile)
+		}
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.TLSClientConfig = &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}
+		k.client.Transport = transport
+	}
+	return k, nil
+}
+
+// run reloads the keys periodically until ctx is done
+func (k *jwks) run(ctx context.Context) {
+	ticker := time.NewTicker(k.refresh)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := k.load(ctx); err != nil {
+				logrus.Errorf("reloading OIDC keys: %v", err)
+			}
+		}
+	}
+}
+
+// keysFor returns the keys with the given ID, or all keys if it is empty. The keys are reloaded if none has the ID
+// and they were not loaded recently, as the issuer may have rotated them.
+func (k *jwks) keysFor(ctx context.Context, kid string) []jose.JSONWebKey {
+	keys, loaded := k.find(kid)
+	if len(keys) > 0 || time.Since(loaded) < minJWKSReload {
+		return keys
+	}
+	if err := k.reload(ctx); err != nil {
+		logrus.Errorf("reloading OIDC keys: %v", err)
+	}
+	keys, _ = k.find(kid)
+	return keys
+}
+
+func (k *jwks) find(kid string) ([]jose.JSONWebKey, time.Time) {
+	k.lock.RLock()
+	defer k.lock.RUnlock()
+	if kid == "" {
+		return k.keys.Keys, k.loaded
+	}
+	return k.keys.Key(kid), k.loaded
+}
+
+func (k *jwks) load(ctx context.Context) error {
+	k.loadLock.Lock()
+	defer k.loadLock.Unlock()
+	return k.loadLocked(ctx)
+}
+
+// reload loads the keys unless they were loaded recently, which happens when concurrent requests with the same
+// unknown key waited for the same load
+func (k *jwks) reload(ctx context.Context) error {
+	k.loadLock.Lock()
+	defer k.loadLock.Unlock()
+	k.lock.RLock()
+	loaded := k.loaded
+	k.lock.RUnlock()
+	if time.Since(loaded) < minJWKSReload {
+		return nil
+	}
+	return k.loadLocked(ctx)
+}
+
+// loadLocked loads the keys, loadLock being held
+func (k *jwks) loadLocked(ctx context.Context) error {
+	var (
+		content []byte
+		err     error
+	)
+	if k.file != "" {
+		content, err = os.ReadFile(k.file)
+	} else {
+		content, err = k.fetch(ctx)
+	}
+	// failed loads also count as loads, so that unknown keys don't cause a load per request
+	k.lock.Lock()
+	k.loaded = time.Now()
+	k.lock.Unlock()
+	if err != nil {
+		return fmt.Errorf("loading JWKS: %w", err)
+	}
+
+	var keys jose.JSONWebKeySet
+	if err := json.Unmarshal(content, &keys); err != nil {
+		return fmt.Errorf("parsing JWKS: %w", err)
+	}
+	valid := keys.Keys[:0]
+	for _, key := range keys.Keys {
+		if key.Valid() && key.IsPublic() && (key.Use == "" || key.Use == "sig") {
+			valid = append(valid, key)
+		}
+	}
+	if len(valid) == 0 {
+		return fmt.Errorf("no public signing keys in JWKS")
+	}
+
+	k.lock.Lock()
+	defer k.lock.Unlock()
+	k.keys = jose.JSONWebKeySet{Keys: valid}
+	return nil
+}
+
+func (k *jwks) fetch(ctx context.Context) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, k.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := k.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d from %s", resp.StatusCode, k.url)
+	}
+	return io.ReadAll(resp.Body)
+}
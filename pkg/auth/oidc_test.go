@@ -0,0 +1,395 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	jose "github.com/go-jose/go-jose/v4"
+	"github.com/go-jose/go-jose/v4/jwt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apiserver/pkg/authentication/user"
+)
+
+const testIssuer = "https://issuer.example.com"
+
+type testKey struct {
+	kid     string
+	private any
+	alg     jose.SignatureAlgorithm
+}
+
+func newRSAKey(t *testing.T, kid string) testKey {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	return testKey{kid: kid, private: key, alg: jose.RS256}
+}
+
+func (k testKey) public() jose.JSONWebKey {
+	jwk := jose.JSONWebKey{Key: k.private, KeyID: k.kid, Algorithm: string(k.alg), Use: "sig"}
+	return jwk.Public()
+}
+
+func (k testKey) sign(t *testing.T, claims map[string]any) string {
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: k.alg, Key: k.private},
+		(&jose.SignerOptions{}).WithType("JWT").WithHeader("kid", k.kid))
+	require.NoError(t, err)
+	token, err := jwt.Signed(signer).Claims(claims).Serialize()
+	require.NoError(t, err)
+	return token
+}
+
+func jwksJSON(t *testing.T, keys ...testKey) []byte {
+	set := jose.JSONWebKeySet{}
+	for _, key := range keys {
+		set.Keys = append(set.Keys, key.public())
+	}
+	data, err := json.Marshal(set)
+	require.NoError(t, err)
+	return data
+}
+
+func validClaims() map[string]any {
+	return map[string]any{
+		"iss":    testIssuer,
+		"aud":    []string{"steve"},
+		"sub":    "alice",
+		"exp":    time.Now().Add(time.Hour).Unix(),
+		"groups": []string{"devs", "ops"},
+		"tenant": "acme",
+	}
+}
+
+func authenticate(t *testing.T, authenticator Authenticator, token string) (user.Info, bool, error) {
+	req := httptest.NewRequest(http.MethodGet, "/v1/pods", nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return authenticator.Authenticate(req)
+}
+
+func TestOIDCAuthenticator(t *testing.T) {
+	key := newRSAKey(t, "key1")
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	otherKey := testKey{kid: "key2", private: ecKey, alg: jose.ES256}
+	unknownKey := newRSAKey(t, "key1")
+
+	jwksFile := filepath.Join(t.TempDir(), "jwks.json")
+	require.NoError(t, os.WriteFile(jwksFile, jwksJSON(t, key, otherKey), 0o600))
+	authenticator, err := NewOIDCAuthenticator(t.Context(), OIDCConfig{Issuers: []OIDCIssuer{{
+		Issuer:         testIssuer,
+		Audiences:      []string{"steve", "dashboard"},
+		JWKSFile:       jwksFile,
+		UsernamePrefix: "oidc:",
+		UIDClaim:       "sub",
+		GroupsClaim:    "groups",
+		GroupsPrefix:   "oidc:",
+		ExtraClaims:    []string{"tenant"},
+		ExtraPrefix:    "example.com/",
+		RequiredClaims: map[string]string{"tenant": "acme"},
+	}}})
+	require.NoError(t, err)
+
+	with := func(changes map[string]any) map[string]any {
+		claims := validClaims()
+		for k, v := range changes {
+			if v == nil {
+				delete(claims, k)
+			} else {
+				claims[k] = v
+			}
+		}
+		return claims
+	}
+	tests := []struct {
+		name    string
+		token   string
+		want    user.Info
+		wantOK  bool
+		wantErr string
+	}{
+		{
+			name:   "valid",
+			token:  key.sign(t, validClaims()),
+			wantOK: true,
+			want: &user.DefaultInfo{
+				Name:   "oidc:alice",
+				UID:    "alice",
+				Groups: []string{"oidc:devs", "oidc:ops"},
+				Extra:  map[string][]string{"example.com/tenant": {"acme"}},
+			},
+		},
+		{
+			name:   "other key and audience",
+			token:  otherKey.sign(t, with(map[string]any{"aud": "dashboard", "groups": "devs"})),
+			wantOK: true,
+			want: &user.DefaultInfo{
+				Name:   "oidc:alice",
+				UID:    "alice",
+				Groups: []string{"oidc:devs"},
+				Extra:  map[string][]string{"example.com/tenant": {"acme"}},
+			},
+		},
+		{
+			name:  "no token",
+			token: "",
+		},
+		{
+			name:  "not a JWT",
+			token: "opaque-webhook-token",
+		},
+		{
+			name:  "other issuer",
+			token: key.sign(t, with(map[string]any{"iss": "https://other.example.com"})),
+		},
+		{
+			name:    "unknown key",
+			token:   unknownKey.sign(t, validClaims()),
+			wantErr: "invalid signature",
+		},
+		{
+			name:    "expired",
+			token:   key.sign(t, with(map[string]any{"exp": time.Now().Add(-time.Hour).Unix()})),
+			wantErr: "expired",
+		},
+		{
+			name:    "no expiry",
+			token:   key.sign(t, with(map[string]any{"exp": nil})),
+			wantErr: "missing exp claim",
+		},
+		{
+			name:    "wrong audience",
+			token:   key.sign(t, with(map[string]any{"aud": "other"})),
+			wantErr: "audience",
+		},
+		{
+			name:    "missing required claim",
+			token:   key.sign(t, with(map[string]any{"tenant": nil})),
+			wantErr: "claim tenant must be",
+		},
+		{
+			name:    "invalid groups",
+			token:   key.sign(t, with(map[string]any{"groups": 42})),
+			wantErr: "claim groups must be a string or a list of strings",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			info, ok, err := authenticate(t, authenticator, test.token)
+			if test.wantErr != "" {
+				assert.ErrorContains(t, err, test.wantErr)
+				assert.False(t, ok)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.wantOK, ok)
+			assert.Equal(t, test.want, info)
+		})
+	}
+}
+
+func TestOIDCAuthenticatorEmailClaim(t *testing.T) {
+	key := newRSAKey(t, "key1")
+	jwksFile := filepath.Join(t.TempDir(), "jwks.json")
+	require.NoError(t, os.WriteFile(jwksFile, jwksJSON(t, key), 0o600))
+	authenticator, err := NewOIDCAuthenticator(t.Context(), OIDCConfig{Issuers: []OIDCIssuer{{
+		Issuer:        testIssuer,
+		Audiences:     []string{"steve"},
+		JWKSFile:      jwksFile,
+		UsernameClaim: "email",
+	}}})
+	require.NoError(t, err)
+
+	claims := validClaims()
+	claims["email"] = "alice@example.com"
+	claims["email_verified"] = true
+	info, ok, err := authenticate(t, authenticator, key.sign(t, claims))
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "alice@example.com", info.GetName())
+	assert.Empty(t, info.GetGroups())
+
+	claims["email_verified"] = false
+	_, ok, err = authenticate(t, authenticator, key.sign(t, claims))
+	assert.ErrorContains(t, err, "email is not verified")
+	assert.False(t, ok)
+
+	delete(claims, "email_verified")
+	_, ok, err = authenticate(t, authenticator, key.sign(t, claims))
+	assert.ErrorContains(t, err, "email is not verified", "a missing email_verified claim doesn't verify the email")
+	assert.False(t, ok)
+}
+
+func TestOIDCAuthenticatorKeyRotation(t *testing.T) {
+	oldKey, newKey := newRSAKey(t, "old"), newRSAKey(t, "new")
+	var current atomic.Value
+	current.Store(jwksJSON(t, oldKey))
+	var fetches atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, _ *http.Request) {
+		fetches.Add(1)
+		_, _ = rw.Write(current.Load().([]byte))
+	}))
+	defer server.Close()
+
+	authenticator, err := NewOIDCAuthenticator(t.Context(), OIDCConfig{Issuers: []OIDCIssuer{{
+		Issuer:    testIssuer,
+		Audiences: []string{"steve"},
+		JWKSURL:   server.URL,
+	}}})
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), fetches.Load())
+
+	_, ok, err := authenticate(t, authenticator, oldKey.sign(t, validClaims()))
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, int32(1), fetches.Load(), "keys are cached")
+
+	// the keys were just loaded, so an unknown key doesn't cause a reload
+	current.Store(jwksJSON(t, newKey))
+	_, ok, err = authenticate(t, authenticator, newKey.sign(t, validClaims()))
+	assert.Error(t, err)
+	assert.False(t, ok)
+	assert.Equal(t, int32(1), fetches.Load())
+
+	issuer := authenticator.(*oidcAuthenticator).issuers[testIssuer]
+	issuer.keys.lock.Lock()
+	issuer.keys.loaded = time.Now().Add(-minJWKSReload)
+	issuer.keys.lock.Unlock()
+
+	// concurrent requests signed with the new key wait for a single reload
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, ok, err := authenticate(t, authenticator, newKey.sign(t, validClaims()))
+			assert.NoError(t, err)
+			assert.True(t, ok)
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(2), fetches.Load())
+
+	info, ok, err := authenticate(t, authenticator, newKey.sign(t, validClaims()))
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, testIssuer+"#alice", info.GetName(), "usernames are prefixed with the issuer by default")
+}
+
+func TestOIDCAuthenticatorReservedNames(t *testing.T) {
+	key := newRSAKey(t, "key1")
+	jwksFile := filepath.Join(t.TempDir(), "jwks.json")
+	require.NoError(t, os.WriteFile(jwksFile, jwksJSON(t, key), 0o600))
+	authenticator, err := NewOIDCAuthenticator(t.Context(), OIDCConfig{Issuers: []OIDCIssuer{{
+		Issuer:         testIssuer,
+		Audiences:      []string{"steve"},
+		JWKSFile:       jwksFile,
+		UsernamePrefix: "-",
+		GroupsClaim:    "groups",
+	}}})
+	require.NoError(t, err)
+
+	info, ok, err := authenticate(t, authenticator, key.sign(t, validClaims()))
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "alice", info.GetName(), "the default prefix can be disabled")
+
+	claims := validClaims()
+	claims["sub"] = "system:admin"
+	_, ok, err = authenticate(t, authenticator, key.sign(t, claims))
+	assert.ErrorContains(t, err, "reserved")
+	assert.False(t, ok)
+
+	claims = validClaims()
+	claims["groups"] = []any{"devs", "system:masters"}
+	_, ok, err = authenticate(t, authenticator, key.sign(t, claims))
+	assert.ErrorContains(t, err, "reserved")
+	assert.False(t, ok)
+}
+
+func TestLoadOIDCConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr string
+	}{
+		{
+			name: "valid",
+			content: `
+issuers:
+- issuer: https://issuer.example.com
+  audiences: [steve]
+  jwksURL: https://issuer.example.com/keys
+  groupsClaim: groups
+  extraClaims: [tenant]
+`,
+		},
+		{
+			name:    "no issuers",
+			content: "issuers: []\n",
+			wantErr: "no issuers configured",
+		},
+		{
+			name:    "no audiences",
+			content: "issuers:\n- issuer: https://issuer.example.com\n  jwksFile: /keys.json\n",
+			wantErr: "audiences are required",
+		},
+		{
+			name:    "both key sources",
+			content: "issuers:\n- issuer: https://issuer.example.com\n  audiences: [steve]\n  jwksFile: /keys.json\n  jwksURL: https://issuer.example.com/keys\n",
+			wantErr: "exactly one of jwksURL and jwksFile is required",
+		},
+		{
+			name:    "unknown field",
+			content: "issuers:\n- issuer: https://issuer.example.com\n  audience: steve\n",
+			wantErr: "unknown field",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "oidc.yaml")
+			require.NoError(t, os.WriteFile(path, []byte(test.content), 0o600))
+			config, err := LoadOIDCConfig(path)
+			if test.wantErr != "" {
+				assert.ErrorContains(t, err, test.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, []string{"tenant"}, config.Issuers[0].ExtraClaims)
+		})
+	}
+}
+
+func TestUnionAuthenticator(t *testing.T) {
+	alice := &user.DefaultInfo{Name: "alice"}
+	failing := AuthenticatorFunc(func(*http.Request) (user.Info, bool, error) { return nil, false, fmt.Errorf("invalid token") })
+	skipping := AuthenticatorFunc(func(*http.Request) (user.Info, bool, error) { return nil, false, nil })
+	succeeding := AuthenticatorFunc(func(*http.Request) (user.Info, bool, error) { return alice, true, nil })
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+
+	info, ok, err := UnionAuthenticator(failing, skipping, succeeding).Authenticate(req)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Same(t, alice, info)
+
+	_, ok, err = UnionAuthenticator(skipping, failing).Authenticate(req)
+	assert.ErrorContains(t, err, "invalid token")
+	assert.False(t, ok)
+
+	_, ok, err = UnionAuthenticator(skipping).Authenticate(req)
+	assert.NoError(t, err)
+	assert.False(t, ok)
+}
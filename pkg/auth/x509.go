@@ -0,0 +1,97 @@
+package auth
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/rancher/steve/pkg/configfile"
+	"k8s.io/apiserver/pkg/authentication/user"
+)
+
+// clientCAsReloadInterval is how often the CA bundle file is checked for changes
+const clientCAsReloadInterval = 10 * time.Second
+
+// ClientCAs is a bundle of the CAs which sign client certificates, reloaded when its file changes
+type ClientCAs struct {
+	lock sync.RWMutex
+	pool *x509.CertPool
+}
+
+// NewClientCAs loads the PEM-encoded CA bundle in path, and reloads it when it changes until ctx is done. A bundle
+// which can't be loaded is logged and the previous one is kept.
+func NewClientCAs(ctx context.Context, path string) (*ClientCAs, error) {
+	c := &ClientCAs{}
+	if err := configfile.Watch(ctx, path, "client CAs", clientCAsReloadInterval, c.load); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// load replaces the CAs with the ones of a PEM-encoded bundle
+func (c *ClientCAs) load(content []byte) error {
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(content) {
+		return fmt.Errorf("no certificates found")
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.pool = pool
+	return nil
+}
+
+// Pool returns the current CAs
+func (c *ClientCAs) Pool() *x509.CertPool {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.pool
+}
+
+// ConfigureTLS makes servers using config request client certificates, and verify the ones given against the
+// current CAs. Clients without certificates are still accepted, so that they can authenticate otherwise.
+func (c *ClientCAs) ConfigureTLS(config *tls.Config) {
+	config.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+		// config is cloned on each handshake, as its certificates may be set or rotated after it is configured
+		clientConfig := config.Clone()
+		clientConfig.GetConfigForClient = nil
+		clientConfig.ClientAuth = tls.VerifyClientCertIfGiven
+		clientConfig.ClientCAs = c.Pool()
+		return clientConfig, nil
+	}
+}
+
+// NewX509Authenticator returns an Authenticator mapping the client certificates signed by cas to users, named after
+// their common name and with their organizations as groups. Requests without client certificates are left to other
+// authenticators.
+func NewX509Authenticator(cas *ClientCAs) Authenticator {
+	return AuthenticatorFunc(func(req *http.Request) (user.Info, bool, error) {
+		if req.TLS == nil || len(req.TLS.PeerCertificates) == 0 {
+			return nil, false, nil
+		}
+		// certificates are verified again, in case the listener doesn't verify them or the CAs changed since the
+		// connection was established
+		certificate := req.TLS.PeerCertificates[0]
+		options := x509.VerifyOptions{
+			Roots:         cas.Pool(),
+			Intermediates: x509.NewCertPool(),
+			KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		}
+		for _, intermediate := range req.TLS.PeerCertificates[1:] {
+			options.Intermediates.AddCert(intermediate)
+		}
+		if _, err := certificate.Verify(options); err != nil {
+			return nil, false, fmt.Errorf("verifying client certificate %q: %w", certificate.Subject.CommonName, err)
+		}
+		if certificate.Subject.CommonName == "" {
+			return nil, false, fmt.Errorf("client certificate has no common name")
+		}
+		return &user.DefaultInfo{
+			Name:   certificate.Subject.CommonName,
+			Groups: certificate.Subject.Organization,
+		}, true, nil
+	})
+}
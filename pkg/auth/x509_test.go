@@ -0,0 +1,169 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apiserver/pkg/authentication/user"
+)
+
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+func newTestCA(t *testing.T, name string) testCA {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return testCA{cert: cert, key: key, pem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+func (ca testCA) issue(t *testing.T, subject pkix.Name, usage x509.ExtKeyUsage) tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      subject,
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	require.NoError(t, err)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+func TestX509Authenticator(t *testing.T) {
+	ca, otherCA := newTestCA(t, "clients"), newTestCA(t, "others")
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	require.NoError(t, os.WriteFile(caFile, ca.pem, 0o600))
+	cas, err := NewClientCAs(t.Context(), caFile)
+	require.NoError(t, err)
+
+	type result struct {
+		info user.Info
+		ok   bool
+		err  error
+	}
+	results := make(chan result, 1)
+	authenticator := NewX509Authenticator(cas)
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(_ http.ResponseWriter, req *http.Request) {
+		info, ok, err := authenticator.Authenticate(req)
+		results <- result{info: info, ok: ok, err: err}
+	}))
+	server.StartTLS()
+	// StartTLS sets the certificate on a copy of the config, which the listener uses
+	cas.ConfigureTLS(server.TLS)
+	defer server.Close()
+
+	request := func(certificates ...tls.Certificate) (result, error) {
+		transport := server.Client().Transport.(*http.Transport).Clone()
+		transport.TLSClientConfig.Certificates = certificates
+		resp, err := (&http.Client{Transport: transport}).Get(server.URL)
+		if err != nil {
+			return result{}, err
+		}
+		resp.Body.Close()
+		return <-results, nil
+	}
+
+	alice := ca.issue(t, pkix.Name{CommonName: "alice", Organization: []string{"devs", "ops"}}, x509.ExtKeyUsageClientAuth)
+	got, err := request(alice)
+	require.NoError(t, err)
+	require.NoError(t, got.err)
+	assert.True(t, got.ok)
+	assert.Equal(t, "alice", got.info.GetName())
+	assert.ElementsMatch(t, []string{"devs", "ops"}, got.info.GetGroups())
+
+	got, err = request()
+	require.NoError(t, err)
+	assert.NoError(t, got.err)
+	assert.False(t, got.ok, "clients without certificates are left to other authenticators")
+
+	// the listener only accepts certificates of the CAs, so clients don't send others
+	got, err = request(otherCA.issue(t, pkix.Name{CommonName: "mallory"}, x509.ExtKeyUsageClientAuth))
+	require.NoError(t, err)
+	assert.NoError(t, got.err)
+	assert.False(t, got.ok)
+
+	got, err = request(ca.issue(t, pkix.Name{Organization: []string{"devs"}}, x509.ExtKeyUsageClientAuth))
+	require.NoError(t, err)
+	assert.ErrorContains(t, got.err, "no common name")
+
+	// the CAs are reloaded without restarting the listener
+	require.NoError(t, cas.load(otherCA.pem))
+	got, err = request(otherCA.issue(t, pkix.Name{CommonName: "bob"}, x509.ExtKeyUsageClientAuth))
+	require.NoError(t, err)
+	require.NoError(t, got.err)
+	assert.Equal(t, "bob", got.info.GetName())
+	got, err = request(alice)
+	require.NoError(t, err)
+	assert.False(t, got.ok)
+
+	// invalid bundles keep the previous CAs
+	assert.Error(t, cas.load([]byte("not a certificate")))
+	assert.NotNil(t, cas.Pool())
+}
+
+func TestX509AuthenticatorVerifies(t *testing.T) {
+	ca, otherCA := newTestCA(t, "clients"), newTestCA(t, "others")
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	require.NoError(t, os.WriteFile(caFile, ca.pem, 0o600))
+	cas, err := NewClientCAs(t.Context(), caFile)
+	require.NoError(t, err)
+	authenticator := NewX509Authenticator(cas)
+
+	authenticate := func(certificate tls.Certificate) (user.Info, bool, error) {
+		parsed, err := x509.ParseCertificate(certificate.Certificate[0])
+		require.NoError(t, err)
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{parsed}}
+		return authenticator.Authenticate(req)
+	}
+
+	// listeners may not verify certificates themselves
+	_, ok, err := authenticate(otherCA.issue(t, pkix.Name{CommonName: "mallory"}, x509.ExtKeyUsageClientAuth))
+	assert.Error(t, err)
+	assert.False(t, ok)
+
+	_, ok, err = authenticate(ca.issue(t, pkix.Name{CommonName: "server"}, x509.ExtKeyUsageServerAuth))
+	assert.Error(t, err, "certificates must allow client authentication")
+	assert.False(t, ok)
+
+	info, ok, err := authenticate(ca.issue(t, pkix.Name{CommonName: "alice"}, x509.ExtKeyUsageClientAuth))
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "alice", info.GetName())
+
+	_, err = NewClientCAs(t.Context(), filepath.Join(t.TempDir(), "missing.pem"))
+	assert.Error(t, err)
+}
@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/rancher/apiserver/pkg/types"
+	"github.com/rancher/steve/pkg/accesscontrol"
 	"github.com/rancher/steve/pkg/attributes"
 	utilnet "k8s.io/apimachinery/pkg/util/net"
 	"k8s.io/apiserver/pkg/endpoints/request"
@@ -210,8 +211,10 @@ func setupConfig(ctx *types.APIRequest, cfg *rest.Config, impersonate bool) (*re
 		if !ok {
 			return nil, fmt.Errorf("user not found for impersonation")
 		}
+		user = accesscontrol.AuthenticatedUser(user)
 		cfg = rest.CopyConfig(cfg)
 		cfg.Impersonate.UserName = user.GetName()
+		cfg.Impersonate.UID = user.GetUID()
 		cfg.Impersonate.Groups = user.GetGroups()
 		cfg.Impersonate.Extra = user.GetExtra()
 	}
@@ -1,9 +1,16 @@
 package client
 
 import (
+	"net/http"
+	"net/http/httptest"
 	"testing"
 
+	"github.com/rancher/apiserver/pkg/types"
+	"github.com/rancher/steve/pkg/accesscontrol"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/apiserver/pkg/endpoints/request"
 	"k8s.io/client-go/rest"
 )
 
@@ -30,3 +37,42 @@ func TestNewFactoryWithOptions(t *testing.T) {
 	assert.Equal(t, float32(50.0), f.clientCfg.QPS)
 	assert.Equal(t, 20, f.clientCfg.Burst)
 }
+
+func TestSetupConfigImpersonation(t *testing.T) {
+	info := &user.DefaultInfo{Name: "alice", UID: "1234", Groups: []string{"devs"}, Extra: map[string][]string{"scopes": {"read"}}}
+	req := httptest.NewRequest(http.MethodGet, "/v1/pods", nil)
+	apiOp := &types.APIRequest{Request: req.WithContext(request.WithUser(req.Context(), info))}
+	cfg := &rest.Config{Host: "https://localhost"}
+
+	impersonating, err := setupConfig(apiOp, cfg, true)
+	require.NoError(t, err)
+	assert.Equal(t, rest.ImpersonationConfig{
+		UserName: "alice",
+		UID:      "1234",
+		Groups:   []string{"devs"},
+		Extra:    map[string][]string{"scopes": {"read"}},
+	}, impersonating.Impersonate)
+	assert.Empty(t, cfg.Impersonate, "the shared config is left unchanged")
+
+	apiOp = &types.APIRequest{Request: req.WithContext(request.WithUser(req.Context(), resolvedUser(t, info)))}
+	impersonating, err = setupConfig(apiOp, cfg, true)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"devs"}, impersonating.Impersonate.Groups, "resolved groups aren't impersonated")
+
+	admin, err := setupConfig(apiOp, cfg, false)
+	require.NoError(t, err)
+	assert.Same(t, cfg, admin)
+}
+
+// resolvedUser returns info as GroupResolverMiddleware puts it in requests, with the devs group mapped to admins
+func resolvedUser(t *testing.T, info user.Info) user.Info {
+	resolver := accesscontrol.NewMappingGroupResolver(accesscontrol.GroupMapping{Groups: map[string][]string{"devs": {"admins"}}})
+	var resolved user.Info
+	handler := accesscontrol.GroupResolverMiddleware(resolver)(http.HandlerFunc(func(_ http.ResponseWriter, req *http.Request) {
+		resolved, _ = request.UserFrom(req.Context())
+	}))
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	handler.ServeHTTP(httptest.NewRecorder(), req.WithContext(request.WithUser(req.Context(), info)))
+	require.Equal(t, []string{"admins", "devs"}, resolved.GetGroups())
+	return resolved
+}
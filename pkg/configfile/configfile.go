@@ -0,0 +1,35 @@
+// Package configfile loads, and watches for changes, the configuration files that enable optional features.
+package configfile
+
+import (
+	"fmt"
+	"os"
+
+	"sigs.k8s.io/yaml"
+)
+
+// Load reads the file at path into a T, rejecting unknown fields, and checks it with validate if it is not nil.
+// name describes the configuration in errors, eg. "audit config".
+func Load[T any](path, name string, validate func(T) error) (T, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		var config T
+		return config, fmt.Errorf("reading %s: %w", name, err)
+	}
+	return Parse(content, name+" "+path, validate)
+}
+
+// Parse decodes the YAML or JSON content into a T as Load does, for configurations which are not read from a file
+// path, eg. the ones in ConfigMaps or reloaded with Watch
+func Parse[T any](content []byte, name string, validate func(T) error) (T, error) {
+	var config T
+	if err := yaml.UnmarshalStrict(content, &config); err != nil {
+		return config, fmt.Errorf("parsing %s: %w", name, err)
+	}
+	if validate != nil {
+		if err := validate(config); err != nil {
+			return config, fmt.Errorf("invalid %s: %w", name, err)
+		}
+	}
+	return config, nil
+}
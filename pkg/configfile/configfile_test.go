@@ -0,0 +1,92 @@
+package configfile
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testConfig struct {
+	Name string `json:"name"`
+}
+
+func validateTestConfig(c testConfig) error {
+	if c.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	return nil
+}
+
+func writeFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func TestLoad(t *testing.T) {
+	config, err := Load(writeFile(t, "name: foo\n"), "test config", validateTestConfig)
+	require.NoError(t, err)
+	assert.Equal(t, testConfig{Name: "foo"}, config)
+
+	config, err = Load(writeFile(t, `{"name": "bar"}`), "test config", validateTestConfig)
+	require.NoError(t, err)
+	assert.Equal(t, testConfig{Name: "bar"}, config)
+
+	_, err = Load[testConfig](writeFile(t, ""), "test config", nil)
+	assert.NoError(t, err)
+}
+
+func TestLoadErrors(t *testing.T) {
+	_, err := Load(filepath.Join(t.TempDir(), "missing.yaml"), "test config", validateTestConfig)
+	assert.ErrorIs(t, err, os.ErrNotExist)
+	assert.ErrorContains(t, err, "reading test config")
+
+	_, err = Load(writeFile(t, "name: foo\nunknown: true\n"), "test config", validateTestConfig)
+	assert.ErrorContains(t, err, "parsing test config")
+
+	_, err = Load(writeFile(t, "name: \"\"\n"), "test config", validateTestConfig)
+	assert.ErrorContains(t, err, "invalid test config")
+	assert.ErrorContains(t, err, "name is required")
+}
+
+func TestWatch(t *testing.T) {
+	path := writeFile(t, "first")
+	loaded := make(chan string, 10)
+	load := func(content []byte) error {
+		// the file is empty while it is being written
+		if string(content) == "invalid" || len(content) == 0 {
+			return fmt.Errorf("invalid content")
+		}
+		loaded <- string(content)
+		return nil
+	}
+	require.NoError(t, Watch(t.Context(), path, "test file", 10*time.Millisecond, load))
+	assert.Equal(t, "first", <-loaded)
+
+	// invalid content is retried until the file changes again
+	require.NoError(t, os.WriteFile(path, []byte("invalid"), 0o600))
+	time.Sleep(50 * time.Millisecond)
+	require.NoError(t, os.WriteFile(path, []byte("second"), 0o600))
+	select {
+	case content := <-loaded:
+		assert.Equal(t, "second", content)
+	case <-time.After(time.Second):
+		t.Fatal("the file was not reloaded")
+	}
+	select {
+	case content := <-loaded:
+		t.Fatalf("unchanged content %q was loaded again", content)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	err := Watch(t.Context(), writeFile(t, "invalid"), "test file", time.Second, load)
+	assert.ErrorContains(t, err, "loading test file")
+	err = Watch(t.Context(), filepath.Join(t.TempDir(), "missing"), "test file", time.Second, load)
+	assert.ErrorContains(t, err, "reading test file")
+}
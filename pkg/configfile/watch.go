@@ -0,0 +1,51 @@
+package configfile
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Watch calls load with the content of the file at path, then checks the file every interval until ctx is done and
+// calls load again whenever its content changed. It returns the error of the first read or load, in which case the
+// file is not watched. Later errors are logged and the content is read again on the next check, so that a file which
+// was being written when it was read is eventually loaded. name describes the file in errors, eg. "client CAs".
+func Watch(ctx context.Context, path, name string, interval time.Duration, load func(content []byte) error) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("reading %s: %w", name, err)
+	}
+	if err := load(content); err != nil {
+		return fmt.Errorf("loading %s %s: %w", name, path, err)
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			newContent, err := os.ReadFile(path)
+			if err != nil {
+				logrus.Errorf("reloading %s: %v", name, err)
+				continue
+			}
+			if bytes.Equal(newContent, content) {
+				continue
+			}
+			if err := load(newContent); err != nil {
+				logrus.Errorf("reloading %s %s: %v", name, path, err)
+				continue
+			}
+			content = newContent
+			logrus.Infof("reloaded %s from %s", name, path)
+		}
+	}()
+	return nil
+}
@@ -0,0 +1,64 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	resultLabel = "result"
+	reasonLabel = "reason"
+)
+
+// Results and eviction reasons used to label AccessSet cache metrics
+const (
+	AccessSetCacheHit  = "hit"
+	AccessSetCacheMiss = "miss"
+
+	AccessSetCacheEvictedCapacity    = "capacity"
+	AccessSetCacheEvictedExpired     = "expired"
+	AccessSetCacheEvictedInvalidated = "invalidated"
+)
+
+var (
+	AccessSetCacheRequests = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Subsystem: "access_store",
+			Name:      "cache_requests_total",
+			Help:      "Total count of AccessSet cache lookups, by result",
+		},
+		[]string{resultLabel})
+	AccessSetCacheEvictions = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Subsystem: "access_store",
+			Name:      "cache_evictions_total",
+			Help:      "Total count of AccessSets removed from the cache, by reason",
+		},
+		[]string{reasonLabel})
+	AccessSetCacheSize = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Subsystem: "access_store",
+			Name:      "cache_size",
+			Help:      "Number of AccessSets in the cache",
+		})
+)
+
+// IncAccessSetCacheRequests counts one AccessSet cache lookup with the given result
+func IncAccessSetCacheRequests(result string) {
+	if prometheusMetrics {
+		AccessSetCacheRequests.With(prometheus.Labels{resultLabel: result}).Inc()
+	}
+}
+
+// IncAccessSetCacheEvictions counts one AccessSet removed from the cache for the given reason
+func IncAccessSetCacheEvictions(reason string) {
+	if prometheusMetrics {
+		AccessSetCacheEvictions.With(prometheus.Labels{reasonLabel: reason}).Inc()
+	}
+}
+
+// SetAccessSetCacheSize records the number of AccessSets currently in the cache
+func SetAccessSetCacheSize(size int) {
+	if prometheusMetrics {
+		AccessSetCacheSize.Set(float64(size))
+	}
+}
@@ -0,0 +1,24 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const sinkLabel = "sink"
+
+var (
+	AuditEventsDropped = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Subsystem: "audit",
+			Name:      "events_dropped_total",
+			Help:      "Total count of audit events which could not be written, by sink",
+		},
+		[]string{sinkLabel})
+)
+
+// IncAuditEventsDropped counts events dropped by the given audit sink
+func IncAuditEventsDropped(sink string, count int) {
+	if prometheusMetrics {
+		AuditEventsDropped.With(prometheus.Labels{sinkLabel: sink}).Add(float64(count))
+	}
+}
@@ -0,0 +1,99 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	classLabel = "class"
+	queueLabel = "queue"
+)
+
+var (
+	RateLimitedRequests = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Subsystem: "rate_limit",
+			Name:      "limited_requests_total",
+			Help:      "Total count of requests rejected because their user exceeded the rate limit of their class",
+		},
+		[]string{classLabel})
+	RateLimitRequestsPerSecond = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Subsystem: "rate_limit",
+			Name:      "requests_per_second",
+			Help:      "Requests per second allowed to each user, by request class",
+		},
+		[]string{classLabel})
+	RateLimitBurst = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Subsystem: "rate_limit",
+			Name:      "burst",
+			Help:      "Requests each user can make at once, by request class",
+		},
+		[]string{classLabel})
+	FairQueueConcurrency = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Subsystem: "fair_queue",
+			Name:      "concurrency",
+			Help:      "Number of requests a fair queue runs at once",
+		},
+		[]string{queueLabel})
+	FairQueueActive = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Subsystem: "fair_queue",
+			Name:      "active",
+			Help:      "Number of requests currently run by a fair queue",
+		},
+		[]string{queueLabel})
+	FairQueueDepth = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Subsystem: "fair_queue",
+			Name:      "depth",
+			Help:      "Number of requests waiting in a fair queue",
+		},
+		[]string{queueLabel})
+	FairQueueRejected = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Subsystem: "fair_queue",
+			Name:      "rejected_total",
+			Help:      "Total count of requests rejected because their user's queue was full",
+		},
+		[]string{queueLabel})
+)
+
+// IncRateLimitedRequests counts one request rejected by the rate limit of class
+func IncRateLimitedRequests(class string) {
+	if prometheusMetrics {
+		RateLimitedRequests.With(prometheus.Labels{classLabel: class}).Inc()
+	}
+}
+
+// SetRateLimit records the limit of class
+func SetRateLimit(class string, requestsPerSecond float64, burst int) {
+	if prometheusMetrics {
+		RateLimitRequestsPerSecond.With(prometheus.Labels{classLabel: class}).Set(requestsPerSecond)
+		RateLimitBurst.With(prometheus.Labels{classLabel: class}).Set(float64(burst))
+	}
+}
+
+// SetFairQueueConcurrency records how many requests of queue may run at once
+func SetFairQueueConcurrency(queue string, concurrency int) {
+	if prometheusMetrics {
+		FairQueueConcurrency.With(prometheus.Labels{queueLabel: queue}).Set(float64(concurrency))
+	}
+}
+
+// SetFairQueueState records the running and waiting requests of queue
+func SetFairQueueState(queue string, active, depth int) {
+	if prometheusMetrics {
+		FairQueueActive.With(prometheus.Labels{queueLabel: queue}).Set(float64(active))
+		FairQueueDepth.With(prometheus.Labels{queueLabel: queue}).Set(float64(depth))
+	}
+}
+
+// IncFairQueueRejected counts one request rejected by queue because too many requests of its user were waiting
+func IncFairQueueRejected(queue string) {
+	if prometheusMetrics {
+		FairQueueRejected.With(prometheus.Labels{queueLabel: queue}).Inc()
+	}
+}
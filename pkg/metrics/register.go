@@ -14,5 +14,26 @@ func init() {
 		prometheus.MustRegister(ProxyTotalResponses)
 		prometheus.MustRegister(K8sClientResponseTime)
 		prometheus.MustRegister(ProxyStoreResponseTime)
+		prometheus.MustRegister(SQLCacheQueryTime)
+		prometheus.MustRegister(SQLCacheRowsReturned)
+		prometheus.MustRegister(SQLCacheTransactionRetries)
+		prometheus.MustRegister(SQLCacheDBFileSize)
+		prometheus.MustRegister(SQLCacheWALFileSize)
+		prometheus.MustRegister(SQLCacheEventLogDrops)
+		prometheus.MustRegister(SQLCacheEventLogReplays)
+		prometheus.MustRegister(SQLCacheInformerResyncs)
+		prometheus.MustRegister(SQLCacheSyntheticWatchPollTime)
+		prometheus.MustRegister(SQLCacheSyntheticWatchChanges)
+		prometheus.MustRegister(AccessSetCacheRequests)
+		prometheus.MustRegister(AccessSetCacheEvictions)
+		prometheus.MustRegister(AccessSetCacheSize)
+		prometheus.MustRegister(AuditEventsDropped)
+		prometheus.MustRegister(RateLimitedRequests)
+		prometheus.MustRegister(RateLimitRequestsPerSecond)
+		prometheus.MustRegister(RateLimitBurst)
+		prometheus.MustRegister(FairQueueConcurrency)
+		prometheus.MustRegister(FairQueueActive)
+		prometheus.MustRegister(FairQueueDepth)
+		prometheus.MustRegister(FairQueueRejected)
 	}
 }
@@ -0,0 +1,151 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	gvkLabel       = "gvk"
+	queryLabel     = "query"
+	eventTypeLabel = "type"
+)
+
+// Query shapes used to label SQL cache query metrics. This is a closed set so that
+// the cardinality of the query label stays bounded.
+const (
+	SQLCacheQueryList    = "list"
+	SQLCacheQuerySummary = "summary"
+	SQLCacheQueryAugment = "augment"
+)
+
+var (
+	SQLCacheQueryTime = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Subsystem: "sql_cache",
+			Name:      "query_time",
+			Help:      "Query times in ms for the SQL cache, by GVK and query shape",
+			Buckets:   prometheus.ExponentialBuckets(1, 2, 14),
+		},
+		[]string{gvkLabel, queryLabel})
+	SQLCacheRowsReturned = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Subsystem: "sql_cache",
+			Name:      "rows_returned",
+			Help:      "Number of rows returned by SQL cache queries, by GVK and query shape",
+			Buckets:   prometheus.ExponentialBuckets(1, 4, 10),
+		},
+		[]string{gvkLabel, queryLabel})
+	SQLCacheTransactionRetries = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Subsystem: "sql_cache",
+			Name:      "transaction_retries_total",
+			Help:      "Total count of write transactions retried because the database was busy",
+		})
+	SQLCacheDBFileSize = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Subsystem: "sql_cache",
+			Name:      "db_file_size_bytes",
+			Help:      "Size in bytes of the SQL cache database file",
+		})
+	SQLCacheWALFileSize = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Subsystem: "sql_cache",
+			Name:      "wal_file_size_bytes",
+			Help:      "Size in bytes of the SQL cache write-ahead log file",
+		})
+	SQLCacheEventLogDrops = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Subsystem: "sql_cache",
+			Name:      "event_log_drops_total",
+			Help:      "Total count of watches dropped because they fell behind the event log past its oldest stored event",
+		},
+		[]string{gvkLabel})
+	SQLCacheEventLogReplays = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Subsystem: "sql_cache",
+			Name:      "event_log_replays_total",
+			Help:      "Total count of watches which fell behind the events kept in memory and were replayed from the database",
+		},
+		[]string{gvkLabel})
+	SQLCacheInformerResyncs = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Subsystem: "sql_cache",
+			Name:      "informer_resyncs_total",
+			Help:      "Total count of full relists replacing the contents of an SQL cache informer",
+		},
+		[]string{gvkLabel})
+	SQLCacheSyntheticWatchPollTime = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Subsystem: "sql_cache",
+			Name:      "synthetic_watch_poll_time",
+			Help:      "Times in ms to list and compare objects of resources polled by synthetic watches, by GVK",
+			Buckets:   prometheus.ExponentialBuckets(1, 2, 14),
+		},
+		[]string{gvkLabel})
+	SQLCacheSyntheticWatchChanges = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Subsystem: "sql_cache",
+			Name:      "synthetic_watch_changes_total",
+			Help:      "Total count of changes detected by synthetic watches, by GVK and event type",
+		},
+		[]string{gvkLabel, eventTypeLabel})
+)
+
+// RecordSQLCacheQuery records the duration in ms and the number of rows returned by a query
+// of the given shape against the cache for gvk
+func RecordSQLCacheQuery(gvk, query string, val float64, rows int) {
+	if prometheusMetrics {
+		labels := prometheus.Labels{
+			gvkLabel:   gvk,
+			queryLabel: query,
+		}
+		SQLCacheQueryTime.With(labels).Observe(val)
+		SQLCacheRowsReturned.With(labels).Observe(float64(rows))
+	}
+}
+
+func IncSQLCacheTransactionRetries() {
+	if prometheusMetrics {
+		SQLCacheTransactionRetries.Inc()
+	}
+}
+
+// SetSQLCacheFileSizes records the current size of the database and WAL files
+func SetSQLCacheFileSizes(dbSize, walSize int64) {
+	if prometheusMetrics {
+		SQLCacheDBFileSize.Set(float64(dbSize))
+		SQLCacheWALFileSize.Set(float64(walSize))
+	}
+}
+
+func IncSQLCacheEventLogDrops(gvk string) {
+	if prometheusMetrics {
+		SQLCacheEventLogDrops.With(prometheus.Labels{gvkLabel: gvk}).Inc()
+	}
+}
+
+func IncSQLCacheEventLogReplays(gvk string) {
+	if prometheusMetrics {
+		SQLCacheEventLogReplays.With(prometheus.Labels{gvkLabel: gvk}).Inc()
+	}
+}
+
+func IncSQLCacheInformerResyncs(gvk string) {
+	if prometheusMetrics {
+		SQLCacheInformerResyncs.With(prometheus.Labels{gvkLabel: gvk}).Inc()
+	}
+}
+
+// RecordSQLCacheSyntheticWatchPoll records the duration in ms of one poll of a synthetic watch for gvk
+func RecordSQLCacheSyntheticWatchPoll(gvk string, val float64) {
+	if prometheusMetrics {
+		SQLCacheSyntheticWatchPollTime.With(prometheus.Labels{gvkLabel: gvk}).Observe(val)
+	}
+}
+
+// AddSQLCacheSyntheticWatchChanges counts changes of the given event type detected by a synthetic watch for gvk
+func AddSQLCacheSyntheticWatchChanges(gvk, eventType string, count int) {
+	if prometheusMetrics && count > 0 {
+		SQLCacheSyntheticWatchChanges.With(prometheus.Labels{gvkLabel: gvk, eventTypeLabel: eventType}).Add(float64(count))
+	}
+}
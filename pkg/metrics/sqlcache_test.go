@@ -0,0 +1,66 @@
+package metrics
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+// enablePrometheusMetrics turns on the metric helpers for the duration of a test
+func enablePrometheusMetrics(t *testing.T) {
+	previous := prometheusMetrics
+	prometheusMetrics = true
+	t.Cleanup(func() { prometheusMetrics = previous })
+}
+
+func TestSQLCacheMetricsDisabled(t *testing.T) {
+	IncSQLCacheEventLogDrops("disabled")
+	IncSQLCacheInformerResyncs("disabled")
+	assert.Zero(t, testutil.ToFloat64(SQLCacheEventLogDrops.With(prometheus.Labels{gvkLabel: "disabled"})))
+	assert.Zero(t, testutil.ToFloat64(SQLCacheInformerResyncs.With(prometheus.Labels{gvkLabel: "disabled"})))
+}
+
+func TestSQLCacheMetrics(t *testing.T) {
+	enablePrometheusMetrics(t)
+	// the sanitized informer name of core v1 Pods, as set by the SQL cache
+	const gvk = "_v1_Pod"
+
+	RecordSQLCacheQuery(gvk, SQLCacheQueryList, 12, 3)
+	labels := prometheus.Labels{gvkLabel: gvk, queryLabel: SQLCacheQueryList}
+	assert.Equal(t, 1, testutil.CollectAndCount(SQLCacheQueryTime))
+	assert.Equal(t, 1, testutil.CollectAndCount(SQLCacheRowsReturned))
+	assert.NotNil(t, SQLCacheQueryTime.With(labels))
+
+	retries := testutil.ToFloat64(SQLCacheTransactionRetries)
+	IncSQLCacheTransactionRetries()
+	assert.Equal(t, retries+1, testutil.ToFloat64(SQLCacheTransactionRetries))
+
+	SetSQLCacheFileSizes(4096, 1024)
+	assert.Equal(t, float64(4096), testutil.ToFloat64(SQLCacheDBFileSize))
+	assert.Equal(t, float64(1024), testutil.ToFloat64(SQLCacheWALFileSize))
+
+	IncSQLCacheEventLogDrops(gvk)
+	IncSQLCacheEventLogReplays(gvk)
+	IncSQLCacheEventLogReplays(gvk)
+	IncSQLCacheInformerResyncs(gvk)
+	IncSQLCacheInformerResyncs(gvk)
+	assert.Equal(t, float64(1), testutil.ToFloat64(SQLCacheEventLogDrops.With(prometheus.Labels{gvkLabel: gvk})))
+	assert.Equal(t, float64(2), testutil.ToFloat64(SQLCacheEventLogReplays.With(prometheus.Labels{gvkLabel: gvk})))
+	assert.Equal(t, float64(2), testutil.ToFloat64(SQLCacheInformerResyncs.With(prometheus.Labels{gvkLabel: gvk})))
+
+}
+
+func TestSQLCacheSyntheticWatchMetrics(t *testing.T) {
+	enablePrometheusMetrics(t)
+	const gvk = "_v1_Secret"
+
+	RecordSQLCacheSyntheticWatchPoll(gvk, 5)
+	assert.Equal(t, 1, testutil.CollectAndCount(SQLCacheSyntheticWatchPollTime))
+
+	AddSQLCacheSyntheticWatchChanges(gvk, "added", 0)
+	AddSQLCacheSyntheticWatchChanges(gvk, "modified", 3)
+	assert.Equal(t, 1, testutil.CollectAndCount(SQLCacheSyntheticWatchChanges), "empty changes are not recorded")
+	assert.Equal(t, float64(3), testutil.ToFloat64(SQLCacheSyntheticWatchChanges.With(prometheus.Labels{gvkLabel: gvk, eventTypeLabel: "modified"})))
+}
@@ -5,6 +5,7 @@ import (
 	"net/url"
 	"strings"
 
+	"github.com/rancher/steve/pkg/accesscontrol"
 	"github.com/sirupsen/logrus"
 	"k8s.io/apimachinery/pkg/util/proxy"
 	"k8s.io/apiserver/pkg/authentication/user"
@@ -20,6 +21,7 @@ func ImpersonatingHandler(prefix string, cfg *rest.Config) http.Handler {
 }
 
 func setupUserAuth(req *http.Request, user user.Info, cfg *rest.Config) (*rest.Config, bool) {
+	user = accesscontrol.AuthenticatedUser(user)
 	authed := true
 	for _, group := range user.GetGroups() {
 		if group == "system:unauthenticated" && strings.HasPrefix(req.Header.Get("Authorization"), "Bearer ") {
@@ -42,6 +44,7 @@ func setupUserAuth(req *http.Request, user user.Info, cfg *rest.Config) (*rest.C
 
 	cfg = rest.CopyConfig(cfg)
 	cfg.Impersonate.UserName = user.GetName()
+	cfg.Impersonate.UID = user.GetUID()
 	cfg.Impersonate.Groups = user.GetGroups()
 	cfg.Impersonate.Extra = user.GetExtra()
 	return cfg, authed
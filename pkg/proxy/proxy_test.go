@@ -0,0 +1,49 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/rancher/steve/pkg/accesscontrol"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/apiserver/pkg/endpoints/request"
+	"k8s.io/client-go/rest"
+)
+
+func TestSetupUserAuth(t *testing.T) {
+	info := &user.DefaultInfo{Name: "alice", UID: "1234", Groups: []string{"devs"}, Extra: map[string][]string{"scopes": {"read"}}}
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/pods", nil)
+	cfg := &rest.Config{Host: "https://localhost"}
+
+	impersonating, authed := setupUserAuth(req, info, cfg)
+	assert.True(t, authed)
+	assert.Equal(t, rest.ImpersonationConfig{
+		UserName: "alice",
+		UID:      "1234",
+		Groups:   []string{"devs"},
+		Extra:    map[string][]string{"scopes": {"read"}},
+	}, impersonating.Impersonate)
+	assert.Empty(t, cfg.Impersonate, "the shared config is left unchanged")
+
+	impersonating, _ = setupUserAuth(req, resolvedUser(t, info), cfg)
+	assert.Equal(t, []string{"devs"}, impersonating.Impersonate.Groups, "resolved groups aren't impersonated")
+
+	_, authed = setupUserAuth(req, &user.DefaultInfo{Name: "system:anonymous", Groups: []string{"system:unauthenticated"}}, cfg)
+	assert.False(t, authed)
+}
+
+// resolvedUser returns info as GroupResolverMiddleware puts it in requests, with the devs group mapped to admins
+func resolvedUser(t *testing.T, info user.Info) user.Info {
+	resolver := accesscontrol.NewMappingGroupResolver(accesscontrol.GroupMapping{Groups: map[string][]string{"devs": {"admins"}}})
+	var resolved user.Info
+	handler := accesscontrol.GroupResolverMiddleware(resolver)(http.HandlerFunc(func(_ http.ResponseWriter, req *http.Request) {
+		resolved, _ = request.UserFrom(req.Context())
+	}))
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	handler.ServeHTTP(httptest.NewRecorder(), req.WithContext(request.WithUser(req.Context(), info)))
+	require.Equal(t, []string{"admins", "devs"}, resolved.GetGroups())
+	return resolved
+}
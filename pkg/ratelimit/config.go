@@ -0,0 +1,117 @@
+// Package ratelimit protects the server from users sending more requests than their share, by rate limiting them per
+// class of request and by queuing the SQL cache queries of all users fairly.
+package ratelimit
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/rancher/steve/pkg/configfile"
+)
+
+// ConfigEnvVar is the path to a YAML or JSON file containing the Config of rate limits
+const ConfigEnvVar = "CATTLE_RATE_LIMITS"
+
+const (
+	// defaultMaxUsers is how many users have their buckets kept by default
+	defaultMaxUsers = 10000
+	// defaultQueueLengthPerUser is how many SQL cache queries of a user wait by default
+	defaultQueueLengthPerUser = 10
+)
+
+// Class is a kind of request rate limited separately
+type Class string
+
+const (
+	// ClassList is /v1 reads: lists and gets
+	ClassList Class = "list"
+	// ClassWatch is /v1/subscribe websockets
+	ClassWatch Class = "watch"
+	// ClassMutation is /v1 creates, updates, patches, deletes and actions
+	ClassMutation Class = "mutation"
+	// ClassProxy is requests proxied to the Kubernetes API server
+	ClassProxy Class = "proxy"
+)
+
+var classes = []Class{ClassList, ClassWatch, ClassMutation, ClassProxy}
+
+// Config configures the rate limits of requests and the queue of SQL cache queries
+type Config struct {
+	// Limits are the token buckets of each user for each class. Classes without limits are not limited.
+	Limits map[Class]Limit `json:"limits,omitempty"`
+	// ExemptGroups are groups whose users are never rate limited, such as system:masters
+	ExemptGroups []string `json:"exemptGroups,omitempty"`
+	// MaxUsers is how many users have their buckets kept, the least recently seen ones being forgotten. 10000 by
+	// default.
+	MaxUsers int `json:"maxUsers,omitempty"`
+	// SQLQueries queues the SQL cache queries fairly between users, if set
+	SQLQueries *QueueConfig `json:"sqlQueries,omitempty"`
+}
+
+// Limit is a token bucket, refilled with RequestsPerSecond tokens per second and holding up to Burst tokens
+type Limit struct {
+	RequestsPerSecond float64 `json:"requestsPerSecond"`
+	Burst             int     `json:"burst"`
+}
+
+// QueueConfig configures a FairQueue
+type QueueConfig struct {
+	// Concurrency is how many queries run at once
+	Concurrency int `json:"concurrency"`
+	// QueueLengthPerUser is how many queries of a single user wait before new ones are rejected, 10 by default
+	QueueLengthPerUser int `json:"queueLengthPerUser,omitempty"`
+}
+
+// LoadConfig reads the rate limits in a YAML or JSON file, rejecting unknown classes, limits which allow no requests
+// and SQL query queues which run no queries
+func LoadConfig(path string) (Config, error) {
+	return configfile.Load(path, "rate limits", Config.validate)
+}
+
+// ConfigFromEnv returns the Config in the file of ConfigEnvVar, or nil if it is not set
+func ConfigFromEnv() (*Config, error) {
+	path := os.Getenv(ConfigEnvVar)
+	if path == "" {
+		return nil, nil
+	}
+	config, err := LoadConfig(path)
+	if err != nil {
+		return nil, err
+	}
+	return &config, nil
+}
+
+func (c Config) validate() error {
+	for class, limit := range c.Limits {
+		if !class.valid() {
+			return fmt.Errorf("unknown class %q", class)
+		}
+		if limit.RequestsPerSecond <= 0 {
+			return fmt.Errorf("%s: requestsPerSecond must be positive", class)
+		}
+		if limit.Burst < 1 {
+			return fmt.Errorf("%s: burst must be at least 1", class)
+		}
+	}
+	if c.MaxUsers < 0 {
+		return fmt.Errorf("maxUsers must not be negative")
+	}
+	if c.SQLQueries != nil {
+		if c.SQLQueries.Concurrency < 1 {
+			return fmt.Errorf("sqlQueries: concurrency must be at least 1")
+		}
+		if c.SQLQueries.QueueLengthPerUser < 0 {
+			return fmt.Errorf("sqlQueries: queueLengthPerUser must not be negative")
+		}
+	}
+	return nil
+}
+
+func (c Class) valid() bool {
+	for _, class := range classes {
+		if c == class {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,87 @@
+package ratelimit
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    Config
+		wantErr bool
+	}{
+		{
+			name: "valid",
+			content: `
+exemptGroups: [system:masters]
+limits:
+  list: {requestsPerSecond: 5, burst: 20}
+  proxy: {requestsPerSecond: 0.5, burst: 1}
+sqlQueries:
+  concurrency: 4
+`,
+			want: Config{
+				ExemptGroups: []string{"system:masters"},
+				Limits: map[Class]Limit{
+					ClassList:  {RequestsPerSecond: 5, Burst: 20},
+					ClassProxy: {RequestsPerSecond: 0.5, Burst: 1},
+				},
+				SQLQueries: &QueueConfig{Concurrency: 4},
+			},
+		},
+		{
+			name:    "unknown class",
+			content: "limits: {delete: {requestsPerSecond: 1, burst: 1}}",
+			wantErr: true,
+		},
+		{
+			name:    "zero rate",
+			content: "limits: {list: {burst: 1}}",
+			wantErr: true,
+		},
+		{
+			name:    "zero burst",
+			content: "limits: {watch: {requestsPerSecond: 1}}",
+			wantErr: true,
+		},
+		{
+			name:    "zero concurrency",
+			content: "sqlQueries: {queueLengthPerUser: 5}",
+			wantErr: true,
+		},
+		{
+			name:    "unknown field",
+			content: "limit: {}",
+			wantErr: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "limits.yaml")
+			require.NoError(t, os.WriteFile(path, []byte(test.content), 0o600))
+			config, err := LoadConfig(path)
+			if test.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.want, config)
+		})
+	}
+}
+
+func TestFromEnvUnset(t *testing.T) {
+	t.Setenv(ConfigEnvVar, "")
+	limiter, err := LimiterFromEnv()
+	require.NoError(t, err)
+	assert.Nil(t, limiter)
+	queue, err := SQLQueueFromEnv()
+	require.NoError(t, err)
+	assert.Nil(t, queue)
+}
@@ -0,0 +1,154 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+
+	"github.com/rancher/steve/pkg/metrics"
+)
+
+// ErrQueueFull is returned by FairQueue.Acquire when too many requests of the same user are already waiting
+var ErrQueueFull = errors.New("too many queued requests")
+
+// FairQueue runs a limited number of requests at once. Waiting requests are queued per user, and users take turns
+// when a slot is released, so that a user sending many requests only delays their own.
+type FairQueue struct {
+	name        string
+	concurrency int
+	queueLength int
+
+	lock   sync.Mutex
+	active int
+	queues map[string][]*waiter
+	// turns are the users with waiting requests, in the order they will be served
+	turns []string
+	depth int
+}
+
+type waiter struct {
+	ready   chan struct{}
+	granted bool
+}
+
+// NewFairQueue returns a FairQueue named name in metrics, running up to config.Concurrency requests at once
+func NewFairQueue(name string, config QueueConfig) *FairQueue {
+	queueLength := config.QueueLengthPerUser
+	if queueLength == 0 {
+		queueLength = defaultQueueLengthPerUser
+	}
+	metrics.SetFairQueueConcurrency(name, config.Concurrency)
+	return &FairQueue{
+		name:        name,
+		concurrency: config.Concurrency,
+		queueLength: queueLength,
+		queues:      map[string][]*waiter{},
+	}
+}
+
+// SQLQueueFromEnv returns the FairQueue of SQL cache queries configured by the file in ConfigEnvVar, or nil if it is
+// not set or doesn't configure one
+func SQLQueueFromEnv() (*FairQueue, error) {
+	config, err := ConfigFromEnv()
+	if err != nil || config == nil || config.SQLQueries == nil {
+		return nil, err
+	}
+	return NewFairQueue("sql", *config.SQLQueries), nil
+}
+
+// Acquire waits for a slot to run a request of user. It returns ErrQueueFull if the queue of user is full, or the
+// error of ctx if it is done first. Otherwise, release must be called once the request is done. A nil FairQueue runs
+// everything at once.
+func (q *FairQueue) Acquire(ctx context.Context, user string) (release func(), err error) {
+	if q == nil {
+		return func() {}, nil
+	}
+	q.lock.Lock()
+	if q.active < q.concurrency && len(q.turns) == 0 {
+		q.active++
+		q.updateMetricsLocked()
+		q.lock.Unlock()
+		return q.releaseFunc(), nil
+	}
+	if len(q.queues[user]) >= q.queueLength {
+		q.lock.Unlock()
+		metrics.IncFairQueueRejected(q.name)
+		return nil, ErrQueueFull
+	}
+	w := &waiter{ready: make(chan struct{})}
+	if len(q.queues[user]) == 0 {
+		q.turns = append(q.turns, user)
+	}
+	q.queues[user] = append(q.queues[user], w)
+	q.depth++
+	q.updateMetricsLocked()
+	q.lock.Unlock()
+
+	select {
+	case <-w.ready:
+		return q.releaseFunc(), nil
+	case <-ctx.Done():
+	}
+
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	if w.granted {
+		// the slot was granted while ctx was done, it is passed on
+		q.active--
+		q.dispatchLocked()
+	} else {
+		q.removeLocked(user, w)
+	}
+	q.updateMetricsLocked()
+	return nil, ctx.Err()
+}
+
+func (q *FairQueue) releaseFunc() func() {
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			q.lock.Lock()
+			defer q.lock.Unlock()
+			q.active--
+			q.dispatchLocked()
+			q.updateMetricsLocked()
+		})
+	}
+}
+
+// dispatchLocked grants free slots to the oldest request of each user in turn
+func (q *FairQueue) dispatchLocked() {
+	for q.active < q.concurrency && len(q.turns) > 0 {
+		user := q.turns[0]
+		q.turns = q.turns[1:]
+		queue := q.queues[user]
+		w := queue[0]
+		if len(queue) > 1 {
+			q.queues[user] = queue[1:]
+			q.turns = append(q.turns, user)
+		} else {
+			delete(q.queues, user)
+		}
+		q.depth--
+		q.active++
+		w.granted = true
+		close(w.ready)
+	}
+}
+
+// removeLocked removes a waiter whose context is done from the queue of user
+func (q *FairQueue) removeLocked(user string, w *waiter) {
+	queue := slices.DeleteFunc(q.queues[user], func(other *waiter) bool { return other == w })
+	q.depth--
+	if len(queue) > 0 {
+		q.queues[user] = queue
+		return
+	}
+	delete(q.queues, user)
+	q.turns = slices.DeleteFunc(q.turns, func(other string) bool { return other == user })
+}
+
+func (q *FairQueue) updateMetricsLocked() {
+	metrics.SetFairQueueState(q.name, q.active, q.depth)
+}
@@ -0,0 +1,88 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFairQueueTakesTurns(t *testing.T) {
+	q := NewFairQueue("test", QueueConfig{Concurrency: 1, QueueLengthPerUser: 3})
+	release, err := q.Acquire(context.Background(), "alice")
+	require.NoError(t, err)
+
+	// alice queues three requests before bob queues one, bob is still served second
+	order := make(chan string, 4)
+	acquire := func(user string) {
+		go func() {
+			release, err := q.Acquire(context.Background(), user)
+			if !assert.NoError(t, err) {
+				return
+			}
+			order <- user
+			release()
+		}()
+	}
+	acquire("alice")
+	waitDepth(t, q, 1)
+	acquire("alice")
+	waitDepth(t, q, 2)
+	acquire("alice")
+	waitDepth(t, q, 3)
+	acquire("bob")
+	waitDepth(t, q, 4)
+
+	_, err = q.Acquire(context.Background(), "alice")
+	assert.ErrorIs(t, err, ErrQueueFull)
+
+	release()
+	var got []string
+	for range 4 {
+		select {
+		case user := <-order:
+			got = append(got, user)
+		case <-time.After(time.Second):
+			t.Fatalf("timed out, got %v", got)
+		}
+	}
+	assert.Equal(t, []string{"alice", "bob", "alice", "alice"}, got)
+}
+
+func TestFairQueueContextDone(t *testing.T) {
+	q := NewFairQueue("test", QueueConfig{Concurrency: 1})
+	release, err := q.Acquire(context.Background(), "alice")
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = q.Acquire(ctx, "bob")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	waitDepth(t, q, 0)
+
+	// the slot is free again once released, released twice or not
+	release()
+	release()
+	release, err = q.Acquire(context.Background(), "bob")
+	require.NoError(t, err)
+	release()
+	assert.Equal(t, 0, q.active)
+}
+
+func TestNilFairQueue(t *testing.T) {
+	var q *FairQueue
+	release, err := q.Acquire(context.Background(), "alice")
+	require.NoError(t, err)
+	release()
+}
+
+func waitDepth(t *testing.T, q *FairQueue, depth int) {
+	t.Helper()
+	require.Eventually(t, func() bool {
+		q.lock.Lock()
+		defer q.lock.Unlock()
+		return q.depth == depth
+	}, time.Second, time.Millisecond)
+}
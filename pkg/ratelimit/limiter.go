@@ -0,0 +1,156 @@
+package ratelimit
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rancher/apiserver/pkg/apierror"
+	"github.com/rancher/steve/pkg/metrics"
+	"github.com/rancher/wrangler/v3/pkg/schemas/validation"
+	"golang.org/x/time/rate"
+	"k8s.io/apiserver/pkg/endpoints/request"
+	"k8s.io/utils/lru"
+)
+
+// ClassFunc returns the Class of req, or an empty Class if it is not rate limited
+type ClassFunc func(req *http.Request) Class
+
+// APIClass classifies /v1 requests
+func APIClass(req *http.Request) Class {
+	if strings.TrimSuffix(req.URL.Path, "/") == "/v1/subscribe" {
+		return ClassWatch
+	}
+	switch req.Method {
+	case http.MethodGet, http.MethodHead:
+		return ClassList
+	default:
+		return ClassMutation
+	}
+}
+
+// ProxyClass classifies requests proxied to the Kubernetes API server
+func ProxyClass(*http.Request) Class {
+	return ClassProxy
+}
+
+// Limiter rate limits the requests of each user with a token bucket per Class
+type Limiter struct {
+	limits       map[Class]Limit
+	exemptGroups []string
+	// buckets holds the *rate.Limiter of each user and class
+	buckets *lru.Cache
+	// lock makes getting or adding a bucket atomic
+	lock sync.Mutex
+	now  func() time.Time
+}
+
+// NewLimiter returns a Limiter enforcing the limits of config
+func NewLimiter(config Config) *Limiter {
+	maxUsers := config.MaxUsers
+	if maxUsers == 0 {
+		maxUsers = defaultMaxUsers
+	}
+	for class, limit := range config.Limits {
+		metrics.SetRateLimit(string(class), limit.RequestsPerSecond, limit.Burst)
+	}
+	return &Limiter{
+		limits:       config.Limits,
+		exemptGroups: config.ExemptGroups,
+		buckets:      lru.New(maxUsers * len(classes)),
+		now:          time.Now,
+	}
+}
+
+// LimiterFromEnv returns a Limiter configured by the file in ConfigEnvVar, or nil if it is not set or limits nothing
+func LimiterFromEnv() (*Limiter, error) {
+	config, err := ConfigFromEnv()
+	if err != nil || config == nil || len(config.Limits) == 0 {
+		return nil, err
+	}
+	return NewLimiter(*config), nil
+}
+
+// Middleware returns a middleware rejecting the requests of users over the limit of their class, as classified by
+// classify, with 429 Too Many Requests. It must be chained after authentication. A nil Limiter limits nothing.
+func (l *Limiter) Middleware(classify ClassFunc) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if l == nil {
+			return next
+		}
+		return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			class := classify(req)
+			if delay, ok := l.allow(req, class); !ok {
+				metrics.IncRateLimitedRequests(string(class))
+				writeTooManyRequests(rw, delay, fmt.Sprintf("rate limit of %s requests exceeded", class))
+				return
+			}
+			next.ServeHTTP(rw, req)
+		})
+	}
+}
+
+// allow takes a token from the bucket of the user of req for class, or returns how long until one is available
+func (l *Limiter) allow(req *http.Request, class Class) (time.Duration, bool) {
+	limit, ok := l.limits[class]
+	if !ok {
+		return 0, true
+	}
+	var name string
+	if u, ok := request.UserFrom(req.Context()); ok {
+		if slices.ContainsFunc(u.GetGroups(), func(group string) bool { return slices.Contains(l.exemptGroups, group) }) {
+			return 0, true
+		}
+		name = u.GetName()
+	}
+
+	bucket := l.bucket(name, class, limit)
+	now := l.now()
+	reservation := bucket.ReserveN(now, 1)
+	if delay := reservation.DelayFrom(now); delay > 0 {
+		// the token is given back, rejected requests must not delay the next allowed ones
+		reservation.CancelAt(now)
+		return delay, false
+	}
+	return 0, true
+}
+
+func (l *Limiter) bucket(name string, class Class, limit Limit) *rate.Limiter {
+	key := string(class) + "/" + name
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if bucket, ok := l.buckets.Get(key); ok {
+		return bucket.(*rate.Limiter)
+	}
+	bucket := rate.NewLimiter(rate.Limit(limit.RequestsPerSecond), limit.Burst)
+	l.buckets.Add(key, bucket)
+	return bucket
+}
+
+// TooManyRequests is the code of the API errors of rejected requests
+var TooManyRequests = validation.ErrorCode{Code: "TooManyRequests", Status: http.StatusTooManyRequests}
+
+// writeTooManyRequests writes a 429 API error telling the client to retry after delay, as the apiserver writes errors
+func writeTooManyRequests(rw http.ResponseWriter, delay time.Duration, message string) {
+	apiError := apierror.NewAPIError(TooManyRequests, message).(*apierror.APIError)
+	rw.Header().Set("Content-Type", "application/json")
+	rw.Header().Set("Retry-After", strconv.Itoa(retryAfterSeconds(delay)))
+	rw.WriteHeader(apiError.Code.Status)
+	_ = json.NewEncoder(rw).Encode(map[string]any{
+		"type":    "error",
+		"status":  apiError.Code.Status,
+		"code":    apiError.Code.Code,
+		"message": apiError.Message,
+	})
+}
+
+// retryAfterSeconds rounds delay up to whole seconds, as required by the Retry-After header
+func retryAfterSeconds(delay time.Duration) int {
+	return max(1, int(math.Ceil(delay.Seconds())))
+}
@@ -0,0 +1,82 @@
+package ratelimit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/apiserver/pkg/endpoints/request"
+)
+
+func TestAPIClass(t *testing.T) {
+	tests := []struct {
+		method string
+		path   string
+		want   Class
+	}{
+		{method: http.MethodGet, path: "/v1/subscribe", want: ClassWatch},
+		{method: http.MethodGet, path: "/v1/pods", want: ClassList},
+		{method: http.MethodGet, path: "/v1/pods/default/web", want: ClassList},
+		{method: http.MethodPost, path: "/v1/pods", want: ClassMutation},
+		{method: http.MethodDelete, path: "/v1/pods/default/web", want: ClassMutation},
+	}
+	for _, test := range tests {
+		req := httptest.NewRequest(test.method, test.path, nil)
+		assert.Equal(t, test.want, APIClass(req), "%s %s", test.method, test.path)
+	}
+}
+
+func TestLimiterMiddleware(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	limiter := NewLimiter(Config{
+		Limits: map[Class]Limit{
+			ClassList: {RequestsPerSecond: 0.5, Burst: 2},
+		},
+		ExemptGroups: []string{"system:masters"},
+	})
+	limiter.now = func() time.Time { return now }
+	handler := limiter.Middleware(APIClass)(http.HandlerFunc(func(rw http.ResponseWriter, _ *http.Request) {
+		rw.WriteHeader(http.StatusOK)
+	}))
+
+	do := func(method string, u user.Info) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/v1/pods", nil)
+		req = req.WithContext(request.WithUser(req.Context(), u))
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+	alice := &user.DefaultInfo{Name: "alice"}
+	bob := &user.DefaultInfo{Name: "bob"}
+	admin := &user.DefaultInfo{Name: "admin", Groups: []string{"system:masters"}}
+
+	assert.Equal(t, http.StatusOK, do(http.MethodGet, alice).Code)
+	assert.Equal(t, http.StatusOK, do(http.MethodGet, alice).Code)
+	rec := do(http.MethodGet, alice)
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.Equal(t, "2", rec.Header().Get("Retry-After"))
+	assert.Contains(t, rec.Body.String(), `"code":"TooManyRequests"`)
+
+	// other users and classes have their own buckets, and exempt groups are not limited
+	assert.Equal(t, http.StatusOK, do(http.MethodGet, bob).Code)
+	assert.Equal(t, http.StatusOK, do(http.MethodPost, alice).Code)
+	for range 5 {
+		assert.Equal(t, http.StatusOK, do(http.MethodGet, admin).Code)
+	}
+
+	// rejected requests don't take tokens, so one is available again after its refill time
+	now = now.Add(2 * time.Second)
+	assert.Equal(t, http.StatusOK, do(http.MethodGet, alice).Code)
+	assert.Equal(t, http.StatusTooManyRequests, do(http.MethodGet, alice).Code)
+}
+
+func TestNilLimiterMiddleware(t *testing.T) {
+	var limiter *Limiter
+	next := http.HandlerFunc(func(http.ResponseWriter, *http.Request) {})
+	rec := httptest.NewRecorder()
+	limiter.Middleware(ProxyClass)(next).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
@@ -0,0 +1,192 @@
+// Package accessreview provides the accessreview schema, which returns the permissions of a user as computed by
+// steve, and explains which bindings grant a given permission.
+package accessreview
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/rancher/apiserver/pkg/apierror"
+	"github.com/rancher/apiserver/pkg/store/empty"
+	"github.com/rancher/apiserver/pkg/types"
+	"github.com/rancher/steve/pkg/accesscontrol"
+	"github.com/rancher/wrangler/v3/pkg/schemas/validation"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/apiserver/pkg/endpoints/request"
+)
+
+const (
+	schemaID = "accessreview"
+
+	// userParam and groupsParam select another user to review, groups being comma-separated
+	userParam   = "user"
+	groupsParam = "groups"
+	// verbParam, with either resourceParam or pathParam, requests an explanation of a permission
+	verbParam      = "verb"
+	groupParam     = "group"
+	resourceParam  = "resource"
+	namespaceParam = "namespace"
+	nameParam      = "name"
+	pathParam      = "path"
+)
+
+// reviewOthersResource is the resource users need to create to review the permissions of others, as they would to
+// ask Kubernetes about them
+var reviewOthersResource = schema.GroupResource{Group: "authorization.k8s.io", Resource: "subjectaccessreviews"}
+
+// AccessReview lists the permissions of a user
+type AccessReview struct {
+	User            string              `json:"user"`
+	Groups          []string            `json:"groups,omitempty"`
+	Resources       []ResourceAccess    `json:"resources"`
+	NonResourceURLs []NonResourceAccess `json:"nonResourceURLs"`
+	// Explanation is only set when a verb is given
+	Explanation *Explanation `json:"explanation,omitempty"`
+}
+
+// ResourceAccess grants a verb on a group and resource. Namespace and ResourceName are "*" when not restricted.
+type ResourceAccess struct {
+	Verb         string `json:"verb"`
+	Group        string `json:"group"`
+	Resource     string `json:"resource"`
+	Namespace    string `json:"namespace"`
+	ResourceName string `json:"resourceName"`
+}
+
+// NonResourceAccess grants a verb on a non-resource URL
+type NonResourceAccess struct {
+	Verb string `json:"verb"`
+	URL  string `json:"url"`
+}
+
+// Explanation tells whether a permission is granted, and by which rules
+type Explanation struct {
+	Allowed bool                  `json:"allowed"`
+	Grants  []accesscontrol.Grant `json:"grants"`
+}
+
+// Register adds the accessreview schema. Explanations are only available if asl implements
+// accesscontrol.AccessExplainer.
+func Register(schemas *types.APISchemas, asl accesscontrol.AccessSetLookup) {
+	schemas.InternalSchemas.TypeName(schemaID, AccessReview{})
+	schemas.MustImportAndCustomize(AccessReview{}, func(schema *types.APISchema) {
+		schema.CollectionMethods = []string{http.MethodGet}
+		schema.ResourceMethods = []string{http.MethodGet}
+		schema.Store = &store{asl: asl}
+	})
+}
+
+type store struct {
+	empty.Store
+	asl accesscontrol.AccessSetLookup
+}
+
+// List returns the review of the requesting user, or of the user in the user query parameter
+func (s *store) List(apiOp *types.APIRequest, _ *types.APISchema) (types.APIObjectList, error) {
+	review, err := s.review(apiOp, apiOp.Request.URL.Query().Get(userParam))
+	if err != nil {
+		return types.APIObjectList{}, err
+	}
+	return types.APIObjectList{Objects: []types.APIObject{toAPIObject(review)}}, nil
+}
+
+// ByID returns the review of the user named id
+func (s *store) ByID(apiOp *types.APIRequest, _ *types.APISchema, id string) (types.APIObject, error) {
+	review, err := s.review(apiOp, id)
+	if err != nil {
+		return types.APIObject{}, err
+	}
+	return toAPIObject(review), nil
+}
+
+func toAPIObject(review *AccessReview) types.APIObject {
+	return types.APIObject{Type: schemaID, ID: review.User, Object: review}
+}
+
+func (s *store) review(apiOp *types.APIRequest, userName string) (*AccessReview, error) {
+	caller, ok := request.UserFrom(apiOp.Context())
+	if !ok {
+		return nil, apierror.NewAPIError(validation.PermissionDenied, "no user found")
+	}
+	query := apiOp.Request.URL.Query()
+	subject := caller
+	accessSet := accesscontrol.AccessSetFromAPIRequest(apiOp)
+	if accessSet == nil {
+		accessSet = s.asl.AccessFor(caller)
+	}
+	if (userName != "" && userName != caller.GetName()) || query.Has(groupsParam) {
+		if !accessSet.Grants("create", reviewOthersResource, "", "") {
+			return nil, apierror.NewAPIError(validation.PermissionDenied, "reviewing the access of other users requires permission to create subjectaccessreviews")
+		}
+		if userName == "" {
+			userName = caller.GetName()
+		}
+		subject = &user.DefaultInfo{Name: userName, Groups: splitList(query.Get(groupsParam))}
+		accessSet = s.asl.AccessFor(subject)
+	}
+
+	review := &AccessReview{
+		User:            subject.GetName(),
+		Groups:          subject.GetGroups(),
+		Resources:       []ResourceAccess{},
+		NonResourceURLs: []NonResourceAccess{},
+	}
+	for _, resourceAccess := range accessSet.ListResourceAccess() {
+		for _, access := range resourceAccess.Access {
+			review.Resources = append(review.Resources, ResourceAccess{
+				Verb:         resourceAccess.Verb,
+				Group:        resourceAccess.GroupResource.Group,
+				Resource:     resourceAccess.GroupResource.Resource,
+				Namespace:    access.Namespace,
+				ResourceName: access.ResourceName,
+			})
+		}
+	}
+	for _, access := range accessSet.ListNonResourceAccess() {
+		review.NonResourceURLs = append(review.NonResourceURLs, NonResourceAccess{Verb: access.Verb, URL: access.URL})
+	}
+
+	if verb := query.Get(verbParam); verb != "" {
+		explanation, err := s.explain(subject, accessSet, verb, query.Get(groupParam), query.Get(resourceParam),
+			query.Get(namespaceParam), query.Get(nameParam), query.Get(pathParam))
+		if err != nil {
+			return nil, err
+		}
+		review.Explanation = explanation
+	}
+	return review, nil
+}
+
+func (s *store) explain(subject user.Info, accessSet *accesscontrol.AccessSet, verb, group, resource, namespace, name, path string) (*Explanation, error) {
+	explainer, ok := s.asl.(accesscontrol.AccessExplainer)
+	if !ok {
+		return nil, apierror.NewAPIError(validation.ActionNotAvailable, "explanations are not available")
+	}
+	explanation := &Explanation{}
+	switch {
+	case path != "":
+		explanation.Allowed = accessSet.GrantsNonResource(verb, path)
+		explanation.Grants = explainer.ExplainNonResource(subject, verb, path)
+	case resource != "":
+		gr := schema.GroupResource{Group: group, Resource: resource}
+		explanation.Allowed = accessSet.Grants(verb, gr, namespace, name)
+		explanation.Grants = explainer.Explain(subject, verb, gr, namespace, name)
+	default:
+		return nil, apierror.NewAPIError(validation.MissingRequired, "explaining a verb requires a resource or a path")
+	}
+	if explanation.Grants == nil {
+		explanation.Grants = []accesscontrol.Grant{}
+	}
+	return explanation, nil
+}
+
+func splitList(value string) []string {
+	var result []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			result = append(result, item)
+		}
+	}
+	return result
+}
@@ -0,0 +1,105 @@
+package accessreview
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/rancher/apiserver/pkg/apierror"
+	"github.com/rancher/apiserver/pkg/types"
+	"github.com/rancher/steve/pkg/accesscontrol"
+	"github.com/rancher/wrangler/v3/pkg/schemas/validation"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/apiserver/pkg/endpoints/request"
+)
+
+type fakeLookup struct {
+	accessSets map[string]*accesscontrol.AccessSet
+	grants     []accesscontrol.Grant
+}
+
+func (f *fakeLookup) AccessFor(u user.Info) *accesscontrol.AccessSet {
+	if accessSet, ok := f.accessSets[u.GetName()]; ok {
+		return accessSet
+	}
+	return &accesscontrol.AccessSet{}
+}
+
+func (f *fakeLookup) PurgeUserData(string) {}
+
+func (f *fakeLookup) Explain(user.Info, string, schema.GroupResource, string, string) []accesscontrol.Grant {
+	return f.grants
+}
+
+func (f *fakeLookup) ExplainNonResource(user.Info, string, string) []accesscontrol.Grant {
+	return nil
+}
+
+func TestStore(t *testing.T) {
+	configMaps := schema.GroupResource{Resource: "configmaps"}
+	adminAccess := &accesscontrol.AccessSet{}
+	adminAccess.Add("*", schema.GroupResource{Group: "*", Resource: "*"}, accesscontrol.Access{Namespace: accesscontrol.All, ResourceName: accesscontrol.All})
+	userAccess := &accesscontrol.AccessSet{}
+	userAccess.Add("get", configMaps, accesscontrol.Access{Namespace: "dev", ResourceName: accesscontrol.All})
+	userAccess.AddNonResourceURLs([]string{"get"}, []string{"/healthz"})
+	grant := accesscontrol.Grant{SubjectKind: "User", SubjectName: "dev-user", BindingKind: "RoleBinding", BindingName: "dev"}
+	s := &store{asl: &fakeLookup{
+		accessSets: map[string]*accesscontrol.AccessSet{"admin": adminAccess, "dev-user": userAccess},
+		grants:     []accesscontrol.Grant{grant},
+	}}
+
+	apiRequest := func(caller, query string) *types.APIRequest {
+		req := &http.Request{URL: &url.URL{RawQuery: query}}
+		return &types.APIRequest{
+			Schemas: types.EmptyAPISchemas(),
+			Request: req.WithContext(request.WithUser(req.Context(), &user.DefaultInfo{Name: caller})),
+		}
+	}
+	reviewFor := func(t *testing.T, caller, query string) *AccessReview {
+		list, err := s.List(apiRequest(caller, query), nil)
+		require.NoError(t, err)
+		require.Len(t, list.Objects, 1)
+		return list.Objects[0].Object.(*AccessReview)
+	}
+
+	t.Run("own access", func(t *testing.T) {
+		assert.Equal(t, &AccessReview{
+			User:            "dev-user",
+			Resources:       []ResourceAccess{{Verb: "get", Resource: "configmaps", Namespace: "dev", ResourceName: "*"}},
+			NonResourceURLs: []NonResourceAccess{{Verb: "get", URL: "/healthz"}},
+		}, reviewFor(t, "dev-user", ""))
+	})
+
+	t.Run("explain", func(t *testing.T) {
+		review := reviewFor(t, "dev-user", "verb=get&resource=configmaps&namespace=dev&name=settings")
+		assert.Equal(t, &Explanation{Allowed: true, Grants: []accesscontrol.Grant{grant}}, review.Explanation)
+
+		review = reviewFor(t, "dev-user", "verb=delete&path=/healthz")
+		assert.Equal(t, &Explanation{Allowed: false, Grants: []accesscontrol.Grant{}}, review.Explanation)
+
+		_, err := s.List(apiRequest("dev-user", "verb=get"), nil)
+		var apiErr *apierror.APIError
+		require.ErrorAs(t, err, &apiErr)
+		assert.Equal(t, validation.MissingRequired, apiErr.Code)
+	})
+
+	t.Run("other users", func(t *testing.T) {
+		_, err := s.ByID(apiRequest("dev-user", ""), nil, "admin")
+		var apiErr *apierror.APIError
+		require.ErrorAs(t, err, &apiErr)
+		assert.Equal(t, validation.PermissionDenied, apiErr.Code)
+
+		_, err = s.List(apiRequest("dev-user", "groups=system:masters"), nil)
+		assert.ErrorAs(t, err, &apiErr, "users can't review themselves with other groups")
+
+		obj, err := s.ByID(apiRequest("admin", "groups=devs"), nil, "dev-user")
+		require.NoError(t, err)
+		assert.Equal(t, "dev-user", obj.ID)
+		review := obj.Object.(*AccessReview)
+		assert.Equal(t, []string{"devs"}, review.Groups)
+		assert.Len(t, review.Resources, 1)
+	})
+}
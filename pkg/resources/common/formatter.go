@@ -30,6 +30,9 @@ import (
 
 type TemplateOptions struct {
 	InSQLMode bool
+	// Redactions are applied to all objects, before any other formatting. They also keep their placeholders from being
+	// written and redacted fields from being listed by.
+	Redactions *Redactions
 }
 
 func DefaultTemplate(clientGetter proxy.ClientGetter,
@@ -38,8 +41,9 @@ func DefaultTemplate(clientGetter proxy.ClientGetter,
 	namespaceCache corecontrollers.NamespaceCache,
 	options TemplateOptions) schema.Template {
 	return schema.Template{
-		Store:     metricsStore.NewMetricsStore(proxy.NewProxyStore(clientGetter, summaryCache, asl, namespaceCache)),
+		Store:     newRedactionStore(metricsStore.NewMetricsStore(proxy.NewProxyStore(clientGetter, summaryCache, asl, namespaceCache)), asl, options),
 		Formatter: formatter(summaryCache, asl, options),
+		Customize: addTreeLink(summaryCache, asl, options.Redactions),
 	}
 }
 
@@ -49,8 +53,9 @@ func DefaultTemplateForStore(store types.Store,
 	asl accesscontrol.AccessSetLookup,
 	options TemplateOptions) schema.Template {
 	return schema.Template{
-		Store:     store,
+		Store:     newRedactionStore(store, asl, options),
 		Formatter: formatter(summaryCache, asl, options),
+		Customize: addTreeLink(summaryCache, asl, options.Redactions),
 	}
 }
 
@@ -102,6 +107,17 @@ func formatter(summarycache common.SummaryCache, asl accesscontrol.AccessSetLook
 			return
 		}
 
+		userInfo, hasUser := request.GetUserInfo()
+		accessSet := accesscontrol.AccessSetFromAPIRequest(request)
+		if accessSet == nil && hasUser {
+			accessSet = asl.AccessFor(userInfo)
+		}
+		unstr, isUnstructured := resource.APIObject.Object.(*unstructured.Unstructured)
+		if isUnstructured {
+			// redact before anything else, so that no early return skips it and no field is derived from the hidden values
+			options.Redactions.Apply(request, resource.Schema, accessSet, unstr)
+		}
+
 		gvr := attributes.GVR(resource.Schema)
 		if gvr.Version == "" {
 			return
@@ -111,17 +127,9 @@ func formatter(summarycache common.SummaryCache, asl accesscontrol.AccessSetLook
 		if err != nil {
 			return
 		}
-		userInfo, ok := request.GetUserInfo()
-		if !ok {
+		if !hasUser || accessSet == nil {
 			return
 		}
-		accessSet := accesscontrol.AccessSetFromAPIRequest(request)
-		if accessSet == nil {
-			accessSet = asl.AccessFor(userInfo)
-			if accessSet == nil {
-				return
-			}
-		}
 		hasGet := accessSet.Grants("get", gvr.GroupResource(), resource.APIObject.Namespace(), resource.APIObject.Name())
 		hasUpdate := accessSet.Grants("update", gvr.GroupResource(), resource.APIObject.Namespace(), resource.APIObject.Name())
 		hasDelete := accessSet.Grants("delete", gvr.GroupResource(), resource.APIObject.Namespace(), resource.APIObject.Name())
@@ -161,7 +169,7 @@ func formatter(summarycache common.SummaryCache, asl accesscontrol.AccessSetLook
 		}
 
 		gvk := attributes.GVK(resource.Schema)
-		if unstr, ok := resource.APIObject.Object.(*unstructured.Unstructured); ok {
+		if isUnstructured {
 			// with the sql cache, these were already added by the indexer. However, the sql cache
 			// is only used for lists, so we need to re-add here for get/watch
 			s, rel := summarycache.SummaryAndRelationship(unstr)
@@ -210,7 +218,7 @@ func formatter(summarycache common.SummaryCache, asl accesscontrol.AccessSetLook
 				}
 			}
 
-			if unstr, ok := resource.APIObject.Object.(*unstructured.Unstructured); ok {
+			if isUnstructured {
 				data.PutValue(unstr.Object, permissions, "resourcePermissions")
 			}
 		}
@@ -0,0 +1,348 @@
+package common
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/rancher/apiserver/pkg/types"
+	"github.com/rancher/steve/pkg/accesscontrol"
+	"github.com/rancher/steve/pkg/attributes"
+	"github.com/rancher/steve/pkg/configfile"
+	"github.com/rancher/steve/pkg/schema/converter"
+	"github.com/rancher/steve/pkg/stores/queryhelper"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// RedactionPoliciesEnvVar is the path to a YAML or JSON file containing RedactionPolicies
+const RedactionPoliciesEnvVar = "CATTLE_REDACTION_POLICIES"
+
+// RedactedValue replaces the values of redacted fields
+const RedactedValue = "<redacted>"
+
+// lastAppliedConfigAnnotation holds a copy of the object as last applied by kubectl, including redacted fields
+const lastAppliedConfigAnnotation = "kubectl.kubernetes.io/last-applied-configuration"
+
+const wildcardSegment = "*"
+
+// RedactionPolicies hide the values of fields from the users who are not exempt
+type RedactionPolicies struct {
+	Policies []RedactionPolicy `json:"policies"`
+}
+
+// RedactionPolicy redacts Fields of the objects of a GVK, an empty version matching all versions
+type RedactionPolicy struct {
+	Group   string `json:"group,omitempty"`
+	Version string `json:"version,omitempty"`
+	Kind    string `json:"kind"`
+	// Fields are dot-separated paths, where "*" matches all keys of a map and "[*]" all items of a list, eg. "data.*"
+	// or "spec.containers[*].env[*].value". Maps and lists keep their keys and length, only their values are redacted.
+	Fields []string `json:"fields"`
+	// Exempt users see the values of Fields
+	Exempt RedactionExemption `json:"exempt,omitempty"`
+}
+
+// RedactionExemption exempts users from a RedactionPolicy if they are granted Verb, or belong to any of Groups
+type RedactionExemption struct {
+	// Verb is checked on the redacted object, or on all objects of Group and Resource if Resource is set
+	Verb     string   `json:"verb,omitempty"`
+	Group    string   `json:"group,omitempty"`
+	Resource string   `json:"resource,omitempty"`
+	Groups   []string `json:"groups,omitempty"`
+}
+
+// Redactions applies RedactionPolicies to objects
+type Redactions struct {
+	byGroupKind map[schema.GroupKind][]compiledRedaction
+}
+
+type compiledRedaction struct {
+	version string
+	paths   [][]string
+	exempt  RedactionExemption
+}
+
+// LoadRedactionPolicies reads the RedactionPolicies in a YAML or JSON file, rejecting policies without a kind or
+// fields, fields which are not valid paths and incomplete exemptions
+func LoadRedactionPolicies(path string) (RedactionPolicies, error) {
+	return configfile.Load(path, "redaction policies", func(policies RedactionPolicies) error {
+		_, err := NewRedactions(policies)
+		return err
+	})
+}
+
+// RedactionsFromEnv returns the Redactions of the file in RedactionPoliciesEnvVar, or nil if it is not set
+func RedactionsFromEnv() (*Redactions, error) {
+	path := os.Getenv(RedactionPoliciesEnvVar)
+	if path == "" {
+		return nil, nil
+	}
+	policies, err := LoadRedactionPolicies(path)
+	if err != nil {
+		return nil, err
+	}
+	return NewRedactions(policies)
+}
+
+// NewRedactions validates and compiles policies
+func NewRedactions(policies RedactionPolicies) (*Redactions, error) {
+	r := &Redactions{byGroupKind: map[schema.GroupKind][]compiledRedaction{}}
+	for i, policy := range policies.Policies {
+		if policy.Kind == "" {
+			return nil, fmt.Errorf("policy %d: kind is required", i)
+		}
+		if len(policy.Fields) == 0 {
+			return nil, fmt.Errorf("policy %d: fields are required", i)
+		}
+		if policy.Exempt.Resource == "" && policy.Exempt.Group != "" {
+			return nil, fmt.Errorf("policy %d: exempt group requires a resource", i)
+		}
+		if policy.Exempt.Resource != "" && policy.Exempt.Verb == "" {
+			return nil, fmt.Errorf("policy %d: exempt resource requires a verb", i)
+		}
+		compiled := compiledRedaction{version: policy.Version, exempt: policy.Exempt}
+		for _, field := range policy.Fields {
+			path, err := parseRedactionPath(field)
+			if err != nil {
+				return nil, fmt.Errorf("policy %d: %w", i, err)
+			}
+			compiled.paths = append(compiled.paths, path)
+		}
+		gk := schema.GroupKind{Group: policy.Group, Kind: policy.Kind}
+		r.byGroupKind[gk] = append(r.byGroupKind[gk], compiled)
+	}
+	return r, nil
+}
+
+// parseRedactionPath splits a field path into map keys, "*" for all map keys and "[*]" for all list items
+func parseRedactionPath(field string) ([]string, error) {
+	var path []string
+	for _, segment := range strings.Split(field, ".") {
+		name, rest, _ := strings.Cut(segment, "[")
+		if rest != "" {
+			rest = "[" + rest
+		}
+		if name == "" {
+			return nil, fmt.Errorf("invalid field %q: empty segment", field)
+		}
+		path = append(path, name)
+		for rest != "" {
+			if !strings.HasPrefix(rest, "[*]") {
+				return nil, fmt.Errorf("invalid field %q: only [*] is supported for lists", field)
+			}
+			path = append(path, "[*]")
+			rest = rest[len("[*]"):]
+		}
+	}
+	return path, nil
+}
+
+// Apply redacts the fields of obj covered by the policies of its GVK which the requester is not exempt from, along
+// with the fields and state messages of the children embedded in its metadata.associatedData
+func (r *Redactions) Apply(apiOp *types.APIRequest, apiSchema *types.APISchema, accessSet *accesscontrol.AccessSet, obj *unstructured.Unstructured) {
+	if r == nil {
+		return
+	}
+	policies := r.policiesFor(apiOp, attributes.GVK(apiSchema), attributes.GVR(apiSchema).GroupResource(), accessSet, obj.GetNamespace(), obj.GetName())
+	for _, policy := range policies {
+		for _, path := range policy.paths {
+			redactPath(obj.Object, path)
+		}
+	}
+	if len(policies) > 0 {
+		redactLastAppliedConfig(obj)
+	}
+	r.redactAssociatedData(apiOp, accessSet, obj)
+}
+
+// Redacts returns whether any field of the object namespace/name of apiSchema is redacted for the requester, where
+// accesscontrol.All stands for all namespaces or names
+func (r *Redactions) Redacts(apiOp *types.APIRequest, apiSchema *types.APISchema, accessSet *accesscontrol.AccessSet, namespace, name string) bool {
+	if r == nil {
+		return false
+	}
+	return len(r.policiesFor(apiOp, attributes.GVK(apiSchema), attributes.GVR(apiSchema).GroupResource(), accessSet, namespace, name)) > 0
+}
+
+// policiesFor returns the policies of gvk the requester is not exempt from for the object namespace/name of gr
+func (r *Redactions) policiesFor(apiOp *types.APIRequest, gvk schema.GroupVersionKind, gr schema.GroupResource, accessSet *accesscontrol.AccessSet, namespace, name string) []compiledRedaction {
+	policies := r.byGroupKind[gvk.GroupKind()]
+	if len(policies) == 0 {
+		return nil
+	}
+	var groups []string
+	if userInfo, ok := apiOp.GetUserInfo(); ok {
+		groups = userInfo.GetGroups()
+	}
+	var result []compiledRedaction
+	for _, policy := range policies {
+		if policy.version != "" && policy.version != gvk.Version {
+			continue
+		}
+		if policy.exempted(gr, accessSet, groups, namespace, name) {
+			continue
+		}
+		result = append(result, policy)
+	}
+	return result
+}
+
+// redactAssociatedData applies the policies of the children embedded in the metadata.associatedData of obj to their
+// fields, and hides the state messages of the redacted children as they may be derived from redacted fields
+func (r *Redactions) redactAssociatedData(apiOp *types.APIRequest, accessSet *accesscontrol.AccessSet, obj *unstructured.Unstructured) {
+	blocks, _, _ := unstructured.NestedSlice(obj.Object, "metadata", "associatedData")
+	if len(blocks) == 0 {
+		return
+	}
+	for _, block := range blocks {
+		block, ok := block.(map[string]any)
+		if !ok {
+			continue
+		}
+		group, _, _ := unstructured.NestedString(block, "gvk", "group")
+		version, _, _ := unstructured.NestedString(block, "gvk", "version")
+		kind, _, _ := unstructured.NestedString(block, "gvk", "kind")
+		gvk := schema.GroupVersionKind{Group: group, Version: version, Kind: kind}
+		var gr schema.GroupResource
+		if apiOp.Schemas != nil {
+			if childSchema := apiOp.Schemas.LookupSchema(converter.GVKToSchemaID(gvk)); childSchema != nil {
+				gr = attributes.GVR(childSchema).GroupResource()
+			}
+		}
+		items, _ := block["data"].([]any)
+		for _, item := range items {
+			item, ok := item.(map[string]any)
+			if !ok {
+				continue
+			}
+			childName, _ := item["childName"].(string)
+			policies := r.policiesFor(apiOp, gvk, gr, accessSet, obj.GetNamespace(), childName)
+			if len(policies) == 0 {
+				continue
+			}
+			if message, _, _ := unstructured.NestedString(item, "state", "message"); message != "" {
+				_ = unstructured.SetNestedField(item, RedactedValue, "state", "message")
+			}
+			fields, _ := item["fields"].(map[string]any)
+			for key, value := range fields {
+				field := queryhelper.SafeSplit(key)
+				for _, policy := range policies {
+					for _, path := range policy.paths {
+						rest, overlaps := matchRedactedField(path, field)
+						switch {
+						case !overlaps:
+						case len(rest) == 0:
+							fields[key] = redactValue(value)
+						default:
+							redactPath(value, rest)
+						}
+					}
+				}
+			}
+		}
+	}
+	_ = unstructured.SetNestedSlice(obj.Object, blocks, "metadata", "associatedData")
+}
+
+// matchRedactedField returns whether field, a path without list items as used by filters, sorts and
+// associatedDataFields, overlaps the redacted path. If field is a parent of the redacted values, rest is the part of
+// path within field.
+func matchRedactedField(path, field []string) (rest []string, overlaps bool) {
+	i, j := 0, 0
+	for i < len(path) && j < len(field) {
+		if path[i] == "[*]" {
+			i++
+			continue
+		}
+		if path[i] != wildcardSegment && path[i] != field[j] {
+			return nil, false
+		}
+		i++
+		j++
+	}
+	if j < len(field) {
+		// field is within a redacted value
+		return nil, true
+	}
+	return path[i:], true
+}
+
+// redactLastAppliedConfig redacts the whole last-applied-configuration annotation, as it may repeat any field
+func redactLastAppliedConfig(obj *unstructured.Unstructured) {
+	annotations := obj.GetAnnotations()
+	if _, ok := annotations[lastAppliedConfigAnnotation]; !ok {
+		return
+	}
+	annotations[lastAppliedConfigAnnotation] = RedactedValue
+	obj.SetAnnotations(annotations)
+}
+
+func (p compiledRedaction) exempted(gr schema.GroupResource, accessSet *accesscontrol.AccessSet, groups []string, namespace, name string) bool {
+	for _, group := range p.exempt.Groups {
+		if slices.Contains(groups, group) {
+			return true
+		}
+	}
+	if p.exempt.Verb == "" || accessSet == nil {
+		return false
+	}
+	if p.exempt.Resource != "" {
+		gr := schema.GroupResource{Group: p.exempt.Group, Resource: p.exempt.Resource}
+		return accessSet.Grants(p.exempt.Verb, gr, accesscontrol.All, accesscontrol.All)
+	}
+	return accessSet.Grants(p.exempt.Verb, gr, namespace, name)
+}
+
+// redactPath redacts the values matching path in obj, which is a map or a list
+func redactPath(obj any, path []string) {
+	segment, rest := path[0], path[1:]
+	switch value := obj.(type) {
+	case map[string]any:
+		if segment == "[*]" {
+			return
+		}
+		for key, child := range value {
+			if segment != wildcardSegment && segment != key {
+				continue
+			}
+			if len(rest) == 0 {
+				value[key] = redactValue(child)
+			} else {
+				redactPath(child, rest)
+			}
+		}
+	case []any:
+		if segment != "[*]" {
+			return
+		}
+		for i, child := range value {
+			if len(rest) == 0 {
+				value[i] = redactValue(child)
+			} else {
+				redactPath(child, rest)
+			}
+		}
+	}
+}
+
+// redactValue replaces the scalar values within value, keeping the keys of maps and the length of lists
+func redactValue(value any) any {
+	switch v := value.(type) {
+	case map[string]any:
+		for key, child := range v {
+			v[key] = redactValue(child)
+		}
+		return v
+	case []any:
+		for i, child := range v {
+			v[i] = redactValue(child)
+		}
+		return v
+	case nil:
+		return nil
+	default:
+		return RedactedValue
+	}
+}
@@ -0,0 +1,326 @@
+package common
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/rancher/apiserver/pkg/apierror"
+	"github.com/rancher/apiserver/pkg/types"
+	"github.com/rancher/steve/pkg/accesscontrol"
+	"github.com/rancher/steve/pkg/attributes"
+	"github.com/rancher/steve/pkg/stores/partition/listprocessor"
+	sqllistprocessor "github.com/rancher/steve/pkg/stores/sqlpartition/listprocessor"
+	"github.com/rancher/wrangler/v3/pkg/schemas/validation"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// maxPatchSize is the largest patch read to look for placeholders, as in the proxy stores
+const maxPatchSize = 2 << 20
+
+// lastAppliedConfigPath is the path of the last-applied-configuration annotation, redacted along with any field
+var lastAppliedConfigPath = []string{"metadata", "annotations", lastAppliedConfigAnnotation}
+
+// redactionStore keeps the placeholders of redacted fields from being written over the values they hide, and rejects
+// the lists filtering, sorting or summarizing by redacted fields, which would reveal their values
+type redactionStore struct {
+	types.Store
+	redactions *Redactions
+	asl        accesscontrol.AccessSetLookup
+	inSQLMode  bool
+}
+
+// newRedactionStore returns store guarded by options.Redactions, or store itself if there are none
+func newRedactionStore(store types.Store, asl accesscontrol.AccessSetLookup, options TemplateOptions) types.Store {
+	if options.Redactions == nil || store == nil {
+		return store
+	}
+	return &redactionStore{
+		Store:      store,
+		redactions: options.Redactions,
+		asl:        asl,
+		inSQLMode:  options.InSQLMode,
+	}
+}
+
+// List rejects filters, sorts and summaries on the fields redacted for the requester in the listed namespace
+func (s *redactionStore) List(apiOp *types.APIRequest, apiSchema *types.APISchema) (types.APIObjectList, error) {
+	namespace := apiOp.Namespace
+	if namespace == "" {
+		namespace = accesscontrol.All
+	}
+	paths := redactedPaths(s.policiesFor(apiOp, apiSchema, namespace, accesscontrol.All))
+	if len(paths) > 0 {
+		for _, field := range s.queryFields(apiOp, apiSchema) {
+			for _, path := range paths {
+				if _, overlaps := matchRedactedField(path, field); overlaps {
+					return types.APIObjectList{}, redactedQueryError(field)
+				}
+			}
+		}
+	}
+	return s.Store.List(apiOp, apiSchema)
+}
+
+// Create rejects objects with placeholders in the fields redacted for the requester
+func (s *redactionStore) Create(apiOp *types.APIRequest, apiSchema *types.APISchema, data types.APIObject) (types.APIObject, error) {
+	input := map[string]any(data.Data())
+	obj := &unstructured.Unstructured{Object: input}
+	namespace := obj.GetNamespace()
+	if namespace == "" {
+		namespace = apiOp.Namespace
+	}
+	for _, path := range redactedPaths(s.policiesFor(apiOp, apiSchema, namespace, obj.GetName())) {
+		if !restorePath(input, nil, path) {
+			return types.APIObject{}, redactedValueError(path)
+		}
+	}
+	return s.Store.Create(apiOp, apiSchema, data)
+}
+
+// Update puts back the stored values of the fields redacted for the requester which still hold placeholders, as
+// objects are usually updated with the result of a get. Patches can't be merged, so they are rejected if they
+// contain placeholders.
+func (s *redactionStore) Update(apiOp *types.APIRequest, apiSchema *types.APISchema, data types.APIObject, id string) (types.APIObject, error) {
+	input := map[string]any(data.Data())
+	obj := &unstructured.Unstructured{Object: input}
+	namespace, name := obj.GetNamespace(), obj.GetName()
+	if namespace == "" {
+		namespace = apiOp.Namespace
+	}
+	if name == "" {
+		name = apiOp.Name
+	}
+	paths := redactedPaths(s.policiesFor(apiOp, apiSchema, namespace, name))
+	if len(paths) == 0 {
+		return s.Store.Update(apiOp, apiSchema, data, id)
+	}
+
+	if apiOp.Method == http.MethodPatch {
+		if err := rejectPatchPlaceholders(apiOp); err != nil {
+			return types.APIObject{}, err
+		}
+		return s.Store.Update(apiOp, apiSchema, data, id)
+	}
+
+	var stored map[string]any
+	for _, path := range paths {
+		if restorePath(input, nil, path) {
+			continue
+		}
+		if stored == nil {
+			current, err := s.Store.ByID(apiOp, apiSchema, id)
+			if err != nil {
+				return types.APIObject{}, err
+			}
+			stored = current.Data()
+		}
+		if !restorePath(input, stored, path) {
+			return types.APIObject{}, redactedValueError(path)
+		}
+	}
+	data.Object = input
+	return s.Store.Update(apiOp, apiSchema, data, id)
+}
+
+// redactedPaths returns the paths redacted by policies, including the last-applied-configuration annotation
+func redactedPaths(policies []compiledRedaction) [][]string {
+	if len(policies) == 0 {
+		return nil
+	}
+	paths := [][]string{lastAppliedConfigPath}
+	for _, policy := range policies {
+		paths = append(paths, policy.paths...)
+	}
+	return paths
+}
+
+func (s *redactionStore) policiesFor(apiOp *types.APIRequest, apiSchema *types.APISchema, namespace, name string) []compiledRedaction {
+	accessSet := accesscontrol.AccessSetFromAPIRequest(apiOp)
+	if userInfo, ok := apiOp.GetUserInfo(); accessSet == nil && ok {
+		accessSet = s.asl.AccessFor(userInfo)
+	}
+	return s.redactions.policiesFor(apiOp, attributes.GVK(apiSchema), attributes.GVR(apiSchema).GroupResource(), accessSet, namespace, name)
+}
+
+// queryFields returns the fields a list request filters, sorts or summarizes by. Invalid queries return no fields,
+// as the wrapped store rejects them.
+func (s *redactionStore) queryFields(apiOp *types.APIRequest, apiSchema *types.APISchema) [][]string {
+	if apiOp.Request == nil {
+		return nil
+	}
+	if !s.inSQLMode {
+		return listprocessor.ParseQuery(apiOp).Fields()
+	}
+	opts, err := sqllistprocessor.ParseQuery(apiOp, attributes.GVK(apiSchema).Kind)
+	if err != nil {
+		return nil
+	}
+	var fields [][]string
+	for _, orFilter := range opts.Filters {
+		for _, filter := range orFilter.Filters {
+			fields = append(fields, filter.Field)
+		}
+	}
+	for _, sort := range opts.SortList.SortDirectives {
+		fields = append(fields, sort.Fields)
+	}
+	return append(fields, opts.SummaryFieldList...)
+}
+
+// rejectPatchPlaceholders fails if the body of a patch holds a placeholder anywhere, leaving the body to be read again
+func rejectPatchPlaceholders(apiOp *types.APIRequest) error {
+	if apiOp.Request == nil || apiOp.Request.Body == nil {
+		return nil
+	}
+	body, err := io.ReadAll(io.LimitReader(apiOp.Request.Body, maxPatchSize))
+	if err != nil {
+		return err
+	}
+	apiOp.Request.Body = io.NopCloser(bytes.NewReader(body))
+	var patch any
+	if err := json.Unmarshal(body, &patch); err != nil {
+		// left for the wrapped store to reject
+		return nil
+	}
+	if containsPlaceholder(patch) {
+		return apierror.NewAPIError(validation.InvalidBodyContent,
+			fmt.Sprintf("patches can't contain the %s placeholder, redacted fields must be set to their actual values", RedactedValue))
+	}
+	return nil
+}
+
+func containsPlaceholder(value any) bool {
+	switch v := value.(type) {
+	case map[string]any:
+		for _, child := range v {
+			if containsPlaceholder(child) {
+				return true
+			}
+		}
+	case []any:
+		for _, child := range v {
+			if containsPlaceholder(child) {
+				return true
+			}
+		}
+	case string:
+		return v == RedactedValue
+	}
+	return false
+}
+
+// restorePath replaces the placeholders matching path in obj with the values at the same place in stored, matching
+// list items by position. It returns false if a placeholder has no stored value, or is within a list whose length
+// changed, so that a nil stored only looks for placeholders.
+func restorePath(obj, stored any, path []string) bool {
+	segment, rest := path[0], path[1:]
+	switch value := obj.(type) {
+	case map[string]any:
+		if segment == "[*]" {
+			return true
+		}
+		storedMap, _ := stored.(map[string]any)
+		for key, child := range value {
+			if segment != wildcardSegment && segment != key {
+				continue
+			}
+			storedChild, found := storedMap[key]
+			ok := false
+			if len(rest) == 0 {
+				value[key], ok = restoreValue(child, storedChild, found)
+			} else {
+				ok = restorePath(child, storedChild, rest)
+			}
+			if !ok {
+				return false
+			}
+		}
+	case []any:
+		if segment != "[*]" {
+			return true
+		}
+		storedList, _ := stored.([]any)
+		for i, child := range value {
+			var storedChild any
+			found := len(storedList) == len(value)
+			if found {
+				storedChild = storedList[i]
+			}
+			ok := false
+			if len(rest) == 0 {
+				value[i], ok = restoreValue(child, storedChild, found)
+			} else {
+				ok = restorePath(child, storedChild, rest)
+			}
+			if !ok {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// restoreValue returns value with its placeholders replaced by the values at the same place in stored, the reverse
+// of redactValue. Placeholders without a stored value are left as they are, and false is returned.
+func restoreValue(value, stored any, found bool) (any, bool) {
+	switch v := value.(type) {
+	case map[string]any:
+		storedMap, _ := stored.(map[string]any)
+		for key, child := range v {
+			storedChild, found := storedMap[key]
+			restored, ok := restoreValue(child, storedChild, found)
+			if !ok {
+				return value, false
+			}
+			v[key] = restored
+		}
+	case []any:
+		storedList, _ := stored.([]any)
+		for i, child := range v {
+			var storedChild any
+			found := len(storedList) == len(v)
+			if found {
+				storedChild = storedList[i]
+			}
+			restored, ok := restoreValue(child, storedChild, found)
+			if !ok {
+				return value, false
+			}
+			v[i] = restored
+		}
+	case string:
+		if v == RedactedValue && found {
+			return stored, true
+		}
+		if v == RedactedValue {
+			return value, false
+		}
+	}
+	return value, true
+}
+
+func redactedValueError(path []string) error {
+	return apierror.NewAPIError(validation.InvalidBodyContent,
+		fmt.Sprintf("field %s holds the %s placeholder and has no stored value to keep", formatRedactionPath(path), RedactedValue))
+}
+
+func redactedQueryError(field []string) error {
+	return apierror.NewAPIError(validation.PermissionDenied,
+		fmt.Sprintf("field %s is redacted and can't be filtered, sorted or summarized by", strings.Join(field, ".")))
+}
+
+// formatRedactionPath is the reverse of parseRedactionPath
+func formatRedactionPath(path []string) string {
+	var b strings.Builder
+	for i, segment := range path {
+		if i > 0 && segment != "[*]" {
+			b.WriteString(".")
+		}
+		b.WriteString(segment)
+	}
+	return b.String()
+}
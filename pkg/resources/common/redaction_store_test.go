@@ -0,0 +1,177 @@
+package common
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/rancher/apiserver/pkg/store/empty"
+	"github.com/rancher/apiserver/pkg/types"
+	"github.com/rancher/steve/pkg/accesscontrol"
+	"github.com/rancher/steve/pkg/attributes"
+	"github.com/rancher/wrangler/v3/pkg/schemas"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	schema2 "k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/apiserver/pkg/endpoints/request"
+)
+
+type secretStore struct {
+	empty.Store
+	stored  *unstructured.Unstructured
+	written types.APIObject
+}
+
+func (s *secretStore) ByID(_ *types.APIRequest, _ *types.APISchema, id string) (types.APIObject, error) {
+	return types.APIObject{Type: "secret", ID: id, Object: s.stored.DeepCopy()}, nil
+}
+
+func (s *secretStore) List(_ *types.APIRequest, _ *types.APISchema) (types.APIObjectList, error) {
+	return types.APIObjectList{}, nil
+}
+
+func (s *secretStore) Create(_ *types.APIRequest, _ *types.APISchema, data types.APIObject) (types.APIObject, error) {
+	s.written = data
+	return data, nil
+}
+
+func (s *secretStore) Update(_ *types.APIRequest, _ *types.APISchema, data types.APIObject, _ string) (types.APIObject, error) {
+	s.written = data
+	return data, nil
+}
+
+func TestRedactionStore(t *testing.T) {
+	redactions, err := NewRedactions(RedactionPolicies{Policies: []RedactionPolicy{
+		{Kind: "Secret", Fields: []string{"data.*"}, Exempt: RedactionExemption{Groups: []string{"admins"}}},
+		{Kind: "Secret", Fields: []string{"spec.items[*].value"}, Exempt: RedactionExemption{Groups: []string{"admins"}}},
+	}})
+	require.NoError(t, err)
+
+	secretSchema := &types.APISchema{Schema: &schemas.Schema{ID: "secret"}}
+	gvr := schema2.GroupVersionResource{Version: "v1", Resource: "secrets"}
+	attributes.SetGVR(secretSchema, gvr)
+	attributes.SetGVK(secretSchema, gvr.GroupVersion().WithKind("Secret"))
+
+	lastApplied := `{"data":{"password":"aHVudGVyMg=="}}`
+	newStore := func() *secretStore {
+		return &secretStore{stored: &unstructured.Unstructured{Object: map[string]any{
+			"apiVersion": "v1",
+			"kind":       "Secret",
+			"metadata": map[string]any{
+				"name":        "creds",
+				"namespace":   "default",
+				"annotations": map[string]any{lastAppliedConfigAnnotation: lastApplied},
+			},
+			"data": map[string]any{"password": "aHVudGVyMg==", "token": "dG9rZW4="},
+			"spec": map[string]any{"items": []any{map[string]any{"name": "a", "value": "1"}}},
+		}}}
+	}
+	newRequest := func(method, target string, groups ...string) *types.APIRequest {
+		httpRequest, err := http.NewRequestWithContext(request.WithUser(t.Context(), &user.DefaultInfo{Name: "user", Groups: groups}), method, target, nil)
+		require.NoError(t, err)
+		apiSchemas := types.EmptyAPISchemas()
+		accesscontrol.SetAccessSetAttribute(apiSchemas, &accesscontrol.AccessSet{})
+		return &types.APIRequest{Request: httpRequest, Method: method, Schemas: apiSchemas, Namespace: "default", Name: "creds"}
+	}
+	// redacted returns the object as the formatter shows it to users who aren't exempt
+	redacted := func(store *secretStore) map[string]any {
+		obj := store.stored.DeepCopy()
+		redactions.Apply(newRequest(http.MethodGet, "/v1/secrets/default/creds"), secretSchema, &accesscontrol.AccessSet{}, obj)
+		return obj.Object
+	}
+
+	t.Run("update keeps the stored values of placeholders", func(t *testing.T) {
+		inner := newStore()
+		store := newRedactionStore(inner, nil, TemplateOptions{Redactions: redactions})
+		input := redacted(inner)
+		input["data"].(map[string]any)["token"] = "bmV3"
+		_, err := store.Update(newRequest(http.MethodPut, "/v1/secrets/default/creds"), secretSchema, types.APIObject{Object: input}, "default/creds")
+		require.NoError(t, err)
+		written := &unstructured.Unstructured{Object: inner.written.Data()}
+		assert.Equal(t, map[string]any{"password": "aHVudGVyMg==", "token": "bmV3"}, written.Object["data"])
+		assert.Equal(t, []any{map[string]any{"name": "a", "value": "1"}}, written.Object["spec"].(map[string]any)["items"])
+		assert.Equal(t, lastApplied, written.GetAnnotations()[lastAppliedConfigAnnotation])
+	})
+
+	t.Run("update rejects placeholders without a stored value", func(t *testing.T) {
+		inner := newStore()
+		store := newRedactionStore(inner, nil, TemplateOptions{Redactions: redactions})
+
+		input := redacted(inner)
+		input["data"].(map[string]any)["new"] = RedactedValue
+		_, err := store.Update(newRequest(http.MethodPut, "/v1/secrets/default/creds"), secretSchema, types.APIObject{Object: input}, "default/creds")
+		assert.ErrorContains(t, err, "data.*")
+
+		input = redacted(inner)
+		spec := input["spec"].(map[string]any)
+		spec["items"] = append(spec["items"].([]any), map[string]any{"name": "b", "value": RedactedValue})
+		_, err = store.Update(newRequest(http.MethodPut, "/v1/secrets/default/creds"), secretSchema, types.APIObject{Object: input}, "default/creds")
+		assert.ErrorContains(t, err, "spec.items[*].value", "list items are matched by position")
+	})
+
+	t.Run("patches with placeholders are rejected", func(t *testing.T) {
+		store := newRedactionStore(newStore(), nil, TemplateOptions{Redactions: redactions})
+		apiOp := newRequest(http.MethodPatch, "/v1/secrets/default/creds")
+		apiOp.Request.Body = io.NopCloser(strings.NewReader(`{"data":{"password":"<redacted>"}}`))
+		_, err := store.Update(apiOp, secretSchema, types.APIObject{}, "default/creds")
+		assert.Error(t, err)
+
+		apiOp.Request.Body = io.NopCloser(strings.NewReader(`[{"op":"replace","path":"/data/password","value":"bmV3"}]`))
+		_, err = store.Update(apiOp, secretSchema, types.APIObject{}, "default/creds")
+		require.NoError(t, err)
+		body, err := io.ReadAll(apiOp.Request.Body)
+		require.NoError(t, err)
+		assert.Contains(t, string(body), "bmV3", "the patch can be read again")
+	})
+
+	t.Run("create rejects placeholders", func(t *testing.T) {
+		inner := newStore()
+		store := newRedactionStore(inner, nil, TemplateOptions{Redactions: redactions})
+		_, err := store.Create(newRequest(http.MethodPost, "/v1/secrets"), secretSchema, types.APIObject{Object: redacted(inner)})
+		assert.Error(t, err)
+
+		_, err = store.Create(newRequest(http.MethodPost, "/v1/secrets", "admins"), secretSchema, types.APIObject{Object: redacted(inner)})
+		assert.NoError(t, err, "exempt users may write the placeholder")
+	})
+
+	t.Run("lists by redacted fields are rejected", func(t *testing.T) {
+		for _, inSQLMode := range []bool{false, true} {
+			store := newRedactionStore(newStore(), nil, TemplateOptions{Redactions: redactions, InSQLMode: inSQLMode})
+			for _, query := range []string{"filter=data.password=abc", "sort=data.token", "filter=data=abc"} {
+				_, err := store.List(newRequest(http.MethodGet, "/v1/secrets?"+query), secretSchema)
+				assert.Error(t, err, query)
+			}
+			_, err := store.List(newRequest(http.MethodGet, "/v1/secrets?filter=data.password=abc", "admins"), secretSchema)
+			assert.NoError(t, err, "exempt users may filter by redacted fields")
+			_, err = store.List(newRequest(http.MethodGet, "/v1/secrets?filter=metadata.name=creds&sort=metadata.name"), secretSchema)
+			assert.NoError(t, err)
+		}
+
+		store := newRedactionStore(newStore(), nil, TemplateOptions{Redactions: redactions, InSQLMode: true})
+		for _, query := range []string{"summary=data.password", "filter=metadata.annotations[kubectl.kubernetes.io/last-applied-configuration]=x"} {
+			_, err := store.List(newRequest(http.MethodGet, "/v1/secrets?"+query), secretSchema)
+			assert.Error(t, err, query)
+		}
+	})
+}
+
+func TestMatchRedactedField(t *testing.T) {
+	path := []string{"spec", "containers", "[*]", "env", "[*]", "value"}
+
+	rest, overlaps := matchRedactedField(path, []string{"spec", "containers", "env", "value"})
+	assert.True(t, overlaps)
+	assert.Empty(t, rest)
+
+	rest, overlaps = matchRedactedField(path, []string{"spec", "containers"})
+	assert.True(t, overlaps, "parents of redacted fields overlap them")
+	assert.Equal(t, []string{"[*]", "env", "[*]", "value"}, rest)
+
+	_, overlaps = matchRedactedField([]string{"data", "*"}, []string{"data", "password", "nested"})
+	assert.True(t, overlaps, "fields within redacted values overlap them")
+
+	_, overlaps = matchRedactedField(path, []string{"spec", "containers", "image"})
+	assert.False(t, overlaps)
+}
@@ -0,0 +1,257 @@
+package common
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/rancher/apiserver/pkg/types"
+	"github.com/rancher/apiserver/pkg/urlbuilder"
+	"github.com/rancher/steve/pkg/accesscontrol"
+	"github.com/rancher/steve/pkg/attributes"
+	"github.com/rancher/steve/pkg/resources/virtual/common"
+	"github.com/rancher/wrangler/v3/pkg/schemas"
+	"github.com/rancher/wrangler/v3/pkg/summary"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	schema2 "k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/apiserver/pkg/endpoints/request"
+)
+
+func TestParseRedactionPath(t *testing.T) {
+	path, err := parseRedactionPath("spec.containers[*].env[*].value")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"spec", "containers", "[*]", "env", "[*]", "value"}, path)
+
+	path, err = parseRedactionPath("data.*")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"data", "*"}, path)
+
+	for _, field := range []string{"spec..containers", "spec.containers[0]", "[*]", "spec.containers[*"} {
+		_, err := parseRedactionPath(field)
+		assert.Error(t, err, field)
+	}
+}
+
+func TestLoadRedactionPolicies(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "policies.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`
+policies:
+- version: v1
+  kind: Secret
+  fields: ["data.*", "stringData.*"]
+  exempt:
+    verb: update
+    groups: ["admins"]
+`), 0o600))
+	policies, err := LoadRedactionPolicies(path)
+	require.NoError(t, err)
+	assert.Equal(t, RedactionPolicies{Policies: []RedactionPolicy{{
+		Version: "v1",
+		Kind:    "Secret",
+		Fields:  []string{"data.*", "stringData.*"},
+		Exempt:  RedactionExemption{Verb: "update", Groups: []string{"admins"}},
+	}}}, policies)
+
+	for _, invalid := range []RedactionPolicies{
+		{Policies: []RedactionPolicy{{Fields: []string{"data.*"}}}},
+		{Policies: []RedactionPolicy{{Kind: "Secret"}}},
+		{Policies: []RedactionPolicy{{Kind: "Secret", Fields: []string{"data"}, Exempt: RedactionExemption{Resource: "secrets"}}}},
+		{Policies: []RedactionPolicy{{Kind: "Secret", Fields: []string{"data"}, Exempt: RedactionExemption{Group: "apps"}}}},
+	} {
+		_, err := NewRedactions(invalid)
+		assert.Error(t, err)
+	}
+}
+
+func TestRedactionsInFormatter(t *testing.T) {
+	redactions, err := NewRedactions(RedactionPolicies{Policies: []RedactionPolicy{
+		{Kind: "Pod", Fields: []string{"spec.containers[*].env[*].value"}, Exempt: RedactionExemption{Verb: "update"}},
+		{Kind: "Pod", Version: "v2", Fields: []string{"metadata.name"}},
+		{Kind: "Pod", Fields: []string{"metadata.annotations"}, Exempt: RedactionExemption{Groups: []string{"admins"}}},
+	}})
+	require.NoError(t, err)
+
+	podSchema := &types.APISchema{Schema: &schemas.Schema{ID: "pod"}}
+	gvr := schema2.GroupVersionResource{Version: "v1", Resource: "pods"}
+	attributes.SetGVR(podSchema, gvr)
+	attributes.SetGVK(podSchema, gvr.GroupVersion().WithKind("Pod"))
+
+	format := func(userInfo user.Info, accessSet *accesscontrol.AccessSet) map[string]any {
+		obj := &unstructured.Unstructured{Object: map[string]any{
+			"apiVersion": "v1",
+			"kind":       "Pod",
+			"metadata": map[string]any{
+				"name":        "web",
+				"namespace":   "default",
+				"annotations": map[string]any{"token": "abc"},
+			},
+			"spec": map[string]any{
+				"containers": []any{map[string]any{
+					"name": "web",
+					"env": []any{
+						map[string]any{"name": "PASSWORD", "value": "hunter2"},
+						map[string]any{"name": "FROM_SECRET", "valueFrom": map[string]any{"secretKeyRef": map[string]any{"name": "creds"}}},
+					},
+				}},
+			},
+		}}
+		httpRequest, err := http.NewRequestWithContext(request.WithUser(t.Context(), userInfo), http.MethodGet, "/v1/pods", nil)
+		require.NoError(t, err)
+		apiSchemas := types.EmptyAPISchemas()
+		accesscontrol.SetAccessSetAttribute(apiSchemas, accessSet)
+		apiOp := &types.APIRequest{Request: httpRequest, URLBuilder: &urlbuilder.DefaultURLBuilder{}, Schemas: apiSchemas}
+		resource := &types.RawResource{
+			Schema:    podSchema,
+			APIObject: types.APIObject{Type: "pod", ID: "default/web", Object: obj},
+			Links:     map[string]string{},
+		}
+		summaryCache := &common.FakeSummaryCache{SummarizedObject: &summary.SummarizedObject{}}
+		formatter(summaryCache, nil, TemplateOptions{Redactions: redactions})(apiOp, resource)
+		return obj.Object
+	}
+
+	viewer := format(&user.DefaultInfo{Name: "viewer"}, &accesscontrol.AccessSet{})
+	env, _, _ := unstructured.NestedSlice(viewer, "spec", "containers")
+	assert.Equal(t, []any{
+		map[string]any{"name": "PASSWORD", "value": RedactedValue},
+		map[string]any{"name": "FROM_SECRET", "valueFrom": map[string]any{"secretKeyRef": map[string]any{"name": "creds"}}},
+	}, env[0].(map[string]any)["env"], "items without the field are left unchanged")
+	assert.Equal(t, "web", viewer["metadata"].(map[string]any)["name"], "policies of other versions are ignored")
+	assert.Equal(t, map[string]any{"token": RedactedValue}, viewer["metadata"].(map[string]any)["annotations"])
+
+	editorAccess := &accesscontrol.AccessSet{}
+	editorAccess.Add("update", gvr.GroupResource(), accesscontrol.Access{Namespace: "default", ResourceName: accesscontrol.All})
+	editor := format(&user.DefaultInfo{Name: "editor", Groups: []string{"admins"}}, editorAccess)
+	env, _, _ = unstructured.NestedSlice(editor, "spec", "containers")
+	assert.Equal(t, map[string]any{"name": "PASSWORD", "value": "hunter2"}, env[0].(map[string]any)["env"].([]any)[0])
+	assert.Equal(t, map[string]any{"token": "abc"}, editor["metadata"].(map[string]any)["annotations"])
+}
+
+func TestRedactionsFailClosed(t *testing.T) {
+	redactions, err := NewRedactions(RedactionPolicies{Policies: []RedactionPolicy{
+		{Kind: "Secret", Fields: []string{"data.*"}, Exempt: RedactionExemption{Verb: "update"}},
+	}})
+	require.NoError(t, err)
+
+	secretSchema := func(version string) *types.APISchema {
+		apiSchema := &types.APISchema{Schema: &schemas.Schema{ID: "secret"}}
+		gvr := schema2.GroupVersionResource{Version: version, Resource: "secrets"}
+		attributes.SetGVR(apiSchema, gvr)
+		attributes.SetGVK(apiSchema, gvr.GroupVersion().WithKind("Secret"))
+		return apiSchema
+	}
+	lastApplied := `{"apiVersion":"v1","kind":"Secret","data":{"password":"aHVudGVyMg=="}}`
+	format := func(apiSchema *types.APISchema, userInfo user.Info, accessSet *accesscontrol.AccessSet) *unstructured.Unstructured {
+		obj := &unstructured.Unstructured{Object: map[string]any{
+			"apiVersion": "v1",
+			"kind":       "Secret",
+			"metadata": map[string]any{
+				"name":        "creds",
+				"namespace":   "default",
+				"annotations": map[string]any{lastAppliedConfigAnnotation: lastApplied},
+			},
+			"data": map[string]any{"password": "aHVudGVyMg=="},
+		}}
+		ctx := t.Context()
+		if userInfo != nil {
+			ctx = request.WithUser(ctx, userInfo)
+		}
+		httpRequest, err := http.NewRequestWithContext(ctx, http.MethodGet, "/v1/secrets", nil)
+		require.NoError(t, err)
+		apiSchemas := types.EmptyAPISchemas()
+		if accessSet != nil {
+			accesscontrol.SetAccessSetAttribute(apiSchemas, accessSet)
+		}
+		apiOp := &types.APIRequest{Request: httpRequest, URLBuilder: &urlbuilder.DefaultURLBuilder{}, Schemas: apiSchemas}
+		resource := &types.RawResource{
+			Schema:    apiSchema,
+			APIObject: types.APIObject{Type: "secret", ID: "default/creds", Object: obj},
+			Links:     map[string]string{},
+		}
+		summaryCache := &common.FakeSummaryCache{SummarizedObject: &summary.SummarizedObject{}}
+		formatter(summaryCache, nil, TemplateOptions{Redactions: redactions})(apiOp, resource)
+		return obj
+	}
+	redacted := map[string]any{"password": RedactedValue}
+
+	noUser := format(secretSchema("v1"), nil, nil)
+	assert.Equal(t, redacted, noUser.Object["data"], "requests without a user are redacted")
+	assert.Equal(t, RedactedValue, noUser.GetAnnotations()[lastAppliedConfigAnnotation])
+
+	noVersion := format(secretSchema(""), &user.DefaultInfo{Name: "viewer"}, &accesscontrol.AccessSet{})
+	assert.Equal(t, redacted, noVersion.Object["data"], "schemas without a version are redacted")
+	assert.Equal(t, RedactedValue, noVersion.GetAnnotations()[lastAppliedConfigAnnotation])
+
+	editorAccess := &accesscontrol.AccessSet{}
+	editorAccess.Add("update", schema2.GroupResource{Resource: "secrets"}, accesscontrol.Access{Namespace: "default", ResourceName: accesscontrol.All})
+	editor := format(secretSchema("v1"), &user.DefaultInfo{Name: "editor"}, editorAccess)
+	assert.Equal(t, map[string]any{"password": "aHVudGVyMg=="}, editor.Object["data"])
+	assert.Equal(t, lastApplied, editor.GetAnnotations()[lastAppliedConfigAnnotation], "exempt users see the last applied configuration")
+}
+
+func TestRedactionsOfAssociatedData(t *testing.T) {
+	redactions, err := NewRedactions(RedactionPolicies{Policies: []RedactionPolicy{
+		{Kind: "Pod", Fields: []string{"spec.containers[*].env[*].value"}, Exempt: RedactionExemption{Verb: "update"}},
+	}})
+	require.NoError(t, err)
+
+	apiSchemas := types.EmptyAPISchemas()
+	for _, gvk := range []schema2.GroupVersionKind{{Group: "apps", Version: "v1", Kind: "Deployment"}, {Version: "v1", Kind: "Pod"}} {
+		apiSchema := &types.APISchema{Schema: &schemas.Schema{ID: map[string]string{"Deployment": "apps.deployment", "Pod": "pod"}[gvk.Kind]}}
+		attributes.SetGVK(apiSchema, gvk)
+		attributes.SetGVR(apiSchema, gvk.GroupVersion().WithResource(strings.ToLower(gvk.Kind)+"s"))
+		require.NoError(t, apiSchemas.AddSchema(*apiSchema))
+	}
+	accessSet := &accesscontrol.AccessSet{}
+	accessSet.Add("update", schema2.GroupResource{Resource: "pods"}, accesscontrol.Access{Namespace: "default", ResourceName: "web-2"})
+
+	// items listed by changesSince carry metadata.change, and are redacted like any other
+	obj := &unstructured.Unstructured{Object: map[string]any{
+		"apiVersion": "apps/v1",
+		"kind":       "Deployment",
+		"metadata": map[string]any{
+			"name":      "web",
+			"namespace": "default",
+			"change":    "modified",
+			"associatedData": []any{map[string]any{
+				"gvk": map[string]any{"group": "", "version": "v1", "kind": "Pod"},
+				"data": []any{
+					map[string]any{
+						"childName": "web-1",
+						"state":     map[string]any{"name": "error", "message": "invalid value hunter2"},
+						"fields": map[string]any{
+							"spec.containers": []any{map[string]any{"name": "web", "env": []any{map[string]any{"name": "PASSWORD", "value": "hunter2"}}}},
+							"spec.nodeName":   "node-1",
+						},
+					},
+					map[string]any{
+						"childName": "web-2",
+						"state":     map[string]any{"name": "error", "message": "invalid value hunter2"},
+						"fields":    map[string]any{"spec.containers": []any{map[string]any{"name": "web", "env": []any{map[string]any{"name": "PASSWORD", "value": "hunter2"}}}}},
+					},
+				},
+			}},
+		},
+	}}
+	httpRequest, err := http.NewRequestWithContext(request.WithUser(t.Context(), &user.DefaultInfo{Name: "viewer"}), http.MethodGet, "/v1/apps.deployments", nil)
+	require.NoError(t, err)
+	apiOp := &types.APIRequest{Request: httpRequest, Schemas: apiSchemas}
+	redactions.Apply(apiOp, apiSchemas.LookupSchema("apps.deployment"), accessSet, obj)
+
+	data, _, _ := unstructured.NestedSlice(obj.Object, "metadata", "associatedData")
+	children := data[0].(map[string]any)["data"].([]any)
+	assert.Equal(t, map[string]any{
+		"childName": "web-1",
+		"state":     map[string]any{"name": "error", "message": RedactedValue},
+		"fields": map[string]any{
+			"spec.containers": []any{map[string]any{"name": "web", "env": []any{map[string]any{"name": "PASSWORD", "value": RedactedValue}}}},
+			"spec.nodeName":   "node-1",
+		},
+	}, children[0])
+	assert.Equal(t, "invalid value hunter2", children[1].(map[string]any)["state"].(map[string]any)["message"], "exempt children are left unchanged")
+}
@@ -0,0 +1,297 @@
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/rancher/apiserver/pkg/apierror"
+	"github.com/rancher/apiserver/pkg/types"
+	"github.com/rancher/steve/pkg/accesscontrol"
+	"github.com/rancher/steve/pkg/attributes"
+	"github.com/rancher/steve/pkg/resources/virtual/common"
+	"github.com/rancher/steve/pkg/schema/converter"
+	"github.com/rancher/wrangler/v3/pkg/schemas/validation"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	schema2 "k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const (
+	treeLink         = "tree"
+	treeDepthParam   = "depth"
+	defaultTreeDepth = 5
+	maxTreeDepth     = 10
+	// maxTreeNodes bounds the size of a response, for objects owning a large number of others
+	maxTreeNodes = 1000
+	ownerRel     = "owner"
+)
+
+// TreeNode is an object of the ownership graph returned by the tree link
+type TreeNode struct {
+	Type  string        `json:"type"`
+	ID    string        `json:"id"`
+	State TreeNodeState `json:"state"`
+	// Owners are the objects owning this one. Only set for the requested object and its owners.
+	Owners []*TreeNode `json:"owners,omitempty"`
+	// Children are the objects owned by this one. Only set for the requested object and its descendants.
+	Children []*TreeNode `json:"children,omitempty"`
+	// Truncated is set when the owners or children of the object were not followed because of the depth or size limits
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+// TreeNodeState is the summarized state of a TreeNode, as in metadata.state
+type TreeNodeState struct {
+	Name          string `json:"name"`
+	Error         bool   `json:"error"`
+	Transitioning bool   `json:"transitioning"`
+	Message       string `json:"message"`
+}
+
+// addTreeLink registers the tree link handler on Kubernetes schemas
+func addTreeLink(summaryCache common.SummaryCache, asl accesscontrol.AccessSetLookup, redactions *Redactions) func(*types.APISchema) {
+	return func(apiSchema *types.APISchema) {
+		if attributes.GVR(apiSchema).Version == "" {
+			return
+		}
+		if apiSchema.LinkHandlers == nil {
+			apiSchema.LinkHandlers = map[string]http.Handler{}
+		}
+		apiSchema.LinkHandlers[treeLink] = treeHandler(summaryCache, asl, redactions)
+	}
+}
+
+// treeHandler returns the owners and descendants of the requested object, built from its owner references and the
+// relationships of the summary cache. Objects the user can't get are left out, along with the objects reached through
+// them. The state messages of objects with fields redacted for the user are redacted, as they may be derived from them.
+func treeHandler(summaryCache common.SummaryCache, asl accesscontrol.AccessSetLookup, redactions *Redactions) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		apiOp := types.GetAPIContext(req.Context())
+		if apiOp == nil || apiOp.Schema == nil || apiOp.Name == "" {
+			http.Error(rw, "tree link requires an object", http.StatusBadRequest)
+			return
+		}
+		tree, err := buildTree(apiOp, summaryCache, asl, redactions)
+		if err != nil {
+			apiOp.WriteError(err)
+			return
+		}
+		rw.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(rw).Encode(tree); err != nil {
+			apiOp.WriteError(err)
+		}
+	})
+}
+
+type treeBuilder struct {
+	apiOp        *types.APIRequest
+	summaryCache common.SummaryCache
+	accessSet    *accesscontrol.AccessSet
+	redactions   *Redactions
+	maxDepth     int
+	nodes        int
+	visited      map[string]bool
+}
+
+func buildTree(apiOp *types.APIRequest, summaryCache common.SummaryCache, asl accesscontrol.AccessSetLookup, redactions *Redactions) (*TreeNode, error) {
+	maxDepth, err := treeDepth(apiOp)
+	if err != nil {
+		return nil, err
+	}
+	accessSet := accesscontrol.AccessSetFromAPIRequest(apiOp)
+	if accessSet == nil {
+		userInfo, ok := apiOp.GetUserInfo()
+		if !ok {
+			return nil, apierror.NewAPIError(validation.PermissionDenied, "no user found")
+		}
+		accessSet = asl.AccessFor(userInfo)
+	}
+	if apiOp.Schema.Store == nil {
+		return nil, apierror.NewAPIError(validation.NotFound, "no store found")
+	}
+	obj, err := apiOp.Schema.Store.ByID(apiOp, apiOp.Schema, apiOp.Name)
+	if err != nil {
+		return nil, err
+	}
+	object, ok := obj.Object.(runtime.Object)
+	if !ok {
+		return nil, apierror.NewAPIError(validation.ServerError, fmt.Sprintf("unexpected object type %T", obj.Object))
+	}
+
+	b := &treeBuilder{
+		apiOp:        apiOp,
+		summaryCache: summaryCache,
+		accessSet:    accessSet,
+		redactions:   redactions,
+		maxDepth:     maxDepth,
+		visited:      map[string]bool{},
+	}
+	root := b.node(apiOp.Schema, object)
+	b.visited[root.Type+":"+root.ID] = true
+	root.Owners, root.Truncated = b.owners(object, 1)
+	var truncated bool
+	root.Children, truncated = b.children(object, 1)
+	root.Truncated = root.Truncated || truncated
+	return root, nil
+}
+
+// treeDepth returns the maximum number of owners and descendants levels to follow from the depth query parameter
+func treeDepth(apiOp *types.APIRequest) (int, error) {
+	value := apiOp.Request.URL.Query().Get(treeDepthParam)
+	if value == "" {
+		return defaultTreeDepth, nil
+	}
+	depth, err := strconv.Atoi(value)
+	if err != nil || depth < 0 || depth > maxTreeDepth {
+		return 0, apierror.NewAPIError(validation.InvalidBodyContent,
+			fmt.Sprintf("depth must be an integer between 0 and %d", maxTreeDepth))
+	}
+	return depth, nil
+}
+
+// owners follows the owner references of obj, fetching each owner through the store of its schema
+func (b *treeBuilder) owners(obj runtime.Object, depth int) ([]*TreeNode, bool) {
+	m, err := meta.Accessor(obj)
+	if err != nil || len(m.GetOwnerReferences()) == 0 {
+		return nil, false
+	}
+	if depth > b.maxDepth || b.nodes >= maxTreeNodes {
+		return nil, true
+	}
+
+	var (
+		owners    []*TreeNode
+		truncated bool
+	)
+	for _, ref := range m.GetOwnerReferences() {
+		ownerSchema := b.apiOp.Schemas.LookupSchema(converter.GVKToSchemaID(schema2.FromAPIVersionAndKind(ref.APIVersion, ref.Kind)))
+		if ownerSchema == nil || ownerSchema.Store == nil {
+			continue
+		}
+		namespace := ""
+		if attributes.Namespaced(ownerSchema) {
+			namespace = m.GetNamespace()
+		}
+		if !b.canGet(ownerSchema, namespace, ref.Name) || b.seen(ownerSchema, namespace, ref.Name) {
+			continue
+		}
+
+		ownerOp := b.apiOp.Clone()
+		ownerOp.Schema = ownerSchema
+		ownerOp.Type = ownerSchema.ID
+		ownerOp.Namespace = namespace
+		ownerOp.Name = ref.Name
+		ownerObj, err := ownerSchema.Store.ByID(ownerOp, ownerSchema, ref.Name)
+		if err != nil {
+			continue
+		}
+		owner, ok := ownerObj.Object.(runtime.Object)
+		if !ok {
+			continue
+		}
+		node := b.node(ownerSchema, owner)
+		node.Owners, node.Truncated = b.owners(owner, depth+1)
+		owners = append(owners, node)
+		if b.nodes >= maxTreeNodes {
+			truncated = true
+			break
+		}
+	}
+	return owners, truncated
+}
+
+// children follows the owner relationships of the summary cache pointing to obj, without fetching the descendants
+func (b *treeBuilder) children(obj runtime.Object, depth int) ([]*TreeNode, bool) {
+	_, rels := b.summaryCache.SummaryAndRelationship(obj)
+	var children []*TreeNode
+	for _, rel := range rels {
+		if rel.Rel != ownerRel || rel.ToID == "" {
+			continue
+		}
+		if depth > b.maxDepth || b.nodes >= maxTreeNodes {
+			return children, true
+		}
+		childSchema := b.apiOp.Schemas.LookupSchema(rel.ToType)
+		if childSchema == nil {
+			continue
+		}
+		namespace, name := "", rel.ToID
+		if ns, n, ok := strings.Cut(rel.ToID, "/"); ok {
+			namespace, name = ns, n
+		}
+		if !b.canGet(childSchema, namespace, name) || b.seen(childSchema, namespace, name) {
+			continue
+		}
+
+		b.nodes++
+		node := &TreeNode{
+			Type: childSchema.ID,
+			ID:   rel.ToID,
+			State: TreeNodeState{
+				Name:          rel.State,
+				Error:         rel.Error,
+				Transitioning: rel.Transitioning,
+				Message:       b.message(childSchema, namespace, name, rel.Message),
+			},
+		}
+		// relationships are indexed by object key, so a stub is enough to find the descendants
+		child := &unstructured.Unstructured{}
+		child.SetGroupVersionKind(attributes.GVK(childSchema))
+		child.SetNamespace(namespace)
+		child.SetName(name)
+		node.Children, node.Truncated = b.children(child, depth+1)
+		children = append(children, node)
+	}
+	return children, false
+}
+
+// node returns a TreeNode for an object fetched from the store, summarizing its state
+func (b *treeBuilder) node(apiSchema *types.APISchema, obj runtime.Object) *TreeNode {
+	b.nodes++
+	node := &TreeNode{Type: apiSchema.ID}
+	var namespace, name string
+	if m, err := meta.Accessor(obj); err == nil {
+		namespace, name = m.GetNamespace(), m.GetName()
+		node.ID = name
+		if namespace != "" {
+			node.ID = namespace + "/" + name
+		}
+	}
+	if s, _ := b.summaryCache.SummaryAndRelationship(obj); s != nil {
+		node.State = TreeNodeState{
+			Name:          s.State,
+			Error:         s.Error,
+			Transitioning: s.Transitioning,
+			Message:       b.message(apiSchema, namespace, name, strings.Join(s.Message, ":")),
+		}
+	}
+	return node
+}
+
+// message returns the state message of an object, redacted if any of its fields is redacted for the user
+func (b *treeBuilder) message(apiSchema *types.APISchema, namespace, name, message string) string {
+	if message != "" && b.redactions.Redacts(b.apiOp, apiSchema, b.accessSet, namespace, name) {
+		return RedactedValue
+	}
+	return message
+}
+
+func (b *treeBuilder) canGet(apiSchema *types.APISchema, namespace, name string) bool {
+	return b.accessSet != nil && b.accessSet.Grants("get", attributes.GVR(apiSchema).GroupResource(), namespace, name)
+}
+
+// seen marks an object as part of the tree, returning whether it already was
+func (b *treeBuilder) seen(apiSchema *types.APISchema, namespace, name string) bool {
+	key := apiSchema.ID + ":" + name
+	if namespace != "" {
+		key = apiSchema.ID + ":" + namespace + "/" + name
+	}
+	if b.visited[key] {
+		return true
+	}
+	b.visited[key] = true
+	return false
+}
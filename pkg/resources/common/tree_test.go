@@ -0,0 +1,173 @@
+package common
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/rancher/apiserver/pkg/store/empty"
+	"github.com/rancher/apiserver/pkg/types"
+	"github.com/rancher/steve/pkg/accesscontrol"
+	"github.com/rancher/steve/pkg/attributes"
+	"github.com/rancher/steve/pkg/summarycache"
+	"github.com/rancher/wrangler/v3/pkg/schemas"
+	"github.com/rancher/wrangler/v3/pkg/summary"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	schema2 "k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+type treeStore struct {
+	empty.Store
+	objects map[string]*unstructured.Unstructured
+}
+
+func (t *treeStore) ByID(apiOp *types.APIRequest, schema *types.APISchema, id string) (types.APIObject, error) {
+	obj, ok := t.objects[schema.ID+":"+apiOp.Namespace+"/"+id]
+	if !ok {
+		return types.APIObject{}, http.ErrMissingFile
+	}
+	return types.APIObject{Type: schema.ID, ID: id, Object: obj}, nil
+}
+
+type treeSummaryCache struct {
+	relationships map[string][]summarycache.Relationship
+	message       []string
+}
+
+func (t *treeSummaryCache) SummaryAndRelationship(obj runtime.Object) (*summary.SummarizedObject, []summarycache.Relationship) {
+	u := obj.(*unstructured.Unstructured)
+	return &summary.SummarizedObject{Summary: summary.Summary{State: "active", Message: t.message}},
+		t.relationships[u.GetKind()+":"+u.GetNamespace()+"/"+u.GetName()]
+}
+
+func TestTreeHandler(t *testing.T) {
+	store := &treeStore{objects: map[string]*unstructured.Unstructured{}}
+	apiSchemas := types.EmptyAPISchemas()
+	accessSet := &accesscontrol.AccessSet{}
+	for _, gvr := range []schema2.GroupVersionResource{
+		{Group: "apps", Version: "v1", Resource: "deployments"},
+		{Group: "apps", Version: "v1", Resource: "replicasets"},
+		{Group: "", Version: "v1", Resource: "pods"},
+	} {
+		kind := map[string]string{"deployments": "Deployment", "replicasets": "ReplicaSet", "pods": "Pod"}[gvr.Resource]
+		s := &types.APISchema{Schema: &schemas.Schema{ID: map[string]string{"Deployment": "apps.deployment", "ReplicaSet": "apps.replicaset", "Pod": "pod"}[kind]}}
+		attributes.SetGVR(s, gvr)
+		attributes.SetGVK(s, gvr.GroupVersion().WithKind(kind))
+		attributes.SetNamespaced(s, true)
+		s.Store = store
+		require.NoError(t, apiSchemas.AddSchema(*s))
+		if kind != "Pod" {
+			accessSet.Add("get", gvr.GroupResource(), accesscontrol.Access{Namespace: "default", ResourceName: accesscontrol.All})
+		}
+	}
+	// only one of the pods is visible to the user
+	accessSet.Add("get", schema2.GroupResource{Resource: "pods"}, accesscontrol.Access{Namespace: "default", ResourceName: "web-abc-1"})
+	accesscontrol.SetAccessSetAttribute(apiSchemas, accessSet)
+
+	newObject := func(apiVersion, kind, name string, owner *metav1.OwnerReference) *unstructured.Unstructured {
+		obj := &unstructured.Unstructured{}
+		obj.SetAPIVersion(apiVersion)
+		obj.SetKind(kind)
+		obj.SetNamespace("default")
+		obj.SetName(name)
+		if owner != nil {
+			obj.SetOwnerReferences([]metav1.OwnerReference{*owner})
+		}
+		return obj
+	}
+	store.objects["apps.deployment:default/web"] = newObject("apps/v1", "Deployment", "web", nil)
+	store.objects["apps.replicaset:default/web-abc"] = newObject("apps/v1", "ReplicaSet", "web-abc",
+		&metav1.OwnerReference{APIVersion: "apps/v1", Kind: "Deployment", Name: "web"})
+	summaryCache := &treeSummaryCache{relationships: map[string][]summarycache.Relationship{
+		"ReplicaSet:default/web-abc": {
+			{FromID: "default/web", FromType: "apps.deployment", Rel: "owner"},
+			{ToID: "default/web-abc-1", ToType: "pod", Rel: "owner", State: "running"},
+			{ToID: "default/web-abc-2", ToType: "pod", Rel: "owner", State: "pending"},
+			{ToID: "default/web-config", ToType: "configmap", Rel: "uses"},
+		},
+		"Pod:default/web-abc-1": {
+			{FromID: "default/web-abc", FromType: "apps.replicaset", Rel: "owner"},
+		},
+	}}
+
+	request := func(query string) *types.APIRequest {
+		apiOp := &types.APIRequest{
+			Schemas:   apiSchemas,
+			Schema:    apiSchemas.LookupSchema("apps.replicaset"),
+			Type:      "apps.replicaset",
+			Namespace: "default",
+			Name:      "web-abc",
+			Request:   &http.Request{URL: &url.URL{RawQuery: query}},
+		}
+		return apiOp
+	}
+
+	t.Run("owners and descendants", func(t *testing.T) {
+		tree, err := buildTree(request(""), summaryCache, nil, nil)
+		require.NoError(t, err)
+		assert.Equal(t, &TreeNode{
+			Type:  "apps.replicaset",
+			ID:    "default/web-abc",
+			State: TreeNodeState{Name: "active"},
+			Owners: []*TreeNode{
+				{Type: "apps.deployment", ID: "default/web", State: TreeNodeState{Name: "active"}},
+			},
+			Children: []*TreeNode{
+				{Type: "pod", ID: "default/web-abc-1", State: TreeNodeState{Name: "running"}},
+			},
+		}, tree)
+	})
+
+	t.Run("depth limit", func(t *testing.T) {
+		tree, err := buildTree(request("depth=0"), summaryCache, nil, nil)
+		require.NoError(t, err)
+		assert.Empty(t, tree.Owners)
+		assert.Empty(t, tree.Children)
+		assert.True(t, tree.Truncated)
+	})
+
+	t.Run("invalid depth", func(t *testing.T) {
+		_, err := buildTree(request("depth=100"), summaryCache, nil, nil)
+		assert.Error(t, err)
+	})
+
+	t.Run("handler", func(t *testing.T) {
+		rw := httptest.NewRecorder()
+		apiOp := types.StoreAPIContext(request(""))
+		treeHandler(summaryCache, nil, nil).ServeHTTP(rw, apiOp.Request)
+		assert.Equal(t, http.StatusOK, rw.Code)
+		assert.JSONEq(t, `{
+			"type": "apps.replicaset", "id": "default/web-abc",
+			"state": {"name": "active", "error": false, "transitioning": false, "message": ""},
+			"owners": [{"type": "apps.deployment", "id": "default/web",
+				"state": {"name": "active", "error": false, "transitioning": false, "message": ""}}],
+			"children": [{"type": "pod", "id": "default/web-abc-1",
+				"state": {"name": "running", "error": false, "transitioning": false, "message": ""}}]
+		}`, rw.Body.String())
+	})
+	t.Run("redacted messages", func(t *testing.T) {
+		redactions, err := NewRedactions(RedactionPolicies{Policies: []RedactionPolicy{
+			{Kind: "Pod", Fields: []string{"spec.containers[*].env[*].value"}},
+			{Group: "apps", Kind: "Deployment", Fields: []string{"spec.template.spec.containers[*].env[*].value"}},
+		}})
+		require.NoError(t, err)
+		messages := &treeSummaryCache{relationships: map[string][]summarycache.Relationship{}, message: []string{"failed"}}
+		for key, rels := range summaryCache.relationships {
+			for _, rel := range rels {
+				rel.Message = "failed"
+				messages.relationships[key] = append(messages.relationships[key], rel)
+			}
+		}
+
+		tree, err := buildTree(request(""), messages, nil, redactions)
+		require.NoError(t, err)
+		assert.Equal(t, "failed", tree.State.Message, "objects without redacted fields keep their message")
+		assert.Equal(t, RedactedValue, tree.Owners[0].State.Message)
+		assert.Equal(t, RedactedValue, tree.Children[0].State.Message)
+	})
+}
@@ -2,6 +2,7 @@ package cli
 
 import (
 	"context"
+	"fmt"
 	"net/http"
 
 	steveauth "github.com/rancher/steve/pkg/auth"
@@ -13,6 +14,7 @@ import (
 	"github.com/rancher/wrangler/v3/pkg/ratelimit"
 	"github.com/urfave/cli/v2"
 	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/client-go/kubernetes"
 )
 
 type Config struct {
@@ -26,8 +28,14 @@ type Config struct {
 	PprofListenAddr string
 
 	WebhookConfig authcli.WebhookConfig
+	OIDCConfig    authcli.OIDCConfig
+	// ClientCAFile is a CA bundle verifying the client certificates which authenticate users
+	ClientCAFile string
 
 	EnableHeaderAuthentication bool
+	// AuthorizeHeaderAuthentication requires the user authenticated by the webhook to be allowed to impersonate the
+	// user in the headers
+	AuthorizeHeaderAuthentication bool
 }
 
 func (c *Config) MustServer(ctx context.Context) *server.Server {
@@ -49,14 +57,47 @@ func (c *Config) ToServer(ctx context.Context, sqlCache bool) (*server.Server, e
 	}
 	restConfig.RateLimiter = ratelimit.None
 
-	if c.WebhookConfig.WebhookAuthentication {
-		auth, err = c.WebhookConfig.WebhookMiddleware()
+	// client certificates and OIDC tokens are validated locally, so they are checked before asking the webhook
+	var (
+		authenticators []steveauth.Authenticator
+		clientCAs      *steveauth.ClientCAs
+	)
+	if c.ClientCAFile != "" {
+		clientCAs, err = steveauth.NewClientCAs(ctx, c.ClientCAFile)
 		if err != nil {
 			return nil, err
 		}
+		authenticators = append(authenticators, steveauth.NewX509Authenticator(clientCAs))
+	}
+	oidcAuthenticator, err := c.OIDCConfig.OIDCAuthenticator(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if oidcAuthenticator != nil {
+		authenticators = append(authenticators, oidcAuthenticator)
+	}
+	webhookAuthenticator, err := c.WebhookConfig.WebhookAuthenticator()
+	if err != nil {
+		return nil, err
+	}
+	if webhookAuthenticator != nil {
+		authenticators = append(authenticators, webhookAuthenticator)
+	}
+	if len(authenticators) > 0 {
+		auth = steveauth.ToMiddleware(steveauth.UnionAuthenticator(authenticators...))
 	}
 
-	if c.EnableHeaderAuthentication {
+	if c.EnableHeaderAuthentication && c.AuthorizeHeaderAuthentication {
+		if auth == nil {
+			return nil, fmt.Errorf("authorizing header authentication requires client certificate, webhook or OIDC authentication")
+		}
+		client, err := kubernetes.NewForConfig(restConfig)
+		if err != nil {
+			return nil, err
+		}
+		authorizer := steveauth.NewSubjectAccessReviewImpersonationAuthorizer(client.AuthorizationV1().SubjectAccessReviews())
+		auth = auth.Chain(steveauth.AuthorizedImpersonation(authorizer))
+	} else if c.EnableHeaderAuthentication {
 		impersonateOrAdmin := func(req *http.Request) (user.Info, bool, error) {
 			info, ok, err := steveauth.Impersonation(req)
 			if ok || err != nil {
@@ -69,6 +110,7 @@ func (c *Config) ToServer(ctx context.Context, sqlCache bool) (*server.Server, e
 
 	return server.New(ctx, restConfig, &server.Options{
 		AuthMiddleware: auth,
+		ClientCAs:      clientCAs,
 		Next:           ui.New(c.UIPath),
 		SQLCache:       sqlCache,
 		SQLCacheFactoryOptions: factory.CacheFactoryOptions{
@@ -103,6 +145,12 @@ func Flags(config *Config) []cli.Flag {
 			Value:       9080,
 			Destination: &config.HTTPListenPort,
 		},
+		&cli.StringFlag{
+			Name:        "client-ca-file",
+			EnvVars:     []string{"CLIENT_CA_FILE"},
+			Usage:       "Path to a PEM-encoded CA bundle verifying client certificates, whose common name and organizations are mapped to the user and groups. It is reloaded when it changes.",
+			Destination: &config.ClientCAFile,
+		},
 		&cli.BoolFlag{
 			Name:        "enable-pprof",
 			Value:       false,
@@ -119,7 +167,14 @@ func Flags(config *Config) []cli.Flag {
 			Value:       false,
 			Destination: &config.EnableHeaderAuthentication,
 		},
+		&cli.BoolFlag{
+			Name:        "authorize-header-auth",
+			Usage:       "Require the user authenticated by a client certificate, the webhook or OIDC to be allowed to impersonate the users, groups, uids and extras of the impersonation headers, instead of trusting them",
+			Value:       false,
+			Destination: &config.AuthorizeHeaderAuthentication,
+		},
 	}
 
-	return append(flags, authcli.Flags(&config.WebhookConfig)...)
+	flags = append(flags, authcli.Flags(&config.WebhookConfig)...)
+	return append(flags, authcli.OIDCFlags(&config.OIDCConfig)...)
 }
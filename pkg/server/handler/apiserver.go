@@ -7,8 +7,10 @@ import (
 	"github.com/rancher/apiserver/pkg/types"
 	"github.com/rancher/apiserver/pkg/urlbuilder"
 	"github.com/rancher/steve/pkg/accesscontrol"
+	"github.com/rancher/steve/pkg/audit"
 	"github.com/rancher/steve/pkg/auth"
 	k8sproxy "github.com/rancher/steve/pkg/proxy"
+	"github.com/rancher/steve/pkg/ratelimit"
 	"github.com/rancher/steve/pkg/schema"
 	"github.com/rancher/steve/pkg/server/router"
 	"github.com/sirupsen/logrus"
@@ -17,7 +19,8 @@ import (
 )
 
 func New(cfg *rest.Config, sf schema.Factory, authMiddleware auth.Middleware, next http.Handler,
-	routerFunc router.RouterFunc, extensionAPIServer http.Handler) (*apiserver.Server, http.Handler, error) {
+	routerFunc router.RouterFunc, extensionAPIServer http.Handler, auditLogger *audit.Logger, rateLimiter *ratelimit.Limiter,
+	groupResolver accesscontrol.GroupResolver) (*apiserver.Server, http.Handler, error) {
 	var (
 		proxy http.Handler
 		err   error
@@ -26,8 +29,10 @@ func New(cfg *rest.Config, sf schema.Factory, authMiddleware auth.Middleware, ne
 	a := &apiServer{
 		sf:     sf,
 		server: apiserver.DefaultAPIServer(),
+		audit:  auditLogger,
 	}
 	a.server.AccessControl = accesscontrol.NewAccessControl()
+	a.server.Parser = auditLogger.Parser(a.server.Parser)
 
 	if authMiddleware == nil {
 		proxy, err = k8sproxy.Handler("/", cfg)
@@ -39,12 +44,20 @@ func New(cfg *rest.Config, sf schema.Factory, authMiddleware auth.Middleware, ne
 		proxy = k8sproxy.ImpersonatingHandler("/", cfg)
 	}
 
-	w := authMiddleware
+	// the groups added by the resolver are resolved once per request, so that everything handling it sees them
+	w := authMiddleware.Chain(accesscontrol.GroupResolverMiddleware(groupResolver))
+	// rate limits are per user, so they are checked once requests are authenticated
+	limitAPI := rateLimiter.Middleware(ratelimit.APIClass)
+	limitProxy := rateLimiter.Middleware(ratelimit.ProxyClass)
+	// requests rejected before reaching the API handlers, and so the audit Parser, are audited by its middleware
+	api := func(apiFunc APIFunc) http.Handler {
+		return auditLogger.Middleware(w(auditLogger.Authenticated(limitAPI(a.apiHandler(apiFunc)))))
+	}
 	handlers := router.Handlers{
 		Next:        next,
-		K8sResource: w(a.apiHandler(k8sAPI)),
-		K8sProxy:    w(proxy),
-		APIRoot:     w(a.apiHandler(apiRoot)),
+		K8sResource: api(k8sAPI),
+		K8sProxy:    w(limitProxy(proxy)),
+		APIRoot:     api(apiRoot),
 	}
 	if extensionAPIServer != nil {
 		handlers.ExtensionAPIServer = w(extensionAPIServer)
@@ -58,6 +71,7 @@ func New(cfg *rest.Config, sf schema.Factory, authMiddleware auth.Middleware, ne
 type apiServer struct {
 	sf     schema.Factory
 	server *apiserver.Server
+	audit  *audit.Logger
 }
 
 func (a *apiServer) common(rw http.ResponseWriter, req *http.Request) (*types.APIRequest, bool) {
@@ -97,7 +111,9 @@ func (a *apiServer) apiHandler(apiFunc APIFunc) http.Handler {
 			if apiFunc != nil {
 				apiFunc(a.sf, apiOp)
 			}
+			auditRequest := a.audit.StartRequest(apiOp)
 			a.server.Handle(apiOp)
+			auditRequest.Finish()
 		}
 	})
 }
@@ -2,6 +2,7 @@ package server
 
 import (
 	"context"
+	"crypto/tls"
 	"errors"
 	"fmt"
 	"net/http"
@@ -11,12 +12,15 @@ import (
 	"github.com/rancher/dynamiclistener/server"
 	"github.com/rancher/steve/pkg/accesscontrol"
 	"github.com/rancher/steve/pkg/aggregation"
+	"github.com/rancher/steve/pkg/audit"
 	"github.com/rancher/steve/pkg/auth"
 	"github.com/rancher/steve/pkg/client"
 	"github.com/rancher/steve/pkg/clustercache"
 	schemacontroller "github.com/rancher/steve/pkg/controllers/schema"
 	"github.com/rancher/steve/pkg/ext"
+	"github.com/rancher/steve/pkg/ratelimit"
 	"github.com/rancher/steve/pkg/resources"
+	"github.com/rancher/steve/pkg/resources/accessreview"
 	"github.com/rancher/steve/pkg/resources/common"
 	"github.com/rancher/steve/pkg/resources/schemas"
 	"github.com/rancher/steve/pkg/schema"
@@ -76,6 +80,11 @@ type Server struct {
 	aggregationSecretNamespace string
 	aggregationSecretName      string
 	SQLCache                   bool
+
+	auditLogger   *audit.Logger
+	clientCAs     *auth.ClientCAs
+	rateLimiter   *ratelimit.Limiter
+	groupResolver accesscontrol.GroupResolver
 }
 
 type Options struct {
@@ -105,6 +114,22 @@ type Options struct {
 
 	// SkipWaitForExtensionAPIServer allows serving requests despite the ExtensionAPIServer may not have been registered yet.
 	SkipWaitForExtensionAPIServer bool
+
+	// ClientCAs, if set, makes ListenAndServe request client certificates and verify them against these CAs. Users
+	// are mapped from the certificates by auth.NewX509Authenticator, which AuthMiddleware must include.
+	ClientCAs *auth.ClientCAs
+
+	// AuditLogger records the requests to /v1 and the watches of subscriptions. If nil, it is configured by the file
+	// in the CATTLE_AUDIT_CONFIG environment variable, if set.
+	AuditLogger *audit.Logger
+
+	// RateLimiter limits the requests of each user to /v1 and to the Kubernetes API proxy. If nil, it is configured
+	// by the file in the CATTLE_RATE_LIMITS environment variable, if set.
+	RateLimiter *ratelimit.Limiter
+
+	// GroupResolver adds groups to users when computing their access, if AccessSetLookup is nil. If nil, it is
+	// configured by the CATTLE_GROUP_MAPPING_FILE or CATTLE_GROUP_MAPPING_CONFIGMAP environment variables, if set.
+	GroupResolver accesscontrol.GroupResolver
 }
 
 func New(ctx context.Context, restConfig *rest.Config, opts *Options) (*Server, error) {
@@ -138,6 +163,10 @@ func New(ctx context.Context, restConfig *rest.Config, opts *Options) (*Server,
 		cacheFactory:                  cacheFactory,
 		extensionAPIServer:            opts.ExtensionAPIServer,
 		SkipWaitForExtensionAPIServer: opts.SkipWaitForExtensionAPIServer,
+		auditLogger:                   opts.AuditLogger,
+		clientCAs:                     opts.ClientCAs,
+		rateLimiter:                   opts.RateLimiter,
+		groupResolver:                 opts.GroupResolver,
 	}
 
 	if err := setup(ctx, server); err != nil {
@@ -188,8 +217,20 @@ func setup(ctx context.Context, server *Server) error {
 	}
 
 	asl := server.AccessSetLookup
+	var groupResolver accesscontrol.GroupResolver
 	if asl == nil {
-		asl = accesscontrol.NewAccessStore(ctx, true, server.controllers.RBAC)
+		accessStore := accesscontrol.NewAccessStore(ctx, true, server.controllers.RBAC)
+		groupResolver = server.groupResolver
+		if groupResolver == nil {
+			groupResolver, err = accesscontrol.GroupResolverFromEnv(ctx, server.controllers.K8s)
+			if err != nil {
+				return err
+			}
+		}
+		if groupResolver != nil {
+			accessStore.SetGroupResolver(groupResolver)
+		}
+		asl = accessStore
 	}
 
 	ccache := clustercache.NewClusterCache(ctx, cf.AdminDynamicClient())
@@ -199,9 +240,29 @@ func setup(ctx context.Context, server *Server) error {
 	if err = resources.DefaultSchemas(ctx, server.BaseSchemas, ccache, server.ClientFactory, sf, server.Version); err != nil {
 		return err
 	}
+	accessreview.Register(server.BaseSchemas, asl)
 	definitions.Register(ctx, server.BaseSchemas, server.controllers.K8s.Discovery(),
 		server.controllers.CRD.CustomResourceDefinition(), server.controllers.API.APIService())
 
+	redactions, err := common.RedactionsFromEnv()
+	if err != nil {
+		return err
+	}
+
+	if server.auditLogger == nil {
+		server.auditLogger, err = audit.FromEnv(ctx)
+		if err != nil {
+			return err
+		}
+	}
+
+	if server.rateLimiter == nil {
+		server.rateLimiter, err = ratelimit.LimiterFromEnv()
+		if err != nil {
+			return err
+		}
+	}
+
 	summaryCache := summarycache.New(sf, ccache)
 	summaryCache.Start(ctx)
 	cols, err := common.NewDynamicColumns(server.RESTConfig)
@@ -230,7 +291,8 @@ func setup(ctx context.Context, server *Server) error {
 		store := metricsStore.NewMetricsStore(errStore)
 		// end store setup code
 
-		for _, template := range resources.DefaultSchemaTemplatesForStore(store, server.BaseSchemas, summaryCache, asl, server.controllers.K8s.Discovery(), common.TemplateOptions{InSQLMode: true}) {
+		for _, template := range resources.DefaultSchemaTemplatesForStore(store, server.BaseSchemas, summaryCache, asl, server.controllers.K8s.Discovery(), common.TemplateOptions{InSQLMode: true, Redactions: redactions}) {
+			template.Store = audit.NewStore(template.Store, server.auditLogger)
 			sf.AddTemplate(template)
 		}
 
@@ -248,7 +310,8 @@ func setup(ctx context.Context, server *Server) error {
 			return retErr
 		}
 	} else {
-		for _, template := range resources.DefaultSchemaTemplates(cf, server.BaseSchemas, summaryCache, asl, server.controllers.K8s.Discovery(), server.controllers.Core.Namespace().Cache(), common.TemplateOptions{InSQLMode: false}) {
+		for _, template := range resources.DefaultSchemaTemplates(cf, server.BaseSchemas, summaryCache, asl, server.controllers.K8s.Discovery(), server.controllers.Core.Namespace().Cache(), common.TemplateOptions{InSQLMode: false, Redactions: redactions}) {
+			template.Store = audit.NewStore(template.Store, server.auditLogger)
 			sf.AddTemplate(template)
 		}
 		onSchemasHandler = ccache.OnSchemas
@@ -279,7 +342,7 @@ func setup(ctx context.Context, server *Server) error {
 		}
 	})
 
-	apiServer, handler, err := handler.New(server.RESTConfig, sf, server.authMiddleware, next, server.router, server.extensionAPIServer)
+	apiServer, handler, err := handler.New(server.RESTConfig, sf, server.authMiddleware, next, server.router, server.extensionAPIServer, server.auditLogger, server.rateLimiter, groupResolver)
 	if err != nil {
 		return err
 	}
@@ -323,6 +386,12 @@ func (c *Server) ListenAndServe(ctx context.Context, httpsPort, httpPort int, op
 	if len(opts.TLSListenerConfig.SANs) == 0 {
 		opts.TLSListenerConfig.SANs = []string{"127.0.0.1"}
 	}
+	if c.clientCAs != nil {
+		if opts.TLSListenerConfig.TLSConfig == nil {
+			opts.TLSListenerConfig.TLSConfig = &tls.Config{}
+		}
+		c.clientCAs.ConfigureTLS(opts.TLSListenerConfig.TLSConfig)
+	}
 	if err := server.ListenAndServe(ctx, httpsPort, httpPort, c, opts); err != nil {
 		return err
 	}
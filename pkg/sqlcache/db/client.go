@@ -23,6 +23,7 @@ import (
 	"strings"
 	"sync"
 
+	"github.com/rancher/steve/pkg/metrics"
 	"github.com/rancher/steve/pkg/sqlcache/db/logging"
 
 	"github.com/sirupsen/logrus"
@@ -62,6 +63,15 @@ type Client interface {
 	NewConnection(isTemp bool) (string, error)
 	Serialize(obj any, encrypt bool) (SerializedObject, error)
 	Deserialize(SerializedObject, any) error
+	UsingEncoding(opts EncodingOptions) Client
+}
+
+// EncodingOptions select how a Client returned by Client.UsingEncoding serializes objects
+type EncodingOptions struct {
+	Encoding Encoding
+	// TrainDictionary trains a zstd dictionary from the first objects serialized, and uses it from then on.
+	// Only used with ZstdMsgpackEncoding.
+	TrainDictionary bool
 }
 
 // WithTransaction runs f within a transaction.
@@ -74,7 +84,8 @@ type Client interface {
 // SQLITE_BUSY (5) errors (aka "Runtime error: database is locked").
 // See discussion in https://github.com/rancher/lasso/pull/98 for details
 //
-// The transaction is committed if f returns nil, otherwise it is rolled back.
+// The transaction is committed if f returns nil, otherwise it is rolled back. Functions registered with
+// TxClient.OnCommit are called once it is committed, before WithTransaction returns.
 func (c *client) WithTransaction(ctx context.Context, forWriting bool, f WithTransactionFunction) error {
 	if err := c.withTransaction(ctx, forWriting, f); err != nil {
 		return fmt.Errorf("transaction: %w", err)
@@ -91,12 +102,17 @@ func (c *client) withTransaction(ctx context.Context, forWriting bool, f WithTra
 		return fmt.Errorf("begin tx: %w", err)
 	}
 
-	if err := f(NewTxClient(tx, WithQueryLogger(c.queryLogger))); err != nil {
+	txClient := newTxClient(tx, WithQueryLogger(c.queryLogger))
+	if err := f(txClient); err != nil {
 		rerr := c.rollback(ctx, tx)
 		return errors.Join(err, rerr)
 	}
 
-	return c.commit(ctx, tx)
+	if err := c.commit(ctx, tx); err != nil {
+		return err
+	}
+	txClient.committed()
+	return nil
 }
 
 // beginTX handles automatic retries for writing transactions for specific error codes.
@@ -116,6 +132,7 @@ func (c *client) beginTX(ctx context.Context, forWriting bool) (Tx, error) {
 		} else if attempts == maxBeginTXAttemptsOnBusyErrors || !isRetriableSQLiteError(err) {
 			return nil, err
 		}
+		metrics.IncSQLCacheTransactionRetries()
 	}
 }
 
@@ -164,6 +181,10 @@ type client struct {
 	decryptor Decryptor
 	encoding  encoding
 
+	// encodings holds the instances used to deserialize rows which were not serialized with encoding
+	encodings     map[Encoding]encoding
+	encodingsLock sync.Mutex
+
 	queryLogger logging.QueryLogger
 }
 
@@ -417,8 +438,9 @@ func (c *client) ReadInt(rows Rows) (int, error) {
 type SerializedObject struct {
 	Bytes sql.RawBytes
 	// only set if encrypted
-	Nonce sql.RawBytes
-	KeyID uint32
+	Nonce    sql.RawBytes
+	KeyID    uint32
+	Encoding Encoding
 }
 
 func (s SerializedObject) encrypted() bool {
@@ -427,20 +449,24 @@ func (s SerializedObject) encrypted() bool {
 
 func (c *client) readRow(rows Rows) (SerializedObject, error) {
 	var obj SerializedObject
-	if err := rows.Scan(&obj.Bytes, &obj.Nonce, &obj.KeyID); err != nil {
+	if err := rows.Scan(&obj.Bytes, &obj.Nonce, &obj.KeyID, &obj.Encoding); err != nil {
 		return SerializedObject{}, err
 	}
 	return obj, nil
 }
 
 func (c *client) Serialize(obj any, encrypt bool) (SerializedObject, error) {
+	return c.serialize(c.encoding, obj, encrypt)
+}
+
+func (c *client) serialize(enc encoding, obj any, encrypt bool) (SerializedObject, error) {
 	var buf bytes.Buffer
-	if err := c.encoding.Encode(&buf, obj); err != nil {
+	if err := enc.Encode(&buf, obj); err != nil {
 		return SerializedObject{}, err
 	}
 
 	if !encrypt {
-		return SerializedObject{Bytes: buf.Bytes()}, nil
+		return SerializedObject{Bytes: buf.Bytes(), Encoding: enc.Type()}, nil
 	}
 
 	if c.encryptor == nil {
@@ -451,12 +477,13 @@ func (c *client) Serialize(obj any, encrypt bool) (SerializedObject, error) {
 		return SerializedObject{}, err
 	}
 
-	return SerializedObject{Bytes: data, Nonce: nonce, KeyID: kid}, nil
+	return SerializedObject{Bytes: data, Nonce: nonce, KeyID: kid, Encoding: enc.Type()}, nil
 }
 
 func (c *client) Deserialize(serialized SerializedObject, dest any) error {
+	enc := c.encodingFor(serialized.Encoding)
 	if !serialized.encrypted() {
-		return c.encoding.Decode(bytes.NewReader(serialized.Bytes), dest)
+		return enc.Decode(bytes.NewReader(serialized.Bytes), dest)
 	}
 
 	if c.encryptor == nil {
@@ -466,13 +493,53 @@ func (c *client) Deserialize(serialized SerializedObject, dest any) error {
 	if err != nil {
 		return err
 	}
-	return c.encoding.Decode(bytes.NewReader(data), dest)
+	return enc.Decode(bytes.NewReader(data), dest)
+}
+
+// encodingFor returns the instance used to decode rows recorded with encType. Instances are shared, because gob
+// decoders must be paired with the encoder that produced the data.
+func (c *client) encodingFor(encType Encoding) encoding {
+	if c.encoding.Type() == encType {
+		return c.encoding
+	}
+
+	c.encodingsLock.Lock()
+	defer c.encodingsLock.Unlock()
+	if enc, ok := c.encodings[encType]; ok {
+		return enc
+	}
+	if c.encodings == nil {
+		c.encodings = make(map[Encoding]encoding)
+	}
+	enc := encodingForType(encType)
+	c.encodings[encType] = enc
+	return enc
+}
+
+// UsingEncoding returns a Client sharing this client's connection, which serializes objects as described by opts.
+// Rows record their encoding, so they can be deserialized by any Client.
+func (c *client) UsingEncoding(opts EncodingOptions) Client {
+	enc := c.encodingFor(opts.Encoding)
+	if z, ok := enc.(*zstdEncoding); ok && opts.TrainDictionary {
+		enc = z.withTrainedDictionary()
+	}
+	return &encodingClient{client: c, encoding: enc}
+}
+
+// encodingClient is a Client serializing objects with a different encoding than its parent
+type encodingClient struct {
+	*client
+	encoding encoding
+}
+
+func (c *encodingClient) Serialize(obj any, encrypt bool) (SerializedObject, error) {
+	return c.client.serialize(c.encoding, obj, encrypt)
 }
 
 // Upsert executes an upsert statement
-// note the statement should have 4 parameters: key, objBytes, dataNonce, kid
+// note the statement should have 5 parameters: key, objBytes, dataNonce, kid, encoding
 func (c *client) Upsert(tx TxClient, stmt Stmt, key string, serialized SerializedObject) error {
-	_, err := tx.Stmt(stmt).Exec(key, serialized.Bytes, serialized.Nonce, serialized.KeyID)
+	_, err := tx.Stmt(stmt).Exec(key, serialized.Bytes, serialized.Nonce, serialized.KeyID, serialized.Encoding)
 	return err
 }
 
@@ -129,6 +129,7 @@ func TestQueryObjects(t *testing.T) {
 			*a[0].(*sql.RawBytes) = testObjectSerialized
 			*a[1].(*sql.RawBytes) = testObjectSerialized
 			*a[2].(*uint32) = keyId
+			*a[3].(*Encoding) = defaultEncoding.Type()
 		})
 		d.EXPECT().Decrypt(testObjectSerialized, testObjectSerialized, keyId).Return(testObjectSerialized, nil)
 		r.EXPECT().Err().Return(nil)
@@ -150,6 +151,7 @@ func TestQueryObjects(t *testing.T) {
 			*a[0].(*sql.RawBytes) = testObjectSerialized
 			*a[1].(*sql.RawBytes) = testObjectSerialized
 			*a[2].(*uint32) = keyId
+			*a[3].(*Encoding) = defaultEncoding.Type()
 		})
 		d.EXPECT().Decrypt(testObjectSerialized, testObjectSerialized, keyId).Return(nil, fmt.Errorf("error"))
 		r.EXPECT().Close().Return(nil)
@@ -181,6 +183,7 @@ func TestQueryObjects(t *testing.T) {
 			*a[0].(*sql.RawBytes) = testObjectSerialized
 			*a[1].(*sql.RawBytes) = testObjectSerialized
 			*a[2].(*uint32) = keyId
+			*a[3].(*Encoding) = defaultEncoding.Type()
 		})
 		d.EXPECT().Decrypt(testObjectSerialized, testObjectSerialized, keyId).Return(testObjectSerialized, nil)
 		r.EXPECT().Err().Return(nil)
@@ -428,7 +431,7 @@ func TestUpsert(t *testing.T) {
 		txC := NewMockTxClient(gomock.NewController(t))
 		stmt := NewMockStmt(gomock.NewController(t))
 		txC.EXPECT().Stmt(stmt).Return(stmt)
-		stmt.EXPECT().Exec("somekey", testObjectBytes, testNonce, keyID).Return(nil, nil)
+		stmt.EXPECT().Exec("somekey", testObjectBytes, testNonce, keyID, serialized.Encoding).Return(nil, nil)
 		err := client.Upsert(txC, stmt, "somekey", serialized)
 		assert.NoError(t, err)
 	},
@@ -442,7 +445,7 @@ func TestUpsert(t *testing.T) {
 		txC := NewMockTxClient(gomock.NewController(t))
 		stmt := NewMockStmt(gomock.NewController(t))
 		txC.EXPECT().Stmt(stmt).Return(stmt)
-		stmt.EXPECT().Exec("somekey", testObjectBytes, testNonce, keyID).Return(nil, fmt.Errorf("error"))
+		stmt.EXPECT().Exec("somekey", testObjectBytes, testNonce, keyID, serialized.Encoding).Return(nil, fmt.Errorf("error"))
 
 		err := client.Upsert(txC, stmt, "somekey", serialized)
 		assert.Error(t, err)
@@ -592,6 +595,38 @@ func TestCommit(t *testing.T) {
 
 }
 
+func TestWithTransaction_OnCommit(t *testing.T) {
+	c := SetupMockConnection(t)
+	client := SetupClient(t, c, nil, nil)
+	tx := NewMockTx(gomock.NewController(t))
+	c.EXPECT().BeginTx(t.Context(), &sql.TxOptions{ReadOnly: false}).Return(tx, nil).Times(2)
+
+	var called []string
+	committed := false
+	tx.EXPECT().Commit().DoAndReturn(func() error {
+		assert.Empty(t, called, "functions are called after the commit")
+		committed = true
+		return nil
+	})
+	err := client.WithTransaction(t.Context(), true, func(tx TxClient) error {
+		tx.OnCommit(func() { called = append(called, "first") })
+		tx.OnCommit(func() { called = append(called, "second") })
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.True(t, committed)
+	assert.Equal(t, []string{"first", "second"}, called)
+
+	called = nil
+	tx.EXPECT().Rollback().Return(nil)
+	err = client.WithTransaction(t.Context(), true, func(tx TxClient) error {
+		tx.OnCommit(func() { called = append(called, "rolled back") })
+		return fmt.Errorf("failed")
+	})
+	assert.Error(t, err)
+	assert.Empty(t, called)
+}
+
 func TestRollback(t *testing.T) {
 
 }
@@ -725,3 +760,66 @@ func Test_client_serialization(t *testing.T) {
 		})
 	}
 }
+
+func Test_client_UsingEncoding(t *testing.T) {
+	cm, err := encryption.NewManager()
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &client{
+		encryptor: cm, decryptor: cm,
+	}
+	WithEncoding(MsgpackEncoding)(c)
+	zstdClient := c.UsingEncoding(EncodingOptions{Encoding: ZstdMsgpackEncoding, TrainDictionary: true})
+
+	newObject := func(i int) *unstructured.Unstructured {
+		return &unstructured.Unstructured{Object: map[string]any{
+			"apiVersion": "v1",
+			"kind":       "ConfigMap",
+			"metadata": map[string]any{
+				"name":      fmt.Sprintf("config-%d", i),
+				"namespace": "default",
+				"labels":    map[string]any{"app.kubernetes.io/managed-by": "Helm"},
+			},
+			"data": map[string]any{"key": fmt.Sprintf("value-%d", i)},
+		}}
+	}
+
+	// enough objects to train a dictionary and use it
+	var serialized []SerializedObject
+	for i := 0; i < 2*dictionarySamples; i++ {
+		s, err := zstdClient.Serialize(newObject(i), i%2 == 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, ZstdMsgpackEncoding, s.Encoding)
+		serialized = append(serialized, s)
+	}
+	assert.Less(t, len(serialized[len(serialized)-1].Bytes), len(serialized[0].Bytes), "dictionary should improve compression")
+
+	// rows keep their encoding, even when read by a client with a different default
+	plain, err := c.Serialize(newObject(0), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, MsgpackEncoding, plain.Encoding)
+	serialized = append(serialized, plain)
+
+	for i, s := range serialized {
+		var dest unstructured.Unstructured
+		if err := c.Deserialize(s, &dest); err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, newObject(i%(2*dictionarySamples)).Object, dest.Object)
+	}
+}
+
+func TestParseEncoding(t *testing.T) {
+	for _, name := range []string{"gob", "json", "msgpack", "gob+gz", "json+gz", "msgpack+zstd"} {
+		encType, err := ParseEncoding(name)
+		assert.NoError(t, err)
+		assert.Equal(t, encType, encodingForType(encType).Type(), name)
+	}
+	_, err := ParseEncoding("zstd")
+	assert.Error(t, err)
+}
@@ -399,6 +399,18 @@ func (mr *MockTxClientMockRecorder) Exec(query any, args ...any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Exec", reflect.TypeOf((*MockTxClient)(nil).Exec), varargs...)
 }
 
+// OnCommit mocks base method.
+func (m *MockTxClient) OnCommit(f func()) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "OnCommit", f)
+}
+
+// OnCommit indicates an expected call of OnCommit.
+func (mr *MockTxClientMockRecorder) OnCommit(f any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "OnCommit", reflect.TypeOf((*MockTxClient)(nil).OnCommit), f)
+}
+
 // Stmt mocks base method.
 func (m *MockTxClient) Stmt(stmt Stmt) Stmt {
 	m.ctrl.T.Helper()
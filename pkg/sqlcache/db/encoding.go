@@ -26,8 +26,12 @@ const (
 	MsgpackEncoding
 	GzippedGobEncoding
 	GzippedJSONEncoding
+	ZstdMsgpackEncoding
 )
 
+// EncodingEnvVar selects the default encoding, see ParseEncoding
+const EncodingEnvVar = "CATTLE_SQL_CACHE_ENCODING"
+
 var defaultEncoding = encodingForType(MsgpackEncoding)
 
 type nonNilEmptySlice struct{}
@@ -39,16 +43,28 @@ func init() {
 	gob.Register(nonNilEmptySlice{})
 
 	// Allow using JSON encoding during development
-	switch os.Getenv("CATTLE_SQL_CACHE_ENCODING") {
+	if encType, err := ParseEncoding(os.Getenv(EncodingEnvVar)); err == nil {
+		defaultEncoding = encodingForType(encType)
+	}
+}
+
+// ParseEncoding returns the Encoding for name, one of "gob", "json", "msgpack", "gob+gz", "json+gz" or "msgpack+zstd"
+func ParseEncoding(name string) (Encoding, error) {
+	switch name {
 	case "gob":
-		defaultEncoding = encodingForType(GobEncoding)
+		return GobEncoding, nil
 	case "json":
-		defaultEncoding = encodingForType(JSONEncoding)
+		return JSONEncoding, nil
+	case "msgpack":
+		return MsgpackEncoding, nil
 	case "gob+gz":
-		defaultEncoding = encodingForType(GzippedGobEncoding)
+		return GzippedGobEncoding, nil
 	case "json+gz":
-		defaultEncoding = encodingForType(GzippedJSONEncoding)
+		return GzippedJSONEncoding, nil
+	case "msgpack+zstd":
+		return ZstdMsgpackEncoding, nil
 	}
+	return 0, fmt.Errorf("unknown encoding %q", name)
 }
 
 func encodingForType(encType Encoding) encoding {
@@ -63,6 +79,8 @@ func encodingForType(encType Encoding) encoding {
 		return gzipped(&gobEncoding{})
 	case GzippedJSONEncoding:
 		return gzipped(jsonEncoding{})
+	case ZstdMsgpackEncoding:
+		return zstdCompressed(msgpackEncoding{}, nil)
 	}
 	// unreachable
 	return msgpackEncoding{}
@@ -79,6 +97,8 @@ type encoding interface {
 	Encode(io.Writer, any) error
 	// Decode reads from a provided reader and deserializes into an object
 	Decode(io.Reader, any) error
+	// Type returns the Encoding recorded for rows serialized with this encoding
+	Type() Encoding
 }
 
 type gobEncoding struct {
@@ -153,6 +173,10 @@ func (g *gobEncoding) registerTypeIfNeeded(obj any) error {
 	return nil
 }
 
+func (g *gobEncoding) Type() Encoding {
+	return GobEncoding
+}
+
 func (g *gobEncoding) Decode(r io.Reader, into any) error {
 	g.readLock.Lock()
 	defer g.readLock.Unlock()
@@ -195,8 +219,16 @@ func (j jsonEncoding) Decode(r io.Reader, into any) error {
 	return json.NewDecoder(r).Decode(into)
 }
 
+func (j jsonEncoding) Type() Encoding {
+	return JSONEncoding
+}
+
 type msgpackEncoding struct{}
 
+func (m msgpackEncoding) Type() Encoding {
+	return MsgpackEncoding
+}
+
 func (m msgpackEncoding) Encode(w io.Writer, obj any) error {
 	if e, ok := obj.(msgp.Encodable); ok {
 		return msgp.Encode(w, e)
@@ -239,6 +271,13 @@ func gzipped(wrapped encoding) *gzipEncoding {
 	return &gz
 }
 
+func (gz *gzipEncoding) Type() Encoding {
+	if gz.encoding.Type() == GobEncoding {
+		return GzippedGobEncoding
+	}
+	return GzippedJSONEncoding
+}
+
 func (gz *gzipEncoding) Encode(w io.Writer, obj any) error {
 	gzw, ok := gz.writers.Get().(*gzip.Writer)
 	if !ok {
@@ -27,6 +27,7 @@ var allEncodings = []struct {
 	{name: "msgpack", encoding: MsgpackEncoding},
 	{name: "gob+gzip", encoding: GzippedGobEncoding},
 	{name: "json+gzip", encoding: GzippedJSONEncoding},
+	{name: "msgpack+zstd", encoding: ZstdMsgpackEncoding},
 }
 
 func TestNonNilEmptySlice(t *testing.T) {
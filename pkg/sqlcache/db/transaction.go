@@ -14,6 +14,9 @@ import (
 type TxClient interface {
 	Exec(query string, args ...any) (sql.Result, error)
 	Stmt(stmt Stmt) Stmt
+	// OnCommit registers f to be called after the transaction is committed, eg. to publish its changes.
+	// f is not called if the transaction is rolled back.
+	OnCommit(f func())
 }
 
 // Tx represents the methods used from sql.Tx
@@ -29,11 +32,16 @@ type Tx interface {
 type txClient struct {
 	tx          Tx
 	queryLogger logging.QueryLogger
+	onCommit    []func()
 }
 
 type TxClientOption func(*txClient)
 
 func NewTxClient(tx Tx, opts ...TxClientOption) TxClient {
+	return newTxClient(tx, opts...)
+}
+
+func newTxClient(tx Tx, opts ...TxClientOption) *txClient {
 	c := &txClient{tx: tx, queryLogger: &logging.NoopQueryLogger{}}
 	for _, opt := range opts {
 		opt(c)
@@ -61,6 +69,17 @@ func (c txClient) Stmt(s Stmt) Stmt {
 	}
 }
 
+func (c *txClient) OnCommit(f func()) {
+	c.onCommit = append(c.onCommit, f)
+}
+
+// committed calls the functions registered with OnCommit, in order
+func (c *txClient) committed() {
+	for _, f := range c.onCommit {
+		f()
+	}
+}
+
 func WithQueryLogger(logger logging.QueryLogger) TxClientOption {
 	return func(c *txClient) {
 		c.queryLogger = logger
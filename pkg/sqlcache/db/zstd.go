@@ -0,0 +1,188 @@
+package db
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// dictionarySamples is how many objects are collected to train a dictionary
+	dictionarySamples = 128
+	// maxDictionarySize bounds the size of trained dictionaries
+	maxDictionarySize = 64 * 1024
+	// firstDictionaryID is the lowest dictionary ID not reserved by the zstd format specification
+	firstDictionaryID = 1 << 15
+)
+
+var (
+	// zstdDicts keeps every dictionary used by this process, so that rows compressed with any of them can be
+	// decoded. zstd frames record the ID of the dictionary they were compressed with.
+	zstdDicts = &zstdDictionaries{}
+	// lastDictionaryID is used to give each trained dictionary a unique ID
+	lastDictionaryID atomic.Uint32
+)
+
+type zstdDictionaries struct {
+	lock    sync.RWMutex
+	dicts   [][]byte
+	decoder *zstd.Decoder
+}
+
+func (z *zstdDictionaries) decodeAll(data []byte) ([]byte, error) {
+	z.lock.RLock()
+	decoder := z.decoder
+	z.lock.RUnlock()
+	if decoder == nil {
+		z.lock.Lock()
+		if z.decoder == nil {
+			var err error
+			if z.decoder, err = zstd.NewReader(nil, zstd.WithDecoderConcurrency(0)); err != nil {
+				z.lock.Unlock()
+				return nil, err
+			}
+		}
+		decoder = z.decoder
+		z.lock.Unlock()
+	}
+	return decoder.DecodeAll(data, nil)
+}
+
+// add makes dict available for decoding
+func (z *zstdDictionaries) add(dict []byte) error {
+	z.lock.Lock()
+	defer z.lock.Unlock()
+
+	dicts := append(z.dicts, dict)
+	decoder, err := zstd.NewReader(nil, zstd.WithDecoderConcurrency(0), zstd.WithDecoderDicts(dicts...))
+	if err != nil {
+		return err
+	}
+	// decoders in use by concurrent reads are left for the garbage collector
+	z.dicts, z.decoder = dicts, decoder
+	return nil
+}
+
+// zstdEncoding compresses the output of another encoding with zstd. If trainDictionary is set, it trains a
+// dictionary from the first objects it encodes, and uses it for all following objects.
+type zstdEncoding struct {
+	encoding
+	encoder atomic.Pointer[zstd.Encoder]
+
+	trainDictionary atomic.Bool
+	samplesLock     sync.Mutex
+	samples         [][]byte
+}
+
+func zstdCompressed(wrapped encoding, dict []byte) *zstdEncoding {
+	z := &zstdEncoding{encoding: wrapped}
+	encoder, err := newZstdEncoder(dict)
+	if err != nil {
+		// only possible with an invalid dictionary
+		panic(err)
+	}
+	z.encoder.Store(encoder)
+	return z
+}
+
+func newZstdEncoder(dict []byte) (*zstd.Encoder, error) {
+	opts := []zstd.EOption{zstd.WithEncoderConcurrency(1)}
+	if dict != nil {
+		opts = append(opts, zstd.WithEncoderDict(dict))
+	}
+	return zstd.NewWriter(nil, opts...)
+}
+
+// withTrainedDictionary returns a copy of z which trains a dictionary from the objects it encodes
+func (z *zstdEncoding) withTrainedDictionary() *zstdEncoding {
+	trained := zstdCompressed(z.encoding, nil)
+	trained.trainDictionary.Store(true)
+	return trained
+}
+
+func (z *zstdEncoding) Type() Encoding {
+	return ZstdMsgpackEncoding
+}
+
+func (z *zstdEncoding) Encode(w io.Writer, obj any) error {
+	var buf bytes.Buffer
+	if err := z.encoding.Encode(&buf, obj); err != nil {
+		return err
+	}
+	if z.trainDictionary.Load() {
+		z.addSample(buf.Bytes())
+	}
+	_, err := w.Write(z.encoder.Load().EncodeAll(buf.Bytes(), nil))
+	return err
+}
+
+func (z *zstdEncoding) Decode(r io.Reader, into any) error {
+	compressed, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	data, err := zstdDicts.decodeAll(compressed)
+	if err != nil {
+		return err
+	}
+	return z.encoding.Decode(bytes.NewReader(data), into)
+}
+
+func (z *zstdEncoding) addSample(sample []byte) {
+	z.samplesLock.Lock()
+	defer z.samplesLock.Unlock()
+	if !z.trainDictionary.Load() {
+		return
+	}
+	z.samples = append(z.samples, bytes.Clone(sample))
+	if len(z.samples) < dictionarySamples {
+		return
+	}
+
+	// train only once, even if it fails
+	z.trainDictionary.Store(false)
+	samples := z.samples
+	z.samples = nil
+	if err := z.train(samples); err != nil {
+		logrus.Warnf("could not train zstd dictionary, continuing without it: %v", err)
+	}
+}
+
+func (z *zstdEncoding) train(samples [][]byte) error {
+	dict, err := buildZstdDictionary(firstDictionaryID+lastDictionaryID.Add(1), samples)
+	if err != nil {
+		return err
+	}
+	encoder, err := newZstdEncoder(dict)
+	if err != nil {
+		return err
+	}
+	// the dictionary must be known to decoders before any row is compressed with it
+	if err := zstdDicts.add(dict); err != nil {
+		return err
+	}
+	z.encoder.Store(encoder)
+	return nil
+}
+
+// buildZstdDictionary builds a dictionary from the most recent samples, fitting in maxDictionarySize
+func buildZstdDictionary(id uint32, samples [][]byte) ([]byte, error) {
+	var history []byte
+	for i := len(samples) - 1; i >= 0 && len(history)+len(samples[i]) <= maxDictionarySize; i-- {
+		history = append(samples[i][:len(samples[i]):len(samples[i])], history...)
+	}
+	if len(history) == 0 {
+		return nil, fmt.Errorf("samples are too large for a %d bytes dictionary", maxDictionarySize)
+	}
+	return zstd.BuildDict(zstd.BuildDictOptions{
+		ID:       id,
+		Contents: samples,
+		History:  history,
+		Offsets:  [3]int{1, 4, 8},
+	})
+}
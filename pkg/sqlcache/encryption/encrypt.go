@@ -2,21 +2,30 @@
 Package encryption provides encryption and decryption functions, while
 abstracting away key management concerns.
 Uses AES-GCM encryption, with key rotation, keeping keys in memory.
+
+Optionally, data keys can be wrapped by a key encryption key held by a
+KeyProvider (envelope encryption), so that they can be stored alongside the
+data they protect.
 */
 package encryption
 
 import (
+	"context"
 	"crypto/aes"
 	"crypto/cipher"
 	"crypto/rand"
 	"fmt"
+	"io"
 	"sync"
+	"time"
 
 	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
 )
 
 var (
-	ErrKeyNotFound = errors.New("data key not found")
+	ErrKeyNotFound   = errors.New("data key not found")
+	ErrNoKeyProvider = errors.New("no key provider configured")
 	// maxWriteCount holds the maximum amount of times the active key can be
 	// used, prior to it being rotated. 2^32 is the currently recommended key
 	// wear-out params by NIST for AES-GCM using random nonces.
@@ -30,22 +39,73 @@ const (
 // Manager uses AES-GCM encryption and keeps in memory the data encryption
 // keys. The active encryption key is automatically rotated once it has been
 // used over a certain amount of times - defined by maxWriteCount.
+//
+// When a KeyProvider is set, every data key is also kept wrapped by the
+// provider's current key encryption key, see WrappedKeys and RewrapKeys.
 type Manager struct {
-	dataKeys         [][]byte
+	dataKeys [][]byte
+	// wrappedKeys holds the wrapped form of dataKeys, at the same index. Only used with a keyProvider.
+	wrappedKeys      []WrappedKey
+	keyProvider      KeyProvider
 	activeKeyCounter int64
+	// rotating is set while the active data key is rotated because it reached maxWriteCount
+	rotating bool
 
 	// lock works as the mutual exclusion lock for dataKeys.
 	lock sync.RWMutex
-	// counterLock works as the mutual exclusion lock for activeKeyCounter.
+	// counterLock works as the mutual exclusion lock for activeKeyCounter and rotating.
 	counterLock sync.Mutex
 }
 
+// WrappedKey is a data key encrypted by the key encryption key identified by KEKID
+type WrappedKey struct {
+	Key   []byte
+	KEKID string
+}
+
+// ManagerOption configures a Manager
+type ManagerOption func(*Manager)
+
+// WithKeyProvider enables envelope encryption, wrapping all data keys with the provider's key encryption key
+func WithKeyProvider(provider KeyProvider) ManagerOption {
+	return func(m *Manager) {
+		m.keyProvider = provider
+	}
+}
+
+// WithWrappedKeys loads data keys previously returned by WrappedKeys, so that data encrypted by an earlier Manager
+// can still be decrypted. It requires WithKeyProvider.
+func WithWrappedKeys(keys []WrappedKey) ManagerOption {
+	return func(m *Manager) {
+		m.wrappedKeys = append([]WrappedKey{}, keys...)
+	}
+}
+
 // NewManager returns Manager, which satisfies db.Encryptor and db.Decryptor
-func NewManager() (*Manager, error) {
+func NewManager(opts ...ManagerOption) (*Manager, error) {
 	m := &Manager{
 		dataKeys: [][]byte{},
 	}
-	m.newDataEncryptionKey()
+	for _, opt := range opts {
+		opt(m)
+	}
+
+	if len(m.wrappedKeys) > 0 {
+		if m.keyProvider == nil {
+			return nil, fmt.Errorf("loading wrapped data keys: %w", ErrNoKeyProvider)
+		}
+		for i, wk := range m.wrappedKeys {
+			dek, err := m.keyProvider.Unwrap(context.Background(), wk.Key, wk.KEKID)
+			if err != nil {
+				return nil, fmt.Errorf("unwrapping data key %d: %w", i, err)
+			}
+			m.dataKeys = append(m.dataKeys, dek)
+		}
+	}
+
+	if _, _, err := m.rotateDataKey(); err != nil {
+		return nil, err
+	}
 
 	return m, nil
 }
@@ -116,14 +176,35 @@ func createGCMCypher(key []byte) (cipher.AEAD, error) {
 // rotated - before being returned.
 func (m *Manager) fetchActiveDataKey() ([]byte, uint32, error) {
 	m.counterLock.Lock()
-	defer m.counterLock.Unlock()
-
 	m.activeKeyCounter++
-	if m.activeKeyCounter >= maxWriteCount {
-		return m.newDataEncryptionKey()
+	rotate := m.activeKeyCounter >= maxWriteCount && !m.rotating
+	if rotate {
+		m.rotating = true
 	}
+	m.counterLock.Unlock()
 
-	return m.activeKey()
+	if !rotate {
+		return m.activeKey()
+	}
+	defer func() {
+		m.counterLock.Lock()
+		m.rotating = false
+		m.counterLock.Unlock()
+	}()
+	return m.rotateDataKey()
+}
+
+// rotateDataKey makes a new data key active and resets activeKeyCounter. The key provider may be remote, so the key is
+// wrapped without holding counterLock: concurrent writes keep using the previous key until the new one is added.
+func (m *Manager) rotateDataKey() ([]byte, uint32, error) {
+	dek, keyID, err := m.newDataEncryptionKey()
+	if err != nil {
+		return nil, 0, err
+	}
+	m.counterLock.Lock()
+	m.activeKeyCounter = 1
+	m.counterLock.Unlock()
+	return dek, keyID, nil
 }
 
 func (m *Manager) newDataEncryptionKey() ([]byte, uint32, error) {
@@ -133,17 +214,34 @@ func (m *Manager) newDataEncryptionKey() ([]byte, uint32, error) {
 		return nil, 0, err
 	}
 
+	var wrapped WrappedKey
+	if m.keyProvider != nil {
+		wrapped.Key, wrapped.KEKID, err = m.keyProvider.Wrap(context.Background(), dek)
+		if err != nil {
+			return nil, 0, err
+		}
+	}
+
 	m.lock.Lock()
 	defer m.lock.Unlock()
 
-	m.activeKeyCounter = 1
-
 	m.dataKeys = append(m.dataKeys, dek)
 	keyID := uint32(len(m.dataKeys) - 1)
+	if m.keyProvider != nil {
+		m.wrappedKeys = append(m.wrappedKeys, wrapped)
+	}
 
 	return dek, keyID, nil
 }
 
+// Close releases the key provider, such as the connection to a KMS plugin
+func (m *Manager) Close() error {
+	if closer, ok := m.keyProvider.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
 func (m *Manager) activeKey() ([]byte, uint32, error) {
 	m.lock.RLock()
 	defer m.lock.RUnlock()
@@ -166,3 +264,99 @@ func (m *Manager) key(keyID uint32) ([]byte, error) {
 	}
 	return m.dataKeys[keyID], nil
 }
+
+// RotateDataKey makes a new data key active. Data encrypted with previous keys can still be decrypted.
+func (m *Manager) RotateDataKey() error {
+	_, _, err := m.rotateDataKey()
+	return err
+}
+
+// WrappedKeys returns all data keys, wrapped by the key provider, in key ID order
+func (m *Manager) WrappedKeys() ([]WrappedKey, error) {
+	if m.keyProvider == nil {
+		return nil, ErrNoKeyProvider
+	}
+
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	return append([]WrappedKey{}, m.wrappedKeys...), nil
+}
+
+// RewrapKeys wraps all data keys again using the key provider's current key encryption key, eg. after it was rotated.
+// Data keys are unchanged, so no data needs to be encrypted again.
+func (m *Manager) RewrapKeys(ctx context.Context) error {
+	if m.keyProvider == nil {
+		return ErrNoKeyProvider
+	}
+
+	// the key provider may be remote, so keys are wrapped without holding the lock, which would block all encryption
+	m.lock.RLock()
+	dataKeys := append([][]byte{}, m.dataKeys...)
+	m.lock.RUnlock()
+
+	rewrapped := make([]WrappedKey, len(dataKeys))
+	for i, dek := range dataKeys {
+		key, kekID, err := m.keyProvider.Wrap(ctx, dek)
+		if err != nil {
+			return fmt.Errorf("rewrapping data key %d: %w", i, err)
+		}
+		rewrapped[i] = WrappedKey{Key: key, KEKID: kekID}
+	}
+
+	m.lock.Lock()
+	// keys added meanwhile were already wrapped with the current key encryption key
+	m.wrappedKeys = append(rewrapped, m.wrappedKeys[len(rewrapped):]...)
+	m.lock.Unlock()
+
+	return nil
+}
+
+// WatchKeyRotation polls the key provider every interval until ctx is done, reloading it first if it reads its keys
+// from a file. When its key encryption key changes, all data keys are rewrapped and a new data key is made active.
+func (m *Manager) WatchKeyRotation(ctx context.Context, interval time.Duration) {
+	if m.keyProvider == nil {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if err := m.checkKeyRotation(ctx); err != nil {
+			logrus.Errorf("checking key encryption key rotation: %v", err)
+		}
+	}
+}
+
+// reloader is implemented by KeyProviders whose keys can be read again from their source, such as FileKeyProvider
+type reloader interface {
+	Reload() error
+}
+
+func (m *Manager) checkKeyRotation(ctx context.Context) error {
+	if r, ok := m.keyProvider.(reloader); ok {
+		if err := r.Reload(); err != nil {
+			return err
+		}
+	}
+	kekID, err := m.keyProvider.KeyID(ctx)
+	if err != nil {
+		return err
+	}
+
+	m.lock.RLock()
+	current := m.wrappedKeys[len(m.wrappedKeys)-1].KEKID
+	m.lock.RUnlock()
+	if current == kekID {
+		return nil
+	}
+
+	logrus.Infof("key encryption key changed from %s to %s, rewrapping data keys", current, kekID)
+	if err := m.RewrapKeys(ctx); err != nil {
+		return err
+	}
+	return m.RotateDataKey()
+}
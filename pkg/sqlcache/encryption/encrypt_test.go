@@ -1,11 +1,15 @@
 package encryption
 
 import (
+	"context"
 	"crypto/aes"
 	"crypto/cipher"
 	"crypto/rand"
+	"encoding/base64"
 	"fmt"
 	"math"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -325,3 +329,159 @@ func benchDecrypt(b *testing.B, size int) {
 		}
 	})
 }
+
+func TestEnvelopeEncryption(t *testing.T) {
+	type testCase struct {
+		description string
+		test        func(t *testing.T)
+	}
+	var tests []testCase
+
+	newKEK := func(t *testing.T) []byte {
+		kek := make([]byte, keySize)
+		_, err := rand.Read(kek)
+		require.NoError(t, err)
+		return kek
+	}
+
+	tests = append(tests, testCase{description: "wrapped keys can be loaded by a new manager", test: func(t *testing.T) {
+		provider, err := NewStaticKeyProvider(newKEK(t))
+		require.NoError(t, err)
+
+		m, err := NewManager(WithKeyProvider(provider))
+		require.NoError(t, err)
+		cipherText, nonce, keyID, err := m.Encrypt([]byte("something"))
+		require.NoError(t, err)
+
+		wrapped, err := m.WrappedKeys()
+		require.NoError(t, err)
+		require.Len(t, wrapped, 1)
+		assert.NotEqual(t, m.dataKeys[0], wrapped[0].Key)
+
+		m2, err := NewManager(WithKeyProvider(provider), WithWrappedKeys(wrapped))
+		require.NoError(t, err)
+		data, err := m2.Decrypt(cipherText, nonce, keyID)
+		require.NoError(t, err)
+		assert.Equal(t, []byte("something"), data)
+
+		// loading keys doesn't reuse the previously active key for new writes
+		_, _, newKeyID, err := m2.Encrypt([]byte("something else"))
+		require.NoError(t, err)
+		assert.Equal(t, uint32(1), newKeyID)
+	}})
+	tests = append(tests, testCase{description: "wrapped keys require a key provider", test: func(t *testing.T) {
+		_, err := NewManager(WithWrappedKeys([]WrappedKey{{Key: []byte("foo"), KEKID: "bar"}}))
+		assert.ErrorIs(t, err, ErrNoKeyProvider)
+
+		m, err := NewManager()
+		require.NoError(t, err)
+		_, err = m.WrappedKeys()
+		assert.ErrorIs(t, err, ErrNoKeyProvider)
+		assert.ErrorIs(t, m.RewrapKeys(context.Background()), ErrNoKeyProvider)
+	}})
+	tests = append(tests, testCase{description: "rotating the KEK rewraps data keys", test: func(t *testing.T) {
+		oldKEK, newKEKBytes := newKEK(t), newKEK(t)
+		provider, err := NewStaticKeyProvider(oldKEK)
+		require.NoError(t, err)
+
+		m, err := NewManager(WithKeyProvider(provider))
+		require.NoError(t, err)
+		cipherText, nonce, keyID, err := m.Encrypt([]byte("something"))
+		require.NoError(t, err)
+
+		require.NoError(t, provider.setKeys([][]byte{newKEKBytes, oldKEK}))
+		require.NoError(t, m.checkKeyRotation(context.Background()))
+
+		wrapped, err := m.WrappedKeys()
+		require.NoError(t, err)
+		require.Len(t, wrapped, 2)
+		for _, wk := range wrapped {
+			assert.Equal(t, staticKeyID(newKEKBytes), wk.KEKID)
+		}
+
+		// the old KEK is no longer needed to load the keys
+		newOnly, err := NewStaticKeyProvider(newKEKBytes)
+		require.NoError(t, err)
+		m2, err := NewManager(WithKeyProvider(newOnly), WithWrappedKeys(wrapped))
+		require.NoError(t, err)
+		data, err := m2.Decrypt(cipherText, nonce, keyID)
+		require.NoError(t, err)
+		assert.Equal(t, []byte("something"), data)
+	}})
+	tests = append(tests, testCase{description: "unchanged KEK doesn't rotate the data key", test: func(t *testing.T) {
+		provider, err := NewStaticKeyProvider(newKEK(t))
+		require.NoError(t, err)
+		m, err := NewManager(WithKeyProvider(provider))
+		require.NoError(t, err)
+
+		require.NoError(t, m.checkKeyRotation(context.Background()))
+		assert.Len(t, m.dataKeys, 1)
+	}})
+
+	tests = append(tests, testCase{description: "writes continue while a rotated data key is wrapped", test: func(t *testing.T) {
+		static, err := NewStaticKeyProvider(newKEK(t))
+		require.NoError(t, err)
+		provider := &blockingKeyProvider{KeyProvider: static}
+		m, err := NewManager(WithKeyProvider(provider))
+		require.NoError(t, err)
+		_, _, oldKeyID, err := m.Encrypt([]byte("something"))
+		require.NoError(t, err)
+
+		provider.block = make(chan struct{})
+		provider.wrapping = make(chan struct{})
+		m.activeKeyCounter += maxWriteCount
+		rotated := make(chan uint32)
+		go func() {
+			_, _, keyID, err := m.Encrypt([]byte("something"))
+			assert.NoError(t, err)
+			rotated <- keyID
+		}()
+		<-provider.wrapping
+
+		_, _, keyID, err := m.Encrypt([]byte("something"))
+		require.NoError(t, err)
+		assert.Equal(t, oldKeyID, keyID, "the previous key is used until the new one is wrapped")
+
+		close(provider.block)
+		assert.NotEqual(t, oldKeyID, <-rotated)
+		assert.Equal(t, int64(1), m.activeKeyCounter)
+	}})
+	tests = append(tests, testCase{description: "rotated key files are reloaded", test: func(t *testing.T) {
+		oldKEK, newKEKBytes := newKEK(t), newKEK(t)
+		path := filepath.Join(t.TempDir(), "keys")
+		require.NoError(t, os.WriteFile(path, []byte(base64.StdEncoding.EncodeToString(oldKEK)+"\n"), 0600))
+		provider, err := NewFileKeyProvider(path)
+		require.NoError(t, err)
+		m, err := NewManager(WithKeyProvider(provider))
+		require.NoError(t, err)
+
+		content := base64.StdEncoding.EncodeToString(newKEKBytes) + "\n" + base64.StdEncoding.EncodeToString(oldKEK) + "\n"
+		require.NoError(t, os.WriteFile(path, []byte(content), 0600))
+		require.NoError(t, m.checkKeyRotation(context.Background()))
+		wrapped, err := m.WrappedKeys()
+		require.NoError(t, err)
+		require.Len(t, wrapped, 2)
+		assert.Equal(t, staticKeyID(newKEKBytes), wrapped[0].KEKID)
+		assert.NoError(t, m.Close())
+	}})
+
+	t.Parallel()
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) { test.test(t) })
+	}
+}
+
+// blockingKeyProvider signals wrapping and waits for block to be closed before wrapping keys, if block is set
+type blockingKeyProvider struct {
+	KeyProvider
+	wrapping chan struct{}
+	block    chan struct{}
+}
+
+func (p *blockingKeyProvider) Wrap(ctx context.Context, dek []byte) ([]byte, string, error) {
+	if p.block != nil {
+		close(p.wrapping)
+		<-p.block
+	}
+	return p.KeyProvider.Wrap(ctx, dek)
+}
@@ -0,0 +1,215 @@
+package encryption
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	fileKeyProviderPrefix = "file:"
+	envKeyProviderPrefix  = "env:"
+	kmsKeyProviderPrefix  = "kms:"
+
+	defaultKMSTimeout = 3 * time.Second
+)
+
+// KeyProvider wraps and unwraps data encryption keys (DEKs) with a key encryption key (KEK) it holds.
+// The KEK itself is never handed to the Manager.
+type KeyProvider interface {
+	// Wrap encrypts dek with the current KEK, returning the wrapped DEK and the ID of the KEK that was used.
+	Wrap(ctx context.Context, dek []byte) ([]byte, string, error)
+	// Unwrap decrypts a DEK previously wrapped with the KEK identified by kekID.
+	Unwrap(ctx context.Context, wrapped []byte, kekID string) ([]byte, error)
+	// KeyID returns the ID of the current KEK. A change in this value means the KEK has been rotated.
+	KeyID(ctx context.Context) (string, error)
+}
+
+// NewKeyProvider returns a KeyProvider as described by spec, which is one of:
+//   - "file:<path>" for keys read from a local file, see NewFileKeyProvider
+//   - "env:<name>" for keys read from an environment variable, see NewEnvKeyProvider
+//   - "kms:<socket path>" for a KMS v2 plugin listening on a Unix socket, see NewKMSKeyProvider
+func NewKeyProvider(spec string) (KeyProvider, error) {
+	switch {
+	case strings.HasPrefix(spec, fileKeyProviderPrefix):
+		return NewFileKeyProvider(strings.TrimPrefix(spec, fileKeyProviderPrefix))
+	case strings.HasPrefix(spec, envKeyProviderPrefix):
+		return NewEnvKeyProvider(strings.TrimPrefix(spec, envKeyProviderPrefix))
+	case strings.HasPrefix(spec, kmsKeyProviderPrefix):
+		return NewKMSKeyProvider(strings.TrimPrefix(spec, kmsKeyProviderPrefix), defaultKMSTimeout)
+	default:
+		return nil, fmt.Errorf("unknown key provider %q", spec)
+	}
+}
+
+// StaticKeyProvider wraps DEKs with AES-GCM using local 256-bit KEKs. The first key is used for wrapping,
+// the remaining ones are only kept to unwrap DEKs wrapped before a rotation.
+type StaticKeyProvider struct {
+	lock    sync.RWMutex
+	current string
+	keks    map[string][]byte
+}
+
+// NewStaticKeyProvider returns a StaticKeyProvider using the given KEKs, the first one being the current KEK
+func NewStaticKeyProvider(keks ...[]byte) (*StaticKeyProvider, error) {
+	p := &StaticKeyProvider{}
+	if err := p.setKeys(keks); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (p *StaticKeyProvider) setKeys(keks [][]byte) error {
+	if len(keks) == 0 {
+		return fmt.Errorf("no key encryption keys provided")
+	}
+	byID := make(map[string][]byte, len(keks))
+	for _, kek := range keks {
+		if len(kek) != keySize {
+			return fmt.Errorf("key encryption keys must be %d bytes long, got %d", keySize, len(kek))
+		}
+		byID[staticKeyID(kek)] = kek
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.current = staticKeyID(keks[0])
+	p.keks = byID
+	return nil
+}
+
+// staticKeyID identifies a local KEK by a fingerprint, so the key itself is never stored next to the data
+func staticKeyID(kek []byte) string {
+	sum := sha256.Sum256(kek)
+	return "local:" + hex.EncodeToString(sum[:8])
+}
+
+// Wrap implements KeyProvider
+func (p *StaticKeyProvider) Wrap(_ context.Context, dek []byte) ([]byte, string, error) {
+	p.lock.RLock()
+	kekID, kek := p.current, p.keks[p.current]
+	p.lock.RUnlock()
+
+	aead, err := createGCMCypher(kek)
+	if err != nil {
+		return nil, "", err
+	}
+	sealed, nonce, err := encrypt(aead, dek)
+	if err != nil {
+		return nil, "", err
+	}
+	return append(nonce, sealed...), kekID, nil
+}
+
+// Unwrap implements KeyProvider
+func (p *StaticKeyProvider) Unwrap(_ context.Context, wrapped []byte, kekID string) ([]byte, error) {
+	p.lock.RLock()
+	kek, ok := p.keks[kekID]
+	p.lock.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: key encryption key %s", ErrKeyNotFound, kekID)
+	}
+
+	aead, err := createGCMCypher(kek)
+	if err != nil {
+		return nil, err
+	}
+	if len(wrapped) < aead.NonceSize() {
+		return nil, fmt.Errorf("wrapped key is too short")
+	}
+	nonce, sealed := wrapped[:aead.NonceSize()], wrapped[aead.NonceSize():]
+	dek, err := aead.Open(nil, nonce, sealed, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unwrap data key using %s: %w", kekID, err)
+	}
+	return dek, nil
+}
+
+// KeyID implements KeyProvider
+func (p *StaticKeyProvider) KeyID(_ context.Context) (string, error) {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+	return p.current, nil
+}
+
+// FileKeyProvider is a StaticKeyProvider reading its KEKs from a file
+type FileKeyProvider struct {
+	*StaticKeyProvider
+	path string
+}
+
+// NewFileKeyProvider returns a KeyProvider reading base64-encoded 256-bit KEKs from path, one per line. The first key
+// is the current one. Blank lines and lines starting with # are ignored.
+//
+// To rotate, add a new key at the top of the file, keeping the previous ones. The file is reloaded by
+// Manager.WatchKeyRotation, or when Reload is called.
+func NewFileKeyProvider(path string) (*FileKeyProvider, error) {
+	p := &FileKeyProvider{StaticKeyProvider: &StaticKeyProvider{}, path: path}
+	if err := p.Reload(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Reload reads the keys from the file again
+func (p *FileKeyProvider) Reload() error {
+	content, err := os.ReadFile(p.path)
+	if err != nil {
+		return fmt.Errorf("reading key file: %w", err)
+	}
+	keks, err := parseKeys(bufio.NewScanner(bytes.NewReader(content)))
+	if err != nil {
+		return fmt.Errorf("parsing key file %s: %w", p.path, err)
+	}
+	return p.setKeys(keks)
+}
+
+// NewEnvKeyProvider returns a KeyProvider reading base64-encoded 256-bit KEKs from the environment variable name,
+// separated by commas. The first key is the current one.
+func NewEnvKeyProvider(name string) (*StaticKeyProvider, error) {
+	value, ok := os.LookupEnv(name)
+	if !ok {
+		return nil, fmt.Errorf("environment variable %s is not set", name)
+	}
+	scanner := bufio.NewScanner(strings.NewReader(value))
+	scanner.Split(scanCommas)
+	keks, err := parseKeys(scanner)
+	if err != nil {
+		return nil, fmt.Errorf("parsing keys from %s: %w", name, err)
+	}
+	return NewStaticKeyProvider(keks...)
+}
+
+func parseKeys(scanner *bufio.Scanner) ([][]byte, error) {
+	var keks [][]byte
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		kek, err := base64.StdEncoding.DecodeString(line)
+		if err != nil {
+			return nil, err
+		}
+		keks = append(keks, kek)
+	}
+	return keks, scanner.Err()
+}
+
+func scanCommas(data []byte, atEOF bool) (int, []byte, error) {
+	if i := bytes.IndexByte(data, ','); i >= 0 {
+		return i + 1, data[:i], nil
+	}
+	if atEOF && len(data) > 0 {
+		return len(data), data, nil
+	}
+	return 0, nil, nil
+}
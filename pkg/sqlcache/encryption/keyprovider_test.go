@@ -0,0 +1,95 @@
+package encryption
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestKEK(t *testing.T) []byte {
+	kek := make([]byte, keySize)
+	_, err := rand.Read(kek)
+	require.NoError(t, err)
+	return kek
+}
+
+func TestStaticKeyProvider(t *testing.T) {
+	oldKEK, newKEK := newTestKEK(t), newTestKEK(t)
+	dek := newTestKEK(t)
+	ctx := context.Background()
+
+	oldProvider, err := NewStaticKeyProvider(oldKEK)
+	require.NoError(t, err)
+	wrapped, oldID, err := oldProvider.Wrap(ctx, dek)
+	require.NoError(t, err)
+
+	provider, err := NewStaticKeyProvider(newKEK, oldKEK)
+	require.NoError(t, err)
+	currentID, err := provider.KeyID(ctx)
+	require.NoError(t, err)
+	assert.NotEqual(t, oldID, currentID)
+
+	unwrapped, err := provider.Unwrap(ctx, wrapped, oldID)
+	require.NoError(t, err)
+	assert.Equal(t, dek, unwrapped)
+
+	_, err = oldProvider.Unwrap(ctx, wrapped, currentID)
+	assert.ErrorIs(t, err, ErrKeyNotFound)
+
+	_, err = NewStaticKeyProvider()
+	assert.Error(t, err)
+	_, err = NewStaticKeyProvider([]byte("too short"))
+	assert.Error(t, err)
+}
+
+func TestFileKeyProvider(t *testing.T) {
+	oldKEK, newKEK := newTestKEK(t), newTestKEK(t)
+	path := filepath.Join(t.TempDir(), "keys")
+	require.NoError(t, os.WriteFile(path, []byte("# comment\n\n"+base64.StdEncoding.EncodeToString(oldKEK)+"\n"), 0600))
+
+	provider, err := NewFileKeyProvider(path)
+	require.NoError(t, err)
+	id, err := provider.KeyID(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, staticKeyID(oldKEK), id)
+
+	content := base64.StdEncoding.EncodeToString(newKEK) + "\n" + base64.StdEncoding.EncodeToString(oldKEK) + "\n"
+	require.NoError(t, os.WriteFile(path, []byte(content), 0600))
+	require.NoError(t, provider.Reload())
+	id, err = provider.KeyID(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, staticKeyID(newKEK), id)
+
+	require.NoError(t, os.WriteFile(path, []byte("not base64!"), 0600))
+	assert.Error(t, provider.Reload())
+	// a failed reload keeps the previous keys
+	id, err = provider.KeyID(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, staticKeyID(newKEK), id)
+}
+
+func TestEnvKeyProvider(t *testing.T) {
+	oldKEK, newKEK := newTestKEK(t), newTestKEK(t)
+	t.Setenv("TEST_SQL_CACHE_KEKS", strings.Join([]string{
+		base64.StdEncoding.EncodeToString(newKEK),
+		base64.StdEncoding.EncodeToString(oldKEK),
+	}, ","))
+
+	provider, err := NewKeyProvider("env:TEST_SQL_CACHE_KEKS")
+	require.NoError(t, err)
+	id, err := provider.KeyID(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, staticKeyID(newKEK), id)
+
+	_, err = NewKeyProvider("env:TEST_SQL_CACHE_KEKS_UNSET")
+	assert.Error(t, err)
+	_, err = NewKeyProvider("vault:foo")
+	assert.Error(t, err)
+}
@@ -0,0 +1,97 @@
+package encryption
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/protobuf/proto"
+	"k8s.io/apimachinery/pkg/util/uuid"
+	kmsapi "k8s.io/kms/apis/v2"
+)
+
+// KMSKeyProvider delegates wrapping and unwrapping DEKs to a Kubernetes KMS v2 plugin, so that the KEK never leaves
+// the external key management system
+type KMSKeyProvider struct {
+	conn    *grpc.ClientConn
+	client  kmsapi.KeyManagementServiceClient
+	timeout time.Duration
+}
+
+// NewKMSKeyProvider returns a KeyProvider talking to the KMS v2 plugin listening on the Unix socket at path.
+// Every call to the plugin is bounded by timeout.
+func NewKMSKeyProvider(path string, timeout time.Duration) (*KMSKeyProvider, error) {
+	conn, err := grpc.NewClient("unix://"+path, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		return nil, fmt.Errorf("connecting to KMS plugin at %s: %w", path, err)
+	}
+	return &KMSKeyProvider{
+		conn:    conn,
+		client:  kmsapi.NewKeyManagementServiceClient(conn),
+		timeout: timeout,
+	}, nil
+}
+
+// Close closes the connection to the plugin
+func (p *KMSKeyProvider) Close() error {
+	return p.conn.Close()
+}
+
+// Wrap implements KeyProvider. The returned wrapped key also carries the annotations returned by the plugin, which
+// are needed to unwrap it.
+func (p *KMSKeyProvider) Wrap(ctx context.Context, dek []byte) ([]byte, string, error) {
+	ctx, cancel := context.WithTimeout(ctx, p.timeout)
+	defer cancel()
+
+	resp, err := p.client.Encrypt(ctx, &kmsapi.EncryptRequest{Plaintext: dek, Uid: string(uuid.NewUUID())})
+	if err != nil {
+		return nil, "", fmt.Errorf("wrapping data key with KMS plugin: %w", err)
+	}
+	wrapped, err := proto.Marshal(&kmsapi.DecryptRequest{
+		Ciphertext:  resp.Ciphertext,
+		KeyId:       resp.KeyId,
+		Annotations: resp.Annotations,
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	return wrapped, resp.KeyId, nil
+}
+
+// Unwrap implements KeyProvider
+func (p *KMSKeyProvider) Unwrap(ctx context.Context, wrapped []byte, kekID string) ([]byte, error) {
+	req := &kmsapi.DecryptRequest{}
+	if err := proto.Unmarshal(wrapped, req); err != nil {
+		return nil, fmt.Errorf("decoding wrapped data key: %w", err)
+	}
+	if req.KeyId != kekID {
+		return nil, fmt.Errorf("data key was wrapped with %s, not %s", req.KeyId, kekID)
+	}
+	req.Uid = string(uuid.NewUUID())
+
+	ctx, cancel := context.WithTimeout(ctx, p.timeout)
+	defer cancel()
+
+	resp, err := p.client.Decrypt(ctx, req)
+	if err != nil {
+		return nil, fmt.Errorf("unwrapping data key with KMS plugin: %w", err)
+	}
+	return resp.Plaintext, nil
+}
+
+// KeyID implements KeyProvider
+func (p *KMSKeyProvider) KeyID(ctx context.Context) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, p.timeout)
+	defer cancel()
+
+	resp, err := p.client.Status(ctx, &kmsapi.StatusRequest{})
+	if err != nil {
+		return "", fmt.Errorf("getting KMS plugin status: %w", err)
+	}
+	if resp.Healthz != "ok" {
+		return "", fmt.Errorf("KMS plugin is not healthy: %s", resp.Healthz)
+	}
+	return resp.KeyId, nil
+}
@@ -0,0 +1,94 @@
+package encryption
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/kms/pkg/service"
+)
+
+// fakeKMS implements a KMS v2 plugin backed by a StaticKeyProvider
+type fakeKMS struct {
+	provider *StaticKeyProvider
+}
+
+func (f *fakeKMS) Decrypt(ctx context.Context, _ string, req *service.DecryptRequest) ([]byte, error) {
+	return f.provider.Unwrap(ctx, req.Ciphertext, req.KeyID)
+}
+
+func (f *fakeKMS) Encrypt(ctx context.Context, _ string, data []byte) (*service.EncryptResponse, error) {
+	ciphertext, keyID, err := f.provider.Wrap(ctx, data)
+	if err != nil {
+		return nil, err
+	}
+	return &service.EncryptResponse{
+		Ciphertext:  ciphertext,
+		KeyID:       keyID,
+		Annotations: map[string][]byte{"example.com/annotation": []byte("value")},
+	}, nil
+}
+
+func (f *fakeKMS) Status(ctx context.Context) (*service.StatusResponse, error) {
+	keyID, err := f.provider.KeyID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &service.StatusResponse{Version: "v2", Healthz: "ok", KeyID: keyID}, nil
+}
+
+func TestKMSKeyProvider(t *testing.T) {
+	ctx := context.Background()
+	oldKEK, newKEK := newTestKEK(t), newTestKEK(t)
+	static, err := NewStaticKeyProvider(oldKEK)
+	require.NoError(t, err)
+
+	socket := filepath.Join(t.TempDir(), "kms.sock")
+	server := service.NewGRPCService(socket, time.Second, &fakeKMS{provider: static})
+	go server.ListenAndServe()
+	t.Cleanup(server.Close)
+
+	provider, err := NewKMSKeyProvider(socket, 5*time.Second)
+	require.NoError(t, err)
+	t.Cleanup(func() { provider.Close() })
+
+	require.Eventually(t, func() bool {
+		_, err := provider.KeyID(ctx)
+		return err == nil
+	}, 5*time.Second, 50*time.Millisecond)
+
+	m, err := NewManager(WithKeyProvider(provider))
+	require.NoError(t, err)
+	cipherText, nonce, keyID, err := m.Encrypt([]byte("something"))
+	require.NoError(t, err)
+
+	wrapped, err := m.WrappedKeys()
+	require.NoError(t, err)
+	assert.Equal(t, staticKeyID(oldKEK), wrapped[0].KEKID)
+
+	// rotate the KEK in the plugin
+	require.NoError(t, static.setKeys([][]byte{newKEK, oldKEK}))
+	require.NoError(t, m.checkKeyRotation(ctx))
+	wrapped, err = m.WrappedKeys()
+	require.NoError(t, err)
+	for _, wk := range wrapped {
+		assert.Equal(t, staticKeyID(newKEK), wk.KEKID)
+	}
+
+	m2, err := NewManager(WithKeyProvider(provider), WithWrappedKeys(wrapped))
+	require.NoError(t, err)
+	data, err := m2.Decrypt(cipherText, nonce, keyID)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("something"), data)
+
+	_, err = provider.Unwrap(ctx, wrapped[0].Key, "some-other-key")
+	assert.Error(t, err)
+
+	// closing the manager closes the connection to the plugin
+	require.NoError(t, m.Close())
+	_, err = provider.KeyID(ctx)
+	assert.Error(t, err)
+}
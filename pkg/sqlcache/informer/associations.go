@@ -0,0 +1,334 @@
+package informer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/rancher/steve/pkg/accesscontrol"
+	"github.com/rancher/steve/pkg/metrics"
+	"github.com/rancher/steve/pkg/sqlcache/db"
+	"github.com/rancher/steve/pkg/sqlcache/sqltypes"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+const (
+	listChildrenStmtFmt             = `SELECT o.object, o.objectnonce, o.dekid, o.encoding FROM "%s" o`
+	listChildrenInNamespacesStmtFmt = listChildrenStmtFmt + `
+  JOIN "%s_fields" f ON o.key = f.key
+  WHERE f."metadata.namespace" IN (?%s)`
+)
+
+// childIndex groups children by namespace, owner UID and ID, so that the children of each parent are found without
+// going through all of them
+type childIndex struct {
+	byNamespace map[string][]*unstructured.Unstructured
+	byOwner     map[types.UID][]*unstructured.Unstructured
+	byID        map[types.NamespacedName]*unstructured.Unstructured
+}
+
+func newChildIndex(children []*unstructured.Unstructured) *childIndex {
+	idx := &childIndex{
+		byNamespace: map[string][]*unstructured.Unstructured{},
+		byOwner:     map[types.UID][]*unstructured.Unstructured{},
+		byID:        map[types.NamespacedName]*unstructured.Unstructured{},
+	}
+	for _, child := range children {
+		idx.byNamespace[child.GetNamespace()] = append(idx.byNamespace[child.GetNamespace()], child)
+		for _, ref := range child.GetOwnerReferences() {
+			idx.byOwner[ref.UID] = append(idx.byOwner[ref.UID], child)
+		}
+		idx.byID[types.NamespacedName{Namespace: child.GetNamespace(), Name: child.GetName()}] = child
+	}
+	return idx
+}
+
+// selected returns the children in namespace matched by selector
+func (idx *childIndex) selected(namespace string, selector labels.Selector) []*unstructured.Unstructured {
+	var result []*unstructured.Unstructured
+	for _, child := range idx.byNamespace[namespace] {
+		if selector.Matches(labels.Set(child.GetLabels())) {
+			result = append(result, child)
+		}
+	}
+	return result
+}
+
+// childFinder returns the children of a given parent
+type childFinder func(idx *childIndex) []*unstructured.Unstructured
+
+// augmentFromObjects embeds the children matching assoc in the metadata.associatedData of the items of list, reading
+// the children objects from the database
+func (l *ListOptionIndexer) augmentFromObjects(ctx context.Context, list *unstructured.UnstructuredList, assoc sqltypes.Association, accessList accesscontrol.AccessListByVerb) error {
+	finders := make([]childFinder, len(list.Items))
+	namespaceSet := sets.Set[string]{}
+	for i := range list.Items {
+		finder, namespaces, err := newChildFinder(&list.Items[i], assoc)
+		if err != nil {
+			return fmt.Errorf("matching children of %s: %w", list.Items[i].GetName(), err)
+		}
+		finders[i] = finder
+		namespaceSet.Insert(namespaces...)
+	}
+	if namespaceSet.Len() == 0 {
+		return nil
+	}
+
+	// an empty namespace means children can be in any namespace
+	allNamespaces := !l.namespaced || namespaceSet.Has("")
+	children, err := l.listChildren(ctx, sets.List(namespaceSet), allNamespaces)
+	if err != nil {
+		return err
+	}
+	if accessList != nil {
+		children = slices.DeleteFunc(children, func(child *unstructured.Unstructured) bool {
+			ns, name := child.GetNamespace(), child.GetName()
+			return !accessList.Grants("list", ns, name) && !accessList.Grants("get", ns, name)
+		})
+	}
+
+	idx := newChildIndex(children)
+	for i, finder := range finders {
+		if finder == nil {
+			continue
+		}
+		var data []map[string]any
+		for _, child := range finder(idx) {
+			data = append(data, childData(child, assoc.Fields))
+		}
+		if err := appendAssociatedData(list.Items[i].Object, assoc, data, "childName"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// newChildFinder returns a finder for the children of parent, and the namespaces they can be found in. It returns a
+// nil finder if parent can't have children.
+func newChildFinder(parent *unstructured.Unstructured, assoc sqltypes.Association) (childFinder, []string, error) {
+	switch assoc.Type {
+	case sqltypes.AssociationByOwnerReference:
+		uid := parent.GetUID()
+		if uid == "" {
+			return nil, nil, nil
+		}
+		return func(idx *childIndex) []*unstructured.Unstructured {
+			return idx.byOwner[uid]
+		}, []string{parent.GetNamespace()}, nil
+	case sqltypes.AssociationBySelector:
+		selector, err := parentSelector(parent, assoc.SelectorPath)
+		if err != nil || selector == nil {
+			return nil, nil, err
+		}
+		namespace := parent.GetNamespace()
+		return func(idx *childIndex) []*unstructured.Unstructured {
+			return idx.selected(namespace, selector)
+		}, []string{namespace}, nil
+	case sqltypes.AssociationBySelectorRelationship, sqltypes.AssociationByIDRelationship:
+		return relationshipFinder(parent, assoc)
+	default:
+		return nil, nil, fmt.Errorf("unknown association type %q", assoc.Type)
+	}
+}
+
+// parentSelector returns the label selector at path in parent, either a plain label map, as in services, or a
+// metav1.LabelSelector. Empty selectors select nothing.
+func parentSelector(parent *unstructured.Unstructured, path []string) (labels.Selector, error) {
+	value, found, err := unstructured.NestedMap(parent.Object, path...)
+	if err != nil || !found || len(value) == 0 {
+		return nil, err
+	}
+	_, hasMatchLabels := value["matchLabels"]
+	_, hasMatchExpressions := value["matchExpressions"]
+	if hasMatchLabels || hasMatchExpressions {
+		var labelSelector metav1.LabelSelector
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(value, &labelSelector); err != nil {
+			return nil, err
+		}
+		return metav1.LabelSelectorAsSelector(&labelSelector)
+	}
+	set := labels.Set{}
+	for k, v := range value {
+		s, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("label %s in selector is not a string", k)
+		}
+		set[k] = s
+	}
+	return labels.SelectorFromSet(set), nil
+}
+
+// relationshipFinder finds the children listed in the metadata.relationships of parent, by selector or ID
+func relationshipFinder(parent *unstructured.Unstructured, assoc sqltypes.Association) (childFinder, []string, error) {
+	relationships, found, err := unstructured.NestedSlice(parent.Object, "metadata", "relationships")
+	if err != nil || !found {
+		return nil, nil, nil
+	}
+	type namespacedSelector struct {
+		namespace string
+		selector  labels.Selector
+	}
+	var selectors []namespacedSelector
+	var ids []types.NamespacedName
+	namespaces := sets.Set[string]{}
+	for _, rel := range relationships {
+		rel, ok := rel.(map[string]any)
+		if !ok || rel["toType"] != assoc.ChildSchemaName {
+			continue
+		}
+		if assoc.Type == sqltypes.AssociationBySelectorRelationship {
+			selectorString, _ := rel["selector"].(string)
+			if selectorString == "" {
+				continue
+			}
+			selector, err := labels.Parse(selectorString)
+			if err != nil {
+				return nil, nil, err
+			}
+			namespace, _ := rel["toNamespace"].(string)
+			selectors = append(selectors, namespacedSelector{namespace: namespace, selector: selector})
+			namespaces.Insert(namespace)
+		} else {
+			toID, _ := rel["toId"].(string)
+			if toID == "" {
+				continue
+			}
+			id := types.NamespacedName{Name: toID}
+			if namespace, name, ok := strings.Cut(toID, "/"); ok {
+				id = types.NamespacedName{Namespace: namespace, Name: name}
+			}
+			ids = append(ids, id)
+			namespaces.Insert(id.Namespace)
+		}
+	}
+	if namespaces.Len() == 0 {
+		return nil, nil, nil
+	}
+	return func(idx *childIndex) []*unstructured.Unstructured {
+		found := sets.Set[*unstructured.Unstructured]{}
+		var result []*unstructured.Unstructured
+		add := func(child *unstructured.Unstructured) {
+			if !found.Has(child) {
+				found.Insert(child)
+				result = append(result, child)
+			}
+		}
+		for _, id := range ids {
+			if child, ok := idx.byID[id]; ok {
+				add(child)
+			}
+		}
+		for _, s := range selectors {
+			for _, child := range idx.selected(s.namespace, s.selector) {
+				add(child)
+			}
+		}
+		return result
+	}, sets.List(namespaces), nil
+}
+
+// listChildren returns the objects in the given namespaces, or in all of them
+func (l *ListOptionIndexer) listChildren(ctx context.Context, namespaces []string, allNamespaces bool) (children []*unstructured.Unstructured, err error) {
+	dbName := db.Sanitize(l.GetName())
+	query := fmt.Sprintf(listChildrenStmtFmt, dbName)
+	var params []any
+	if !allNamespaces {
+		query = fmt.Sprintf(listChildrenInNamespacesStmtFmt, dbName, dbName, strings.Repeat(", ?", len(namespaces)-1))
+		for _, ns := range namespaces {
+			params = append(params, ns)
+		}
+	}
+	stmt := l.Prepare(query)
+	defer func() {
+		if cerr := stmt.Close(); cerr != nil {
+			err = errors.Join(err, cerr)
+		}
+	}()
+
+	var items []any
+	err = l.WithTransaction(ctx, false, func(tx db.TxClient) error {
+		now := time.Now()
+		rows, err := l.QueryForRows(ctx, tx.Stmt(stmt), params...)
+		if err != nil {
+			return err
+		}
+		logLongQuery(time.Since(now), query, params)
+		items, err = l.ReadObjects(rows, l.GetType())
+		if err != nil {
+			return fmt.Errorf("read objects: %w", err)
+		}
+		metrics.RecordSQLCacheQuery(l.metricsLabel, metrics.SQLCacheQueryAugment, float64(time.Since(now).Milliseconds()), len(items))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	children = make([]*unstructured.Unstructured, 0, len(items))
+	for _, item := range items {
+		if child, ok := item.(*unstructured.Unstructured); ok {
+			children = append(children, child)
+		}
+	}
+	return children, nil
+}
+
+// childData returns the name and state of child, and the values of the requested fields, keyed by field name
+func childData(child *unstructured.Unstructured, fields [][]string) map[string]any {
+	data := map[string]any{
+		"childName": child.GetName(),
+	}
+	if state, found, _ := unstructured.NestedMap(child.Object, "metadata", "state"); found {
+		data["state"] = state
+	}
+	if len(fields) > 0 {
+		values := map[string]any{}
+		for _, field := range fields {
+			if value, found, _ := unstructured.NestedFieldCopy(child.Object, field...); found {
+				values[smartJoin(field)] = value
+			}
+		}
+		data["fields"] = values
+	}
+	return data
+}
+
+// appendAssociatedData adds a block with the children in data to the metadata.associatedData of obj. When the
+// association has a limit, children are sorted by nameKey, only the first ones are kept and the block also holds the
+// total count.
+func appendAssociatedData(obj map[string]any, assoc sqltypes.Association, data []map[string]any, nameKey string) error {
+	if len(data) == 0 {
+		return nil
+	}
+	block := map[string]any{
+		"gvk": map[string]any{
+			"group":   assoc.ChildGVK.Group,
+			"version": assoc.ChildGVK.Version,
+			"kind":    assoc.ChildGVK.Kind,
+		},
+	}
+	if assoc.Limit > 0 {
+		slices.SortStableFunc(data, func(a, b map[string]any) int {
+			return strings.Compare(fmt.Sprint(a[nameKey]), fmt.Sprint(b[nameKey]))
+		})
+		block["count"] = int64(len(data))
+		data = data[:min(len(data), assoc.Limit)]
+	}
+	items := make([]any, len(data))
+	for i, item := range data {
+		items[i] = item
+	}
+	block["data"] = items
+	associatedData, _, err := unstructured.NestedSlice(obj, "metadata", "associatedData")
+	if err != nil {
+		return err
+	}
+	return unstructured.SetNestedSlice(obj, append(associatedData, block), "metadata", "associatedData")
+}
@@ -0,0 +1,142 @@
+package informer
+
+import (
+	"context"
+	"testing"
+
+	"github.com/rancher/steve/pkg/accesscontrol"
+	"github.com/rancher/steve/pkg/sqlcache/sqltypes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestAugmentListFromObjects(t *testing.T) {
+	podGVK := corev1.SchemeGroupVersion.WithKind("Pod")
+	newPod := func(namespace, name, phase string, labels map[string]string, owner types.UID) *unstructured.Unstructured {
+		pod := &unstructured.Unstructured{Object: map[string]any{
+			"id": namespace + "/" + name,
+			"metadata": map[string]any{
+				"state": map[string]any{"name": phase},
+			},
+			"status": map[string]any{"phase": phase},
+		}}
+		pod.SetGroupVersionKind(podGVK)
+		pod.SetNamespace(namespace)
+		pod.SetName(name)
+		pod.SetLabels(labels)
+		if owner != "" {
+			pod.SetOwnerReferences([]metav1.OwnerReference{{Kind: "Job", Name: "job", UID: owner}})
+		}
+		return pod
+	}
+	newParent := func(name string, uid types.UID, extra map[string]any) unstructured.Unstructured {
+		parent := unstructured.Unstructured{Object: extra}
+		parent.SetNamespace("default")
+		parent.SetName(name)
+		parent.SetUID(uid)
+		return parent
+	}
+	childNames := func(obj unstructured.Unstructured) []string {
+		associatedData, _, _ := unstructured.NestedSlice(obj.Object, "metadata", "associatedData")
+		var names []string
+		for _, block := range associatedData {
+			for _, item := range block.(map[string]any)["data"].([]any) {
+				names = append(names, item.(map[string]any)["childName"].(string))
+			}
+		}
+		return names
+	}
+
+	ctx := context.Background()
+	loi, dbPath, err := makeListOptionIndexer(ctx, podGVK, ListOptionIndexerOptions{IsNamespaced: true}, false, emptyNamespaceList)
+	defer cleanTempFiles(dbPath)
+	require.NoError(t, err)
+	for _, pod := range []*unstructured.Unstructured{
+		newPod("default", "job-a-3", "Pending", map[string]string{"app": "web"}, "uid-a"),
+		newPod("default", "job-a-1", "Running", map[string]string{"app": "web"}, "uid-a"),
+		newPod("default", "job-a-2", "Failed", nil, "uid-a"),
+		newPod("default", "job-b-1", "Running", nil, "uid-b"),
+		newPod("other", "web-1", "Running", map[string]string{"app": "web"}, "uid-a"),
+	} {
+		require.NoError(t, loi.Add(pod))
+	}
+
+	t.Run("owner references with limit and fields", func(t *testing.T) {
+		list := &unstructured.UnstructuredList{Items: []unstructured.Unstructured{
+			newParent("job-a", "uid-a", map[string]any{}),
+			newParent("job-b", "uid-b", map[string]any{}),
+			newParent("job-c", "uid-c", map[string]any{}),
+		}}
+		assoc := sqltypes.Association{
+			ChildGVK:        podGVK,
+			ChildSchemaName: "pod",
+			Type:            sqltypes.AssociationByOwnerReference,
+			Fields:          [][]string{{"status", "phase"}},
+			Limit:           2,
+		}
+		require.NoError(t, loi.AugmentList(ctx, list, assoc, nil))
+
+		assert.Equal(t, []string{"job-a-1", "job-a-2"}, childNames(list.Items[0]))
+		assert.Equal(t, []string{"job-b-1"}, childNames(list.Items[1]))
+		assert.Empty(t, childNames(list.Items[2]))
+
+		block := list.Items[0].Object["metadata"].(map[string]any)["associatedData"].([]any)[0].(map[string]any)
+		assert.Equal(t, int64(3), block["count"])
+		assert.Equal(t, map[string]any{"group": "", "version": "v1", "kind": "Pod"}, block["gvk"])
+		first := block["data"].([]any)[0].(map[string]any)
+		assert.Equal(t, map[string]any{"status.phase": "Running"}, first["fields"])
+		assert.Equal(t, map[string]any{"name": "Running"}, first["state"])
+	})
+
+	t.Run("label selector", func(t *testing.T) {
+		list := &unstructured.UnstructuredList{Items: []unstructured.Unstructured{
+			newParent("web", "", map[string]any{"spec": map[string]any{"selector": map[string]any{"app": "web"}}}),
+			newParent("web-deployment", "", map[string]any{"spec": map[string]any{"selector": map[string]any{
+				"matchExpressions": []any{map[string]any{"key": "app", "operator": "Exists"}},
+			}}}),
+			newParent("headless", "", map[string]any{"spec": map[string]any{}}),
+		}}
+		assoc := sqltypes.Association{
+			ChildGVK:        podGVK,
+			ChildSchemaName: "pod",
+			Type:            sqltypes.AssociationBySelector,
+			SelectorPath:    []string{"spec", "selector"},
+		}
+		require.NoError(t, loi.AugmentList(ctx, list, assoc, nil))
+
+		// children in other namespaces are never selected
+		assert.ElementsMatch(t, []string{"job-a-1", "job-a-3"}, childNames(list.Items[0]))
+		assert.ElementsMatch(t, []string{"job-a-1", "job-a-3"}, childNames(list.Items[1]))
+		assert.Empty(t, childNames(list.Items[2]))
+
+		// without a limit, blocks keep the original shape
+		block := list.Items[0].Object["metadata"].(map[string]any)["associatedData"].([]any)[0].(map[string]any)
+		assert.NotContains(t, block, "count")
+	})
+
+	t.Run("relationship with fields and access list", func(t *testing.T) {
+		list := &unstructured.UnstructuredList{Items: []unstructured.Unstructured{
+			newParent("statefulset", "", map[string]any{"metadata": map[string]any{"relationships": []any{
+				map[string]any{"toType": "pod", "toNamespace": "default", "selector": "app=web"},
+			}}}),
+		}}
+		list.Items[0].SetNamespace("default")
+		list.Items[0].SetName("statefulset")
+		assoc := sqltypes.Association{
+			ChildGVK:        podGVK,
+			ChildSchemaName: "pod",
+			Type:            sqltypes.AssociationBySelectorRelationship,
+			Fields:          [][]string{{"metadata", "labels", "app"}},
+		}
+		accessList := accesscontrol.AccessListByVerb{
+			"get": accesscontrol.AccessList{{Namespace: "default", ResourceName: "job-a-3"}},
+		}
+		require.NoError(t, loi.AugmentList(ctx, list, assoc, accessList))
+
+		assert.Equal(t, []string{"job-a-3"}, childNames(list.Items[0]))
+	})
+}
@@ -0,0 +1,193 @@
+package informer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/rancher/steve/pkg/metrics"
+	"github.com/rancher/steve/pkg/sqlcache/db"
+	"github.com/rancher/steve/pkg/sqlcache/partition"
+	"github.com/rancher/steve/pkg/sqlcache/sqltypes"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+const (
+	// ChangeAdded marks objects created since the revision of a list of changes
+	ChangeAdded = "added"
+	// ChangeModified marks objects modified since the revision of a list of changes
+	ChangeModified = "modified"
+	// ChangeDeleted marks objects deleted since the revision of a list of changes, or no longer matching its filters
+	ChangeDeleted = "deleted"
+)
+
+// objectChange collapses the events of one object since a revision
+type objectChange struct {
+	key string
+	// created is set if the object did not exist at the revision
+	created bool
+	last    metav1.Object
+}
+
+// listChanges returns the objects changed since lo.ChangesSince that match lo, partitions and namespace, with
+// metadata.change set to one of ChangeAdded, ChangeModified or ChangeDeleted. Deleted objects, and objects which no
+// longer match the filters, only have their ID, name, namespace and resourceVersion. The resourceVersion of the list
+// is the one of the last change, so that a watch can resume from there.
+func (l *ListOptionIndexer) listChanges(ctx context.Context, lo *sqltypes.ListOptions, partitions []partition.Partition, namespace string) (result *unstructured.UnstructuredList, err error) {
+	const mainObjectPrefix = "o"
+	const mainFieldPrefix = "f"
+	dbName := db.Sanitize(l.GetName())
+
+	// all matching changes are returned, in the order they happened
+	opts := *lo
+	opts.Pagination = sqltypes.Pagination{}
+	opts.SortList = sqltypes.SortList{}
+	filterComponents, err := l.compileQuery(&opts, partitions, namespace, dbName, mainFieldPrefix, map[string]int{}, false, false)
+	if err != nil {
+		return nil, err
+	}
+	filterComponents.whereClauses = append(filterComponents.whereClauses, mainFieldPrefix+`.key IN (SELECT value FROM json_each(?))`)
+	queryInfo, err := l.generateSQL(filterComponents, dbName, mainObjectPrefix, mainFieldPrefix)
+	if err != nil {
+		return nil, err
+	}
+	stmt := l.Prepare(queryInfo.query)
+	defer func() {
+		if cerr := stmt.Close(); cerr != nil {
+			err = errors.Join(err, cerr)
+		}
+	}()
+
+	var changes []*objectChange
+	matching := map[string]*unstructured.Unstructured{}
+	latestRV := lo.ChangesSince
+	start := time.Now()
+	// events and objects are read in the same transaction, so that they are consistent with each other
+	err = l.WithTransaction(ctx, false, func(tx db.TxClient) error {
+		afterID, err := l.eventIDForRV(ctx, tx.Stmt(l.getEventIDStmt), lo.ChangesSince)
+		if err != nil {
+			return err
+		}
+		byKey := map[string]*objectChange{}
+		for {
+			events, err := l.readEvents(ctx, tx.Stmt(l.listEventsStmt), afterID)
+			if err != nil {
+				return err
+			}
+			// ids are sequential, a gap means the following events were pruned
+			if len(events) > 0 && events[0].ID != afterID+1 {
+				return ErrTooOld
+			}
+			for _, e := range events {
+				key := objectKey(e.Object)
+				change, ok := byKey[key]
+				if !ok {
+					change = &objectChange{key: key, created: e.Type == watch.Added}
+					byKey[key] = change
+					changes = append(changes, change)
+				}
+				change.last = e.Object
+				afterID = e.ID
+				latestRV = e.Object.GetResourceVersion()
+			}
+			if len(events) < eventLogBatchSize {
+				break
+			}
+		}
+		if len(changes) == 0 {
+			return nil
+		}
+
+		keys := make([]string, len(changes))
+		for i, change := range changes {
+			keys[i] = change.key
+		}
+		keysJSON, err := json.Marshal(keys)
+		if err != nil {
+			return err
+		}
+		params := append(append([]any{}, queryInfo.params...), string(keysJSON))
+		rows, err := l.QueryForRows(ctx, tx.Stmt(stmt), params...)
+		if err != nil {
+			return err
+		}
+		items, err := l.ReadObjects(rows, l.GetType())
+		if err != nil {
+			return fmt.Errorf("read objects: %w", err)
+		}
+		for _, item := range items {
+			if obj, ok := item.(*unstructured.Unstructured); ok {
+				matching[objectKey(obj)] = obj
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var items []any
+	for _, change := range changes {
+		if obj, ok := matching[change.key]; ok {
+			changeType := ChangeModified
+			if change.created {
+				changeType = ChangeAdded
+			}
+			if err := unstructured.SetNestedField(obj.Object, changeType, "metadata", "change"); err != nil {
+				return nil, err
+			}
+			items = append(items, obj)
+			continue
+		}
+		// objects created after the revision were never seen by the client
+		if change.created || !partitionsMatch(partitions, namespace, change.last) {
+			continue
+		}
+		items = append(items, deletedObject(change))
+	}
+	metrics.RecordSQLCacheQuery(l.metricsLabel, metrics.SQLCacheQueryList, float64(time.Since(start).Milliseconds()), len(items))
+	return toUnstructuredList(items, latestRV), nil
+}
+
+// deletedObject returns the ChangeDeleted entry for an object, with only enough to identify it
+func deletedObject(change *objectChange) *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{Object: map[string]any{"id": change.key}}
+	if last, ok := change.last.(*unstructured.Unstructured); ok {
+		obj.SetAPIVersion(last.GetAPIVersion())
+		obj.SetKind(last.GetKind())
+	}
+	obj.SetNamespace(change.last.GetNamespace())
+	obj.SetName(change.last.GetName())
+	obj.SetResourceVersion(change.last.GetResourceVersion())
+	_ = unstructured.SetNestedField(obj.Object, ChangeDeleted, "metadata", "change")
+	return obj
+}
+
+// partitionsMatch returns whether obj is visible through any of partitions, as generatePartitionClauses does in SQL
+func partitionsMatch(partitions []partition.Partition, namespace string, obj metav1.Object) bool {
+	if namespace != "" && namespace != "*" && obj.GetNamespace() != namespace {
+		return false
+	}
+	for _, p := range partitions {
+		if p.Passthrough {
+			return true
+		}
+		namespaceOK := p.Namespace == "" || p.Namespace == "*" || p.Namespace == obj.GetNamespace()
+		if namespaceOK && (p.All || p.Names.Has(obj.GetName())) {
+			return true
+		}
+	}
+	return false
+}
+
+// objectKey returns the key of obj in the database, as cache.MetaNamespaceKeyFunc does
+func objectKey(obj metav1.Object) string {
+	if obj.GetNamespace() == "" {
+		return obj.GetName()
+	}
+	return obj.GetNamespace() + "/" + obj.GetName()
+}
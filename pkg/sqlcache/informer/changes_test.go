@@ -0,0 +1,93 @@
+package informer
+
+import (
+	"context"
+	"testing"
+
+	"github.com/rancher/steve/pkg/sqlcache/partition"
+	"github.com/rancher/steve/pkg/sqlcache/sqltypes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+func TestListByOptionsChangesSince(t *testing.T) {
+	gvk := corev1.SchemeGroupVersion.WithKind("ConfigMap")
+	newConfigMap := func(namespace, name, rv, app string) *unstructured.Unstructured {
+		obj := &unstructured.Unstructured{}
+		obj.SetGroupVersionKind(gvk)
+		obj.SetName(name)
+		obj.SetNamespace(namespace)
+		obj.SetResourceVersion(rv)
+		obj.SetLabels(map[string]string{"app": app})
+		obj.Object["id"] = namespace + "/" + name
+		return obj
+	}
+	changes := func(list *unstructured.UnstructuredList) map[string]string {
+		result := map[string]string{}
+		for _, item := range list.Items {
+			change, _, _ := unstructured.NestedString(item.Object, "metadata", "change")
+			result[item.GetNamespace()+"/"+item.GetName()] = change
+		}
+		return result
+	}
+
+	ctx := context.Background()
+	loi, dbPath, err := makeListOptionIndexer(ctx, gvk, ListOptionIndexerOptions{IsNamespaced: true}, false, emptyNamespaceList)
+	defer cleanTempFiles(dbPath)
+	require.NoError(t, err)
+
+	require.NoError(t, loi.Add(newConfigMap("default", "modified", "100", "web")))
+	require.NoError(t, loi.Add(newConfigMap("default", "deleted", "101", "web")))
+	require.NoError(t, loi.Add(newConfigMap("default", "relabeled", "102", "web")))
+	require.NoError(t, loi.Add(newConfigMap("other", "hidden", "103", "web")))
+	// changes since 103
+	require.NoError(t, loi.Update(newConfigMap("default", "modified", "104", "web")))
+	require.NoError(t, loi.Delete(newConfigMap("default", "deleted", "105", "web")))
+	require.NoError(t, loi.Update(newConfigMap("default", "relabeled", "106", "db")))
+	require.NoError(t, loi.Add(newConfigMap("default", "added", "107", "web")))
+	require.NoError(t, loi.Add(newConfigMap("default", "transient", "108", "web")))
+	require.NoError(t, loi.Delete(newConfigMap("default", "transient", "109", "web")))
+	require.NoError(t, loi.Delete(newConfigMap("other", "hidden", "110", "web")))
+
+	lo := &sqltypes.ListOptions{
+		ChangesSince: "103",
+		Filters: []sqltypes.OrFilter{{Filters: []sqltypes.Filter{
+			{Field: []string{"metadata", "labels", "app"}, Matches: []string{"web"}, Op: sqltypes.Eq},
+		}}},
+	}
+	partitions := []partition.Partition{{Namespace: "default", All: true}}
+	list, total, _, _, err := loi.ListByOptions(ctx, lo, partitions, "")
+	require.NoError(t, err)
+	assert.Equal(t, 4, total)
+	assert.Equal(t, map[string]string{
+		"default/modified":  ChangeModified,
+		"default/deleted":   ChangeDeleted,
+		"default/relabeled": ChangeDeleted,
+		"default/added":     ChangeAdded,
+	}, changes(list))
+	assert.Equal(t, "110", list.GetResourceVersion())
+	assert.Equal(t, "default/deleted", list.Items[1].Object["id"])
+
+	// partitions restricted by name
+	partitions = []partition.Partition{{Namespace: "default", Names: sets.New("modified")}}
+	list, _, _, _, err = loi.ListByOptions(ctx, lo, partitions, "")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"default/modified": ChangeModified}, changes(list))
+
+	// namespace requested
+	list, _, _, _, err = loi.ListByOptions(ctx, &sqltypes.ListOptions{ChangesSince: "103"}, []partition.Partition{{Passthrough: true}}, "other")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"other/hidden": ChangeDeleted}, changes(list))
+
+	// no changes since the latest revision
+	list, total, _, _, err = loi.ListByOptions(ctx, &sqltypes.ListOptions{ChangesSince: "110"}, partitions, "")
+	require.NoError(t, err)
+	assert.Equal(t, 0, total)
+	assert.Equal(t, "110", list.GetResourceVersion())
+
+	_, _, _, _, err = loi.ListByOptions(ctx, &sqltypes.ListOptions{ChangesSince: "1"}, partitions, "")
+	assert.ErrorIs(t, err, ErrTooOld)
+}
@@ -0,0 +1,287 @@
+package informer
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/rancher/steve/pkg/sqlcache/db"
+	"github.com/rancher/steve/pkg/sqlcache/informer/internal/ring"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+const (
+	// defaultGCKeepCount is the default number of events kept in memory
+	defaultGCKeepCount = 1000
+	// defaultEventLogMaxCount is the default number of events kept in the database
+	defaultEventLogMaxCount = 10000
+	// eventLogBatchSize is how many events are read from the database at once when resuming a watch
+	eventLogBatchSize = 500
+	// eventLogRetryInterval is how long to wait before reading the database again, when events were committed but not
+	// published to memory yet
+	eventLogRetryInterval = 10 * time.Millisecond
+)
+
+const (
+	// AUTOINCREMENT keeps SQLite from reusing the ids of pruned events, so that a gap in ids always means that events
+	// were pruned
+	createEventsTableFmt = `CREATE TABLE "%s_events" (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		rv TEXT NOT NULL,
+		type TEXT NOT NULL,
+		created INTEGER NOT NULL,
+		object BLOB,
+		objectnonce BLOB,
+		dekid INTEGER,
+		encoding INTEGER,
+		previous BLOB,
+		previousnonce BLOB,
+		previousdekid INTEGER,
+		previousencoding INTEGER
+	)`
+	createEventsRVIndexFmt      = `CREATE INDEX "%s_events_rv_index" ON "%s_events"(rv)`
+	createEventsCreatedIndexFmt = `CREATE INDEX "%s_events_created_index" ON "%s_events"(created)`
+	dropEventsFmt               = `DROP TABLE IF EXISTS "%s_events"`
+
+	addEventStmtFmt = `INSERT INTO "%s_events"(rv, type, created, object, objectnonce, dekid, encoding, previous, previousnonce, previousdekid, previousencoding)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	// addStoredEventStmtFmt copies the object just written to the objects table, rather than serializing it again
+	addStoredEventStmtFmt = `INSERT INTO "%s_events"(rv, type, created, object, objectnonce, dekid, encoding, previous, previousnonce, previousdekid, previousencoding)
+SELECT ?, ?, ?, object, objectnonce, dekid, encoding, ?, ?, ?, ? FROM "%s" WHERE key = ?`
+	getStoredObjectStmtFmt = `SELECT object, objectnonce, dekid, encoding FROM "%s" WHERE key = ?`
+	pruneEventsStmtFmt     = `DELETE FROM "%s_events" WHERE id <= ? OR created < ?`
+	getEventIDStmtFmt      = `SELECT id FROM "%s_events" WHERE rv = ? ORDER BY id DESC LIMIT 1`
+	listEventsStmtFmt      = `SELECT id, type, object, objectnonce, dekid, encoding, previous, previousnonce, previousdekid, previousencoding FROM "%s_events" WHERE id > ? ORDER BY id LIMIT ?`
+)
+
+// eventPruner decides when stored events are pruned. Deleting them on every write would add a statement to each
+// transaction, so they are pruned once the table grows by a tenth of MaxCount (at most maxPruneInterval events), or a
+// tenth of MaxAge elapses.
+type eventPruner struct {
+	lock     sync.Mutex
+	interval int64
+	maxAge   time.Duration
+	lastID   int64
+	lastTime time.Time
+}
+
+// maxPruneInterval is the most events stored between two prunes
+const maxPruneInterval = 100
+
+func newEventPruner(opts EventLogOptions) *eventPruner {
+	return &eventPruner{
+		interval: int64(min(max(opts.MaxCount/10, 1), maxPruneInterval)),
+		maxAge:   opts.MaxAge,
+	}
+}
+
+// due returns whether events should be pruned after storing the one with ID id, at now
+func (p *eventPruner) due(id int64, now time.Time) bool {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if id-p.lastID < p.interval && (p.maxAge <= 0 || now.Sub(p.lastTime) < p.maxAge/10) {
+		return false
+	}
+	p.lastID, p.lastTime = id, now
+	return true
+}
+
+// EventLogOptions configures how many events are kept to resume watches from a past resourceVersion
+type EventLogOptions struct {
+	// GCKeepCount is how many events to keep in memory. Defaults to 1000.
+	GCKeepCount int
+	// MaxCount is how many events to keep in the database, allowing watches to resume from revisions no longer in
+	// memory. Defaults to 10000.
+	MaxCount int
+	// MaxAge is how long events are kept in the database. Zero keeps events regardless of their age.
+	MaxAge time.Duration
+}
+
+func (l *ListOptionIndexer) createEventsTable(tx db.TxClient, dbName string) error {
+	if _, err := tx.Exec(fmt.Sprintf(dropEventsFmt, dbName)); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(fmt.Sprintf(createEventsTableFmt, dbName)); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(fmt.Sprintf(createEventsRVIndexFmt, dbName, dbName)); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(fmt.Sprintf(createEventsCreatedIndexFmt, dbName, dbName)); err != nil {
+		return err
+	}
+	return nil
+}
+
+// addEvent persists an event in the same transaction as the change to the object, returning its ID. previous is the
+// serialized object before the change, if any. The object of Added and Modified events is copied from the row just
+// written for key, so that it isn't serialized twice, while that of Deleted events is no longer stored and is
+// serialized from current.
+func (l *ListOptionIndexer) addEvent(tx db.TxClient, eventType watch.EventType, key string, previous db.SerializedObject, current any, rv string) (int64, error) {
+	now := time.Now()
+	var result sql.Result
+	var err error
+	if eventType == watch.Deleted {
+		var object db.SerializedObject
+		if object, err = l.Serialize(current, l.GetShouldEncrypt()); err != nil {
+			return 0, err
+		}
+		result, err = tx.Stmt(l.addEventStmt).Exec(rv, string(eventType), now.UnixMilli(),
+			object.Bytes, object.Nonce, object.KeyID, object.Encoding,
+			previous.Bytes, previous.Nonce, previous.KeyID, previous.Encoding)
+	} else {
+		result, err = tx.Stmt(l.addStoredEventStmt).Exec(rv, string(eventType), now.UnixMilli(),
+			previous.Bytes, previous.Nonce, previous.KeyID, previous.Encoding, key)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("adding event: %w", err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	if !l.eventPruner.due(id, now) {
+		return id, nil
+	}
+	// ids are sequential, so events beyond the maximum count are all those up to id - MaxCount
+	var createdBefore int64
+	if l.eventLogOpts.MaxAge > 0 {
+		createdBefore = now.Add(-l.eventLogOpts.MaxAge).UnixMilli()
+	}
+	if _, err := tx.Stmt(l.pruneEventsStmt).Exec(id-int64(l.eventLogOpts.MaxCount), createdBefore); err != nil {
+		return 0, fmt.Errorf("pruning events: %w", err)
+	}
+	return id, nil
+}
+
+// getStored returns the object stored for key, both serialized and deserialized, so that it can be stored again in
+// an event without serializing it
+func (l *ListOptionIndexer) getStored(key string) (db.SerializedObject, any, bool, error) {
+	var serialized db.SerializedObject
+	rows, err := l.QueryForRows(l.ctx, l.getStoredObjectStmt, key)
+	if err != nil {
+		return serialized, nil, false, err
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		return serialized, nil, false, rows.Err()
+	}
+	if err := rows.Scan(&serialized.Bytes, &serialized.Nonce, &serialized.KeyID, &serialized.Encoding); err != nil {
+		return serialized, nil, false, err
+	}
+	obj := reflect.New(l.GetType().Elem()).Interface()
+	if err := l.Deserialize(serialized, obj); err != nil {
+		return serialized, nil, false, err
+	}
+	return serialized, obj, true, rows.Close()
+}
+
+func (l *ListOptionIndexer) dropEvents(tx db.TxClient) error {
+	_, err := tx.Stmt(l.dropEventsStmt).Exec()
+	return err
+}
+
+// eventIDForRV returns the ID of the last stored event for rv, or ErrTooOld if it is no longer stored. stmt is
+// getEventIDStmt, possibly bound to a transaction.
+func (l *ListOptionIndexer) eventIDForRV(ctx context.Context, stmt db.Stmt, rv string) (int64, error) {
+	rows, err := l.QueryForRows(ctx, stmt, rv)
+	if err != nil {
+		return 0, err
+	}
+	id, err := l.ReadInt(rows)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, ErrTooOld
+	}
+	return int64(id), err
+}
+
+// readEvents returns up to eventLogBatchSize stored events following the one with ID afterID. stmt is listEventsStmt,
+// possibly bound to a transaction.
+func (l *ListOptionIndexer) readEvents(ctx context.Context, stmt db.Stmt, afterID int64) ([]*event, error) {
+	rows, err := l.QueryForRows(ctx, stmt, afterID, eventLogBatchSize)
+	if err != nil {
+		return nil, err
+	}
+	var events []*event
+	for rows.Next() {
+		e, err := l.scanEvent(rows)
+		if err != nil {
+			return nil, errors.Join(err, rows.Close())
+		}
+		events = append(events, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.Join(err, rows.Close())
+	}
+	return events, rows.Close()
+}
+
+func (l *ListOptionIndexer) scanEvent(rows db.Rows) (*event, error) {
+	var e event
+	var typ string
+	var object, previous db.SerializedObject
+	if err := rows.Scan(&e.ID, &typ,
+		&object.Bytes, &object.Nonce, &object.KeyID, &object.Encoding,
+		&previous.Bytes, &previous.Nonce, &previous.KeyID, &previous.Encoding); err != nil {
+		return nil, err
+	}
+	e.Type = watch.EventType(typ)
+
+	current := &unstructured.Unstructured{}
+	if err := l.Deserialize(object, current); err != nil {
+		return nil, err
+	}
+	e.Object = current
+	if len(previous.Bytes) > 0 {
+		prev := &unstructured.Unstructured{}
+		if err := l.Deserialize(previous, prev); err != nil {
+			return nil, err
+		}
+		e.Previous = prev
+	}
+	return &e, nil
+}
+
+// replayEvents sends the stored events following the one with ID afterID, until it catches up with the events kept in
+// memory. It returns a reader positioned right after the last event sent.
+func (l *ListOptionIndexer) replayEvents(ctx context.Context, afterID int64, send func(*event)) (*ring.Reader[*event], error) {
+	for {
+		events, err := l.readEvents(ctx, l.listEventsStmt, afterID)
+		if err != nil {
+			return nil, err
+		}
+		// ids are sequential, a gap means the following events were pruned
+		if len(events) > 0 && events[0].ID != afterID+1 {
+			return nil, ErrTooOld
+		}
+		for _, e := range events {
+			send(e)
+			afterID = e.ID
+		}
+		if len(events) == eventLogBatchSize {
+			continue
+		}
+
+		r := l.eventLog.NewReader()
+		if r.Rewind(func(e *event) bool { return e.ID == afterID }) {
+			// the last sent event is still in memory, continue from there
+			if _, err := r.Read(ctx); err != nil {
+				return nil, err
+			}
+			return r, nil
+		}
+
+		// either the last committed events are not published yet, or the reader was lapped again
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(eventLogRetryInterval):
+		}
+	}
+}
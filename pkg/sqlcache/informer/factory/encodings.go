@@ -0,0 +1,52 @@
+package factory
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/rancher/steve/pkg/sqlcache/db"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// trainDictionarySuffix enables training a zstd dictionary when appended to an encoding name
+const trainDictionarySuffix = "+dict"
+
+// ParseTypeEncodings parses a comma-separated list of "<group>/<version>/<kind>=<encoding>" entries, where the group
+// is omitted for core types and encoding is one accepted by db.ParseEncoding. "msgpack+zstd+dict" also trains a
+// dictionary for the GVK, eg:
+//
+//	v1/ConfigMap=msgpack+zstd+dict,apiextensions.k8s.io/v1/CustomResourceDefinition=msgpack+zstd
+func ParseTypeEncodings(spec string) (map[schema.GroupVersionKind]db.EncodingOptions, error) {
+	encodings := map[schema.GroupVersionKind]db.EncodingOptions{}
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		typ, name, ok := strings.Cut(entry, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid entry %q, expected <type>=<encoding>", entry)
+		}
+
+		slash := strings.LastIndex(typ, "/")
+		if slash == -1 {
+			return nil, fmt.Errorf("invalid type %q, expected <group>/<version>/<kind>", typ)
+		}
+		gv, err := schema.ParseGroupVersion(typ[:slash])
+		if err != nil {
+			return nil, err
+		}
+		gvk := gv.WithKind(typ[slash+1:])
+
+		var opts db.EncodingOptions
+		name, opts.TrainDictionary = strings.CutSuffix(name, trainDictionarySuffix)
+		if opts.Encoding, err = db.ParseEncoding(name); err != nil {
+			return nil, err
+		}
+		if opts.TrainDictionary && opts.Encoding != db.ZstdMsgpackEncoding {
+			return nil, fmt.Errorf("dictionaries are only supported with msgpack+zstd, got %s for %s", name, typ)
+		}
+		encodings[gvk] = opts
+	}
+	return encodings, nil
+}
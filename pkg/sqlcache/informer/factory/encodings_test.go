@@ -0,0 +1,31 @@
+package factory
+
+import (
+	"testing"
+
+	"github.com/rancher/steve/pkg/sqlcache/db"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestParseTypeEncodings(t *testing.T) {
+	encodings, err := ParseTypeEncodings("v1/ConfigMap=msgpack+zstd+dict, apiextensions.k8s.io/v1/CustomResourceDefinition=msgpack+zstd,,v1/Event=json")
+	require.NoError(t, err)
+	assert.Equal(t, map[schema.GroupVersionKind]db.EncodingOptions{
+		{Version: "v1", Kind: "ConfigMap"}:                                               {Encoding: db.ZstdMsgpackEncoding, TrainDictionary: true},
+		{Group: "apiextensions.k8s.io", Version: "v1", Kind: "CustomResourceDefinition"}: {Encoding: db.ZstdMsgpackEncoding},
+		{Version: "v1", Kind: "Event"}:                                                   {Encoding: db.JSONEncoding},
+	}, encodings)
+
+	for _, spec := range []string{
+		"v1/ConfigMap",
+		"ConfigMap=msgpack",
+		"v1/ConfigMap=zstd",
+		"v1/ConfigMap=json+dict",
+		"a/b/c/ConfigMap=json",
+	} {
+		_, err := ParseTypeEncodings(spec)
+		assert.Error(t, err, spec)
+	}
+}
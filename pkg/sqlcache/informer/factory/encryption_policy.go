@@ -0,0 +1,110 @@
+package factory
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/rancher/steve/pkg/configfile"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// EncryptionPolicyEnvVar is the path to a YAML or JSON file containing an EncryptionPolicy
+const EncryptionPolicyEnvVar = "CATTLE_ENCRYPT_CACHE_POLICY"
+
+// defaultEncryptedResourceTypes are always encrypted, unless a ResourceEncryptionRule explicitly disables it
+var defaultEncryptedResourceTypes = map[schema.GroupVersionKind]struct{}{
+	{
+		Version: "v1",
+		Kind:    "Secret",
+	}: {},
+	{
+		Group:   "management.cattle.io",
+		Version: "v3",
+		Kind:    "Token",
+	}: {},
+}
+
+// unhashableFields can't be hashed because they are compared in plaintext when filtering by partition,
+// or, for labels, because they are stored in their own table
+var unhashableFields = []string{"id", "metadata.name", "metadata.namespace", "metadata.labels"}
+
+// EncryptionPolicy decides which GVKs have their objects encrypted in the cache, and which of their indexed fields
+// are stored as keyed hashes instead of plaintext
+type EncryptionPolicy struct {
+	// EncryptAll encrypts objects of all GVKs, except those with a rule disabling it
+	EncryptAll bool `json:"encryptAll,omitempty"`
+	// Resources lists per-GVK rules
+	Resources []ResourceEncryptionRule `json:"resources,omitempty"`
+}
+
+// ResourceEncryptionRule configures encryption for a single GVK
+type ResourceEncryptionRule struct {
+	Group   string `json:"group,omitempty"`
+	Version string `json:"version"`
+	Kind    string `json:"kind"`
+	// Encrypt encrypts the objects of this GVK. Defaults to true.
+	Encrypt *bool `json:"encrypt,omitempty"`
+	// HashedFields are indexed fields stored as keyed hashes, eg. "spec.userName" or
+	// "metadata.annotations[example.com/owner]". Only exact matches can be used to filter on them, and they
+	// can't be sorted on or summarized.
+	HashedFields []string `json:"hashedFields,omitempty"`
+}
+
+func (r ResourceEncryptionRule) gvk() schema.GroupVersionKind {
+	return schema.GroupVersionKind{Group: r.Group, Version: r.Version, Kind: r.Kind}
+}
+
+// LoadEncryptionPolicy reads the EncryptionPolicy in the file at path, eg. the one in EncryptionPolicyEnvVar, and rejects rules that repeat a GVK or hash a field the SQL cache needs in plaintext.
+func LoadEncryptionPolicy(path string) (EncryptionPolicy, error) {
+	return configfile.Load(path, "encryption policy", EncryptionPolicy.Validate)
+}
+
+// Validate checks that rules are complete, unique per GVK, and only hash fields that can be hashed
+func (p EncryptionPolicy) Validate() error {
+	seen := map[schema.GroupVersionKind]bool{}
+	for _, rule := range p.Resources {
+		gvk := rule.gvk()
+		if rule.Version == "" || rule.Kind == "" {
+			return fmt.Errorf("rule for %v: version and kind are required", gvk)
+		}
+		if seen[gvk] {
+			return fmt.Errorf("duplicate rule for %v", gvk)
+		}
+		seen[gvk] = true
+		for _, field := range rule.HashedFields {
+			if field == "" {
+				return fmt.Errorf("rule for %v: empty hashed field", gvk)
+			}
+			for _, unhashable := range unhashableFields {
+				if field == unhashable || strings.HasPrefix(field, unhashable+"[") || strings.HasPrefix(field, unhashable+".") {
+					return fmt.Errorf("rule for %v: field %s cannot be hashed", gvk, field)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (p EncryptionPolicy) rule(gvk schema.GroupVersionKind) (ResourceEncryptionRule, bool) {
+	for _, rule := range p.Resources {
+		if rule.gvk() == gvk {
+			return rule, true
+		}
+	}
+	return ResourceEncryptionRule{}, false
+}
+
+// ShouldEncrypt returns whether objects of gvk are encrypted
+func (p EncryptionPolicy) ShouldEncrypt(gvk schema.GroupVersionKind) bool {
+	if rule, ok := p.rule(gvk); ok {
+		return rule.Encrypt == nil || *rule.Encrypt
+	}
+	_, encryptResourceAlways := defaultEncryptedResourceTypes[gvk]
+	return p.EncryptAll || encryptResourceAlways
+}
+
+// HashedFieldsFor returns the IDs of the indexed fields of gvk stored as keyed hashes
+func (p EncryptionPolicy) HashedFieldsFor(gvk schema.GroupVersionKind) []string {
+	rule, _ := p.rule(gvk)
+	return rule.HashedFields
+}
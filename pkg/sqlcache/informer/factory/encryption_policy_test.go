@@ -0,0 +1,89 @@
+package factory
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestEncryptionPolicy(t *testing.T) {
+	secretGVK := schema.GroupVersionKind{Version: "v1", Kind: "Secret"}
+	configMapGVK := schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"}
+	podGVK := schema.GroupVersionKind{Version: "v1", Kind: "Pod"}
+	disabled := false
+
+	policy := EncryptionPolicy{
+		Resources: []ResourceEncryptionRule{
+			{Version: "v1", Kind: "ConfigMap", HashedFields: []string{"data.owner"}},
+			{Version: "v1", Kind: "Secret", Encrypt: &disabled},
+		},
+	}
+	require.NoError(t, policy.Validate())
+	assert.True(t, policy.ShouldEncrypt(configMapGVK))
+	assert.False(t, policy.ShouldEncrypt(secretGVK))
+	assert.False(t, policy.ShouldEncrypt(podGVK))
+	assert.Equal(t, []string{"data.owner"}, policy.HashedFieldsFor(configMapGVK))
+	assert.Empty(t, policy.HashedFieldsFor(podGVK))
+
+	policy.EncryptAll = true
+	assert.True(t, policy.ShouldEncrypt(podGVK))
+	assert.False(t, policy.ShouldEncrypt(secretGVK))
+
+	// the zero value keeps the default encrypted types
+	assert.True(t, EncryptionPolicy{}.ShouldEncrypt(secretGVK))
+	assert.False(t, EncryptionPolicy{}.ShouldEncrypt(podGVK))
+}
+
+func TestEncryptionPolicyValidate(t *testing.T) {
+	tests := []struct {
+		name  string
+		rules []ResourceEncryptionRule
+	}{
+		{
+			name:  "missing kind",
+			rules: []ResourceEncryptionRule{{Version: "v1"}},
+		},
+		{
+			name:  "duplicate GVK",
+			rules: []ResourceEncryptionRule{{Version: "v1", Kind: "Pod"}, {Version: "v1", Kind: "Pod"}},
+		},
+		{
+			name:  "hashed name",
+			rules: []ResourceEncryptionRule{{Version: "v1", Kind: "Pod", HashedFields: []string{"metadata.name"}}},
+		},
+		{
+			name:  "hashed label",
+			rules: []ResourceEncryptionRule{{Version: "v1", Kind: "Pod", HashedFields: []string{"metadata.labels[app]"}}},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Error(t, EncryptionPolicy{Resources: test.rules}.Validate())
+		})
+	}
+}
+
+func TestLoadEncryptionPolicy(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "policy.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`
+encryptAll: true
+resources:
+- version: v1
+  kind: ConfigMap
+  hashedFields:
+  - metadata.annotations[example.com/owner]
+`), 0600))
+
+	policy, err := LoadEncryptionPolicy(path)
+	require.NoError(t, err)
+	assert.True(t, policy.EncryptAll)
+	assert.Equal(t, []string{"metadata.annotations[example.com/owner]"}, policy.HashedFieldsFor(schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"}))
+
+	require.NoError(t, os.WriteFile(path, []byte("unknownField: true\n"), 0600))
+	_, err = LoadEncryptionPolicy(path)
+	assert.Error(t, err)
+}
@@ -5,12 +5,14 @@ package factory
 
 import (
 	"context"
+	"crypto/rand"
 	"fmt"
 	"os"
 	"sync"
 	"time"
 
 	"github.com/rancher/lasso/pkg/log"
+	"github.com/rancher/steve/pkg/metrics"
 	"github.com/rancher/steve/pkg/sqlcache/db"
 	"github.com/rancher/steve/pkg/sqlcache/encryption"
 	"github.com/rancher/steve/pkg/sqlcache/informer"
@@ -22,9 +24,24 @@ import (
 )
 
 // EncryptAllEnvVar is set to "true" if users want all types' data blobs to be encrypted in SQLite
-// otherwise only variables in defaultEncryptedResourceTypes will have their blobs encrypted
+// otherwise only variables in defaultEncryptedResourceTypes will have their blobs encrypted.
+//
+// Deprecated: set EncryptAll in the EncryptionPolicy instead
 const EncryptAllEnvVar = "CATTLE_ENCRYPT_CACHE_ALL"
 
+// KeyProviderEnvVar selects a provider for the key encryption key used to wrap data encryption keys, in the format
+// accepted by encryption.NewKeyProvider, eg. "file:/path/to/keys" or "kms:/path/to/kms.sock"
+const KeyProviderEnvVar = "CATTLE_ENCRYPT_CACHE_KEY_PROVIDER"
+
+// keyRotationCheckInterval is how often the key provider is checked for a rotated key encryption key
+const keyRotationCheckInterval = time.Minute
+
+// TypeEncodingsEnvVar overrides the encoding for some GVKs, see ParseTypeEncodings
+const TypeEncodingsEnvVar = "CATTLE_SQL_CACHE_TYPE_ENCODINGS"
+
+// dbFileSizeReportInterval is how often the size of the database files is published as a metric
+const dbFileSizeReportInterval = 30 * time.Second
+
 // CacheFactory builds Informer instances and keeps a cache of instances it created
 type CacheFactory struct {
 	dbClient db.Client
@@ -33,9 +50,18 @@ type CacheFactory struct {
 	ctx    context.Context
 	cancel context.CancelFunc
 
-	encryptAll bool
+	encryptionPolicy EncryptionPolicy
+	// hashKey is the key for the hashes of HashedFields. The database doesn't outlive the factory, so it is
+	// generated for each factory.
+	hashKey []byte
+
+	encodings map[schema.GroupVersionKind]db.EncodingOptions
 
-	gcKeepCount int
+	eventLog informer.EventLogOptions
+
+	pruneRules informer.PruneRules
+
+	namespaces *NamespaceScopes
 
 	newInformer newInformer
 
@@ -56,7 +82,7 @@ type guardedInformer struct {
 	wg sync.WaitGroup
 }
 
-type newInformer func(ctx context.Context, client dynamic.ResourceInterface, fields map[string]informer.IndexedField, externalUpdateInfo *sqltypes.ExternalGVKUpdates, selfUpdateInfo *sqltypes.ExternalGVKUpdates, transform cache.TransformFunc, gvk schema.GroupVersionKind, db db.Client, shouldEncrypt bool, namespace bool, watchable bool, gcKeepCount int) (*informer.Informer, error)
+type newInformer func(ctx context.Context, client dynamic.ResourceInterface, fields map[string]informer.IndexedField, externalUpdateInfo *sqltypes.ExternalGVKUpdates, selfUpdateInfo *sqltypes.ExternalGVKUpdates, transform cache.TransformFunc, gvk schema.GroupVersionKind, db db.Client, shouldEncrypt bool, namespace bool, watchable bool, eventLog informer.EventLogOptions) (*informer.Informer, error)
 
 type Cache struct {
 	informer.ByOptionsLister
@@ -76,24 +102,33 @@ func (c *Cache) GVK() schema.GroupVersionKind {
 	return c.gvk
 }
 
-var defaultEncryptedResourceTypes = map[schema.GroupVersionKind]struct{}{
-	{
-		Version: "v1",
-		Kind:    "Secret",
-	}: {},
-	{
-		Group:   "management.cattle.io",
-		Version: "v3",
-		Kind:    "Token",
-	}: {},
-}
-
 type CacheFactoryOptions struct {
 	// GCInterval is how often to run the garbage collection
 	// Deprecated: events are not stored in memory using a fixed-length circular list
 	GCInterval time.Duration
 	// GCKeepCount is how many events to keep in memory
 	GCKeepCount int
+	// EventLogMaxCount is how many events to keep in the database, so that watches can resume from revisions that
+	// are no longer in memory. Defaults to 10000.
+	EventLogMaxCount int
+	// EventLogMaxAge is how long events are kept in the database. Zero keeps events regardless of their age.
+	EventLogMaxAge time.Duration
+	// PruneRules lists, per GVK, the fields removed from objects before they are stored in the cache.
+	// GVKs without an entry have informer.DefaultPrunedFields removed.
+	// If nil, the rules in the file named by PruneRulesEnvVar are used, if any.
+	PruneRules informer.PruneRules
+	// KeyProvider, if set, wraps the data encryption keys with a key encryption key it holds.
+	// If nil, the provider named by KeyProviderEnvVar is used, if any.
+	KeyProvider encryption.KeyProvider
+	// EncryptionPolicy decides which GVKs are encrypted and which indexed fields are hashed.
+	// If nil, the policy in the file named by EncryptionPolicyEnvVar is used, if any.
+	EncryptionPolicy *EncryptionPolicy
+	// Encodings overrides the encoding of objects for some GVKs, eg. to compress large objects.
+	// If nil, the encodings in TypeEncodingsEnvVar are used, if any.
+	Encodings map[schema.GroupVersionKind]db.EncodingOptions
+	// Namespaces restricts the informers of some namespaced types to a list of namespaces, combining the objects of
+	// all of them in one cache. If nil, the scopes in NamespacesEnvVar are used, if any.
+	Namespaces *NamespaceScopes
 }
 
 // NewCacheFactory returns an informer factory instance
@@ -103,30 +138,121 @@ func NewCacheFactory(opts CacheFactoryOptions) (*CacheFactory, error) {
 }
 
 func NewCacheFactoryWithContext(ctx context.Context, opts CacheFactoryOptions) (*CacheFactory, error) {
-	m, err := encryption.NewManager()
+	var encryptionPolicy EncryptionPolicy
+	if opts.EncryptionPolicy != nil {
+		encryptionPolicy = *opts.EncryptionPolicy
+		if err := encryptionPolicy.Validate(); err != nil {
+			return nil, fmt.Errorf("invalid encryption policy: %w", err)
+		}
+	} else if path := os.Getenv(EncryptionPolicyEnvVar); path != "" {
+		var err error
+		if encryptionPolicy, err = LoadEncryptionPolicy(path); err != nil {
+			return nil, err
+		}
+	}
+	if os.Getenv(EncryptAllEnvVar) == "true" {
+		encryptionPolicy.EncryptAll = true
+	}
+	encodings := opts.Encodings
+	if spec := os.Getenv(TypeEncodingsEnvVar); encodings == nil && spec != "" {
+		var err error
+		if encodings, err = ParseTypeEncodings(spec); err != nil {
+			return nil, fmt.Errorf("parsing %s: %w", TypeEncodingsEnvVar, err)
+		}
+	}
+	namespaces := opts.Namespaces
+	if spec := os.Getenv(NamespacesEnvVar); namespaces == nil && spec != "" {
+		var err error
+		if namespaces, err = ParseNamespaceScopes(spec); err != nil {
+			return nil, fmt.Errorf("parsing %s: %w", NamespacesEnvVar, err)
+		}
+	}
+	pruneRules := opts.PruneRules
+	if path := os.Getenv(PruneRulesEnvVar); pruneRules == nil && path != "" {
+		var err error
+		if pruneRules, err = LoadPruneRules(path); err != nil {
+			return nil, err
+		}
+	}
+	hashKey := make([]byte, 32)
+	if _, err := rand.Read(hashKey); err != nil {
+		return nil, err
+	}
+
+	keyProvider := opts.KeyProvider
+	if spec := os.Getenv(KeyProviderEnvVar); keyProvider == nil && spec != "" {
+		var err error
+		if keyProvider, err = encryption.NewKeyProvider(spec); err != nil {
+			return nil, fmt.Errorf("creating key provider: %w", err)
+		}
+	}
+	var managerOpts []encryption.ManagerOption
+	if keyProvider != nil {
+		managerOpts = append(managerOpts, encryption.WithKeyProvider(keyProvider))
+	}
+	m, err := encryption.NewManager(managerOpts...)
 	if err != nil {
 		return nil, err
 	}
 	ctx, cancel := context.WithCancel(ctx)
-	dbClient, _, err := db.NewClient(ctx, nil, m, m, false)
+	context.AfterFunc(ctx, func() {
+		if err := m.Close(); err != nil {
+			log.Errorf("closing key provider: %v", err)
+		}
+	})
+	dbClient, dbPath, err := db.NewClient(ctx, nil, m, m, false)
 	if err != nil {
 		cancel()
 		return nil, err
 	}
+	go reportDBFileSizes(ctx, dbPath, dbFileSizeReportInterval)
+	if keyProvider != nil {
+		go m.WatchKeyRotation(ctx, keyRotationCheckInterval)
+	}
 	return &CacheFactory{
 		ctx:    ctx,
 		cancel: cancel,
 
-		encryptAll: os.Getenv(EncryptAllEnvVar) == "true",
-		dbClient:   dbClient,
+		encryptionPolicy: encryptionPolicy,
+		hashKey:          hashKey,
+		encodings:        encodings,
+		dbClient:         dbClient,
 
-		gcKeepCount: opts.GCKeepCount,
+		eventLog: informer.EventLogOptions{
+			GCKeepCount: opts.GCKeepCount,
+			MaxCount:    opts.EventLogMaxCount,
+			MaxAge:      opts.EventLogMaxAge,
+		},
+		pruneRules: pruneRules,
+		namespaces: namespaces,
 
 		newInformer: informer.NewInformer,
 		informers:   map[schema.GroupVersionKind]*guardedInformer{},
 	}, nil
 }
 
+// reportDBFileSizes periodically publishes the size of the database file and its WAL until ctx is canceled
+func reportDBFileSizes(ctx context.Context, dbPath string, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		metrics.SetSQLCacheFileSizes(fileSize(dbPath), fileSize(dbPath+"-wal"))
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func fileSize(path string) int64 {
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0
+	}
+	return info.Size()
+}
+
 func logCacheInitializationDuration(gvk schema.GroupVersionKind) func() {
 	start := time.Now()
 	log.Infof("CacheFor STARTS creating informer for %v", gvk)
@@ -226,11 +352,24 @@ func (f *CacheFactory) ensureInformerInitialized(gi *guardedInformer, fields map
 }
 
 func (f *CacheFactory) initializeInformerLocked(gi *guardedInformer, fields map[string]informer.IndexedField, externalUpdateInfo *sqltypes.ExternalGVKUpdates, selfUpdateInfo *sqltypes.ExternalGVKUpdates, transform cache.TransformFunc, client dynamic.ResourceInterface, gvk schema.GroupVersionKind, namespaced bool, watchable bool) error {
-	_, encryptResourceAlways := defaultEncryptedResourceTypes[gvk]
-	shouldEncrypt := f.encryptAll || encryptResourceAlways
+	shouldEncrypt := f.encryptionPolicy.ShouldEncrypt(gvk)
+	fields = informer.HashFields(fields, f.encryptionPolicy.HashedFieldsFor(gvk), f.hashKey)
+	transform = informer.NewPruningTransform(transform, f.pruneRules.FieldsFor(gvk))
+	dbClient := f.dbClient
+	if encoding, ok := f.encodings[gvk]; ok {
+		dbClient = dbClient.UsingEncoding(encoding)
+	}
+	if namespaces := f.namespaces.For(gvk); namespaced && len(namespaces) > 0 {
+		namespacesClient, err := newMultiNamespaceClient(client, namespaces)
+		if err != nil {
+			log.Errorf("restricting informer for %v to namespaces: %v", gvk, err)
+			return err
+		}
+		client = namespacesClient
+	}
 	// In non-test code this invokes pkg/sqlcache/informer/informer.go: NewInformer()
 	// search for "func NewInformer(ctx"
-	i, err := f.newInformer(gi.ctx, client, fields, externalUpdateInfo, selfUpdateInfo, transform, gvk, f.dbClient, shouldEncrypt, namespaced, watchable, f.gcKeepCount)
+	i, err := f.newInformer(gi.ctx, client, fields, externalUpdateInfo, selfUpdateInfo, transform, gvk, dbClient, shouldEncrypt, namespaced, watchable, f.eventLog)
 	if err != nil {
 		log.Errorf("creating informer for %v: %v", gvk, err)
 		return err
@@ -255,6 +394,12 @@ func (f *CacheFactory) initializeInformerLocked(gi *guardedInformer, fields map[
 	return nil
 }
 
+// NamespacesFor returns the namespaces the informer of gvk is restricted to, or nil if it watches the whole cluster.
+// Clients given to CacheFor for such types must implement NamespaceClientGetter.
+func (f *CacheFactory) NamespacesFor(gvk schema.GroupVersionKind) []string {
+	return f.namespaces.For(gvk)
+}
+
 // waitForCacheReady will block until the informer has synced, or any of the context is canceled (the one from the request or the informer's own context)
 func (f *CacheFactory) waitForCacheReady(ctx context.Context, gvk schema.GroupVersionKind, gi *guardedInformer) (*Cache, error) {
 	// We don't want to get stuck in WaitForCachesSync if the request from
@@ -0,0 +1,315 @@
+package factory
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"golang.org/x/sync/errgroup"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/dynamic"
+)
+
+// NamespacesEnvVar restricts the informers of namespaced types to some namespaces, see ParseNamespaceScopes
+const NamespacesEnvVar = "CATTLE_SQL_CACHE_NAMESPACES"
+
+// allTypesScope is the type of the NamespaceScopes entry applying to all namespaced types
+const allTypesScope = "*"
+
+// NamespaceScopes restricts the informers of namespaced types to a list of namespaces. The objects of each namespace
+// are listed and watched separately, with clients which only need access to that namespace, and stored in the same
+// cache so that queries are unchanged.
+type NamespaceScopes struct {
+	// Default applies to all namespaced types without an entry in ByGVK. Empty means cluster-wide.
+	Default []string
+	// ByGVK overrides Default for some types
+	ByGVK map[schema.GroupVersionKind][]string
+}
+
+// For returns the namespaces the informer of gvk is restricted to, or nil if it is cluster-wide
+func (n *NamespaceScopes) For(gvk schema.GroupVersionKind) []string {
+	if n == nil {
+		return nil
+	}
+	if namespaces, ok := n.ByGVK[gvk]; ok {
+		return namespaces
+	}
+	return n.Default
+}
+
+// ParseNamespaceScopes parses a semicolon-separated list of "<type>=<namespace>,<namespace>..." entries, where type
+// is "*" for all namespaced types or "<group>/<version>/<kind>", with the group omitted for core types, eg:
+//
+//	*=team-a,team-b;v1/Event=team-a
+func ParseNamespaceScopes(spec string) (*NamespaceScopes, error) {
+	scopes := &NamespaceScopes{ByGVK: map[schema.GroupVersionKind][]string{}}
+	for _, entry := range strings.Split(spec, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		typ, list, ok := strings.Cut(entry, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid entry %q, expected <type>=<namespaces>", entry)
+		}
+		var namespaces []string
+		for _, namespace := range strings.Split(list, ",") {
+			if namespace = strings.TrimSpace(namespace); namespace != "" && !slices.Contains(namespaces, namespace) {
+				namespaces = append(namespaces, namespace)
+			}
+		}
+		if len(namespaces) == 0 {
+			return nil, fmt.Errorf("no namespaces for %q", typ)
+		}
+		slices.Sort(namespaces)
+
+		if typ == allTypesScope {
+			scopes.Default = namespaces
+			continue
+		}
+		slash := strings.LastIndex(typ, "/")
+		if slash == -1 {
+			return nil, fmt.Errorf("invalid type %q, expected %s or <group>/<version>/<kind>", typ, allTypesScope)
+		}
+		gv, err := schema.ParseGroupVersion(typ[:slash])
+		if err != nil {
+			return nil, err
+		}
+		scopes.ByGVK[gv.WithKind(typ[slash+1:])] = namespaces
+	}
+	return scopes, nil
+}
+
+// NamespaceClientGetter is implemented by the clients given to CacheFor for namespaced types, so that informers can
+// be restricted to some namespaces
+type NamespaceClientGetter interface {
+	ClientForNamespace(namespace string) (dynamic.ResourceInterface, error)
+}
+
+// multiNamespaceClient lists and watches the objects of several namespaces as if they were a single collection. As
+// resource versions are only comparable within a namespace, the last one seen in each namespace is kept to resume
+// its watch, regardless of the one requested by the informer.
+type multiNamespaceClient struct {
+	dynamic.ResourceInterface
+	namespaces []string
+	clients    map[string]dynamic.ResourceInterface
+
+	lock     sync.Mutex
+	versions map[string]string
+}
+
+var _ dynamic.ResourceInterface = (*multiNamespaceClient)(nil)
+
+func newMultiNamespaceClient(client dynamic.ResourceInterface, namespaces []string) (*multiNamespaceClient, error) {
+	getter, ok := client.(NamespaceClientGetter)
+	if !ok {
+		return nil, fmt.Errorf("client %T can't be restricted to namespaces", client)
+	}
+	clients := make(map[string]dynamic.ResourceInterface, len(namespaces))
+	for _, namespace := range namespaces {
+		namespaceClient, err := getter.ClientForNamespace(namespace)
+		if err != nil {
+			return nil, fmt.Errorf("client for namespace %s: %w", namespace, err)
+		}
+		clients[namespace] = namespaceClient
+	}
+	return &multiNamespaceClient{
+		ResourceInterface: client,
+		namespaces:        namespaces,
+		clients:           clients,
+		versions:          map[string]string{},
+	}, nil
+}
+
+// List returns the objects of all namespaces, with the highest resource version of the namespaces. The limit applies
+// to each namespace, and the continue token holds the continue token of every namespace with more objects, so that
+// the following pages only list those.
+func (c *multiNamespaceClient) List(ctx context.Context, opts metav1.ListOptions) (*unstructured.UnstructuredList, error) {
+	namespaces := c.namespaces
+	continues := map[string]string{}
+	if opts.Continue != "" {
+		var err error
+		if continues, err = decodeMultiNamespaceContinue(opts.Continue); err != nil {
+			return nil, err
+		}
+		namespaces = slices.DeleteFunc(slices.Clone(c.namespaces), func(namespace string) bool {
+			return continues[namespace] == ""
+		})
+	}
+
+	lists := make([]*unstructured.UnstructuredList, len(namespaces))
+	eg, egCtx := errgroup.WithContext(ctx)
+	for i, namespace := range namespaces {
+		eg.Go(func() error {
+			namespaceOpts := opts
+			namespaceOpts.Continue = continues[namespace]
+			list, err := c.clients[namespace].List(egCtx, namespaceOpts)
+			if err != nil {
+				return fmt.Errorf("listing namespace %s: %w", namespace, err)
+			}
+			lists[i] = list
+			return nil
+		})
+	}
+	if err := eg.Wait(); err != nil {
+		return nil, err
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	result := &unstructured.UnstructuredList{}
+	next := map[string]string{}
+	for i, list := range lists {
+		if i == 0 {
+			result.Object = list.Object
+		}
+		result.Items = append(result.Items, list.Items...)
+		version := list.GetResourceVersion()
+		c.versions[namespaces[i]] = version
+		if newerResourceVersion(version, result.GetResourceVersion()) {
+			result.SetResourceVersion(version)
+		}
+		if token := list.GetContinue(); token != "" {
+			next[namespaces[i]] = token
+		}
+	}
+	token, err := encodeMultiNamespaceContinue(next)
+	if err != nil {
+		return nil, err
+	}
+	result.SetContinue(token)
+	return result, nil
+}
+
+// encodeMultiNamespaceContinue returns a continue token holding the continue tokens of several namespaces, or an empty
+// one if there are none
+func encodeMultiNamespaceContinue(continues map[string]string) (string, error) {
+	if len(continues) == 0 {
+		return "", nil
+	}
+	data, err := json.Marshal(continues)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+func decodeMultiNamespaceContinue(token string) (map[string]string, error) {
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, fmt.Errorf("invalid continue token: %w", err)
+	}
+	var continues map[string]string
+	if err := json.Unmarshal(data, &continues); err != nil {
+		return nil, fmt.Errorf("invalid continue token: %w", err)
+	}
+	return continues, nil
+}
+
+// Watch merges the watches of all namespaces, each one resuming from the last resource version seen in its namespace.
+// The merged watch stops as soon as any of them does.
+func (c *multiNamespaceClient) Watch(ctx context.Context, opts metav1.ListOptions) (watch.Interface, error) {
+	mw := &multiNamespaceWatch{
+		result: make(chan watch.Event),
+		stopCh: make(chan struct{}),
+	}
+	for _, namespace := range c.namespaces {
+		namespaceOpts := opts
+		c.lock.Lock()
+		if version := c.versions[namespace]; version != "" {
+			namespaceOpts.ResourceVersion = version
+		}
+		c.lock.Unlock()
+		w, err := c.clients[namespace].Watch(ctx, namespaceOpts)
+		if err != nil {
+			mw.Stop()
+			return nil, fmt.Errorf("watching namespace %s: %w", namespace, err)
+		}
+		mw.watches = append(mw.watches, w)
+	}
+	for i, w := range mw.watches {
+		mw.wg.Add(1)
+		go mw.forward(w, func(e watch.Event) {
+			if e.Type == watch.Error {
+				return
+			}
+			if m, err := meta.Accessor(e.Object); err == nil && m.GetResourceVersion() != "" {
+				c.lock.Lock()
+				c.versions[c.namespaces[i]] = m.GetResourceVersion()
+				c.lock.Unlock()
+			}
+		})
+	}
+	go func() {
+		mw.wg.Wait()
+		close(mw.result)
+	}()
+	return mw, nil
+}
+
+// multiNamespaceWatch merges the events of several watches
+type multiNamespaceWatch struct {
+	watches  []watch.Interface
+	result   chan watch.Event
+	stopCh   chan struct{}
+	stopOnce sync.Once
+	wg       sync.WaitGroup
+}
+
+func (mw *multiNamespaceWatch) forward(w watch.Interface, seen func(watch.Event)) {
+	defer mw.wg.Done()
+	// the merged watch ends with any of its watches, so that it is restarted from the last seen resource versions
+	defer mw.Stop()
+	for {
+		select {
+		case e, ok := <-w.ResultChan():
+			if !ok {
+				return
+			}
+			select {
+			case mw.result <- e:
+				// only delivered events move the resource version, so that the others are seen again after a restart
+				seen(e)
+			case <-mw.stopCh:
+				return
+			}
+		case <-mw.stopCh:
+			return
+		}
+	}
+}
+
+func (mw *multiNamespaceWatch) ResultChan() <-chan watch.Event {
+	return mw.result
+}
+
+func (mw *multiNamespaceWatch) Stop() {
+	mw.stopOnce.Do(func() {
+		close(mw.stopCh)
+		for _, w := range mw.watches {
+			w.Stop()
+		}
+	})
+}
+
+// newerResourceVersion returns whether version a is newer than b, comparing them as numbers when possible
+func newerResourceVersion(a, b string) bool {
+	if b == "" {
+		return a != ""
+	}
+	numA, errA := strconv.ParseUint(a, 10, 64)
+	numB, errB := strconv.ParseUint(b, 10, 64)
+	if errA != nil || errB != nil {
+		return a > b
+	}
+	return numA > numB
+}
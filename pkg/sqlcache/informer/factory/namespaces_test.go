@@ -0,0 +1,180 @@
+package factory
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/dynamic/fake"
+)
+
+func TestParseNamespaceScopes(t *testing.T) {
+	scopes, err := ParseNamespaceScopes(" *=team-b,team-a ; v1/Event=team-a;apps/v1/Deployment=team-c,team-c")
+	require.NoError(t, err)
+	assert.Equal(t, &NamespaceScopes{
+		Default: []string{"team-a", "team-b"},
+		ByGVK: map[schema.GroupVersionKind][]string{
+			{Version: "v1", Kind: "Event"}:                     {"team-a"},
+			{Group: "apps", Version: "v1", Kind: "Deployment"}: {"team-c"},
+		},
+	}, scopes)
+	assert.Equal(t, []string{"team-a"}, scopes.For(schema.GroupVersionKind{Version: "v1", Kind: "Event"}))
+	assert.Equal(t, []string{"team-a", "team-b"}, scopes.For(schema.GroupVersionKind{Version: "v1", Kind: "Pod"}))
+	assert.Nil(t, (*NamespaceScopes)(nil).For(schema.GroupVersionKind{Version: "v1", Kind: "Pod"}))
+
+	for _, spec := range []string{"team-a", "v1/Event=", "Event=team-a", "a/b/c/Event=team-a"} {
+		_, err := ParseNamespaceScopes(spec)
+		assert.Error(t, err, spec)
+	}
+}
+
+// namespaceClients gives clients for the namespaces of a fake dynamic client
+type namespaceClients struct {
+	dynamic.ResourceInterface
+	client dynamic.NamespaceableResourceInterface
+}
+
+func (n *namespaceClients) ClientForNamespace(namespace string) (dynamic.ResourceInterface, error) {
+	return n.client.Namespace(namespace), nil
+}
+
+func TestMultiNamespaceClient(t *testing.T) {
+	gvr := schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}
+	newConfigMap := func(namespace, name, resourceVersion string) *unstructured.Unstructured {
+		obj := &unstructured.Unstructured{}
+		obj.SetAPIVersion("v1")
+		obj.SetKind("ConfigMap")
+		obj.SetNamespace(namespace)
+		obj.SetName(name)
+		obj.SetResourceVersion(resourceVersion)
+		return obj
+	}
+	dynamicClient := fake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{gvr: "ConfigMapList"},
+		newConfigMap("team-a", "a", "10"),
+		newConfigMap("team-b", "b", "12"),
+		newConfigMap("team-c", "c", "14"),
+	)
+	resource := dynamicClient.Resource(gvr)
+
+	_, err := newMultiNamespaceClient(resource, []string{"team-a"})
+	assert.Error(t, err, "clients must provide clients for namespaces")
+
+	client, err := newMultiNamespaceClient(&namespaceClients{ResourceInterface: resource, client: resource}, []string{"team-a", "team-b"})
+	require.NoError(t, err)
+
+	list, err := client.List(context.Background(), metav1.ListOptions{Limit: 1})
+	require.NoError(t, err)
+	var names []string
+	for _, item := range list.Items {
+		names = append(names, item.GetName())
+	}
+	assert.ElementsMatch(t, []string{"a", "b"}, names, "objects of other namespaces are left out")
+	assert.Empty(t, list.GetContinue())
+
+	w, err := client.Watch(context.Background(), metav1.ListOptions{})
+	require.NoError(t, err)
+	_, err = resource.Namespace("team-c").Update(context.Background(), newConfigMap("team-c", "c", "15"), metav1.UpdateOptions{})
+	require.NoError(t, err)
+	_, err = resource.Namespace("team-b").Update(context.Background(), newConfigMap("team-b", "b", "16"), metav1.UpdateOptions{})
+	require.NoError(t, err)
+	select {
+	case e := <-w.ResultChan():
+		assert.Equal(t, watch.Modified, e.Type)
+		assert.Equal(t, "b", e.Object.(*unstructured.Unstructured).GetName())
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for event")
+	}
+	assert.Eventually(t, func() bool {
+		client.lock.Lock()
+		defer client.lock.Unlock()
+		return client.versions["team-b"] == "16"
+	}, 5*time.Second, 10*time.Millisecond)
+	client.lock.Lock()
+	assert.NotContains(t, client.versions, "team-c")
+	client.lock.Unlock()
+
+	// stopping the merged watch closes its result channel
+	w.Stop()
+	for range w.ResultChan() {
+	}
+}
+
+// pagedClient lists names by pages of opts.Limit, with the continue token holding the index of the next name
+type pagedClient struct {
+	dynamic.ResourceInterface
+	names     []string
+	continues []string
+}
+
+func (p *pagedClient) List(_ context.Context, opts metav1.ListOptions) (*unstructured.UnstructuredList, error) {
+	p.continues = append(p.continues, opts.Continue)
+	start := 0
+	if opts.Continue != "" {
+		start, _ = strconv.Atoi(opts.Continue)
+	}
+	end := len(p.names)
+	if opts.Limit > 0 {
+		end = min(end, start+int(opts.Limit))
+	}
+	list := &unstructured.UnstructuredList{}
+	for _, name := range p.names[start:end] {
+		item := unstructured.Unstructured{}
+		item.SetName(name)
+		list.Items = append(list.Items, item)
+	}
+	if end < len(p.names) {
+		list.SetContinue(strconv.Itoa(end))
+	}
+	return list, nil
+}
+
+func TestMultiNamespaceClientPagination(t *testing.T) {
+	clients := map[string]*pagedClient{
+		"team-a": {names: []string{"a1", "a2", "a3"}},
+		"team-b": {names: []string{"b1"}},
+	}
+	client := &multiNamespaceClient{
+		namespaces: []string{"team-a", "team-b"},
+		clients:    map[string]dynamic.ResourceInterface{"team-a": clients["team-a"], "team-b": clients["team-b"]},
+		versions:   map[string]string{},
+	}
+
+	var pages [][]string
+	opts := metav1.ListOptions{Limit: 2}
+	for {
+		list, err := client.List(context.Background(), opts)
+		require.NoError(t, err)
+		var names []string
+		for _, item := range list.Items {
+			names = append(names, item.GetName())
+		}
+		pages = append(pages, names)
+		if opts.Continue = list.GetContinue(); opts.Continue == "" {
+			break
+		}
+	}
+	assert.Equal(t, [][]string{{"a1", "a2", "b1"}, {"a3"}}, pages)
+	// namespaces without more objects aren't listed again
+	assert.Equal(t, []string{"", "2"}, clients["team-a"].continues)
+	assert.Equal(t, []string{""}, clients["team-b"].continues)
+
+	_, err := client.List(context.Background(), metav1.ListOptions{Continue: "not a token"})
+	assert.ErrorContains(t, err, "invalid continue token")
+}
+
+func TestNewerResourceVersion(t *testing.T) {
+	assert.True(t, newerResourceVersion("10", ""))
+	assert.True(t, newerResourceVersion("10", "9"))
+	assert.False(t, newerResourceVersion("9", "10"))
+	assert.False(t, newerResourceVersion("", ""))
+}
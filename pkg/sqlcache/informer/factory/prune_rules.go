@@ -0,0 +1,81 @@
+package factory
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/rancher/steve/pkg/configfile"
+	"github.com/rancher/steve/pkg/sqlcache/informer"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// PruneRulesEnvVar is the path to a YAML or JSON file containing PruneRulesConfig
+const PruneRulesEnvVar = "CATTLE_SQL_CACHE_PRUNE_RULES"
+
+// unprunableFields identify objects in the cache, so neither they nor their parents can be pruned
+var unprunableFields = []string{"metadata.name", "metadata.namespace", "metadata.resourceVersion", "metadata.uid"}
+
+// PruneRulesConfig is the file format of informer.PruneRules
+type PruneRulesConfig struct {
+	// Resources lists per-GVK rules
+	Resources []ResourcePruneRule `json:"resources,omitempty"`
+}
+
+// ResourcePruneRule lists the fields pruned from the objects of a single GVK
+type ResourcePruneRule struct {
+	Group   string `json:"group,omitempty"`
+	Version string `json:"version"`
+	Kind    string `json:"kind"`
+	// Fields are removed from the objects of this GVK instead of informer.DefaultPrunedFields, eg.
+	// "metadata.annotations[kubectl.kubernetes.io/last-applied-configuration]". An empty list disables pruning.
+	Fields []string `json:"fields,omitempty"`
+}
+
+func (r ResourcePruneRule) gvk() schema.GroupVersionKind {
+	return schema.GroupVersionKind{Group: r.Group, Version: r.Version, Kind: r.Kind}
+}
+
+// LoadPruneRules reads the PruneRulesConfig in the file at path, eg. the one in PruneRulesEnvVar, and rejects rules
+// that repeat a GVK or prune a field identifying objects in the cache
+func LoadPruneRules(path string) (informer.PruneRules, error) {
+	config, err := configfile.Load(path, "prune rules", PruneRulesConfig.Validate)
+	if err != nil {
+		return nil, err
+	}
+	return config.PruneRules(), nil
+}
+
+// Validate checks that rules are complete, unique per GVK, and only prune fields that can be pruned
+func (c PruneRulesConfig) Validate() error {
+	seen := map[schema.GroupVersionKind]bool{}
+	for _, rule := range c.Resources {
+		gvk := rule.gvk()
+		if rule.Version == "" || rule.Kind == "" {
+			return fmt.Errorf("rule for %v: version and kind are required", gvk)
+		}
+		if seen[gvk] {
+			return fmt.Errorf("duplicate rule for %v", gvk)
+		}
+		seen[gvk] = true
+		for _, field := range rule.Fields {
+			if field == "" {
+				return fmt.Errorf("rule for %v: empty pruned field", gvk)
+			}
+			for _, unprunable := range unprunableFields {
+				if field == unprunable || strings.HasPrefix(unprunable, field+".") {
+					return fmt.Errorf("rule for %v: field %s cannot be pruned", gvk, field)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// PruneRules returns the informer.PruneRules of c
+func (c PruneRulesConfig) PruneRules() informer.PruneRules {
+	rules := make(informer.PruneRules, len(c.Resources))
+	for _, rule := range c.Resources {
+		rules[rule.gvk()] = append([]string{}, rule.Fields...)
+	}
+	return rules
+}
@@ -0,0 +1,70 @@
+package factory
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestPruneRulesConfigValidate(t *testing.T) {
+	tests := []struct {
+		name  string
+		rules []ResourcePruneRule
+	}{
+		{
+			name:  "missing kind",
+			rules: []ResourcePruneRule{{Version: "v1"}},
+		},
+		{
+			name:  "duplicate GVK",
+			rules: []ResourcePruneRule{{Version: "v1", Kind: "Pod"}, {Version: "v1", Kind: "Pod"}},
+		},
+		{
+			name:  "empty field",
+			rules: []ResourcePruneRule{{Version: "v1", Kind: "Pod", Fields: []string{""}}},
+		},
+		{
+			name:  "pruned name",
+			rules: []ResourcePruneRule{{Version: "v1", Kind: "Pod", Fields: []string{"metadata.name"}}},
+		},
+		{
+			name:  "pruned metadata",
+			rules: []ResourcePruneRule{{Version: "v1", Kind: "Pod", Fields: []string{"metadata"}}},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Error(t, PruneRulesConfig{Resources: test.rules}.Validate())
+		})
+	}
+}
+
+func TestLoadPruneRules(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "prune.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`
+resources:
+- version: v1
+  kind: ConfigMap
+  fields:
+  - metadata.annotations[kubectl.kubernetes.io/last-applied-configuration]
+- group: events.k8s.io
+  version: v1
+  kind: Event
+`), 0600))
+
+	rules, err := LoadPruneRules(path)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"metadata.annotations[kubectl.kubernetes.io/last-applied-configuration]"}, rules.FieldsFor(schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"}))
+	fields := rules.FieldsFor(schema.GroupVersionKind{Group: "events.k8s.io", Version: "v1", Kind: "Event"})
+	assert.NotNil(t, fields, "a rule without fields disables pruning")
+	assert.Empty(t, fields)
+	assert.Equal(t, []string{"metadata.managedFields"}, rules.FieldsFor(schema.GroupVersionKind{Version: "v1", Kind: "Pod"}))
+
+	require.NoError(t, os.WriteFile(path, []byte("- version: v1\n"), 0600))
+	_, err = LoadPruneRules(path)
+	assert.Error(t, err)
+}
@@ -12,6 +12,7 @@ package factory
 import (
 	context "context"
 	reflect "reflect"
+	time "time"
 
 	types "github.com/rancher/apiserver/pkg/types"
 	accesscontrol "github.com/rancher/steve/pkg/accesscontrol"
@@ -20,7 +21,6 @@ import (
 	sqltypes "github.com/rancher/steve/pkg/sqlcache/sqltypes"
 	gomock "go.uber.org/mock/gomock"
 	unstructured "k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
-	schema "k8s.io/apimachinery/pkg/runtime/schema"
 	watch "k8s.io/apimachinery/pkg/watch"
 )
 
@@ -49,17 +49,17 @@ func (m *MockByOptionsLister) EXPECT() *MockByOptionsListerMockRecorder {
 }
 
 // AugmentList mocks base method.
-func (m *MockByOptionsLister) AugmentList(ctx context.Context, list *unstructured.UnstructuredList, childGVK schema.GroupVersionKind, childSchemaName string, useSelectors bool, accessList accesscontrol.AccessListByVerb) error {
+func (m *MockByOptionsLister) AugmentList(ctx context.Context, list *unstructured.UnstructuredList, assoc sqltypes.Association, accessList accesscontrol.AccessListByVerb) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "AugmentList", ctx, list, childGVK, childSchemaName, useSelectors, accessList)
+	ret := m.ctrl.Call(m, "AugmentList", ctx, list, assoc, accessList)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // AugmentList indicates an expected call of AugmentList.
-func (mr *MockByOptionsListerMockRecorder) AugmentList(ctx, list, childGVK, childSchemaName, useSelectors, accessList any) *gomock.Call {
+func (mr *MockByOptionsListerMockRecorder) AugmentList(ctx, list, assoc, accessList any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AugmentList", reflect.TypeOf((*MockByOptionsLister)(nil).AugmentList), ctx, list, childGVK, childSchemaName, useSelectors, accessList)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AugmentList", reflect.TypeOf((*MockByOptionsLister)(nil).AugmentList), ctx, list, assoc, accessList)
 }
 
 // DropAll mocks base method.
@@ -108,6 +108,20 @@ func (mr *MockByOptionsListerMockRecorder) ListByOptions(ctx, lo, partitions, na
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListByOptions", reflect.TypeOf((*MockByOptionsLister)(nil).ListByOptions), ctx, lo, partitions, namespace)
 }
 
+// WaitForRevision mocks base method.
+func (m *MockByOptionsLister) WaitForRevision(ctx context.Context, revision string, timeout time.Duration) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "WaitForRevision", ctx, revision, timeout)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// WaitForRevision indicates an expected call of WaitForRevision.
+func (mr *MockByOptionsListerMockRecorder) WaitForRevision(ctx, revision, timeout any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WaitForRevision", reflect.TypeOf((*MockByOptionsLister)(nil).WaitForRevision), ctx, revision, timeout)
+}
+
 // Watch mocks base method.
 func (m *MockByOptionsLister) Watch(ctx context.Context, options informer.WatchOptions, eventsCh chan<- watch.Event) error {
 	m.ctrl.T.Helper()
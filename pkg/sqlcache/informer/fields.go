@@ -1,6 +1,10 @@
 package informer
 
 import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
 	"regexp"
 	"strconv"
 	"time"
@@ -69,6 +73,60 @@ func (f *ComputedField) GetValue(obj *unstructured.Unstructured) (any, error) {
 	return f.GetValueFunc(obj)
 }
 
+// HashedField stores a keyed hash (HMAC-SHA256) of another field's value instead of the value itself, so that the
+// plaintext never reaches the database. Only exact matches (=, !=, in, notin) can be used against it. Missing values
+// are stored as NULL rather than hashed, so they never collide with a hash of a real value.
+type HashedField struct {
+	Field IndexedField
+	Key   []byte
+}
+
+func (f *HashedField) ColumnName() string {
+	return f.Field.ColumnName()
+}
+
+func (f *HashedField) ColumnType() string {
+	return "TEXT"
+}
+
+func (f *HashedField) GetValue(obj *unstructured.Unstructured) (any, error) {
+	value, err := f.Field.GetValue(obj)
+	if err != nil {
+		return nil, err
+	}
+	if value == nil {
+		return nil, nil
+	}
+	return f.Hash(fmt.Sprint(normalizeValue(value))), nil
+}
+
+// Hash returns the keyed hash stored for value
+func (f *HashedField) Hash(value string) string {
+	mac := hmac.New(sha256.New, f.Key)
+	mac.Write([]byte(value))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// HashFields returns a copy of fields where the fields named in hashed are replaced by a HashedField using key.
+// Hashed fields which are not in fields, eg. the default indexed fields, are added as a JSONPathField.
+func HashFields(fields map[string]IndexedField, hashed []string, key []byte) map[string]IndexedField {
+	if len(hashed) == 0 {
+		return fields
+	}
+	result := make(map[string]IndexedField, len(fields)+len(hashed))
+	for id, field := range fields {
+		result[id] = field
+	}
+	for _, id := range hashed {
+		field, ok := result[id]
+		if !ok {
+			field = &JSONPathField{Path: splitFieldID(id)}
+		}
+		result[id] = &HashedField{Field: field, Key: key}
+	}
+	return result
+}
+
 // restartsPattern matches "4 (3h38m ago)" or just "0"
 var restartsPattern = regexp.MustCompile(`^(\d+)(?:\s+\((.+?)\s+ago\))?$`)
 
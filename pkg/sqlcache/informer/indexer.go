@@ -16,7 +16,7 @@ import (
 
 const (
 	selectQueryFmt = `
-			SELECT object, objectnonce, dekid FROM "%[1]s"
+			SELECT object, objectnonce, dekid, encoding FROM "%[1]s"
 				WHERE key IN (
 					SELECT key FROM "%[1]s_indices"
 						WHERE name = ? AND value IN (?%s)
@@ -35,7 +35,7 @@ const (
 	// addIndexFmt expects to use a big insert, so the columns must be kept in sync with addIndexValuesPlaceholderFmt
 	addIndexFmt                  = `INSERT INTO "%s_indices" (name, value, key) VALUES %s`
 	addIndexValuesPlaceholderFmt = `(?, ?, ?)`
-	listByIndexFmt               = `SELECT object, objectnonce, dekid FROM "%[1]s"
+	listByIndexFmt               = `SELECT object, objectnonce, dekid, encoding FROM "%[1]s"
 			WHERE key IN (
 			    SELECT key FROM "%[1]s_indices"
 			    	WHERE name = ? AND value = ?
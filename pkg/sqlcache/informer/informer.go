@@ -9,6 +9,7 @@ import (
 	"errors"
 	"sort"
 	"strconv"
+	"sync"
 	"time"
 
 	"github.com/rancher/apiserver/pkg/types"
@@ -29,7 +30,12 @@ import (
 	"k8s.io/client-go/tools/cache"
 )
 
-var defaultRefreshTime = 5 * time.Second
+var (
+	syntheticWatchIntervalsLock sync.RWMutex
+	defaultRefreshTime          = 5 * time.Second
+	// syntheticWatchIntervals overrides defaultRefreshTime for some GVKs
+	syntheticWatchIntervals = map[schema.GroupVersionKind]time.Duration{}
+)
 
 // Informer is a SQLite-backed cache.SharedIndexInformer that can execute queries on listprocessor structs
 type Informer struct {
@@ -40,6 +46,9 @@ type Informer struct {
 type WatchOptions struct {
 	ResourceVersion string
 	Filter          WatchFilter
+	// BookmarkInterval is how often a BOOKMARK event is sent when the latest revision changed without any event
+	// matching the filter. Zero disables bookmarks.
+	BookmarkInterval time.Duration
 }
 
 type WatchFilter struct {
@@ -67,8 +76,9 @@ func (f *WatchFilter) matches(obj metav1.Object) bool {
 }
 
 type ByOptionsLister interface {
-	AugmentList(ctx context.Context, list *unstructured.UnstructuredList, childGVK schema.GroupVersionKind, childSchemaName string, useSelectors bool, accessList accesscontrol.AccessListByVerb) error
+	AugmentList(ctx context.Context, list *unstructured.UnstructuredList, assoc sqltypes.Association, accessList accesscontrol.AccessListByVerb) error
 	ListByOptions(ctx context.Context, lo *sqltypes.ListOptions, partitions []partition.Partition, namespace string) (*unstructured.UnstructuredList, int, *types.APISummary, string, error)
+	WaitForRevision(ctx context.Context, revision string, timeout time.Duration) error
 	Watch(ctx context.Context, options WatchOptions, eventsCh chan<- watch.Event) error
 	GetLatestResourceVersion() []string
 	DropAll(context.Context) error
@@ -80,14 +90,14 @@ var newInformer = cache.NewSharedIndexInformer
 // NewInformer returns a new SQLite-backed Informer for the type specified by schema in unstructured.Unstructured form
 // using the specified client
 func NewInformer(ctx context.Context, client dynamic.ResourceInterface, fields map[string]IndexedField, externalUpdateInfo *sqltypes.ExternalGVKUpdates, selfUpdateInfo *sqltypes.ExternalGVKUpdates, transform cache.TransformFunc, gvk schema.GroupVersionKind, db db.Client, shouldEncrypt bool,
-	namespaced bool, watchable bool, gcKeepCount int) (*Informer, error) {
+	namespaced bool, watchable bool, eventLog EventLogOptions) (*Informer, error) {
 	watchFunc := func(options metav1.ListOptions) (watch.Interface, error) {
 		return client.Watch(ctx, options)
 	}
 	if !watchable {
 		watchFunc = func(options metav1.ListOptions) (watch.Interface, error) {
 			ctx, cancel := context.WithCancel(ctx)
-			return newSyntheticWatcher(ctx, cancel).watch(client, options, defaultRefreshTime)
+			return newSyntheticWatcher(ctx, cancel, metricsLabelFromGVK(gvk)).watch(client, options, syntheticWatchInterval(gvk))
 		}
 	}
 	listWatcher := &cache.ListWatch{
@@ -151,7 +161,7 @@ func NewInformer(ctx context.Context, client dynamic.ResourceInterface, fields m
 	opts := ListOptionIndexerOptions{
 		Fields:       fields,
 		IsNamespaced: namespaced,
-		GCKeepCount:  gcKeepCount,
+		EventLog:     eventLog,
 	}
 	loi, err := NewListOptionIndexer(ctx, s, opts)
 	if err != nil {
@@ -181,8 +191,8 @@ func (i *Informer) RunWithContext(ctx context.Context) {
 	wg.Wait()
 }
 
-func (i *Informer) AugmentList(ctx context.Context, list *unstructured.UnstructuredList, childGVK schema.GroupVersionKind, childSchemaName string, useSelectors bool, accessList accesscontrol.AccessListByVerb) error {
-	return i.ByOptionsLister.AugmentList(ctx, list, childGVK, childSchemaName, useSelectors, accessList)
+func (i *Informer) AugmentList(ctx context.Context, list *unstructured.UnstructuredList, assoc sqltypes.Association, accessList accesscontrol.AccessListByVerb) error {
+	return i.ByOptionsLister.AugmentList(ctx, list, assoc, accessList)
 }
 
 // ListByOptions returns objects according to the specified list options and partitions.
@@ -198,13 +208,42 @@ func (i *Informer) ListByOptions(ctx context.Context, lo *sqltypes.ListOptions,
 
 // SetSyntheticWatchableInterval - call this function to override the default interval time of 5 seconds
 func SetSyntheticWatchableInterval(interval time.Duration) {
+	syntheticWatchIntervalsLock.Lock()
+	defer syntheticWatchIntervalsLock.Unlock()
 	defaultRefreshTime = interval
 }
 
+// SetSyntheticWatchableIntervalForGVK overrides the polling interval of synthetic watches for one GVK. A zero
+// interval restores the default. Watches already running keep their interval until they are restarted.
+func SetSyntheticWatchableIntervalForGVK(gvk schema.GroupVersionKind, interval time.Duration) {
+	syntheticWatchIntervalsLock.Lock()
+	defer syntheticWatchIntervalsLock.Unlock()
+	if interval <= 0 {
+		delete(syntheticWatchIntervals, gvk)
+		return
+	}
+	syntheticWatchIntervals[gvk] = interval
+}
+
+// syntheticWatchInterval returns the polling interval of synthetic watches for gvk
+func syntheticWatchInterval(gvk schema.GroupVersionKind) time.Duration {
+	syntheticWatchIntervalsLock.RLock()
+	defer syntheticWatchIntervalsLock.RUnlock()
+	if interval, ok := syntheticWatchIntervals[gvk]; ok {
+		return interval
+	}
+	return defaultRefreshTime
+}
+
 func informerNameFromGVK(gvk schema.GroupVersionKind) string {
 	return gvk.Group + "_" + gvk.Version + "_" + gvk.Kind
 }
 
+// metricsLabelFromGVK returns the label identifying gvk in SQL cache metrics, as used by the ListOptionIndexer
+func metricsLabelFromGVK(gvk schema.GroupVersionKind) string {
+	return db.Sanitize(informerNameFromGVK(gvk))
+}
+
 // noWatchListListWatch disables WatchList feature
 type noWatchListListWatch struct {
 	*cache.ListWatch
@@ -11,6 +11,7 @@ import (
 
 	"github.com/rancher/apiserver/pkg/types"
 	"github.com/rancher/steve/pkg/accesscontrol"
+	"github.com/rancher/steve/pkg/metrics"
 	"github.com/rancher/steve/pkg/sqlcache/db"
 	"github.com/rancher/steve/pkg/sqlcache/informer/internal/ring"
 	"github.com/rancher/steve/pkg/sqlcache/partition"
@@ -20,7 +21,6 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
-	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/apimachinery/pkg/watch"
 	"k8s.io/client-go/tools/cache"
@@ -35,18 +35,33 @@ type ListOptionIndexer struct {
 	columnOrder   []string                // all UI field IDs (sorted, for deterministic iteration)
 	uniqueColumns []string                // unique database column names (for schema creation and value extraction)
 
-	// lock protects latestRV
-	lock     sync.RWMutex
-	latestRV string
-
-	eventLog *ring.CircularBuffer[*event]
-
-	addFieldsStmt    db.Stmt
-	deleteFieldsStmt db.Stmt
-	dropFieldsStmt   db.Stmt
-	upsertLabelsStmt db.Stmt
-	deleteLabelsStmt db.Stmt
-	dropLabelsStmt   db.Stmt
+	// lock protects latestRV and latestEventID, and orders writes to eventLog with their updates
+	lock          sync.RWMutex
+	latestRV      string
+	latestEventID int64
+	// rvChanged is closed and replaced every time latestRV changes
+	rvChanged chan struct{}
+
+	eventLog     *ring.CircularBuffer[*event]
+	eventLogOpts EventLogOptions
+	eventPruner  *eventPruner
+
+	// metricsLabel identifies the GVK of this indexer in SQL cache metrics
+	metricsLabel string
+
+	addFieldsStmt       db.Stmt
+	deleteFieldsStmt    db.Stmt
+	dropFieldsStmt      db.Stmt
+	upsertLabelsStmt    db.Stmt
+	deleteLabelsStmt    db.Stmt
+	dropLabelsStmt      db.Stmt
+	addEventStmt        db.Stmt
+	addStoredEventStmt  db.Stmt
+	getStoredObjectStmt db.Stmt
+	pruneEventsStmt     db.Stmt
+	getEventIDStmt      db.Stmt
+	listEventsStmt      db.Stmt
+	dropEventsStmt      db.Stmt
 }
 
 var (
@@ -94,6 +109,8 @@ ON CONFLICT(key, label) DO UPDATE SET
 
 // event mimics watch.Event but replaces uses a metav1.Object instead of runtime.Object, as its guaranteed to be an actual Object, as Bookmark or Error are treated separately
 type event struct {
+	// ID identifies the event in the events table
+	ID       int64
 	Type     watch.EventType
 	Previous metav1.Object
 	Object   metav1.Object
@@ -106,8 +123,8 @@ type ListOptionIndexerOptions struct {
 	// IsNamespaced determines whether the GVK for this ListOptionIndexer is
 	// namespaced
 	IsNamespaced bool
-	// GCKeepCount is how many events to keep in memory
-	GCKeepCount int
+	// EventLog configures how many events are kept to resume watches
+	EventLog EventLogOptions
 }
 
 // NewListOptionIndexer returns a SQLite-backed cache.Indexer of unstructured.Unstructured Kubernetes resources of a certain GVK
@@ -156,9 +173,12 @@ func NewListOptionIndexer(ctx context.Context, s Store, opts ListOptionIndexerOp
 	}
 	slices.Sort(uniqueColumns)
 
-	maxEventHistory := opts.GCKeepCount
-	if maxEventHistory <= 0 {
-		maxEventHistory = 1000
+	eventLogOpts := opts.EventLog
+	if eventLogOpts.GCKeepCount <= 0 {
+		eventLogOpts.GCKeepCount = defaultGCKeepCount
+	}
+	if eventLogOpts.MaxCount <= 0 {
+		eventLogOpts.MaxCount = defaultEventLogMaxCount
 	}
 
 	l := &ListOptionIndexer{
@@ -167,7 +187,10 @@ func NewListOptionIndexer(ctx context.Context, s Store, opts ListOptionIndexerOp
 		indexedFields: indexedFields,
 		columnOrder:   columnOrder,
 		uniqueColumns: uniqueColumns,
-		eventLog:      ring.NewCircularBuffer[*event](maxEventHistory),
+		eventLog:      ring.NewCircularBuffer[*event](eventLogOpts.GCKeepCount),
+		eventLogOpts:  eventLogOpts,
+		eventPruner:   newEventPruner(eventLogOpts),
+		rvChanged:     make(chan struct{}),
 	}
 	l.RegisterAfterAdd(l.addIndexFields)
 	l.RegisterAfterAdd(l.addLabels)
@@ -181,6 +204,7 @@ func NewListOptionIndexer(ctx context.Context, s Store, opts ListOptionIndexerOp
 	l.RegisterBeforeDropAll(l.closeEventLog)
 	l.RegisterBeforeDropAll(l.dropLabels)
 	l.RegisterBeforeDropAll(l.dropFields)
+	l.RegisterBeforeDropAll(l.dropEvents)
 
 	columnDefs := make([]string, 0, len(uniqueColumns))
 	for _, colName := range uniqueColumns {
@@ -195,6 +219,7 @@ func NewListOptionIndexer(ctx context.Context, s Store, opts ListOptionIndexerOp
 	}
 
 	dbName := db.Sanitize(i.GetName())
+	l.metricsLabel = dbName
 	columns := make([]string, 0, len(columnOrder))
 	qmarks := make([]string, 0, len(columnOrder))
 	setStatements := make([]string, 0, len(columnOrder))
@@ -243,7 +268,7 @@ func NewListOptionIndexer(ctx context.Context, s Store, opts ListOptionIndexerOp
 			return err
 		}
 
-		return nil
+		return l.createEventsTable(tx, dbName)
 	})
 	if err != nil {
 		return nil, err
@@ -267,6 +292,14 @@ func NewListOptionIndexer(ctx context.Context, s Store, opts ListOptionIndexerOp
 	l.deleteLabelsStmt = l.Prepare(fmt.Sprintf(deleteLabelsStmtFmt, dbName))
 	l.dropLabelsStmt = l.Prepare(fmt.Sprintf(dropLabelsStmtFmt, dbName))
 
+	l.addEventStmt = l.Prepare(fmt.Sprintf(addEventStmtFmt, dbName))
+	l.addStoredEventStmt = l.Prepare(fmt.Sprintf(addStoredEventStmtFmt, dbName, dbName))
+	l.getStoredObjectStmt = l.Prepare(fmt.Sprintf(getStoredObjectStmtFmt, dbName))
+	l.pruneEventsStmt = l.Prepare(fmt.Sprintf(pruneEventsStmtFmt, dbName))
+	l.getEventIDStmt = l.Prepare(fmt.Sprintf(getEventIDStmtFmt, dbName))
+	l.listEventsStmt = l.Prepare(fmt.Sprintf(listEventsStmtFmt, dbName))
+	l.dropEventsStmt = l.Prepare(fmt.Sprintf(dropEventsFmt, dbName))
+
 	return l, nil
 }
 
@@ -284,60 +317,117 @@ func (l *ListOptionIndexer) Watch(ctx context.Context, opts WatchOptions, events
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
 
+	l.lock.RLock()
 	r := l.eventLog.NewReader()
+	lastID, lastRV := l.latestEventID, l.latestRV
+	l.lock.RUnlock()
+
+	if opts.ResourceVersion != "" {
+		lastRV = opts.ResourceVersion
+	}
+	// lastRV is the revision of the last event read, lastSentRV the one the watcher last knew of, starting with the
+	// revision it watches from
+	lastSentRV := lastRV
+	filter := opts.Filter
+	send := func(e *event) {
+		lastID, lastRV = e.ID, e.Object.GetResourceVersion()
+		if !filter.matches(e.Previous) && !filter.matches(e.Object) {
+			return
+		}
+		eventsCh <- watch.Event{
+			Type:   e.Type,
+			Object: e.Object.(runtime.Object).DeepCopyObject(),
+		}
+		lastSentRV = lastRV
+	}
+
 	if targetRV := opts.ResourceVersion; targetRV != "" {
 		found := r.Rewind(func(v *event) bool {
-			return v.Object.GetResourceVersion() == targetRV
+			if v.Object.GetResourceVersion() != targetRV {
+				return false
+			}
+			lastID = v.ID
+			return true
 		})
-		if !found {
-			return ErrTooOld
-		}
-
-		// Discard the target object, as that's actually the last known resource version, we need to send the following ones
-		if _, err := r.Read(ctx); err != nil {
-			return err
+		if found {
+			// Discard the target object, as that's actually the last known resource version, we need to send the following ones
+			if _, err := r.Read(ctx); err != nil {
+				return err
+			}
+		} else {
+			// The target is no longer in memory, resume from the events table
+			targetID, err := l.eventIDForRV(ctx, l.getEventIDStmt, targetRV)
+			if err != nil {
+				return err
+			}
+			if r, err = l.replayEvents(ctx, targetID, send); err != nil {
+				return ignoreCanceled(err)
+			}
 		}
 	}
 
-	filter := opts.Filter
+	nextBookmark := time.Now().Add(opts.BookmarkInterval)
 	for {
-		e, err := r.Read(ctx)
-		if err != nil {
-			if errors.Is(err, context.Canceled) {
-				return nil
+		readCtx, readCancel := ctx, context.CancelFunc(func() {})
+		if opts.BookmarkInterval > 0 {
+			readCtx, readCancel = context.WithDeadline(ctx, nextBookmark)
+		}
+		e, err := r.Read(readCtx)
+		readCancel()
+		if ctx.Err() != nil {
+			// the bookmark deadline may expire as the watch is canceled
+			return ignoreCanceled(ctx.Err())
+		}
+		if errors.Is(err, context.DeadlineExceeded) {
+			// Let idle watchers know about revisions skipped by their filter, so they can resume from there
+			if lastRV != lastSentRV {
+				eventsCh <- bookmarkEvent(lastRV)
+				lastSentRV = lastRV
 			}
-			return err
+			nextBookmark = time.Now().Add(opts.BookmarkInterval)
+			continue
 		}
-		if !filter.matches(e.Previous) && !filter.matches(e.Object) {
+		if errors.Is(err, ring.ErrSlowReader) {
+			// serve the missed events from the events table instead of failing
+			metrics.IncSQLCacheEventLogReplays(l.metricsLabel)
+			r, err = l.replayEvents(ctx, lastID, send)
+			if errors.Is(err, ErrTooOld) {
+				metrics.IncSQLCacheEventLogDrops(l.metricsLabel)
+			}
+			if err != nil {
+				return ignoreCanceled(err)
+			}
 			continue
 		}
-		eventsCh <- watch.Event{
-			Type:   e.Type,
-			Object: e.Object.(runtime.Object).DeepCopyObject(),
+		if err != nil {
+			return ignoreCanceled(err)
 		}
+		send(e)
 	}
 }
 
-func (l *ListOptionIndexer) decryptScanEvent(rows db.Rows, into runtime.Object) (watch.EventType, error) {
-	var typ, rv string
-	var serialized db.SerializedObject
-	if err := rows.Scan(&typ, &rv, &serialized.Bytes, &serialized.Nonce, &serialized.KeyID); err != nil {
-		return watch.Error, err
-	}
-	if err := l.Deserialize(serialized, into); err != nil {
-		return watch.Error, err
+// bookmarkEvent returns a BOOKMARK event for rv, carrying an object with only its resourceVersion set
+func bookmarkEvent(rv string) watch.Event {
+	obj := &unstructured.Unstructured{Object: map[string]any{}}
+	obj.SetResourceVersion(rv)
+	return watch.Event{Type: watch.Bookmark, Object: obj}
+}
+
+func ignoreCanceled(err error) error {
+	if errors.Is(err, context.Canceled) {
+		return nil
 	}
-	return watch.EventType(typ), nil
+	return err
 }
 
 /* Core methods */
 
-func (l *ListOptionIndexer) notifyEventAdded(key string, obj any, _ db.TxClient) error {
-	return l.notifyEvent(watch.Added, nil, obj)
+func (l *ListOptionIndexer) notifyEventAdded(key string, obj any, tx db.TxClient) error {
+	return l.notifyEvent(tx, watch.Added, key, db.SerializedObject{}, nil, obj)
 }
 
-func (l *ListOptionIndexer) notifyEventModified(key string, obj any, _ db.TxClient) error {
-	oldObj, exists, err := l.GetByKey(key)
+func (l *ListOptionIndexer) notifyEventModified(key string, obj any, tx db.TxClient) error {
+	serialized, oldObj, exists, err := l.getStored(key)
 	if err != nil {
 		return fmt.Errorf("error getting old object: %w", err)
 	}
@@ -346,11 +436,11 @@ func (l *ListOptionIndexer) notifyEventModified(key string, obj any, _ db.TxClie
 		return fmt.Errorf("old object %q should be in store but was not", key)
 	}
 
-	return l.notifyEvent(watch.Modified, oldObj, obj)
+	return l.notifyEvent(tx, watch.Modified, key, serialized, oldObj, obj)
 }
 
-func (l *ListOptionIndexer) notifyEventDeleted(key string, obj any, _ db.TxClient) error {
-	oldObj, exists, err := l.GetByKey(key)
+func (l *ListOptionIndexer) notifyEventDeleted(key string, obj any, tx db.TxClient) error {
+	serialized, oldObj, exists, err := l.getStored(key)
 	if err != nil {
 		return fmt.Errorf("error getting old object: %w", err)
 	}
@@ -358,10 +448,10 @@ func (l *ListOptionIndexer) notifyEventDeleted(key string, obj any, _ db.TxClien
 	if !exists {
 		return fmt.Errorf("old object %q should be in store but was not", key)
 	}
-	return l.notifyEvent(watch.Deleted, oldObj, obj)
+	return l.notifyEvent(tx, watch.Deleted, key, serialized, oldObj, obj)
 }
 
-func (l *ListOptionIndexer) notifyEvent(eventType watch.EventType, old any, current any) error {
+func (l *ListOptionIndexer) notifyEvent(tx db.TxClient, eventType watch.EventType, key string, serializedOld db.SerializedObject, old any, current any) error {
 	obj, err := meta.Accessor(current)
 	if err != nil {
 		return err
@@ -376,18 +466,36 @@ func (l *ListOptionIndexer) notifyEvent(eventType watch.EventType, old any, curr
 	}
 
 	latestRV := obj.GetResourceVersion()
-	if err := l.eventLog.Write(&event{
-		Type:     eventType,
-		Previous: oldObj,
-		Object:   obj,
-	}); err != nil {
+	id, err := l.addEvent(tx, eventType, key, serializedOld, current, latestRV)
+	if err != nil {
 		return err
 	}
 
+	// watchers must not see events that could still be rolled back. Writes to the store of an informer are
+	// sequential, so events are published in the order of their IDs.
+	tx.OnCommit(func() {
+		l.publishEvent(&event{
+			ID:       id,
+			Type:     eventType,
+			Previous: oldObj,
+			Object:   obj,
+		}, latestRV)
+	})
+	return nil
+}
+
+// publishEvent sends a committed event to watchers and makes its revision the latest one
+func (l *ListOptionIndexer) publishEvent(e *event, rv string) {
 	l.lock.Lock()
 	defer l.lock.Unlock()
-	l.latestRV = latestRV
-	return nil
+	if err := l.eventLog.Write(e); err != nil {
+		logrus.Errorf("publishing %s event for indexer [%s]: %v", e.Type, l.GetName(), err)
+		return
+	}
+	l.latestRV = rv
+	l.latestEventID = e.ID
+	close(l.rvChanged)
+	l.rvChanged = make(chan struct{})
 }
 
 func (l *ListOptionIndexer) closeEventLog(_ db.TxClient) error {
@@ -417,6 +525,11 @@ func (l *ListOptionIndexer) addIndexFields(key string, obj any, tx db.TxClient)
 			logrus.Errorf("cannot index object of type [%s] with key [%s] for indexer [%s]: %v", l.GetType().String(), key, l.GetName(), err)
 			return err
 		}
+		if value == nil && isHashedField(field) {
+			// keep missing hashed values NULL, see HashedField
+			args = append(args, nil)
+			continue
+		}
 		args = append(args, normalizeValue(value))
 	}
 
@@ -491,8 +604,19 @@ func (l *ListOptionIndexer) dropLabels(tx db.TxClient) error {
 // Otherwise, we're just looking for `relationship` blocks that contain a `toId` field.
 // The `type` field is implicit in these blocks.
 // Matching is done on the child node's ID.
-
-func (l *ListOptionIndexer) AugmentList(ctx context.Context, list *unstructured.UnstructuredList, childGVK schema.GroupVersionKind, childSchemaName string, useSelectors bool, accessList accesscontrol.AccessListByVerb) error {
+//
+// Relationship associations only need indexed fields, other associations, and those embedding more child fields,
+// are matched against the child objects instead, see augmentFromObjects.
+
+func (l *ListOptionIndexer) AugmentList(ctx context.Context, list *unstructured.UnstructuredList, assoc sqltypes.Association, accessList accesscontrol.AccessListByVerb) error {
+	useSelectors := assoc.Type == sqltypes.AssociationBySelectorRelationship
+	if (!useSelectors && assoc.Type != sqltypes.AssociationByIDRelationship) || len(assoc.Fields) > 0 {
+		if err := l.augmentFromObjects(ctx, list, assoc, accessList); err != nil {
+			logrus.Debugf("Error augmenting the info: %s\n", err)
+		}
+		return nil
+	}
+	childSchemaName := assoc.ChildSchemaName
 	var namespaceSet = sets.Set[string]{}
 	for _, data := range list.Items {
 		relationships, found, err := unstructured.NestedFieldNoCopy(data.Object, "metadata", "relationships")
@@ -535,12 +659,12 @@ func (l *ListOptionIndexer) AugmentList(ctx context.Context, list *unstructured.
 		return nil
 	}
 	namespaces := sets.List(namespaceSet) // Set.List() sorts the elements
-	tableBaseName := childGVK.Group + "_" + childGVK.Version + "_" + childGVK.Kind
+	tableBaseName := assoc.ChildGVK.Group + "_" + assoc.ChildGVK.Version + "_" + assoc.ChildGVK.Kind
 	query, params, err := makeAugmentedDBQuery(namespaces, tableBaseName)
 	if err != nil {
 		return err
 	}
-	err = l.finishAugmenting(ctx, list, query, params, childGVK, childSchemaName, useSelectors, accessList)
+	err = l.finishAugmenting(ctx, list, query, params, assoc, accessList)
 	if err != nil {
 		logrus.Debugf("Error augmenting the info: %s\n", err)
 	}
@@ -562,7 +686,7 @@ func makeAugmentedDBQuery(namespaces []string, tableBaseName string) (string, []
 	return query, params, nil
 }
 
-func (l *ListOptionIndexer) finishAugmenting(ctx context.Context, list *unstructured.UnstructuredList, query string, params []any, childGVK schema.GroupVersionKind, childSchemaName string, useSelectors bool, accessList accesscontrol.AccessListByVerb) error {
+func (l *ListOptionIndexer) finishAugmenting(ctx context.Context, list *unstructured.UnstructuredList, query string, params []any, assoc sqltypes.Association, accessList accesscontrol.AccessListByVerb) error {
 	stmt := l.Prepare(query)
 	var err error
 	defer func() {
@@ -584,6 +708,7 @@ func (l *ListOptionIndexer) finishAugmenting(ctx context.Context, list *unstruct
 		if err != nil {
 			return fmt.Errorf("finishAugmenting: error reading objects: %w", err)
 		}
+		metrics.RecordSQLCacheQuery(l.metricsLabel, metrics.SQLCacheQueryAugment, float64(time.Since(now).Milliseconds()), len(items))
 		return nil
 	})
 	if err != nil {
@@ -611,19 +736,20 @@ func (l *ListOptionIndexer) finishAugmenting(ctx context.Context, list *unstruct
 
 	// And now plug in the new data into metadata.associatedData
 	sortedItems := sortTheItems(items)
-	if useSelectors {
-		return l.getAssociatedDataBySelector(list.Items, sortedItems, childGVK, childSchemaName)
+	if assoc.Type == sqltypes.AssociationBySelectorRelationship {
+		return l.getAssociatedDataBySelector(list.Items, sortedItems, assoc)
 	}
-	return l.getAssociatedDataByID(list.Items, sortedItems, childGVK, childSchemaName)
+	return l.getAssociatedDataByID(list.Items, sortedItems, assoc)
 }
 
-func (l *ListOptionIndexer) getAssociatedDataBySelector(parentItems []unstructured.Unstructured, dbRows podsByNamespace, childGVK schema.GroupVersionKind, childSchemaName string) error {
+func (l *ListOptionIndexer) getAssociatedDataBySelector(parentItems []unstructured.Unstructured, dbRows podsByNamespace, assoc sqltypes.Association) error {
+	childSchemaName := assoc.ChildSchemaName
 	for _, listItem := range parentItems {
 		relationships, found, err := unstructured.NestedFieldNoCopy(listItem.Object, "metadata", "relationships")
 		if err != nil || !found {
 			continue
 		}
-		relatedDataItems := make([]any, 0)
+		relatedDataItems := make([]map[string]any, 0)
 		finalSelector := ""
 		finalNamespace := ""
 		for _, rel := range relationships.([]any) {
@@ -671,32 +797,21 @@ func (l *ListOptionIndexer) getAssociatedDataBySelector(parentItems []unstructur
 				})
 			}
 		}
-		if len(relatedDataItems) > 0 {
-			associationBlock := map[string]any{
-				"gvk": map[string]any{
-					"group":   childGVK.Group,
-					"version": childGVK.Version,
-					"kind":    childGVK.Kind,
-				},
-				"data": relatedDataItems,
-			}
-			associationWrapper := []any{associationBlock}
-			err = unstructured.SetNestedSlice(listItem.Object, associationWrapper, "metadata", "associatedData")
-			if err != nil {
-				return err
-			}
+		if err := appendAssociatedData(listItem.Object, assoc, relatedDataItems, "childName"); err != nil {
+			return err
 		}
 	}
 	return nil
 }
 
-func (l *ListOptionIndexer) getAssociatedDataByID(parentItems []unstructured.Unstructured, dbRows podsByNamespace, childGVK schema.GroupVersionKind, childSchemaName string) error {
+func (l *ListOptionIndexer) getAssociatedDataByID(parentItems []unstructured.Unstructured, dbRows podsByNamespace, assoc sqltypes.Association) error {
+	childSchemaName := assoc.ChildSchemaName
 	for _, listItem := range parentItems {
 		relationships, found, err := unstructured.NestedFieldNoCopy(listItem.Object, "metadata", "relationships")
 		if err != nil || !found {
 			continue
 		}
-		relatedDataItems := make([]any, 0)
+		relatedDataItems := make([]map[string]any, 0)
 		for _, rel := range relationships.([]any) {
 			rel2 := rel.(map[string]any)
 			toType, toTypeOK := rel2["toType"]
@@ -724,21 +839,9 @@ func (l *ListOptionIndexer) getAssociatedDataByID(parentItems []unstructured.Uns
 				}
 			}
 		}
-		if len(relatedDataItems) > 0 {
-			associationBlock := map[string]any{
-				"gvk": map[string]any{
-					"group":   childGVK.Group,
-					"version": childGVK.Version,
-					"kind":    childGVK.Kind,
-				},
-				"data": relatedDataItems,
-			}
-			associationWrapper := []any{associationBlock}
-			err = unstructured.SetNestedSlice(listItem.Object, associationWrapper, "metadata", "associatedData")
-			if err != nil {
-				logrus.Errorf("Can't set data: %s\n", err)
-				return err
-			}
+		if err := appendAssociatedData(listItem.Object, assoc, relatedDataItems, "podName"); err != nil {
+			logrus.Errorf("Can't set data: %s\n", err)
+			return err
 		}
 	}
 	return nil
@@ -795,8 +898,21 @@ func sortTheItems(items [][]string) podsByNamespace {
 //   - a summary object, containing the possible values for each field specified in a summary= subquery
 //   - a continue token, if there are more pages after the returned one
 //   - an error instead of all of the above if anything went wrong
+//
+// If lo.ChangesSince is set, only the objects changed since that revision are returned, see listChanges.
 func (l *ListOptionIndexer) ListByOptions(ctx context.Context, lo *sqltypes.ListOptions, partitions []partition.Partition, namespace string) (list *unstructured.UnstructuredList, total int, summary *types.APISummary, continueToken string, err error) {
 	dbName := db.Sanitize(l.GetName())
+	if lo.WaitForRevision > 0 {
+		if err = l.WaitForRevision(ctx, lo.Revision, lo.WaitForRevision); err != nil {
+			return
+		}
+	}
+	if lo.ChangesSince != "" {
+		if list, err = l.listChanges(ctx, lo, partitions, namespace); err == nil {
+			total = len(list.Items)
+		}
+		return
+	}
 	if len(lo.SummaryFieldList) > 0 {
 		if summary, err = l.ListSummaryFields(ctx, lo, partitions, dbName, namespace); err != nil {
 			return
@@ -834,6 +950,7 @@ func (l *ListOptionIndexer) executeQuery(ctx context.Context, queryInfo *QueryIn
 	}()
 
 	var items []any
+	start := time.Now()
 	err = l.WithTransaction(ctx, false, func(tx db.TxClient) error {
 		now := time.Now()
 		rows, err := l.QueryForRows(ctx, tx.Stmt(stmt), queryInfo.params...)
@@ -873,6 +990,7 @@ func (l *ListOptionIndexer) executeQuery(ctx context.Context, queryInfo *QueryIn
 	if err != nil {
 		return nil, 0, "", err
 	}
+	metrics.RecordSQLCacheQuery(l.metricsLabel, metrics.SQLCacheQueryList, float64(time.Since(start).Milliseconds()), len(items))
 
 	continueToken := ""
 	limit := queryInfo.limit
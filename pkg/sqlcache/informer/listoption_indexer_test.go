@@ -11,6 +11,7 @@ import (
 	"fmt"
 	"os"
 	"slices"
+	"strconv"
 	"strings"
 	"testing"
 	"time"
@@ -25,6 +26,7 @@ import (
 	"github.com/stretchr/testify/require"
 	"go.uber.org/mock/gomock"
 	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime/schema"
@@ -155,6 +157,11 @@ func TestNewListOptionIndexer(t *testing.T) {
 		txClient.EXPECT().Exec(fmt.Sprintf(dropLabelsStmtFmt, id)).Return(nil, nil)
 		txClient.EXPECT().Exec(fmt.Sprintf(createLabelsTableFmt, id, id)).Return(nil, nil)
 		txClient.EXPECT().Exec(fmt.Sprintf(createLabelsTableIndexFmt, id, id)).Return(nil, nil)
+		// create events table
+		txClient.EXPECT().Exec(fmt.Sprintf(dropEventsFmt, id)).Return(nil, nil)
+		txClient.EXPECT().Exec(fmt.Sprintf(createEventsTableFmt, id)).Return(nil, nil)
+		txClient.EXPECT().Exec(fmt.Sprintf(createEventsRVIndexFmt, id, id)).Return(nil, nil)
+		txClient.EXPECT().Exec(fmt.Sprintf(createEventsCreatedIndexFmt, id, id)).Return(nil, nil)
 		store.EXPECT().WithTransaction(gomock.Any(), true, gomock.Any()).Return(nil).Do(
 			func(ctx context.Context, shouldEncrypt bool, f db.WithTransactionFunction) {
 				err := f(txClient)
@@ -378,6 +385,11 @@ func TestNewListOptionIndexer(t *testing.T) {
 		txClient.EXPECT().Exec(fmt.Sprintf(dropLabelsStmtFmt, id)).Return(nil, nil)
 		txClient.EXPECT().Exec(fmt.Sprintf(createLabelsTableFmt, id, id)).Return(nil, nil)
 		txClient.EXPECT().Exec(fmt.Sprintf(createLabelsTableIndexFmt, id, id)).Return(nil, nil)
+		// create events table
+		txClient.EXPECT().Exec(fmt.Sprintf(dropEventsFmt, id)).Return(nil, nil)
+		txClient.EXPECT().Exec(fmt.Sprintf(createEventsTableFmt, id)).Return(nil, nil)
+		txClient.EXPECT().Exec(fmt.Sprintf(createEventsRVIndexFmt, id, id)).Return(nil, nil)
+		txClient.EXPECT().Exec(fmt.Sprintf(createEventsCreatedIndexFmt, id, id)).Return(nil, nil)
 		store.EXPECT().WithTransaction(gomock.Any(), true, gomock.Any()).Return(fmt.Errorf("error")).Do(
 			func(ctx context.Context, shouldEncrypt bool, f db.WithTransactionFunction) {
 				err := f(txClient)
@@ -1176,3 +1188,441 @@ func TestWatchCancel(t *testing.T) {
 	<-errCh
 	time.Sleep(1 * time.Second)
 }
+
+func TestHashedFields(t *testing.T) {
+	ctx := context.Background()
+	gvk := corev1.SchemeGroupVersion.WithKind("TestKind")
+
+	fields := HashFields(map[string]IndexedField{
+		"spec.owner": &JSONPathField{Path: []string{"spec", "owner"}},
+	}, []string{"spec.owner"}, []byte("hash key"))
+	opts := ListOptionIndexerOptions{
+		Fields:       fields,
+		IsNamespaced: true,
+	}
+	loi, dbPath, err := makeListOptionIndexer(ctx, gvk, opts, false, emptyNamespaceList)
+	defer cleanTempFiles(dbPath)
+	require.NoError(t, err)
+
+	for _, name := range []string{"foo", "bar"} {
+		obj := &unstructured.Unstructured{Object: map[string]any{
+			"metadata": map[string]any{"name": name},
+			"id":       "/" + name,
+			"spec":     map[string]any{"owner": name + "-owner"},
+		}}
+		obj.SetGroupVersionKind(gvk)
+		require.NoError(t, loi.Add(obj))
+	}
+	ownerless := &unstructured.Unstructured{Object: map[string]any{
+		"metadata": map[string]any{"name": "baz"},
+		"id":       "/baz",
+	}}
+	ownerless.SetGroupVersionKind(gvk)
+	require.NoError(t, loi.Add(ownerless))
+
+	// the plaintext value never reaches the fields table, and missing values are stored as NULL
+	stmt := loi.Prepare(fmt.Sprintf(`SELECT "spec.owner" FROM "%s_fields" WHERE "spec.owner" IS NOT NULL`, db.Sanitize(loi.GetName())))
+	rows, err := loi.QueryForRows(ctx, stmt)
+	require.NoError(t, err)
+	stored, err := loi.ReadStrings(rows)
+	require.NoError(t, err)
+	require.Len(t, stored, 2)
+	for _, value := range stored {
+		assert.NotContains(t, value, "owner")
+	}
+
+	list := func(filter sqltypes.Filter) ([]string, error) {
+		lo := &sqltypes.ListOptions{Filters: []sqltypes.OrFilter{{Filters: []sqltypes.Filter{filter}}}}
+		result, _, _, _, err := loi.ListByOptions(ctx, lo, []partition.Partition{{All: true}}, "")
+		if err != nil {
+			return nil, err
+		}
+		var names []string
+		for _, item := range result.Items {
+			names = append(names, item.GetName())
+		}
+		return names, nil
+	}
+
+	names, err := list(sqltypes.Filter{Field: []string{"spec", "owner"}, Matches: []string{"foo-owner"}, Op: sqltypes.Eq})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"foo"}, names)
+
+	names, err = list(sqltypes.Filter{Field: []string{"spec", "owner"}, Matches: []string{"foo-owner"}, Op: sqltypes.NotEq})
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"bar", "baz"}, names)
+
+	names, err = list(sqltypes.Filter{Field: []string{"spec", "owner"}, Matches: []string{"foo-owner", "bar-owner"}, Op: sqltypes.In})
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"foo", "bar"}, names)
+
+	names, err = list(sqltypes.Filter{Field: []string{"spec", "owner"}, Matches: []string{"foo-owner", "bar-owner"}, Op: sqltypes.NotIn})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"baz"}, names)
+
+	// a missing value doesn't collide with any literal value
+	for _, match := range []string{"", "<nil>"} {
+		names, err = list(sqltypes.Filter{Field: []string{"spec", "owner"}, Matches: []string{match}, Op: sqltypes.Eq})
+		require.NoError(t, err)
+		assert.Empty(t, names)
+	}
+
+	_, err = list(sqltypes.Filter{Field: []string{"spec", "owner"}, Matches: []string{"foo"}, Op: sqltypes.Eq, Partial: true})
+	assert.ErrorIs(t, err, ErrInvalidColumn)
+
+	lo := &sqltypes.ListOptions{SortList: sqltypes.SortList{SortDirectives: []sqltypes.Sort{{Fields: []string{"spec", "owner"}}}}}
+	_, _, _, _, err = loi.ListByOptions(ctx, lo, []partition.Partition{{All: true}}, "")
+	assert.ErrorIs(t, err, ErrInvalidColumn)
+}
+
+func TestWatchEventLog(t *testing.T) {
+	gvk := corev1.SchemeGroupVersion.WithKind("ConfigMap")
+	newConfigMap := func(i int) *unstructured.Unstructured {
+		obj := &unstructured.Unstructured{}
+		obj.SetGroupVersionKind(gvk)
+		obj.SetName(fmt.Sprintf("cm-%d", i))
+		obj.SetNamespace("default")
+		obj.SetResourceVersion(strconv.Itoa(100 + i))
+		obj.Object["id"] = "default/" + obj.GetName()
+		return obj
+	}
+	receiveEvents := func(t *testing.T, eventsCh chan watch.Event, count int) []string {
+		t.Helper()
+		var rvs []string
+		for len(rvs) < count {
+			select {
+			case ev := <-eventsCh:
+				rvs = append(rvs, ev.Object.(metav1.Object).GetResourceVersion())
+			case <-time.After(5 * time.Second):
+				t.Fatalf("received %d events, expected %d", len(rvs), count)
+			}
+		}
+		return rvs
+	}
+	expectedRVs := func(from, to int) []string {
+		var rvs []string
+		for i := from; i <= to; i++ {
+			rvs = append(rvs, strconv.Itoa(100+i))
+		}
+		return rvs
+	}
+
+	t.Run("resume from a revision no longer in memory", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		opts := ListOptionIndexerOptions{
+			IsNamespaced: true,
+			EventLog:     EventLogOptions{GCKeepCount: 2},
+		}
+		loi, dbPath, err := makeListOptionIndexer(ctx, gvk, opts, true, emptyNamespaceList)
+		defer cleanTempFiles(dbPath)
+		require.NoError(t, err)
+
+		for i := 0; i < 10; i++ {
+			require.NoError(t, loi.Add(newConfigMap(i)))
+		}
+
+		eventsCh := make(chan watch.Event, 100)
+		errCh := make(chan error, 1)
+		go func() {
+			errCh <- loi.Watch(ctx, WatchOptions{ResourceVersion: "101"}, eventsCh)
+		}()
+		assert.Equal(t, expectedRVs(2, 9), receiveEvents(t, eventsCh, 8))
+
+		// new events are received once caught up
+		require.NoError(t, loi.Add(newConfigMap(10)))
+		assert.Equal(t, expectedRVs(10, 10), receiveEvents(t, eventsCh, 1))
+
+		cancel()
+		assert.NoError(t, <-errCh)
+	})
+
+	t.Run("revision pruned by count", func(t *testing.T) {
+		ctx := context.Background()
+		opts := ListOptionIndexerOptions{
+			IsNamespaced: true,
+			EventLog:     EventLogOptions{GCKeepCount: 2, MaxCount: 3},
+		}
+		loi, dbPath, err := makeListOptionIndexer(ctx, gvk, opts, false, emptyNamespaceList)
+		defer cleanTempFiles(dbPath)
+		require.NoError(t, err)
+
+		for i := 0; i < 10; i++ {
+			require.NoError(t, loi.Add(newConfigMap(i)))
+		}
+
+		err = loi.Watch(ctx, WatchOptions{ResourceVersion: "101"}, make(chan watch.Event, 100))
+		assert.ErrorIs(t, err, ErrTooOld)
+
+		eventsCh := make(chan watch.Event, 100)
+		watchCtx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		go loi.Watch(watchCtx, WatchOptions{ResourceVersion: "107"}, eventsCh)
+		assert.Equal(t, expectedRVs(8, 9), receiveEvents(t, eventsCh, 2))
+	})
+
+	t.Run("revision pruned by age", func(t *testing.T) {
+		ctx := context.Background()
+		opts := ListOptionIndexerOptions{
+			IsNamespaced: true,
+			EventLog:     EventLogOptions{GCKeepCount: 2, MaxAge: 100 * time.Millisecond},
+		}
+		loi, dbPath, err := makeListOptionIndexer(ctx, gvk, opts, false, emptyNamespaceList)
+		defer cleanTempFiles(dbPath)
+		require.NoError(t, err)
+
+		for i := 0; i < 3; i++ {
+			require.NoError(t, loi.Add(newConfigMap(i)))
+		}
+		time.Sleep(200 * time.Millisecond)
+		require.NoError(t, loi.Add(newConfigMap(3)))
+
+		err = loi.Watch(ctx, WatchOptions{ResourceVersion: "100"}, make(chan watch.Event, 100))
+		assert.ErrorIs(t, err, ErrTooOld)
+	})
+
+	t.Run("events reuse the stored objects", func(t *testing.T) {
+		ctx := context.Background()
+		opts := ListOptionIndexerOptions{IsNamespaced: true}
+		// encrypted objects get a new nonce each time they are serialized
+		loi, dbPath, err := makeListOptionIndexer(ctx, gvk, opts, true, emptyNamespaceList)
+		defer cleanTempFiles(dbPath)
+		require.NoError(t, err)
+
+		obj := newConfigMap(0)
+		require.NoError(t, loi.Add(obj))
+		obj = obj.DeepCopy()
+		obj.SetResourceVersion("200")
+		require.NoError(t, loi.Update(obj))
+
+		count := func(query string) int {
+			t.Helper()
+			dbName := db.Sanitize(loi.GetName())
+			rows, err := loi.QueryForRows(ctx, loi.Prepare(strings.ReplaceAll(query, "%s", dbName)))
+			require.NoError(t, err)
+			n, err := loi.ReadInt(rows)
+			require.NoError(t, err)
+			return n
+		}
+		assert.Equal(t, 1, count(`SELECT COUNT(*) FROM "%s_events" e JOIN "%s" o ON e.object = o.object AND e.objectnonce = o.objectnonce WHERE e.type = 'MODIFIED'`))
+		assert.Equal(t, 1, count(`SELECT COUNT(*) FROM "%s_events" m JOIN "%s_events" a ON m.previous = a.object AND m.previousnonce = a.objectnonce WHERE m.type = 'MODIFIED' AND a.type = 'ADDED'`))
+	})
+
+	t.Run("events are pruned in batches", func(t *testing.T) {
+		ctx := context.Background()
+		opts := ListOptionIndexerOptions{
+			IsNamespaced: true,
+			EventLog:     EventLogOptions{GCKeepCount: 2, MaxCount: 20},
+		}
+		loi, dbPath, err := makeListOptionIndexer(ctx, gvk, opts, false, emptyNamespaceList)
+		defer cleanTempFiles(dbPath)
+		require.NoError(t, err)
+
+		countEvents := func() int {
+			t.Helper()
+			rows, err := loi.QueryForRows(ctx, loi.Prepare(fmt.Sprintf(`SELECT COUNT(*) FROM "%s_events"`, db.Sanitize(loi.GetName()))))
+			require.NoError(t, err)
+			n, err := loi.ReadInt(rows)
+			require.NoError(t, err)
+			return n
+		}
+		for i := 0; i < 21; i++ {
+			require.NoError(t, loi.Add(newConfigMap(i)))
+		}
+		// pruning runs every other event
+		assert.Equal(t, 21, countEvents())
+		require.NoError(t, loi.Add(newConfigMap(21)))
+		assert.Equal(t, 20, countEvents())
+	})
+
+	t.Run("slow reader is served from the database", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		opts := ListOptionIndexerOptions{
+			IsNamespaced: true,
+			EventLog:     EventLogOptions{GCKeepCount: 2},
+		}
+		loi, dbPath, err := makeListOptionIndexer(ctx, gvk, opts, false, emptyNamespaceList)
+		defer cleanTempFiles(dbPath)
+		require.NoError(t, err)
+		require.NoError(t, loi.Add(newConfigMap(0)))
+
+		eventsCh := make(chan watch.Event)
+		errCh := make(chan error, 1)
+		go func() {
+			errCh <- loi.Watch(ctx, WatchOptions{}, eventsCh)
+		}()
+		time.Sleep(100 * time.Millisecond)
+
+		// the watcher is blocked sending the first event while the others overflow the buffer
+		for i := 1; i < 20; i++ {
+			require.NoError(t, loi.Add(newConfigMap(i)))
+		}
+		assert.Equal(t, expectedRVs(1, 19), receiveEvents(t, eventsCh, 19))
+
+		cancel()
+		assert.NoError(t, <-errCh)
+	})
+
+	t.Run("events of rolled back transactions are not published", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		opts := ListOptionIndexerOptions{IsNamespaced: true}
+		loi, dbPath, err := makeListOptionIndexer(ctx, gvk, opts, false, emptyNamespaceList)
+		defer cleanTempFiles(dbPath)
+		require.NoError(t, err)
+		require.NoError(t, loi.Add(newConfigMap(0)))
+
+		fail := true
+		loi.RegisterAfterAdd(func(key string, obj any, tx db.TxClient) error {
+			if fail {
+				return fmt.Errorf("failed")
+			}
+			return nil
+		})
+		require.Error(t, loi.Add(newConfigMap(1)))
+		assert.Equal(t, "100", loi.latestRV)
+
+		eventsCh := make(chan watch.Event, 100)
+		go loi.Watch(ctx, WatchOptions{ResourceVersion: "100"}, eventsCh)
+		fail = false
+		require.NoError(t, loi.Add(newConfigMap(2)))
+		assert.Equal(t, expectedRVs(2, 2), receiveEvents(t, eventsCh, 1))
+	})
+
+	t.Run("ids of pruned events are not reused", func(t *testing.T) {
+		ctx := context.Background()
+		opts := ListOptionIndexerOptions{IsNamespaced: true}
+		loi, dbPath, err := makeListOptionIndexer(ctx, gvk, opts, false, emptyNamespaceList)
+		defer cleanTempFiles(dbPath)
+		require.NoError(t, err)
+
+		require.NoError(t, loi.Add(newConfigMap(0)))
+		lastID := loi.latestEventID
+		err = loi.WithTransaction(ctx, true, func(tx db.TxClient) error {
+			_, err := tx.Exec(fmt.Sprintf(`DELETE FROM "%s_events"`, db.Sanitize(loi.GetName())))
+			return err
+		})
+		require.NoError(t, err)
+		require.NoError(t, loi.Add(newConfigMap(1)))
+		assert.Greater(t, loi.latestEventID, lastID)
+	})
+}
+
+func TestWatchBookmarks(t *testing.T) {
+	gvk := corev1.SchemeGroupVersion.WithKind("ConfigMap")
+	newConfigMap := func(namespace, rv string) *unstructured.Unstructured {
+		obj := &unstructured.Unstructured{}
+		obj.SetGroupVersionKind(gvk)
+		obj.SetName("cm-" + rv)
+		obj.SetNamespace(namespace)
+		obj.SetResourceVersion(rv)
+		obj.Object["id"] = namespace + "/" + obj.GetName()
+		return obj
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	opts := ListOptionIndexerOptions{
+		IsNamespaced: true,
+	}
+	loi, dbPath, err := makeListOptionIndexer(ctx, gvk, opts, false, emptyNamespaceList)
+	defer cleanTempFiles(dbPath)
+	require.NoError(t, err)
+	require.NoError(t, loi.Add(newConfigMap("foo", "100")))
+
+	eventsCh := make(chan watch.Event, 100)
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- loi.Watch(ctx, WatchOptions{
+			ResourceVersion:  "100",
+			Filter:           WatchFilter{Namespace: "foo"},
+			BookmarkInterval: 50 * time.Millisecond,
+		}, eventsCh)
+	}()
+	// watchers starting from the latest revision know it already
+	latestCh := make(chan watch.Event, 100)
+	latestErrCh := make(chan error, 1)
+	go func() {
+		latestErrCh <- loi.Watch(ctx, WatchOptions{
+			Filter:           WatchFilter{Namespace: "foo"},
+			BookmarkInterval: 50 * time.Millisecond,
+		}, latestCh)
+	}()
+
+	// nothing changed, no bookmark is needed
+	time.Sleep(200 * time.Millisecond)
+	assert.Empty(t, eventsCh)
+	assert.Empty(t, latestCh)
+
+	// events filtered out advance the revision through bookmarks
+	require.NoError(t, loi.Add(newConfigMap("bar", "101")))
+	require.NoError(t, loi.Add(newConfigMap("bar", "102")))
+	// a bookmark may be sent between both events, but never twice for the same revision
+	var bookmarks []string
+	for len(bookmarks) == 0 || bookmarks[len(bookmarks)-1] != "102" {
+		select {
+		case ev := <-eventsCh:
+			assert.Equal(t, watch.Bookmark, ev.Type)
+			bookmarks = append(bookmarks, ev.Object.(metav1.Object).GetResourceVersion())
+		case <-time.After(5 * time.Second):
+			t.Fatal("bookmark not received")
+		}
+	}
+	assert.Subset(t, []string{"101", "102"}, bookmarks)
+	assert.Equal(t, slices.Compact(slices.Clone(bookmarks)), bookmarks)
+	time.Sleep(200 * time.Millisecond)
+	assert.Empty(t, eventsCh)
+
+	// matching events are sent as usual
+	require.NoError(t, loi.Add(newConfigMap("foo", "103")))
+	select {
+	case ev := <-eventsCh:
+		assert.Equal(t, watch.Added, ev.Type)
+		assert.Equal(t, "103", ev.Object.(metav1.Object).GetResourceVersion())
+	case <-time.After(5 * time.Second):
+		t.Fatal("event not received")
+	}
+	time.Sleep(200 * time.Millisecond)
+	assert.Empty(t, eventsCh)
+
+	cancel()
+	assert.NoError(t, <-errCh)
+	assert.NoError(t, <-latestErrCh)
+}
+
+func TestListByOptionsWaitForRevision(t *testing.T) {
+	gvk := corev1.SchemeGroupVersion.WithKind("ConfigMap")
+	newConfigMap := func(rv string) *unstructured.Unstructured {
+		obj := &unstructured.Unstructured{}
+		obj.SetGroupVersionKind(gvk)
+		obj.SetName("cm-" + rv)
+		obj.SetNamespace("default")
+		obj.SetResourceVersion(rv)
+		obj.Object["id"] = "default/" + obj.GetName()
+		return obj
+	}
+
+	ctx := context.Background()
+	opts := ListOptionIndexerOptions{
+		IsNamespaced: true,
+	}
+	loi, dbPath, err := makeListOptionIndexer(ctx, gvk, opts, false, emptyNamespaceList)
+	defer cleanTempFiles(dbPath)
+	require.NoError(t, err)
+	require.NoError(t, loi.Add(newConfigMap("100")))
+
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		assert.NoError(t, loi.Add(newConfigMap("101")))
+	}()
+	list, total, _, _, err := loi.ListByOptions(ctx, &sqltypes.ListOptions{Revision: "101", WaitForRevision: 5 * time.Second}, []partition.Partition{{All: true}}, "")
+	require.NoError(t, err)
+	assert.Equal(t, 2, total)
+	assert.Equal(t, "101", list.GetResourceVersion())
+
+	start := time.Now()
+	_, _, _, _, err = loi.ListByOptions(ctx, &sqltypes.ListOptions{Revision: "200", WaitForRevision: 100 * time.Millisecond}, []partition.Partition{{All: true}}, "")
+	assert.ErrorIs(t, err, ErrUnknownRevision)
+	assert.GreaterOrEqual(t, time.Since(start), 100*time.Millisecond)
+}
@@ -0,0 +1,67 @@
+package informer
+
+import (
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/tools/cache"
+)
+
+// DefaultPrunedFields are removed from objects of every GVK which doesn't have its own entry in PruneRules
+var DefaultPrunedFields = []string{"metadata.managedFields"}
+
+// PruneRules maps a GVK to the fields removed from its objects before they are stored in the cache.
+//
+// Fields use the same dotted notation as indexed fields. The last component may be written in square
+// brackets if it contains dots, eg: "metadata.annotations[kubectl.kubernetes.io/last-applied-configuration]".
+// An empty, non-nil list disables pruning for that GVK.
+//
+// Pruned fields are gone from everything served from the cache (lists and watches), and cannot be indexed.
+type PruneRules map[schema.GroupVersionKind][]string
+
+// FieldsFor returns the fields to prune from objects of the given GVK
+func (r PruneRules) FieldsFor(gvk schema.GroupVersionKind) []string {
+	if fields, ok := r[gvk]; ok {
+		return fields
+	}
+	return DefaultPrunedFields
+}
+
+// NewPruningTransform returns a cache.TransformFunc which runs transform (if not nil) and then removes fields
+// from the resulting object
+func NewPruningTransform(transform cache.TransformFunc, fields []string) cache.TransformFunc {
+	if len(fields) == 0 {
+		return transform
+	}
+	paths := make([][]string, 0, len(fields))
+	for _, field := range fields {
+		paths = append(paths, splitFieldID(field))
+	}
+	return func(raw any) (any, error) {
+		if transform != nil {
+			var err error
+			if raw, err = transform(raw); err != nil {
+				return raw, err
+			}
+		}
+		obj, ok := raw.(*unstructured.Unstructured)
+		if !ok {
+			return raw, nil
+		}
+		for _, path := range paths {
+			unstructured.RemoveNestedField(obj.Object, path...)
+		}
+		return obj, nil
+	}
+}
+
+// splitFieldID turns a field ID "metadata.annotations[a.b/c]" into ["metadata", "annotations", "a.b/c"]
+func splitFieldID(field string) []string {
+	bracket := strings.Index(field, "[")
+	if bracket == -1 || !strings.HasSuffix(field, "]") {
+		return strings.Split(field, ".")
+	}
+	path := strings.Split(strings.TrimSuffix(field[:bracket], "."), ".")
+	return append(path, field[bracket+1:len(field)-1])
+}
@@ -0,0 +1,112 @@
+package informer
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestPruneRulesFieldsFor(t *testing.T) {
+	secretGVK := schema.GroupVersionKind{Version: "v1", Kind: "Secret"}
+	podGVK := schema.GroupVersionKind{Version: "v1", Kind: "Pod"}
+	rules := PruneRules{
+		secretGVK: {},
+	}
+
+	assert.Empty(t, rules.FieldsFor(secretGVK))
+	assert.Equal(t, DefaultPrunedFields, rules.FieldsFor(podGVK))
+	assert.Equal(t, DefaultPrunedFields, PruneRules(nil).FieldsFor(podGVK))
+}
+
+func TestNewPruningTransform(t *testing.T) {
+	newObj := func() *unstructured.Unstructured {
+		return &unstructured.Unstructured{Object: map[string]any{
+			"metadata": map[string]any{
+				"name":          "foo",
+				"managedFields": []any{map[string]any{"manager": "kubectl"}},
+				"annotations": map[string]any{
+					"kubectl.kubernetes.io/last-applied-configuration": "{}",
+					"keep": "me",
+				},
+			},
+			"data": map[string]any{"key": "value"},
+		}}
+	}
+
+	tests := []struct {
+		name      string
+		transform cache.TransformFunc
+		fields    []string
+		want      map[string]any
+		wantErr   bool
+	}{
+		{
+			name:   "default fields",
+			fields: DefaultPrunedFields,
+			want: map[string]any{
+				"metadata": map[string]any{
+					"name": "foo",
+					"annotations": map[string]any{
+						"kubectl.kubernetes.io/last-applied-configuration": "{}",
+						"keep": "me",
+					},
+				},
+				"data": map[string]any{"key": "value"},
+			},
+		},
+		{
+			name:   "bracketed annotation and top-level field",
+			fields: []string{"metadata.annotations[kubectl.kubernetes.io/last-applied-configuration]", "data"},
+			want: map[string]any{
+				"metadata": map[string]any{
+					"name":          "foo",
+					"managedFields": []any{map[string]any{"manager": "kubectl"}},
+					"annotations":   map[string]any{"keep": "me"},
+				},
+			},
+		},
+		{
+			name: "runs the wrapped transform first",
+			transform: func(obj any) (any, error) {
+				u := obj.(*unstructured.Unstructured)
+				u.Object["id"] = "foo"
+				return u, nil
+			},
+			fields: []string{"metadata", "data"},
+			want:   map[string]any{"id": "foo"},
+		},
+		{
+			name: "wrapped transform error",
+			transform: func(obj any) (any, error) {
+				return nil, errors.New("boom")
+			},
+			fields:  DefaultPrunedFields,
+			wantErr: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := NewPruningTransform(test.transform, test.fields)(newObj())
+			if test.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.want, got.(*unstructured.Unstructured).Object)
+		})
+	}
+}
+
+func TestNewPruningTransformNoFields(t *testing.T) {
+	assert.Nil(t, NewPruningTransform(nil, nil))
+
+	obj := cache.DeletedFinalStateUnknown{Key: "foo"}
+	got, err := NewPruningTransform(nil, DefaultPrunedFields)(obj)
+	require.NoError(t, err)
+	assert.Equal(t, obj, got)
+}
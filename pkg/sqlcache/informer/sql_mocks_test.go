@@ -454,6 +454,20 @@ func (mr *MockStoreMockRecorder) Upsert(tx, stmt, key, obj any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Upsert", reflect.TypeOf((*MockStore)(nil).Upsert), tx, stmt, key, obj)
 }
 
+// UsingEncoding mocks base method.
+func (m *MockStore) UsingEncoding(opts db.EncodingOptions) db.Client {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UsingEncoding", opts)
+	ret0, _ := ret[0].(db.Client)
+	return ret0
+}
+
+// UsingEncoding indicates an expected call of UsingEncoding.
+func (mr *MockStoreMockRecorder) UsingEncoding(opts any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UsingEncoding", reflect.TypeOf((*MockStore)(nil).UsingEncoding), opts)
+}
+
 // WithTransaction mocks base method.
 func (m *MockStore) WithTransaction(ctx context.Context, forWriting bool, f db.WithTransactionFunction) error {
 	m.ctrl.T.Helper()
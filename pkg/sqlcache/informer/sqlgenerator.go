@@ -14,6 +14,7 @@ import (
 	"time"
 
 	"github.com/rancher/apiserver/pkg/types"
+	"github.com/rancher/steve/pkg/metrics"
 	"github.com/rancher/steve/pkg/sqlcache/db"
 	"github.com/rancher/steve/pkg/sqlcache/partition"
 	"github.com/rancher/steve/pkg/sqlcache/sqltypes"
@@ -195,6 +196,37 @@ func (l *ListOptionIndexer) buildClauseFromProjectsOrNamespaces(orFilters sqltyp
 		orFilters.Filters[0].Op)
 }
 
+// WaitForRevision blocks until the cache reaches revision, timeout expires or ctx is canceled. If the revision is not
+// reached in time, the request fails later on with ErrUnknownRevision.
+func (l *ListOptionIndexer) WaitForRevision(ctx context.Context, revision string, timeout time.Duration) error {
+	requestRevision, err := strconv.ParseInt(revision, 10, 64)
+	if err != nil {
+		return err
+	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	for {
+		l.lock.RLock()
+		latestRV, changed := l.latestRV, l.rvChanged
+		l.lock.RUnlock()
+
+		if latestRV != "" {
+			// checkRevision reports revisions that are not numbers
+			currentRevision, err := strconv.ParseInt(latestRV, 10, 64)
+			if err != nil || currentRevision >= requestRevision {
+				return nil
+			}
+		}
+		select {
+		case <-changed:
+		case <-timer.C:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
 func (l *ListOptionIndexer) checkRevision(lo *sqltypes.ListOptions) error {
 	l.lock.RLock()
 	latestRV := l.latestRV
@@ -380,6 +412,9 @@ func (l *ListOptionIndexer) compileQuery(lo *sqltypes.ListOptions,
 					if err != nil {
 						return nil, err
 					}
+					if isHashedField(l.indexedFields[smartJoin(fields)]) {
+						return nil, fmt.Errorf("column [%s] is hashed and cannot be sorted on: %w", smartJoin(fields), ErrInvalidColumn)
+					}
 					if sortDirective.SortAsIP {
 						fieldEntry = fmt.Sprintf("inet_aton(%s)", fieldEntry)
 					}
@@ -687,6 +722,7 @@ func (l *ListOptionIndexer) executeSummaryQueryForField(ctx context.Context, que
 		if err != nil {
 			return fmt.Errorf("executeSummaryQueryForField: read objects: %w", err)
 		}
+		metrics.RecordSQLCacheQuery(l.metricsLabel, metrics.SQLCacheQuerySummary, float64(time.Since(now).Milliseconds()), len(items))
 		return nil
 	})
 	if err != nil {
@@ -737,6 +773,7 @@ func (l *ListOptionIndexer) executeSummaryQuery(ctx context.Context, queryInfo *
 		if err != nil {
 			return fmt.Errorf("executeSummaryQuery: read objects: %w", err)
 		}
+		metrics.RecordSQLCacheQuery(l.metricsLabel, metrics.SQLCacheQuerySummary, float64(time.Since(now).Milliseconds()), len(items))
 		return nil
 	})
 	if err != nil {
@@ -811,7 +848,8 @@ SELECT key, value FROM "%s_labels"
 	if filterComponents.queryUsesLabels {
 		query += "DISTINCT "
 	}
-	query += fmt.Sprintf(`%s.object, %s.objectnonce, %s.dekid FROM "%s" %s%s`,
+	query += fmt.Sprintf(`%s.object, %s.objectnonce, %s.dekid, %s.encoding FROM "%s" %s%s`,
+		mainObjectPrefix,
 		mainObjectPrefix,
 		mainObjectPrefix,
 		mainObjectPrefix,
@@ -897,6 +935,9 @@ func (l *ListOptionIndexer) getFieldFilter(filter sqltypes.Filter, prefix string
 	if err != nil {
 		return "", nil, err
 	}
+	if field, ok := l.indexedFields[smartJoin(filter.Field)].(*HashedField); ok {
+		return getHashedFieldFilter(field, filter, fieldEntry)
+	}
 
 	switch filter.Op {
 	case sqltypes.Eq:
@@ -971,6 +1012,32 @@ func (l *ListOptionIndexer) getFieldFilter(filter sqltypes.Filter, prefix string
 	return "", nil, fmt.Errorf("unrecognized operator: %s", opString)
 }
 
+// getHashedFieldFilter compares hashes of the filter's values against a HashedField's column
+func getHashedFieldFilter(field *HashedField, filter sqltypes.Filter, fieldEntry string) (string, []any, error) {
+	matches := make([]any, len(filter.Matches))
+	for i, match := range filter.Matches {
+		matches[i] = field.Hash(match)
+	}
+	switch {
+	case filter.Partial:
+	case filter.Op == sqltypes.Eq:
+		return fmt.Sprintf("%s = ?", fieldEntry), matches[:1], nil
+	case filter.Op == sqltypes.NotEq:
+		// missing values are NULL and must still match a negative filter
+		return fmt.Sprintf("(%s IS NULL OR %s != ?)", fieldEntry, fieldEntry), matches[:1], nil
+	case filter.Op == sqltypes.In || filter.Op == sqltypes.NotIn:
+		target := "()"
+		if len(matches) > 0 {
+			target = fmt.Sprintf("(?%s)", strings.Repeat(", ?", len(matches)-1))
+		}
+		if filter.Op == sqltypes.NotIn {
+			return fmt.Sprintf("(%s IS NULL OR %s NOT IN %s)", fieldEntry, fieldEntry, target), matches, nil
+		}
+		return fmt.Sprintf("%s IN %s", fieldEntry, target), matches, nil
+	}
+	return "", nil, fmt.Errorf("column [%s] is hashed and only supports exact matches: %w", smartJoin(filter.Field), ErrInvalidColumn)
+}
+
 func (l *ListOptionIndexer) getLabelFilter(index int, filter sqltypes.Filter, mainFieldPrefix string, isSummaryFilter bool, dbName string) (string, []any, error) {
 	opString := ""
 	escapeString := ""
@@ -1156,6 +1223,9 @@ func (l *ListOptionIndexer) getStandardColumnNameToDisplay(fieldParts []string,
 
 	// Try direct field lookup first
 	if field, ok := l.indexedFields[fieldID]; ok {
+		if isHashedField(field) {
+			return "", fmt.Errorf("column [%s] is hashed and cannot be summarized: %w", fieldID, ErrInvalidColumn)
+		}
 		columnName := field.ColumnName()
 		if mainFieldPrefix == "" {
 			columnValueName = fmt.Sprintf("%q", columnName)
@@ -1172,7 +1242,7 @@ func (l *ListOptionIndexer) getStandardColumnNameToDisplay(fieldParts []string,
 
 	// Check if base field (without numeric index) exists
 	baseFieldID := smartJoin(fieldParts[:len(fieldParts)-1])
-	if field, ok := l.indexedFields[baseFieldID]; ok {
+	if field, ok := l.indexedFields[baseFieldID]; ok && !isHashedField(field) {
 		index, err := strconv.Atoi(fieldParts[len(fieldParts)-1])
 		if err != nil {
 			return "", fmt.Errorf("column is invalid [%s]: %w", fieldID, ErrInvalidColumn)
@@ -1234,13 +1304,18 @@ func (l *ListOptionIndexer) getValidFieldEntry(prefix string, fields []string) (
 	baseFieldID := smartJoin(otherFields)
 
 	// Check if the base field exists
-	if field, ok := l.indexedFields[baseFieldID]; ok {
+	if field, ok := l.indexedFields[baseFieldID]; ok && !isHashedField(field) {
 		return fmt.Sprintf(`extractBarredValue(%s."%s", "%s")`, prefix, field.ColumnName(), indexField), nil
 	}
 
 	return "", fmt.Errorf("column is invalid [%s]: %w", fieldID, ErrInvalidColumn)
 }
 
+func isHashedField(field IndexedField) bool {
+	_, ok := field.(*HashedField)
+	return ok
+}
+
 // isIntegerField checks if a field is stored as INTEGER type.
 func (l *ListOptionIndexer) isIntegerField(fieldID string) bool {
 	if f, ok := l.indexedFields[fieldID]; ok {
@@ -6,13 +6,23 @@ import (
 	"sync"
 	"time"
 
+	"github.com/rancher/steve/pkg/metrics"
 	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/api/equality"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/apimachinery/pkg/watch"
 	"k8s.io/client-go/dynamic"
 )
 
+const (
+	// syntheticWatchMaxBackoff caps the delay between polls after consecutive list errors
+	syntheticWatchMaxBackoff = 5 * time.Minute
+	// syntheticWatchJitterFactor spreads the retries of watchers failing at the same time
+	syntheticWatchJitterFactor = 0.5
+)
+
 type SyntheticWatcher struct {
 	resultChan   chan watch.Event
 	stopChan     chan struct{}
@@ -20,15 +30,18 @@ type SyntheticWatcher struct {
 	stopChanLock sync.Mutex
 	context      context.Context
 	cancelFunc   context.CancelFunc
+	// metricsLabel identifies the GVK of this watcher in SQL cache metrics
+	metricsLabel string
 }
 
-func newSyntheticWatcher(context context.Context, cancel context.CancelFunc) *SyntheticWatcher {
+func newSyntheticWatcher(context context.Context, cancel context.CancelFunc, metricsLabel string) *SyntheticWatcher {
 	return &SyntheticWatcher{
-		stopChan:   make(chan struct{}),
-		doneChan:   make(chan struct{}),
-		resultChan: make(chan watch.Event, 0),
-		context:    context,
-		cancelFunc: cancel,
+		stopChan:     make(chan struct{}),
+		doneChan:     make(chan struct{}),
+		resultChan:   make(chan watch.Event, 0),
+		context:      context,
+		cancelFunc:   cancel,
+		metricsLabel: metricsLabel,
 	}
 }
 
@@ -42,66 +55,51 @@ type objectHolder struct {
 	unstructuredObject *unstructured.Unstructured
 }
 
-// receive periodically calls client.List(), and converts the returned items into Watch Events
+// pollState is what a synthetic watcher remembers of the previous list to detect changes
+type pollState struct {
+	listVersion string
+	objects     map[string]objectHolder
+}
+
+// receive periodically calls client.List(), and converts the returned items into Watch Events. List errors are
+// retried with a jittered exponential backoff, starting at interval.
 func (rw *SyntheticWatcher) receive(client dynamic.ResourceInterface, options metav1.ListOptions, interval time.Duration) {
 	go func() {
 		defer close(rw.doneChan)
 		defer close(rw.resultChan)
 		defer rw.cancelFunc()
-		previousState := make(map[string]objectHolder)
-		ticker := time.NewTicker(interval)
+		state := &pollState{objects: map[string]objectHolder{}}
+		timer := time.NewTimer(interval)
+		defer timer.Stop()
+		failures := 0
 
 		for {
 			select {
-			case <-ticker.C:
-				list, err := client.List(rw.context, options)
+			case <-timer.C:
+				start := time.Now()
+				events, err := rw.poll(client, options, state)
 				if err != nil {
-					logrus.Errorf("synthetic watcher: client.List => error: %s", err)
+					failures++
+					delay := syntheticWatchBackoff(interval, failures)
+					logrus.Errorf("synthetic watcher: client.List => error: %s, retrying in %s", err, delay)
+					timer.Reset(delay)
 					continue
 				}
-				newObjects := make(map[string]objectHolder)
-				for _, uItem := range list.Items {
-					namespace := uItem.GetNamespace()
-					name := uItem.GetName()
-					key := name
-					if namespace != "" {
-						key = fmt.Sprintf("%s/%s", namespace, name)
-					}
-					version := uItem.GetResourceVersion()
-					newObjects[key] = objectHolder{version: version, unstructuredObject: &uItem}
-				}
-				// Now determine whether items were added, deleted, or modified
-				currentState := make(map[string]objectHolder)
-				for key, newObject := range newObjects {
-					currentState[key] = newObject
-					if oldItem, ok := previousState[key]; !ok {
-						w, err := createWatchEvent(watch.Added, newObject.unstructuredObject)
-						if err != nil {
-							logrus.Errorf("can't convert unstructured obj into runtime: %s", err)
-							continue
-						}
-						rw.resultChan <- w
-					} else {
-						delete(previousState, key)
-						if isUpdatedObject(oldItem, newObject) {
-							w, err := createWatchEvent(watch.Modified, oldItem.unstructuredObject)
-							if err != nil {
-								logrus.Errorf("can't convert unstructured obj into runtime: %s", err)
-								continue
-							}
-							rw.resultChan <- w
-						}
+				failures = 0
+				metrics.RecordSQLCacheSyntheticWatchPoll(rw.metricsLabel, float64(time.Since(start).Milliseconds()))
+				counts := map[watch.EventType]int{}
+				for _, event := range events {
+					select {
+					case rw.resultChan <- event:
+						counts[event.Type]++
+					case <-rw.context.Done():
+						return
 					}
 				}
-				// And anything left  in the previousState didn't show up in currentState and can be deleted.
-				for _, item := range previousState {
-					w, err := createWatchEvent(watch.Deleted, item.unstructuredObject)
-					if err != nil {
-						continue
-					}
-					rw.resultChan <- w
+				for eventType, count := range counts {
+					metrics.AddSQLCacheSyntheticWatchChanges(rw.metricsLabel, string(eventType), count)
 				}
-				previousState = currentState
+				timer.Reset(interval)
 
 			case <-rw.stopChan:
 				rw.cancelFunc()
@@ -114,14 +112,74 @@ func (rw *SyntheticWatcher) receive(client dynamic.ResourceInterface, options me
 	}()
 }
 
-func createWatchEvent(event watch.EventType, u *unstructured.Unstructured) (watch.Event, error) {
-	return watch.Event{Type: event, Object: u}, nil
+// poll lists the objects and returns the events turning the previous state into the current one, updating state
+func (rw *SyntheticWatcher) poll(client dynamic.ResourceInterface, options metav1.ListOptions, state *pollState) ([]watch.Event, error) {
+	list, err := client.List(rw.context, options)
+	if err != nil {
+		return nil, err
+	}
+	// a list at the same resourceVersion as the previous one has no changes
+	if listVersion := list.GetResourceVersion(); listVersion != "" {
+		if listVersion == state.listVersion {
+			return nil, nil
+		}
+		state.listVersion = listVersion
+	}
+
+	var events []watch.Event
+	currentState := make(map[string]objectHolder, len(list.Items))
+	for i := range list.Items {
+		uItem := &list.Items[i]
+		key := uItem.GetName()
+		if namespace := uItem.GetNamespace(); namespace != "" {
+			key = fmt.Sprintf("%s/%s", namespace, key)
+		}
+		newObject := objectHolder{version: uItem.GetResourceVersion(), unstructuredObject: uItem}
+		currentState[key] = newObject
+		oldItem, ok := state.objects[key]
+		if !ok {
+			events = append(events, createWatchEvent(watch.Added, uItem))
+			continue
+		}
+		delete(state.objects, key)
+		if isUpdatedObject(oldItem, newObject) {
+			events = append(events, createWatchEvent(watch.Modified, uItem))
+		}
+	}
+	// And anything left in the previous state didn't show up in currentState and can be deleted.
+	for _, item := range state.objects {
+		events = append(events, createWatchEvent(watch.Deleted, item.unstructuredObject))
+	}
+	state.objects = currentState
+	return events, nil
+}
+
+// syntheticWatchBackoff returns the delay before the next poll after failures consecutive list errors
+func syntheticWatchBackoff(interval time.Duration, failures int) time.Duration {
+	delay := syntheticWatchMaxBackoff
+	if failures < 32 {
+		if backoff := interval << (failures - 1); backoff > 0 && backoff < delay {
+			delay = backoff
+		}
+	}
+	return wait.Jitter(delay, syntheticWatchJitterFactor)
+}
+
+func createWatchEvent(event watch.EventType, u *unstructured.Unstructured) watch.Event {
+	return watch.Event{Type: event, Object: u}
 }
 
-// isUpdatedObject compares two objectHolder instances to determine if the underlying object has changed. It checks the resource version, and for NodeMetrics, it also checks the timestamp field
+// isUpdatedObject compares two objectHolder instances to determine if the underlying object has changed. Objects
+// with a resource version are only compared by version, except for NodeMetrics where the timestamp field is also
+// checked. Objects without one are compared as a whole.
 func isUpdatedObject(oldItem, newItem objectHolder) bool {
-	return oldItem.version != newItem.version ||
-		(newItem.unstructuredObject.Object["kind"] == "NodeMetrics" && newItem.unstructuredObject.Object["timestamp"] != oldItem.unstructuredObject.Object["timestamp"])
+	if newItem.unstructuredObject.Object["kind"] == "NodeMetrics" && newItem.unstructuredObject.Object["timestamp"] != oldItem.unstructuredObject.Object["timestamp"] {
+		return true
+	}
+	if oldItem.version != "" && newItem.version != "" {
+		return oldItem.version != newItem.version
+	}
+	return !equality.Semantic.DeepEqual(oldItem.unstructuredObject.Object, newItem.unstructuredObject.Object)
 }
 
 // ResultChan implements [k8s.io/apimachinery/pkg/watch].Interface.
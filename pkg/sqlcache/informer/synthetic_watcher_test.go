@@ -17,6 +17,7 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/watch"
 )
 
@@ -84,7 +85,7 @@ func TestSyntheticWatcher(t *testing.T) {
 	dynamicClient.EXPECT().List(gomock.Any(), gomock.Any()).AnyTimes().Return(list2, nil)
 
 	ctx, cancel := context.WithCancel(context.Background())
-	sw := newSyntheticWatcher(ctx, cancel)
+	sw := newSyntheticWatcher(ctx, cancel, "test")
 	pollingInterval := 10 * time.Millisecond
 	watchFunc := func(options metav1.ListOptions) (watch.Interface, error) {
 		return sw.watch(dynamicClient, options, pollingInterval)
@@ -194,6 +195,18 @@ func TestIsUpdateObjectScenarios(t *testing.T) {
 			newObj: objectHolder{unstructuredObject: oldResource1, version: "v2"},
 			want:   true,
 		},
+		{
+			name:   "Generic resource without version has different content",
+			oldObj: objectHolder{unstructuredObject: oldResource},
+			newObj: objectHolder{unstructuredObject: updatedTSResource},
+			want:   true,
+		},
+		{
+			name:   "Generic resource without version has same content",
+			oldObj: objectHolder{unstructuredObject: oldResource},
+			newObj: objectHolder{unstructuredObject: oldResource.DeepCopy()},
+			want:   false,
+		},
 	}
 
 	for _, tt := range tests {
@@ -205,6 +218,76 @@ func TestIsUpdateObjectScenarios(t *testing.T) {
 
 }
 
+func TestSyntheticWatcherPoll(t *testing.T) {
+	dynamicClient := NewMockResourceInterface(gomock.NewController(t))
+	cs1 := v1.ComponentStatus{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "ComponentStatus"},
+		ObjectMeta: metav1.ObjectMeta{Name: "cs1", ResourceVersion: "1"},
+	}
+	cs2 := v1.ComponentStatus{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "ComponentStatus"},
+		ObjectMeta: metav1.ObjectMeta{Name: "cs2", ResourceVersion: "2"},
+	}
+	list, err := makeCSList(cs1, cs2)
+	assert.Nil(t, err)
+	list.SetResourceVersion("2")
+	cs1b := cs1.DeepCopy()
+	cs1b.ResourceVersion = "3"
+	list2, err := makeCSList(*cs1b)
+	assert.Nil(t, err)
+	list2.SetResourceVersion("3")
+	gomock.InOrder(
+		dynamicClient.EXPECT().List(gomock.Any(), gomock.Any()).Return(list, nil),
+		dynamicClient.EXPECT().List(gomock.Any(), gomock.Any()).Return(list.DeepCopy(), nil),
+		dynamicClient.EXPECT().List(gomock.Any(), gomock.Any()).Return(list2, nil),
+	)
+
+	sw := newSyntheticWatcher(context.Background(), func() {}, "test")
+	state := &pollState{objects: map[string]objectHolder{}}
+
+	events, err := sw.poll(dynamicClient, metav1.ListOptions{}, state)
+	assert.Nil(t, err)
+	assert.Len(t, events, 2)
+
+	// the list is at the same resourceVersion, nothing changed
+	events, err = sw.poll(dynamicClient, metav1.ListOptions{}, state)
+	assert.Nil(t, err)
+	assert.Empty(t, events)
+
+	events, err = sw.poll(dynamicClient, metav1.ListOptions{}, state)
+	assert.Nil(t, err)
+	if assert.Len(t, events, 2) {
+		assert.Equal(t, watch.Modified, events[0].Type)
+		assert.Equal(t, "3", events[0].Object.(*unstructured.Unstructured).GetResourceVersion())
+		assert.Equal(t, watch.Deleted, events[1].Type)
+		assert.Equal(t, "cs2", events[1].Object.(*unstructured.Unstructured).GetName())
+	}
+}
+
+func TestSyntheticWatchBackoff(t *testing.T) {
+	interval := time.Second
+	for failures, want := range map[int]time.Duration{
+		1:   interval,
+		3:   4 * interval,
+		20:  syntheticWatchMaxBackoff,
+		100: syntheticWatchMaxBackoff,
+	} {
+		delay := syntheticWatchBackoff(interval, failures)
+		assert.GreaterOrEqual(t, delay, want)
+		assert.LessOrEqual(t, delay, time.Duration(float64(want)*(1+syntheticWatchJitterFactor)))
+	}
+}
+
+func TestSyntheticWatchInterval(t *testing.T) {
+	gvk := schema.GroupVersionKind{Group: "metrics.k8s.io", Version: "v1beta1", Kind: "NodeMetrics"}
+	assert.Equal(t, defaultRefreshTime, syntheticWatchInterval(gvk))
+	SetSyntheticWatchableIntervalForGVK(gvk, time.Minute)
+	assert.Equal(t, time.Minute, syntheticWatchInterval(gvk))
+	assert.Equal(t, defaultRefreshTime, syntheticWatchInterval(schema.GroupVersionKind{Version: "v1", Kind: "ComponentStatus"}))
+	SetSyntheticWatchableIntervalForGVK(gvk, 0)
+	assert.Equal(t, defaultRefreshTime, syntheticWatchInterval(gvk))
+}
+
 func makeCSList(objs ...v1.ComponentStatus) (*unstructured.UnstructuredList, error) {
 	unList := make([]unstructured.Unstructured, len(objs))
 	for i, cs := range objs {
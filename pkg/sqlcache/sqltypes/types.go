@@ -1,6 +1,10 @@
 package sqltypes
 
-import "k8s.io/apimachinery/pkg/runtime/schema"
+import (
+	"time"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
 
 type Op string
 
@@ -35,7 +39,55 @@ type ListOptions struct {
 	SummaryFieldList      SummaryFieldList
 	Pagination            Pagination
 	IncludeAssociatedData bool
-	Revision              string
+	// AssociatedData selects which children are embedded when IncludeAssociatedData is set
+	AssociatedData AssociatedDataOptions
+	Revision       string
+	// WaitForRevision is how long to wait for the cache to reach Revision, before failing with an unknown revision error
+	WaitForRevision time.Duration
+	// ChangesSince lists only the objects added, modified or deleted since this revision, instead of all of them
+	ChangesSince string
+}
+
+// AssociatedDataOptions selects the children embedded in each listed object's metadata.associatedData
+type AssociatedDataOptions struct {
+	// Children are the schema IDs of the child types to embed, eg. "pod". If empty, the default children of the
+	// listed type are embedded.
+	Children []string
+	// Fields are child fields to embed in addition to their name and state
+	Fields [][]string
+	// Limit is the maximum number of children of each type embedded per object. Zero means no limit.
+	Limit int
+}
+
+// AssociationType is how children are related to their parent
+type AssociationType string
+
+const (
+	// AssociationBySelectorRelationship matches children selected by the label selector of one of the parent's
+	// metadata.relationships
+	AssociationBySelectorRelationship AssociationType = "selectorRelationship"
+	// AssociationByIDRelationship matches children whose ID is the toId of one of the parent's metadata.relationships
+	AssociationByIDRelationship AssociationType = "idRelationship"
+	// AssociationByOwnerReference matches children with an owner reference to the parent
+	AssociationByOwnerReference AssociationType = "ownerReference"
+	// AssociationBySelector matches children in the parent's namespace selected by the label selector found in the
+	// parent at SelectorPath
+	AssociationBySelector AssociationType = "selector"
+)
+
+// Association describes the children of another GVK to embed in listed objects
+type Association struct {
+	ChildGVK        schema.GroupVersionKind
+	ChildSchemaName string
+	Type            AssociationType
+	// SelectorPath is the path to the label selector in the parent, for AssociationBySelector. Both plain label maps
+	// and metav1.LabelSelector values are supported.
+	SelectorPath []string
+	// Fields are child fields to embed in addition to their name and state
+	Fields [][]string
+	// Limit is the maximum number of children embedded per parent, sorted by name, in a block which also holds their
+	// total count. Zero means no limit, and neither sorting nor count.
+	Limit int
 }
 
 // Filter represents a field to filter by.
@@ -96,10 +148,29 @@ type ExternalLabelDependency struct {
 	TargetFinalFieldName string
 }
 
+// ExternalDependencyHop follows a reference from an object to another, either through one of its fields or labels
+type ExternalDependencyHop struct {
+	// SourceFieldName is the field of the referencing object holding the reference, if SourceLabelName is empty
+	SourceFieldName string
+	// SourceLabelName is the label of the referencing object holding the reference
+	SourceLabelName    string
+	TargetGVK          string
+	TargetKeyFieldName string
+}
+
+// ExternalChainDependency copies TargetFinalFieldName from the object reached by following Hops from a SourceGVK
+// object into the field of the same name of the SourceGVK object
+type ExternalChainDependency struct {
+	SourceGVK            string
+	Hops                 []ExternalDependencyHop
+	TargetFinalFieldName string
+}
+
 type ExternalGVKUpdates struct {
 	AffectedGVK               schema.GroupVersionKind
 	ExternalDependencies      []ExternalDependency
 	ExternalLabelDependencies []ExternalLabelDependency
+	ExternalChainDependencies []ExternalChainDependency
 }
 
 type ExternalGVKDependency map[schema.GroupVersionKind]*ExternalGVKUpdates
@@ -115,3 +186,41 @@ func NewSortList() *SortList {
 		SortDirectives: []Sort{},
 	}
 }
+
+// Chain returns the equivalent single-hop ExternalChainDependency
+func (d ExternalDependency) Chain() ExternalChainDependency {
+	return ExternalChainDependency{
+		SourceGVK: d.SourceGVK,
+		Hops: []ExternalDependencyHop{{
+			SourceFieldName:    d.SourceFieldName,
+			TargetGVK:          d.TargetGVK,
+			TargetKeyFieldName: d.TargetKeyFieldName,
+		}},
+		TargetFinalFieldName: d.TargetFinalFieldName,
+	}
+}
+
+// Chain returns the equivalent single-hop ExternalChainDependency
+func (d ExternalLabelDependency) Chain() ExternalChainDependency {
+	return ExternalChainDependency{
+		SourceGVK: d.SourceGVK,
+		Hops: []ExternalDependencyHop{{
+			SourceLabelName:    d.SourceLabelName,
+			TargetGVK:          d.TargetGVK,
+			TargetKeyFieldName: d.TargetKeyFieldName,
+		}},
+		TargetFinalFieldName: d.TargetFinalFieldName,
+	}
+}
+
+// Chains returns all dependencies in u as ExternalChainDependency values, label dependencies first
+func (u *ExternalGVKUpdates) Chains() []ExternalChainDependency {
+	chains := make([]ExternalChainDependency, 0, len(u.ExternalLabelDependencies)+len(u.ExternalDependencies)+len(u.ExternalChainDependencies))
+	for _, dep := range u.ExternalLabelDependencies {
+		chains = append(chains, dep.Chain())
+	}
+	for _, dep := range u.ExternalDependencies {
+		chains = append(chains, dep.Chain())
+	}
+	return append(chains, u.ExternalChainDependencies...)
+}
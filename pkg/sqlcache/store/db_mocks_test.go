@@ -293,6 +293,20 @@ func (mr *MockClientMockRecorder) Upsert(tx, stmt, key, obj any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Upsert", reflect.TypeOf((*MockClient)(nil).Upsert), tx, stmt, key, obj)
 }
 
+// UsingEncoding mocks base method.
+func (m *MockClient) UsingEncoding(opts db.EncodingOptions) db.Client {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UsingEncoding", opts)
+	ret0, _ := ret[0].(db.Client)
+	return ret0
+}
+
+// UsingEncoding indicates an expected call of UsingEncoding.
+func (mr *MockClientMockRecorder) UsingEncoding(opts any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UsingEncoding", reflect.TypeOf((*MockClient)(nil).UsingEncoding), opts)
+}
+
 // WithTransaction mocks base method.
 func (m *MockClient) WithTransaction(ctx context.Context, forWriting bool, f db.WithTransactionFunction) error {
 	m.ctrl.T.Helper()
@@ -351,6 +365,18 @@ func (mr *MockTxClientMockRecorder) Exec(query any, args ...any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Exec", reflect.TypeOf((*MockTxClient)(nil).Exec), varargs...)
 }
 
+// OnCommit mocks base method.
+func (m *MockTxClient) OnCommit(f func()) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "OnCommit", f)
+}
+
+// OnCommit indicates an expected call of OnCommit.
+func (mr *MockTxClientMockRecorder) OnCommit(f any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "OnCommit", reflect.TypeOf((*MockTxClient)(nil).OnCommit), f)
+}
+
 // Stmt mocks base method.
 func (m *MockTxClient) Stmt(stmt db.Stmt) db.Stmt {
 	m.ctrl.T.Helper()
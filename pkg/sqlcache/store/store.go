@@ -10,6 +10,7 @@ import (
 	"strings"
 
 	"github.com/rancher/lasso/pkg/log"
+	"github.com/rancher/steve/pkg/metrics"
 	"github.com/rancher/steve/pkg/sqlcache/db"
 	"github.com/rancher/steve/pkg/sqlcache/sqltypes"
 	"github.com/sirupsen/logrus"
@@ -22,23 +23,25 @@ import (
 
 const (
 	upsertStmtFmt = `
-INSERT INTO "%s" (key, object, objectnonce, dekid)
-VALUES (?, ?, ?, ?)
+INSERT INTO "%s" (key, object, objectnonce, dekid, encoding)
+VALUES (?, ?, ?, ?, ?)
 ON CONFLICT(key) DO UPDATE SET
   object = excluded.object,
   objectnonce = excluded.objectnonce,
-  dekid = excluded.dekid`
+  dekid = excluded.dekid,
+  encoding = excluded.encoding`
 	deleteStmtFmt    = `DELETE FROM "%s" WHERE key = ?`
 	deleteAllStmtFmt = `DELETE FROM "%s"`
 	dropBaseStmtFmt  = `DROP TABLE IF EXISTS "%s"`
-	getStmtFmt       = `SELECT object, objectnonce, dekid FROM "%s" WHERE key = ?`
-	listStmtFmt      = `SELECT object, objectnonce, dekid FROM "%s"`
+	getStmtFmt       = `SELECT object, objectnonce, dekid, encoding FROM "%s" WHERE key = ?`
+	listStmtFmt      = `SELECT object, objectnonce, dekid, encoding FROM "%s"`
 	listKeysStmtFmt  = `SELECT key FROM "%s"`
 	createTableFmt   = `CREATE TABLE IF NOT EXISTS "%s" (
 		key TEXT UNIQUE NOT NULL PRIMARY KEY,
 		object BLOB,
 		objectnonce BLOB,
-		dekid INTEGER
+		dekid INTEGER,
+		encoding INTEGER
 	)`
 )
 
@@ -155,93 +158,35 @@ func (s *Store) checkUpdateExternalInfo(key string) {
 // with the empty string. I assume this is never desired.
 
 func (s *Store) updateExternalInfo(tx db.TxClient, key string, externalUpdateInfo *sqltypes.ExternalGVKUpdates) error {
-	for _, labelDep := range externalUpdateInfo.ExternalLabelDependencies {
-		rawGetStmt := fmt.Sprintf(`SELECT DISTINCT f.key, ex2."%s" FROM "%s_fields" f
-  LEFT OUTER JOIN "%s_labels" lt1 ON f.key = lt1.key
-  JOIN "%s_fields" ex2 ON lt1.value = ex2."%s"
- WHERE lt1.label = ? AND f."%s" != ex2."%s"`,
-			labelDep.TargetFinalFieldName,
-			labelDep.SourceGVK,
-			labelDep.SourceGVK,
-			labelDep.TargetGVK,
-			labelDep.TargetKeyFieldName,
-			labelDep.TargetFinalFieldName,
-			labelDep.TargetFinalFieldName,
-		)
-		getStmt := s.Prepare(rawGetStmt)
-		rows, err := s.QueryForRows(s.ctx, getStmt, labelDep.SourceLabelName)
-		getStmt.Close()
-		if err != nil {
-			if !isDBError(err) {
-				logrus.Infof("Error getting external info for table %s, key %s: %v", labelDep.TargetGVK, key, err)
-			}
-			continue
-		}
-		result, err := s.ReadStringsN(rows, 2)
-		if err != nil {
-			logrus.Infof("Error reading objects for table %s, key %s: %s", labelDep.TargetGVK, key, err)
+	for _, dep := range externalUpdateInfo.Chains() {
+		if len(dep.Hops) == 0 {
 			continue
 		}
-		if len(result) == 0 {
-			continue
-		}
-		for _, innerResult := range result {
-			sourceKey := innerResult[0]
-			finalTargetValue := innerResult[1]
-			ignoreUpdate, err := s.overrideCheck(labelDep.TargetFinalFieldName, labelDep.SourceGVK, sourceKey, finalTargetValue)
-			if ignoreUpdate || err != nil {
-				continue
-			}
-			rawStmt := fmt.Sprintf(`UPDATE "%s_fields" SET "%s" = ? WHERE key = ?`,
-				labelDep.SourceGVK, labelDep.TargetFinalFieldName)
-			preparedStmt := s.Prepare(rawStmt)
-			_, err = tx.Stmt(preparedStmt).Exec(finalTargetValue, sourceKey)
-			preparedStmt.Close()
-			if err != nil {
-				logrus.Infof("Error running %s(%s, %s): %s", rawStmt, finalTargetValue, sourceKey, err)
-				continue
-			}
-		}
-	}
-	for _, nonLabelDep := range externalUpdateInfo.ExternalDependencies {
-		rawGetStmt := fmt.Sprintf(`SELECT DISTINCT f.key, ex2."%s"
- FROM "%s_fields" f JOIN "%s_fields" ex2 ON f."%s" = ex2."%s"
- WHERE f."%s" != ex2."%s"`,
-			nonLabelDep.TargetFinalFieldName,
-			nonLabelDep.SourceGVK,
-			nonLabelDep.TargetGVK,
-			nonLabelDep.SourceFieldName,
-			nonLabelDep.TargetKeyFieldName,
-			nonLabelDep.TargetFinalFieldName,
-			nonLabelDep.TargetFinalFieldName)
-		// TODO: Try to fold the two blocks together
-
+		targetGVK := dep.Hops[len(dep.Hops)-1].TargetGVK
+		rawGetStmt, args := externalChainQuery(dep)
 		getStmt := s.Prepare(rawGetStmt)
-		rows, err := s.QueryForRows(s.ctx, getStmt)
+		rows, err := s.QueryForRows(s.ctx, getStmt, args...)
 		getStmt.Close()
 		if err != nil {
 			if !isDBError(err) {
-				logrus.Infof("Error getting external info for table %s, key %s: %v", nonLabelDep.TargetGVK, key, err)
+				logrus.Infof("Error getting external info for table %s, key %s: %v", targetGVK, key, err)
 			}
 			continue
 		}
 		result, err := s.ReadStringsN(rows, 2)
 		if err != nil {
-			logrus.Infof("Error reading objects for table %s, key %s: %s", nonLabelDep.TargetGVK, key, err)
-			continue
-		}
-		if len(result) == 0 {
+			logrus.Infof("Error reading objects for table %s, key %s: %s", targetGVK, key, err)
 			continue
 		}
 		for _, innerResult := range result {
 			sourceKey := innerResult[0]
 			finalTargetValue := innerResult[1]
-			ignoreUpdate, err := s.overrideCheck(nonLabelDep.TargetFinalFieldName, nonLabelDep.SourceGVK, sourceKey, finalTargetValue)
+			ignoreUpdate, err := s.overrideCheck(dep.TargetFinalFieldName, dep.SourceGVK, sourceKey, finalTargetValue)
 			if ignoreUpdate || err != nil {
 				continue
 			}
 			rawStmt := fmt.Sprintf(`UPDATE "%s_fields" SET "%s" = ? WHERE key = ?`,
-				nonLabelDep.SourceGVK, nonLabelDep.TargetFinalFieldName)
+				dep.SourceGVK, dep.TargetFinalFieldName)
 			preparedStmt := s.Prepare(rawStmt)
 			_, err = tx.Stmt(preparedStmt).Exec(finalTargetValue, sourceKey)
 			preparedStmt.Close()
@@ -249,15 +194,48 @@ func (s *Store) updateExternalInfo(tx db.TxClient, key string, externalUpdateInf
 				logrus.Infof("Error running %s(%s, %s): %s", rawStmt, finalTargetValue, sourceKey, err)
 				continue
 			}
-			logrus.Tracef("updateExternalInfo: non-label-updated %s[%s].%s to %s",
-				nonLabelDep.SourceGVK,
+			logrus.Tracef("updateExternalInfo: updated %s[%s].%s to %s",
+				dep.SourceGVK,
 				sourceKey,
-				nonLabelDep.TargetFinalFieldName,
+				dep.TargetFinalFieldName,
 				finalTargetValue)
 		}
 	}
 	return nil
+}
+
+// externalChainQuery returns a query selecting the key of every SourceGVK object along with the value of
+// TargetFinalFieldName in the object reached by following all hops, when the two differ, and its arguments.
+// The source object is aliased as f, the object reached by the n-th hop as ex<n+1>, and the labels table used by the
+// n-th hop, if any, as lt<n>.
+func externalChainQuery(dep sqltypes.ExternalChainDependency) (string, []any) {
+	var joins, conditions []string
+	var args []any
+	prevAlias, prevGVK := "f", dep.SourceGVK
+	for i, hop := range dep.Hops {
+		alias := fmt.Sprintf("ex%d", i+2)
+		if hop.SourceLabelName != "" {
+			labelAlias := fmt.Sprintf("lt%d", i+1)
+			joins = append(joins,
+				fmt.Sprintf(`LEFT OUTER JOIN "%s_labels" %s ON %s.key = %s.key`, prevGVK, labelAlias, prevAlias, labelAlias),
+				fmt.Sprintf(`JOIN "%s_fields" %s ON %s.value = %s."%s"`, hop.TargetGVK, alias, labelAlias, alias, hop.TargetKeyFieldName))
+			conditions = append(conditions, labelAlias+".label = ?")
+			args = append(args, hop.SourceLabelName)
+		} else {
+			joins = append(joins,
+				fmt.Sprintf(`JOIN "%s_fields" %s ON %s."%s" = %s."%s"`, hop.TargetGVK, alias, prevAlias, hop.SourceFieldName, alias, hop.TargetKeyFieldName))
+		}
+		prevAlias, prevGVK = alias, hop.TargetGVK
+	}
+	conditions = append(conditions, fmt.Sprintf(`f."%s" != %s."%s"`, dep.TargetFinalFieldName, prevAlias, dep.TargetFinalFieldName))
 
+	query := fmt.Sprintf(`SELECT DISTINCT f.key, %s."%s" FROM "%s_fields" f
+  %s
+ WHERE %s`,
+		prevAlias, dep.TargetFinalFieldName, dep.SourceGVK,
+		strings.Join(joins, "\n  "),
+		strings.Join(conditions, " AND "))
+	return query, args
 }
 
 // If the new value will change a non-empty current value, return [true, error:nil]
@@ -445,6 +423,7 @@ func (s *Store) Replace(objects []any, _ string) error {
 		log.Errorf("Error in Store.Replace for type %v: %v", s.name, err)
 		return err
 	}
+	metrics.IncSQLCacheInformerResyncs(s.name)
 	return nil
 }
 
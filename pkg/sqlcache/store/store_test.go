@@ -899,8 +899,8 @@ func TestAddWithExternalUpdates(t *testing.T) {
          WHERE f."spec.projectName" != ex2."spec.projectName"`
 		c.EXPECT().Prepare(WSIgnoringMatcher(rawStmt3)).Return(preparedStmt)
 		args2 := []any{}
-		c.EXPECT().QueryForRows(gomock.Any(), 
-preparedStmt, args2)
+		c.EXPECT().QueryForRows(gomock.Any(),
+			preparedStmt, args2)
 		preparedStmt.EXPECT().Close()
 		c.EXPECT().ReadStringsN(gomock.Any(), 2).Return([][]string{{"lego.cattle.io/fields2", "moose2"}}, nil)
 
@@ -1184,3 +1184,30 @@ func SetupStoreWithExternalDependencies(t *testing.T, client *MockClient, update
 	}
 	return store
 }
+
+func TestExternalChainQuery(t *testing.T) {
+	dep := sqltypes.ExternalChainDependency{
+		SourceGVK: gvkKey("example.com", "v1", "Widget"),
+		Hops: []sqltypes.ExternalDependencyHop{
+			{
+				SourceFieldName:    "spec.gadgetRef",
+				TargetGVK:          gvkKey("example.com", "v1", "Gadget"),
+				TargetKeyFieldName: "metadata.name",
+			},
+			{
+				SourceLabelName:    "example.com/owner",
+				TargetGVK:          gvkKey("example.com", "v1", "Owner"),
+				TargetKeyFieldName: "id",
+			},
+		},
+		TargetFinalFieldName: "spec.displayName",
+	}
+	query, args := externalChainQuery(dep)
+	expected := `SELECT DISTINCT f.key, ex3."spec.displayName" FROM "example.com_v1_Widget_fields" f
+  JOIN "example.com_v1_Gadget_fields" ex2 ON f."spec.gadgetRef" = ex2."metadata.name"
+  LEFT OUTER JOIN "example.com_v1_Gadget_labels" lt2 ON ex2.key = lt2.key
+  JOIN "example.com_v1_Owner_fields" ex3 ON lt2.value = ex3."id"
+ WHERE lt2.label = ? AND f."spec.displayName" != ex3."spec.displayName"`
+	assert.Equal(t, dropWhiteSpace(expected), dropWhiteSpace(query))
+	assert.Equal(t, []any{"example.com/owner"}, args)
+}
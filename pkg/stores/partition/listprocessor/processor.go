@@ -54,6 +54,17 @@ type ListOptions struct {
 	ProjectsOrNamespaces ProjectsOrNamespacesFilter
 }
 
+// Fields returns the fields filtered and sorted by, eg. [metadata, name]
+func (o *ListOptions) Fields() [][]string {
+	var fields [][]string
+	for _, orFilter := range o.Filters {
+		for _, filter := range orFilter.filters {
+			fields = append(fields, filter.field)
+		}
+	}
+	return append(fields, o.Sort.Fields...)
+}
+
 // Filter represents a field to filter by.
 // A subfield in an object is represented in a request query using . notation, e.g. 'metadata.name'.
 // The subfield is internally represented as a slice, e.g. [metadata, name].
@@ -33,6 +33,9 @@ const (
 	defaultCacheSize = 1000
 	// Set to "false" to enable list request caching.
 	cacheDisableEnv = "CATTLE_REQUEST_CACHE_DISABLED"
+
+	// BookmarkAPIEvent is sent for watch bookmarks, carrying the latest revision in Revision
+	BookmarkAPIEvent = "resource.bookmark"
 )
 
 // Partitioner is an interface for interacting with partitions.
@@ -380,6 +383,8 @@ func ToAPIEvent(apiOp *types.APIRequest, schema *types.APISchema, event watch.Ev
 		name = types.RemoveAPIEvent
 	case watch.Added:
 		name = types.CreateAPIEvent
+	case watch.Bookmark:
+		name = BookmarkAPIEvent
 	case watch.Error:
 		name = "resource.error"
 	}
@@ -394,7 +399,10 @@ func ToAPIEvent(apiOp *types.APIRequest, schema *types.APISchema, event watch.Ev
 		return apiEvent
 	}
 
-	apiEvent.Object = ToAPI(schema, event.Object, nil, types.ReservedFields)
+	if event.Type != watch.Bookmark {
+		// bookmarks only carry a revision, there is no object to send
+		apiEvent.Object = ToAPI(schema, event.Object, nil, types.ReservedFields)
+	}
 
 	m, err := meta.Accessor(event.Object)
 	if err != nil {
@@ -2404,6 +2404,17 @@ var namespaces = map[string]*corev1.Namespace{
 	},
 }
 
+func TestToAPIEventBookmark(t *testing.T) {
+	obj := &unstructured.Unstructured{Object: map[string]any{}}
+	obj.SetResourceVersion("100")
+	schema := &types.APISchema{Schema: &schemas.Schema{ID: "configmap"}}
+
+	event := ToAPIEvent(nil, schema, watch.Event{Type: watch.Bookmark, Object: obj})
+	assert.Equal(t, BookmarkAPIEvent, event.Name)
+	assert.Equal(t, "100", event.Revision)
+	assert.Nil(t, event.Object.Object)
+}
+
 type mockNamespaceCache struct{}
 
 func (m mockNamespaceCache) Get(name string) (*corev1.Namespace, error) {
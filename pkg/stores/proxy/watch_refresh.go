@@ -6,9 +6,17 @@ import (
 
 	"github.com/rancher/apiserver/pkg/types"
 	"github.com/rancher/steve/pkg/accesscontrol"
+	"k8s.io/apiserver/pkg/authentication/user"
 	"k8s.io/apiserver/pkg/endpoints/request"
 )
 
+// PermissionsChangedEvent is sent on a watch right before it is stopped because the requester's access changed, so
+// that clients know to reload their schemas before watching again
+const PermissionsChangedEvent = "resource.permissions.changed"
+
+// watchRefreshInterval is how often the requester's access is checked when the AccessSetLookup doesn't notify changes
+const watchRefreshInterval = 2 * time.Second
+
 // WatchRefresh implements types.Store with awareness of changes to the requester's access.
 type WatchRefresh struct {
 	types.Store
@@ -30,26 +38,74 @@ func (w *WatchRefresh) Watch(apiOp *types.APIRequest, schema *types.APISchema, w
 		return w.Store.Watch(apiOp, schema, wr)
 	}
 
-	as := w.asl.AccessFor(user)
 	ctx, cancel := context.WithCancel(apiOp.Context())
 	apiOp = apiOp.WithContext(ctx)
 
+	var changed <-chan struct{}
+	if notifier, ok := w.asl.(accesscontrol.AccessChangeNotifier); ok {
+		changed = notifier.AccessChanged(ctx, user)
+	} else {
+		changed = w.pollAccess(ctx, user)
+	}
+
+	c, err := w.Store.Watch(apiOp, schema, wr)
+	if err != nil || c == nil {
+		cancel()
+		return c, err
+	}
+
+	result := make(chan types.APIEvent)
+	go func() {
+		defer close(result)
+		defer func() {
+			cancel()
+			for range c {
+				// drain until the watch is stopped
+			}
+		}()
+		// send gives up once the watch is canceled, as nobody reads result anymore
+		send := func(event types.APIEvent) bool {
+			select {
+			case result <- event:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+		for {
+			select {
+			case event, ok := <-c:
+				if !ok || !send(event) {
+					return
+				}
+			case <-changed:
+				// RBAC changed
+				send(types.APIEvent{Name: PermissionsChangedEvent})
+				return
+			}
+		}
+	}()
+	return result, nil
+}
+
+// pollAccess returns a channel which is closed once the AccessSet of user changes, checking it periodically
+func (w *WatchRefresh) pollAccess(ctx context.Context, user user.Info) <-chan struct{} {
+	as := w.asl.AccessFor(user)
+	changed := make(chan struct{})
 	go func() {
 		for {
 			select {
 			case <-ctx.Done():
 				return
-			case <-time.After(2 * time.Second):
+			case <-time.After(watchRefreshInterval):
 			}
 
 			newAs := w.asl.AccessFor(user)
 			if as.ID != newAs.ID {
-				// RBAC changed
-				cancel()
+				close(changed)
 				return
 			}
 		}
 	}()
-
-	return w.Store.Watch(apiOp, schema, wr)
+	return changed
 }
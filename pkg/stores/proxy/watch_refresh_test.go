@@ -0,0 +1,94 @@
+package proxy
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/rancher/apiserver/pkg/store/empty"
+	"github.com/rancher/apiserver/pkg/types"
+	"github.com/rancher/steve/pkg/accesscontrol"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apiserver/pkg/authentication/user"
+	"k8s.io/apiserver/pkg/endpoints/request"
+)
+
+type watchStore struct {
+	empty.Store
+	events chan types.APIEvent
+}
+
+func (s *watchStore) Watch(apiOp *types.APIRequest, _ *types.APISchema, _ types.WatchRequest) (chan types.APIEvent, error) {
+	result := make(chan types.APIEvent)
+	go func() {
+		defer close(result)
+		for {
+			select {
+			case event := <-s.events:
+				result <- event
+			case <-apiOp.Context().Done():
+				return
+			}
+		}
+	}()
+	return result, nil
+}
+
+type notifyingLookup struct {
+	changed chan struct{}
+}
+
+func (n *notifyingLookup) AccessFor(user.Info) *accesscontrol.AccessSet {
+	return &accesscontrol.AccessSet{}
+}
+
+func (n *notifyingLookup) PurgeUserData(string) {}
+
+func (n *notifyingLookup) AccessChanged(context.Context, user.Info) <-chan struct{} {
+	return n.changed
+}
+
+func TestWatchRefresh(t *testing.T) {
+	store := &watchStore{events: make(chan types.APIEvent)}
+	lookup := &notifyingLookup{changed: make(chan struct{})}
+	req, err := http.NewRequest(http.MethodGet, "/v1/pods", nil)
+	require.NoError(t, err)
+	req = req.WithContext(request.WithUser(req.Context(), &user.DefaultInfo{Name: "alice"}))
+
+	c, err := NewWatchRefresh(store, lookup).Watch(&types.APIRequest{Request: req}, nil, types.WatchRequest{})
+	require.NoError(t, err)
+
+	store.events <- types.APIEvent{Name: types.ChangeAPIEvent}
+	assert.Equal(t, types.ChangeAPIEvent, (<-c).Name)
+
+	close(lookup.changed)
+	select {
+	case event := <-c:
+		assert.Equal(t, PermissionsChangedEvent, event.Name)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the permissions changed event")
+	}
+	_, ok := <-c
+	assert.False(t, ok, "the watch stops once the access changed")
+}
+
+func TestWatchRefreshCanceled(t *testing.T) {
+	store := &watchStore{events: make(chan types.APIEvent)}
+	lookup := &notifyingLookup{changed: make(chan struct{})}
+	req, err := http.NewRequest(http.MethodGet, "/v1/pods", nil)
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(request.WithUser(req.Context(), &user.DefaultInfo{Name: "alice"}))
+	req = req.WithContext(ctx)
+
+	c, err := NewWatchRefresh(store, lookup).Watch(&types.APIRequest{Request: req}, nil, types.WatchRequest{})
+	require.NoError(t, err)
+
+	// nobody reads the event before the watch is canceled, which must not block the watch forever
+	store.events <- types.APIEvent{Name: types.ChangeAPIEvent}
+	cancel()
+	time.Sleep(100 * time.Millisecond)
+	_, ok := <-c
+	assert.False(t, ok, "the watch stops once canceled, dropping unread events")
+}
@@ -9,6 +9,7 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/rancher/apiserver/pkg/apierror"
 	"github.com/rancher/apiserver/pkg/types"
@@ -24,12 +25,17 @@ import (
 
 const (
 	defaultLimit               = 100000
+	maxWaitForRevision         = 30 * time.Second
 	filterParam                = "filter"
 	includeAssociatedDataParam = "includeAssociatedData"
+	associatedDataFieldsParam  = "associatedDataFields"
+	associatedDataLimitParam   = "associatedDataLimit"
 	sortParam                  = "sort"
 	pageSizeParam              = "pagesize"
 	pageParam                  = "page"
 	revisionParam              = "revision"
+	waitForRevisionParam       = "waitForRevision"
+	changesSinceParam          = "changesSince"
 	summaryParam               = "summary"
 	projectsOrNamespacesVar    = "projectsornamespaces"
 	projectIDFieldLabel        = "field.cattle.io/projectId"
@@ -190,6 +196,25 @@ func ParseQuery(apiOp *types.APIRequest, gvKind string) (sqltypes.ListOptions, e
 		}
 		opts.Revision = revision
 	}
+	if waitForRevision := q.Get(waitForRevisionParam); waitForRevision != "" {
+		wait, err := time.ParseDuration(waitForRevision)
+		if err != nil || wait < 0 || wait > maxWaitForRevision {
+			return opts, apierror.NewAPIError(validation.ErrorCode{Code: "invalid waitForRevision query param", Status: http.StatusBadRequest},
+				fmt.Sprintf("value %s for waitForRevision query param is not a duration between 0s and %s", waitForRevision, maxWaitForRevision))
+		}
+		if revision == "" {
+			return opts, apierror.NewAPIError(validation.ErrorCode{Code: "invalid waitForRevision query param", Status: http.StatusBadRequest},
+				"waitForRevision query param requires a revision")
+		}
+		opts.WaitForRevision = wait
+	}
+	if changesSince := q.Get(changesSinceParam); changesSince != "" {
+		if _, err := strconv.ParseInt(changesSince, 10, 64); err != nil {
+			return opts, apierror.NewAPIError(validation.ErrorCode{Code: "invalid changesSince query param", Status: http.StatusBadRequest},
+				fmt.Sprintf("value %s for changesSince query param is not valid", changesSince))
+		}
+		opts.ChangesSince = changesSince
+	}
 	summaryParams := q[summaryParam]
 	if len(summaryParams) > 1 {
 		return opts, fmt.Errorf("got %d summary parameters, at most 1 is allowed", len(summaryParams))
@@ -214,8 +239,32 @@ func ParseQuery(apiOp *types.APIRequest, gvKind string) (sqltypes.ListOptions, e
 
 	assocDataParams := q[includeAssociatedDataParam]
 	if len(assocDataParams) > 0 {
+		// either true, false, or the schema IDs of the children to include
 		lastParam := assocDataParams[len(assocDataParams)-1]
-		opts.IncludeAssociatedData = strings.ToLower(lastParam) == "true"
+		switch strings.ToLower(lastParam) {
+		case "true":
+			opts.IncludeAssociatedData = true
+		case "false", "":
+		default:
+			opts.IncludeAssociatedData = true
+			opts.AssociatedData.Children = strings.Split(lastParam, orOp)
+		}
+	}
+	for _, fields := range q[associatedDataFieldsParam] {
+		for _, field := range strings.Split(fields, orOp) {
+			if field == "" {
+				return opts, apierror.NewAPIError(validation.InvalidBodyContent, "unable to parse requirement: empty associatedDataFields parameter")
+			}
+			opts.AssociatedData.Fields = append(opts.AssociatedData.Fields, queryhelper.SafeSplit(field))
+		}
+	}
+	if limit := q.Get(associatedDataLimitParam); limit != "" {
+		var err error
+		opts.AssociatedData.Limit, err = strconv.Atoi(limit)
+		if err != nil || opts.AssociatedData.Limit < 0 {
+			return opts, apierror.NewAPIError(validation.ErrorCode{Code: "invalid associatedDataLimit query param", Status: http.StatusBadRequest},
+				fmt.Sprintf("value %s for associatedDataLimit query param is not a non-negative integer", limit))
+		}
 	}
 
 	return opts, nil
@@ -4,6 +4,7 @@ import (
 	"net/http"
 	"net/url"
 	"testing"
+	"time"
 
 	"github.com/rancher/apiserver/pkg/types"
 	"github.com/rancher/steve/pkg/sqlcache/sqltypes"
@@ -380,6 +381,107 @@ func TestParseQuery(t *testing.T) {
 		errExpected: true,
 		errorText:   "invalid revision query param 400: value invalid for revision query param is not valid",
 	})
+	tests = append(tests, testCase{
+		description: "ParseQuery() with waitForRevision query param",
+		req: &types.APIRequest{
+			Request: &http.Request{
+				URL: &url.URL{RawQuery: "revision=3400&waitForRevision=5s"},
+			},
+		},
+		expectedLO: sqltypes.ListOptions{
+			Revision:        "3400",
+			WaitForRevision: 5 * time.Second,
+			Filters:         []sqltypes.OrFilter{},
+			Pagination: sqltypes.Pagination{
+				Page: 1,
+			},
+		},
+	})
+	tests = append(tests, testCase{
+		description: "ParseQuery() with waitForRevision query param exceeding the maximum",
+		req: &types.APIRequest{
+			Request: &http.Request{
+				URL: &url.URL{RawQuery: "revision=3400&waitForRevision=1h"},
+			},
+		},
+		errExpected: true,
+		errorText:   "invalid waitForRevision query param 400: value 1h for waitForRevision query param is not a duration between 0s and 30s",
+	})
+	tests = append(tests, testCase{
+		description: "ParseQuery() with waitForRevision query param and no revision",
+		req: &types.APIRequest{
+			Request: &http.Request{
+				URL: &url.URL{RawQuery: "waitForRevision=5s"},
+			},
+		},
+		errExpected: true,
+		errorText:   "invalid waitForRevision query param 400: waitForRevision query param requires a revision",
+	})
+	tests = append(tests, testCase{
+		description: "ParseQuery() with includeAssociatedData naming children, fields and a limit",
+		req: &types.APIRequest{
+			Request: &http.Request{
+				URL: &url.URL{RawQuery: "includeAssociatedData=pod,discovery.k8s.io.endpointslice&associatedDataFields=status.phase,metadata.labels[app]&associatedDataLimit=5"},
+			},
+		},
+		expectedLO: sqltypes.ListOptions{
+			IncludeAssociatedData: true,
+			AssociatedData: sqltypes.AssociatedDataOptions{
+				Children: []string{"pod", "discovery.k8s.io.endpointslice"},
+				Fields:   [][]string{{"status", "phase"}, {"metadata", "labels", "app"}},
+				Limit:    5,
+			},
+			Filters: []sqltypes.OrFilter{},
+			Pagination: sqltypes.Pagination{
+				Page: 1,
+			},
+		},
+	})
+	tests = append(tests, testCase{
+		description: "ParseQuery() with an invalid associatedDataLimit",
+		req: &types.APIRequest{
+			Request: &http.Request{
+				URL: &url.URL{RawQuery: "includeAssociatedData=true&associatedDataLimit=-1"},
+			},
+		},
+		errExpected: true,
+		errorText:   "invalid associatedDataLimit query param 400: value -1 for associatedDataLimit query param is not a non-negative integer",
+	})
+	tests = append(tests, testCase{
+		description: "ParseQuery() with changesSince query param",
+		req: &types.APIRequest{
+			Request: &http.Request{
+				URL: &url.URL{RawQuery: "changesSince=100&filter=metadata.name=web"},
+			},
+		},
+		expectedLO: sqltypes.ListOptions{
+			ChangesSince: "100",
+			Filters: []sqltypes.OrFilter{
+				{
+					Filters: []sqltypes.Filter{
+						{
+							Field:   []string{"metadata", "name"},
+							Matches: []string{"web"},
+							Op:      sqltypes.Eq,
+						},
+					},
+				},
+			},
+			Pagination: sqltypes.Pagination{
+				Page: 1,
+			},
+		},
+	})
+	tests = append(tests, testCase{
+		description: "ParseQuery() with an invalid changesSince query param",
+		req: &types.APIRequest{
+			Request: &http.Request{
+				URL: &url.URL{RawQuery: "changesSince=abc"},
+			},
+		},
+		errExpected: true,
+		errorText:   "invalid changesSince query param 400: value abc for changesSince query param is not valid",
+	})
 	tests = append(tests, testCase{
 		description: "ParseQuery() with a labels filter param should create a labels-specific filter.",
 		req: &types.APIRequest{
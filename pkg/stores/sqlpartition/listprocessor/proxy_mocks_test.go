@@ -19,7 +19,6 @@ import (
 	sqltypes "github.com/rancher/steve/pkg/sqlcache/sqltypes"
 	gomock "go.uber.org/mock/gomock"
 	unstructured "k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
-	schema "k8s.io/apimachinery/pkg/runtime/schema"
 )
 
 // MockCache is a mock of Cache interface.
@@ -47,17 +46,17 @@ func (m *MockCache) EXPECT() *MockCacheMockRecorder {
 }
 
 // AugmentList mocks base method.
-func (m *MockCache) AugmentList(ctx context.Context, list *unstructured.UnstructuredList, childGVK schema.GroupVersionKind, childSchemaName string, useSelectors bool, accessList accesscontrol.AccessListByVerb) error {
+func (m *MockCache) AugmentList(ctx context.Context, list *unstructured.UnstructuredList, assoc sqltypes.Association, accessList accesscontrol.AccessListByVerb) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "AugmentList", ctx, list, childGVK, childSchemaName, useSelectors, accessList)
+	ret := m.ctrl.Call(m, "AugmentList", ctx, list, assoc, accessList)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // AugmentList indicates an expected call of AugmentList.
-func (mr *MockCacheMockRecorder) AugmentList(ctx, list, childGVK, childSchemaName, useSelectors, accessList any) *gomock.Call {
+func (mr *MockCacheMockRecorder) AugmentList(ctx, list, assoc, accessList any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AugmentList", reflect.TypeOf((*MockCache)(nil).AugmentList), ctx, list, childGVK, childSchemaName, useSelectors, accessList)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AugmentList", reflect.TypeOf((*MockCache)(nil).AugmentList), ctx, list, assoc, accessList)
 }
 
 // ListByOptions mocks base method.
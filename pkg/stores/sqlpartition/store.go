@@ -10,8 +10,13 @@ import (
 	"github.com/rancher/steve/pkg/accesscontrol"
 	cachepartition "github.com/rancher/steve/pkg/sqlcache/partition"
 	"github.com/rancher/steve/pkg/stores/partition"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 )
 
+// RevisionHeader is set on create and update responses to the revision of the written object, so that clients can
+// list with revision=<value>&waitForRevision=<duration> to read their own writes
+const RevisionHeader = "X-Api-Revision"
+
 // Partitioner is an interface for interacting with partitions.
 type Partitioner interface {
 	All(apiOp *types.APIRequest, schema *types.APISchema, verb, id string) ([]cachepartition.Partition, error)
@@ -117,6 +122,7 @@ func (s *Store) Create(apiOp *types.APIRequest, schema *types.APISchema, data ty
 	if err != nil {
 		return types.APIObject{}, err
 	}
+	setRevisionHeader(apiOp, obj)
 	return partition.ToAPI(schema, obj, warnings, types.ReservedFields), nil
 }
 
@@ -128,9 +134,19 @@ func (s *Store) Update(apiOp *types.APIRequest, schema *types.APISchema, data ty
 	if err != nil {
 		return types.APIObject{}, err
 	}
+	setRevisionHeader(apiOp, obj)
 	return partition.ToAPI(schema, obj, warnings, types.ReservedFields), nil
 }
 
+func setRevisionHeader(apiOp *types.APIRequest, obj *unstructured.Unstructured) {
+	if apiOp.Response == nil || obj == nil {
+		return
+	}
+	if rv := obj.GetResourceVersion(); rv != "" {
+		apiOp.Response.Header().Set(RevisionHeader, rv)
+	}
+}
+
 // Watch returns a channel of events for a list or resource.
 func (s *Store) Watch(apiOp *types.APIRequest, schema *types.APISchema, wr types.WatchRequest) (chan types.APIEvent, error) {
 	partitions, err := s.Partitioner.All(apiOp, schema, "watch", wr.ID)
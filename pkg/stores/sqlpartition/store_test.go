@@ -6,6 +6,7 @@ import (
 	"encoding/base64"
 	"fmt"
 	"net/http"
+	"net/http/httptest"
 	"net/url"
 	"strconv"
 	"testing"
@@ -383,3 +384,32 @@ func (m mockNamespaceCache) AddIndexer(indexName string, indexer generic.Indexer
 func (m mockNamespaceCache) GetByIndex(indexName, key string) ([]*corev1.Namespace, error) {
 	panic("not implemented")
 }
+
+func TestCreateUpdateRevisionHeader(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	p := NewMockPartitioner(ctrl)
+	us := NewMockUnstructuredStore(ctrl)
+	s := Store{
+		Partitioner: p,
+	}
+	schema := &types.APISchema{
+		Schema: &schemas.Schema{},
+	}
+	obj := &unstructured.Unstructured{}
+	obj.SetName("fuji")
+	obj.SetResourceVersion("42")
+
+	p.EXPECT().Store().Return(us).Times(2)
+	us.EXPECT().Create(gomock.Any(), schema, gomock.Any()).Return(obj, nil, nil)
+	us.EXPECT().Update(gomock.Any(), schema, gomock.Any(), "fuji").Return(obj, nil, nil)
+
+	recorder := httptest.NewRecorder()
+	_, err := s.Create(&types.APIRequest{Response: recorder}, schema, types.APIObject{})
+	assert.NoError(t, err)
+	assert.Equal(t, "42", recorder.Header().Get(RevisionHeader))
+
+	recorder = httptest.NewRecorder()
+	_, err = s.Update(&types.APIRequest{Response: recorder}, schema, types.APIObject{}, "fuji")
+	assert.NoError(t, err)
+	assert.Equal(t, "42", recorder.Header().Get(RevisionHeader))
+}
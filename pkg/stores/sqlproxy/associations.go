@@ -0,0 +1,79 @@
+package sqlproxy
+
+import (
+	"github.com/rancher/steve/pkg/sqlcache/sqltypes"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// AssociationRule declares how children are associated with objects of the Parent GVK, for includeAssociatedData
+type AssociationRule struct {
+	Parent schema.GroupVersionKind
+	// Default rules are applied when includeAssociatedData=true, without naming the children
+	Default bool
+	sqltypes.Association
+}
+
+var (
+	podGVK = schema.GroupVersionKind{Group: "", Version: "v1", Kind: "Pod"}
+	jobGVK = schema.GroupVersionKind{Group: "batch", Version: "v1", Kind: "Job"}
+)
+
+// AssociationRules lists the known associations. Children requested for a parent without a rule are associated
+// through their owner references.
+var AssociationRules = []AssociationRule{
+	{
+		Parent:      schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"},
+		Default:     true,
+		Association: sqltypes.Association{ChildGVK: podGVK, ChildSchemaName: "pod", Type: sqltypes.AssociationBySelectorRelationship},
+	},
+	{
+		Parent:      schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "DaemonSet"},
+		Default:     true,
+		Association: sqltypes.Association{ChildGVK: podGVK, ChildSchemaName: "pod", Type: sqltypes.AssociationBySelectorRelationship},
+	},
+	{
+		Parent:      schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "StatefulSet"},
+		Default:     true,
+		Association: sqltypes.Association{ChildGVK: podGVK, ChildSchemaName: "pod", Type: sqltypes.AssociationBySelectorRelationship},
+	},
+	{
+		Parent:      jobGVK,
+		Default:     true,
+		Association: sqltypes.Association{ChildGVK: podGVK, ChildSchemaName: "pod", Type: sqltypes.AssociationBySelectorRelationship},
+	},
+	{
+		Parent:      schema.GroupVersionKind{Group: "batch", Version: "v1", Kind: "CronJob"},
+		Default:     true,
+		Association: sqltypes.Association{ChildGVK: jobGVK, ChildSchemaName: "batch.job", Type: sqltypes.AssociationByIDRelationship},
+	},
+	{
+		// pods don't reference the services selecting them
+		Parent:      schema.GroupVersionKind{Group: "", Version: "v1", Kind: "Service"},
+		Association: sqltypes.Association{ChildGVK: podGVK, ChildSchemaName: "pod", Type: sqltypes.AssociationBySelector, SelectorPath: []string{"spec", "selector"}},
+	},
+}
+
+// associationsFor returns the associations of parent with the given children schema IDs, or its default associations
+func associationsFor(parent schema.GroupVersionKind, children []string) []sqltypes.Association {
+	var associations []sqltypes.Association
+	if len(children) == 0 {
+		for _, rule := range AssociationRules {
+			if rule.Parent == parent && rule.Default {
+				associations = append(associations, rule.Association)
+			}
+		}
+		return associations
+	}
+
+	for _, child := range children {
+		assoc := sqltypes.Association{ChildSchemaName: child, Type: sqltypes.AssociationByOwnerReference}
+		for _, rule := range AssociationRules {
+			if rule.Parent == parent && rule.ChildSchemaName == child {
+				assoc = rule.Association
+				break
+			}
+		}
+		associations = append(associations, assoc)
+	}
+	return associations
+}
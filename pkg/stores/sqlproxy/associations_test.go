@@ -0,0 +1,25 @@
+package sqlproxy
+
+import (
+	"testing"
+
+	"github.com/rancher/steve/pkg/sqlcache/sqltypes"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestAssociationsFor(t *testing.T) {
+	deploymentGVK := schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"}
+	serviceGVK := schema.GroupVersionKind{Version: "v1", Kind: "Service"}
+
+	assert.Equal(t, []sqltypes.Association{
+		{ChildGVK: podGVK, ChildSchemaName: "pod", Type: sqltypes.AssociationBySelectorRelationship},
+	}, associationsFor(deploymentGVK, nil))
+	// services have no default children
+	assert.Empty(t, associationsFor(serviceGVK, nil))
+
+	assert.Equal(t, []sqltypes.Association{
+		{ChildGVK: podGVK, ChildSchemaName: "pod", Type: sqltypes.AssociationBySelector, SelectorPath: []string{"spec", "selector"}},
+		{ChildSchemaName: "discovery.k8s.io.endpointslice", Type: sqltypes.AssociationByOwnerReference},
+	}, associationsFor(serviceGVK, []string{"pod", "discovery.k8s.io.endpointslice"}))
+}
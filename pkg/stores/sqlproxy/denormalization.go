@@ -0,0 +1,278 @@
+package sqlproxy
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/rancher/steve/pkg/configfile"
+	"github.com/rancher/steve/pkg/sqlcache/informer"
+	"github.com/rancher/steve/pkg/sqlcache/sqltypes"
+	"github.com/rancher/steve/pkg/stores/queryhelper"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+// DenormalizationRulesEnvVar is the path to a YAML or JSON file containing DenormalizationRules, applied in addition
+// to the built-in ones
+const DenormalizationRulesEnvVar = "CATTLE_SQL_CACHE_DENORMALIZATION_RULES"
+
+// DenormalizationRules copy fields of referenced objects into the indexed fields of the objects referencing them, so
+// that they can be filtered and sorted on, eg. namespaces by the display name of their project
+type DenormalizationRules struct {
+	Rules []DenormalizationRule `json:"rules"`
+}
+
+// DenormalizationGVK identifies a resource type in DenormalizationRules
+type DenormalizationGVK struct {
+	Group   string `json:"group,omitempty"`
+	Version string `json:"version"`
+	Kind    string `json:"kind"`
+}
+
+func (g DenormalizationGVK) gvk() schema.GroupVersionKind {
+	return schema.GroupVersionKind{Group: g.Group, Version: g.Version, Kind: g.Kind}
+}
+
+// DenormalizationRule copies Fields from the object reached by following Hops from an object of the rule's GVK.
+// Copied fields are indexed under the same name for both GVKs.
+type DenormalizationRule struct {
+	DenormalizationGVK `json:",inline"`
+	// Hops are followed in order, each one starting from the object reached by the previous one
+	Hops []DenormalizationHop `json:"hops"`
+	// Fields are the fields of the last referenced object to copy, eg. "spec.displayName"
+	Fields []string `json:"fields"`
+}
+
+// DenormalizationHop references the object of the Target GVK whose Key field equals either the Field or the Label of
+// the previous object
+type DenormalizationHop struct {
+	Field  string             `json:"field,omitempty"`
+	Label  string             `json:"label,omitempty"`
+	Target DenormalizationGVK `json:"target"`
+	Key    string             `json:"key"`
+}
+
+// defaultDenormalizationRules are always applied
+var defaultDenormalizationRules = []DenormalizationRule{
+	{
+		// namespaces are sorted by the display name of their project
+		DenormalizationGVK: DenormalizationGVK{Version: "v1", Kind: "Namespace"},
+		Hops: []DenormalizationHop{{
+			Label:  "field.cattle.io/projectId",
+			Target: DenormalizationGVK{Group: "management.cattle.io", Version: "v3", Kind: "Project"},
+			Key:    "metadata.name",
+		}},
+		Fields: []string{"spec.displayName"},
+	},
+	{
+		DenormalizationGVK: DenormalizationGVK{Version: "v1", Kind: "Secret"},
+		Hops: []DenormalizationHop{{
+			Label:  "management.cattle.io/project-scoped-secret",
+			Target: DenormalizationGVK{Group: "management.cattle.io", Version: "v3", Kind: "Project"},
+			Key:    "metadata.name",
+		}},
+		Fields: []string{"spec.displayName", "spec.clusterName"},
+	},
+	{
+		// provisioning clusters are sorted on the resources of their management cluster
+		DenormalizationGVK: DenormalizationGVK{Group: "provisioning.cattle.io", Version: "v1", Kind: "Cluster"},
+		Hops: []DenormalizationHop{{
+			Field:  "status.clusterName",
+			Target: DenormalizationGVK{Group: "management.cattle.io", Version: "v3", Kind: "Cluster"},
+			Key:    "id",
+		}},
+		Fields: []string{
+			"status.allocatable.cpu",
+			"status.allocatable.cpuRaw",
+			"status.allocatable.memory",
+			"status.allocatable.memoryRaw",
+			"status.allocatable.pods",
+			"status.requested.cpu",
+			"status.requested.cpuRaw",
+			"status.requested.memory",
+			"status.requested.memoryRaw",
+			"status.requested.pods",
+		},
+	},
+}
+
+var defaultDenormalizations = func() *denormalizations {
+	d, err := newDenormalizations(defaultDenormalizationRules)
+	if err != nil {
+		panic(err)
+	}
+	return d
+}()
+
+// LoadDenormalizationRules reads the DenormalizationRules in the file at path, eg. the one in
+// DenormalizationRulesEnvVar, and rejects rules which conflict with each other or with the built-in ones
+func LoadDenormalizationRules(path string) (DenormalizationRules, error) {
+	return configfile.Load(path, "denormalization rules", DenormalizationRules.Validate)
+}
+
+// Validate checks that rules are complete, that no chain visits a GVK twice, and that no field is copied into the
+// same GVK by more than one rule, including the built-in ones
+func (r DenormalizationRules) Validate() error {
+	_, err := newDenormalizations(append(append([]DenormalizationRule{}, defaultDenormalizationRules...), r.Rules...))
+	return err
+}
+
+func (g DenormalizationGVK) validate() error {
+	if g.Version == "" || g.Kind == "" {
+		return fmt.Errorf("version and kind are required")
+	}
+	return validateIdentifier(g.Group + g.Version + g.Kind)
+}
+
+func (r DenormalizationRule) validate() error {
+	if err := r.DenormalizationGVK.validate(); err != nil {
+		return err
+	}
+	if len(r.Hops) == 0 {
+		return fmt.Errorf("at least one hop is required")
+	}
+	if len(r.Fields) == 0 {
+		return fmt.Errorf("at least one field is required")
+	}
+	visited := map[schema.GroupVersionKind]bool{r.gvk(): true}
+	for i, hop := range r.Hops {
+		if (hop.Field == "") == (hop.Label == "") {
+			return fmt.Errorf("hop %d: exactly one of field and label is required", i)
+		}
+		if hop.Key == "" {
+			return fmt.Errorf("hop %d: key is required", i)
+		}
+		if err := hop.Target.validate(); err != nil {
+			return fmt.Errorf("hop %d: %w", i, err)
+		}
+		if err := validateIdentifier(hop.Field + hop.Key); err != nil {
+			return fmt.Errorf("hop %d: %w", i, err)
+		}
+		if visited[hop.Target.gvk()] {
+			return fmt.Errorf("hop %d: %v is already part of the chain", i, hop.Target.gvk())
+		}
+		visited[hop.Target.gvk()] = true
+	}
+	for _, field := range r.Fields {
+		if field == "" {
+			return fmt.Errorf("empty field")
+		}
+		if err := validateIdentifier(field); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateIdentifier rejects names that can't be quoted as SQL identifiers
+func validateIdentifier(name string) error {
+	if strings.Contains(name, `"`) {
+		return fmt.Errorf("%q: double quotes are not allowed", name)
+	}
+	return nil
+}
+
+// denormalizations holds the dependencies derived from DenormalizationRules, by GVK
+type denormalizations struct {
+	// external are applied when an object of the GVK changes, as it is referenced by objects of other GVKs
+	external sqltypes.ExternalGVKDependency
+	// self are applied when an object of the GVK changes, as it references objects of other GVKs
+	self sqltypes.ExternalGVKDependency
+	// fields are indexed for the GVK so that dependencies can be joined and copied
+	fields map[schema.GroupVersionKind]map[string]informer.IndexedField
+	// dependencies are the GVKs referenced by objects of the GVK, whose caches must be running
+	dependencies map[schema.GroupVersionKind][]schema.GroupVersionKind
+	// copied are the fields of the GVK copied from referenced objects
+	copied map[schema.GroupVersionKind]sets.Set[string]
+}
+
+func newDenormalizations(rules []DenormalizationRule) (*denormalizations, error) {
+	d := &denormalizations{
+		external:     sqltypes.ExternalGVKDependency{},
+		self:         sqltypes.ExternalGVKDependency{},
+		fields:       map[schema.GroupVersionKind]map[string]informer.IndexedField{},
+		dependencies: map[schema.GroupVersionKind][]schema.GroupVersionKind{},
+		copied:       map[schema.GroupVersionKind]sets.Set[string]{},
+	}
+	for i, rule := range rules {
+		if err := rule.validate(); err != nil {
+			return nil, fmt.Errorf("rule %d for %v: %w", i, rule.gvk(), err)
+		}
+		source := rule.gvk()
+		if d.copied[source] == nil {
+			d.copied[source] = sets.New[string]()
+		}
+
+		hops := make([]sqltypes.ExternalDependencyHop, len(rule.Hops))
+		for j, hop := range rule.Hops {
+			hops[j] = sqltypes.ExternalDependencyHop{
+				SourceFieldName:    hop.Field,
+				SourceLabelName:    hop.Label,
+				TargetGVK:          gvkKey(hop.Target.Group, hop.Target.Version, hop.Target.Kind),
+				TargetKeyFieldName: hop.Key,
+			}
+		}
+		var chains []sqltypes.ExternalChainDependency
+		for _, field := range rule.Fields {
+			if d.copied[source].Has(field) {
+				return nil, fmt.Errorf("rule %d for %v: field %s is already copied by another rule", i, source, field)
+			}
+			d.copied[source].Insert(field)
+			chains = append(chains, sqltypes.ExternalChainDependency{
+				SourceGVK:            gvkKey(source.Group, source.Version, source.Kind),
+				Hops:                 hops,
+				TargetFinalFieldName: field,
+			})
+		}
+
+		// the rule is applied whenever an object anywhere in the chain changes
+		d.addChains(d.self, source, source, chains)
+		prev := source
+		for j, hop := range rule.Hops {
+			target := hop.Target.gvk()
+			d.addChains(d.external, target, source, chains)
+			if !slices.Contains(d.dependencies[source], target) {
+				d.dependencies[source] = append(d.dependencies[source], target)
+			}
+			if hop.Field != "" {
+				d.addField(prev, hop.Field, "")
+			}
+			d.addField(target, hop.Key, "")
+			if j == len(rule.Hops)-1 {
+				for _, field := range rule.Fields {
+					d.addField(target, field, "")
+					// the copy is stored with the same type as the original
+					d.addField(source, field, fieldType(target, field))
+				}
+			}
+			prev = target
+		}
+	}
+	return d, nil
+}
+
+func (d *denormalizations) addChains(deps sqltypes.ExternalGVKDependency, gvk, affected schema.GroupVersionKind, chains []sqltypes.ExternalChainDependency) {
+	updates := deps[gvk]
+	if updates == nil {
+		updates = &sqltypes.ExternalGVKUpdates{AffectedGVK: affected}
+		deps[gvk] = updates
+	}
+	updates.ExternalChainDependencies = append(updates.ExternalChainDependencies, chains...)
+}
+
+func (d *denormalizations) addField(gvk schema.GroupVersionKind, name, typ string) {
+	if d.fields[gvk] == nil {
+		d.fields[gvk] = map[string]informer.IndexedField{}
+	}
+	field := &informer.JSONPathField{Path: queryhelper.SafeSplit(name), Type: typ}
+	d.fields[gvk][field.ColumnName()] = field
+}
+
+// fieldType returns the column type of a type-specific indexed field, if known
+func fieldType(gvk schema.GroupVersionKind, name string) string {
+	if field, ok := TypeSpecificIndexedFields[gvkKey(gvk.Group, gvk.Version, gvk.Kind)][name].(*informer.JSONPathField); ok {
+		return field.Type
+	}
+	return ""
+}
@@ -0,0 +1,158 @@
+package sqlproxy
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/rancher/steve/pkg/sqlcache/informer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestLoadDenormalizationRules(t *testing.T) {
+	widgetGVK := schema.GroupVersionKind{Group: "example.com", Version: "v1", Kind: "Widget"}
+	gadgetGVK := schema.GroupVersionKind{Group: "example.com", Version: "v1", Kind: "Gadget"}
+	ownerGVK := schema.GroupVersionKind{Group: "example.com", Version: "v1", Kind: "Owner"}
+
+	path := filepath.Join(t.TempDir(), "rules.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`rules:
+- group: example.com
+  version: v1
+  kind: Widget
+  hops:
+  - field: spec.gadgetRef
+    target: {group: example.com, version: v1, kind: Gadget}
+    key: metadata.name
+  - label: example.com/owner
+    target: {group: example.com, version: v1, kind: Owner}
+    key: metadata.name
+  fields: [spec.displayName]
+`), 0o600))
+	rules, err := LoadDenormalizationRules(path)
+	require.NoError(t, err)
+	require.Len(t, rules.Rules, 1)
+
+	d, err := newDenormalizations(append(append([]DenormalizationRule{}, defaultDenormalizationRules...), rules.Rules...))
+	require.NoError(t, err)
+
+	// the chain is applied when objects of any GVK in it change
+	require.NotNil(t, d.self[widgetGVK])
+	chains := d.self[widgetGVK].ExternalChainDependencies
+	require.Len(t, chains, 1)
+	assert.Equal(t, "example.com_v1_Widget", chains[0].SourceGVK)
+	assert.Len(t, chains[0].Hops, 2)
+	assert.Equal(t, "example.com_v1_Owner", chains[0].Hops[1].TargetGVK)
+	assert.Equal(t, "example.com/owner", chains[0].Hops[1].SourceLabelName)
+	assert.Equal(t, chains, d.external[gadgetGVK].ExternalChainDependencies)
+	assert.Equal(t, chains, d.external[ownerGVK].ExternalChainDependencies)
+	assert.Nil(t, d.external[widgetGVK])
+	assert.Equal(t, []schema.GroupVersionKind{gadgetGVK, ownerGVK}, d.dependencies[widgetGVK])
+
+	// fields used along the chain are indexed
+	assert.Contains(t, d.fields[widgetGVK], "spec.gadgetRef")
+	assert.Contains(t, d.fields[widgetGVK], "spec.displayName")
+	assert.Contains(t, d.fields[gadgetGVK], "metadata.name")
+	assert.Contains(t, d.fields[ownerGVK], "metadata.name")
+	assert.Contains(t, d.fields[ownerGVK], "spec.displayName")
+
+	_, err = LoadDenormalizationRules(filepath.Join(t.TempDir(), "missing.yaml"))
+	assert.Error(t, err)
+}
+
+func TestDefaultDenormalizations(t *testing.T) {
+	pcioCluster := schema.GroupVersionKind{Group: "provisioning.cattle.io", Version: "v1", Kind: "Cluster"}
+	mcioCluster := schema.GroupVersionKind{Group: "management.cattle.io", Version: "v3", Kind: "Cluster"}
+	mcioProject := schema.GroupVersionKind{Group: "management.cattle.io", Version: "v3", Kind: "Project"}
+
+	// projects update both namespaces and secrets
+	require.NotNil(t, defaultDenormalizations.external[mcioProject])
+	assert.Len(t, defaultDenormalizations.external[mcioProject].ExternalChainDependencies, 3)
+	assert.Len(t, defaultDenormalizations.self[pcioCluster].ExternalChainDependencies, 10)
+	assert.Len(t, defaultDenormalizations.external[mcioCluster].ExternalChainDependencies, 10)
+
+	// copies keep the type of the original field
+	assert.Equal(t, &informer.JSONPathField{Path: []string{"status", "allocatable", "cpuRaw"}, Type: "REAL"},
+		defaultDenormalizations.fields[pcioCluster]["status.allocatable.cpuRaw"])
+}
+
+func TestDenormalizationRulesValidate(t *testing.T) {
+	target := DenormalizationGVK{Group: "example.com", Version: "v1", Kind: "Gadget"}
+	tests := []struct {
+		name string
+		rule DenormalizationRule
+	}{
+		{
+			name: "missing kind",
+			rule: DenormalizationRule{
+				DenormalizationGVK: DenormalizationGVK{Version: "v1"},
+				Hops:               []DenormalizationHop{{Field: "spec.ref", Target: target, Key: "id"}},
+				Fields:             []string{"spec.displayName"},
+			},
+		},
+		{
+			name: "no hops",
+			rule: DenormalizationRule{
+				DenormalizationGVK: DenormalizationGVK{Version: "v1", Kind: "Widget"},
+				Fields:             []string{"spec.displayName"},
+			},
+		},
+		{
+			name: "no fields",
+			rule: DenormalizationRule{
+				DenormalizationGVK: DenormalizationGVK{Version: "v1", Kind: "Widget"},
+				Hops:               []DenormalizationHop{{Field: "spec.ref", Target: target, Key: "id"}},
+			},
+		},
+		{
+			name: "field and label",
+			rule: DenormalizationRule{
+				DenormalizationGVK: DenormalizationGVK{Version: "v1", Kind: "Widget"},
+				Hops:               []DenormalizationHop{{Field: "spec.ref", Label: "example.com/ref", Target: target, Key: "id"}},
+				Fields:             []string{"spec.displayName"},
+			},
+		},
+		{
+			name: "missing key",
+			rule: DenormalizationRule{
+				DenormalizationGVK: DenormalizationGVK{Version: "v1", Kind: "Widget"},
+				Hops:               []DenormalizationHop{{Field: "spec.ref", Target: target}},
+				Fields:             []string{"spec.displayName"},
+			},
+		},
+		{
+			name: "cycle",
+			rule: DenormalizationRule{
+				DenormalizationGVK: DenormalizationGVK{Version: "v1", Kind: "Widget"},
+				Hops: []DenormalizationHop{
+					{Field: "spec.ref", Target: target, Key: "id"},
+					{Field: "spec.ref", Target: DenormalizationGVK{Version: "v1", Kind: "Widget"}, Key: "id"},
+				},
+				Fields: []string{"spec.displayName"},
+			},
+		},
+		{
+			name: "quoted field",
+			rule: DenormalizationRule{
+				DenormalizationGVK: DenormalizationGVK{Version: "v1", Kind: "Widget"},
+				Hops:               []DenormalizationHop{{Field: "spec.ref", Target: target, Key: "id"}},
+				Fields:             []string{`spec"displayName`},
+			},
+		},
+		{
+			name: "conflicts with built-in rule",
+			rule: DenormalizationRule{
+				DenormalizationGVK: DenormalizationGVK{Version: "v1", Kind: "Namespace"},
+				Hops:               []DenormalizationHop{{Field: "spec.ref", Target: target, Key: "id"}},
+				Fields:             []string{"spec.displayName"},
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rules := DenormalizationRules{Rules: []DenormalizationRule{test.rule}}
+			assert.Error(t, rules.Validate())
+		})
+	}
+}
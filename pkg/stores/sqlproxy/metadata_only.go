@@ -0,0 +1,159 @@
+package sqlproxy
+
+import (
+	"maps"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/rancher/apiserver/pkg/types"
+	"github.com/rancher/steve/pkg/attributes"
+	"github.com/rancher/steve/pkg/sqlcache/informer"
+	"github.com/rancher/steve/pkg/stores/sqlproxy/metadataclient"
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/metadata"
+	"k8s.io/client-go/tools/cache"
+)
+
+// MetadataOnlyTypesEnvVar is a comma-separated list of schema IDs, eg. "secret,configmap,event", whose SQL cache only
+// holds the metadata of objects
+const MetadataOnlyTypesEnvVar = "CATTLE_SQL_CACHE_METADATA_ONLY_TYPES"
+
+// MetadataClientGetter is implemented by ClientGetters which can list and watch the metadata of objects only
+type MetadataClientGetter interface {
+	MetadataClient() metadata.Interface
+}
+
+// metadataOnlyTypesFromEnv returns the schema IDs of MetadataOnlyTypesEnvVar
+func metadataOnlyTypesFromEnv() sets.Set[string] {
+	ids := sets.New[string]()
+	for _, id := range strings.Split(os.Getenv(MetadataOnlyTypesEnvVar), ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			ids.Insert(id)
+		}
+	}
+	return ids
+}
+
+// metadataOnlyClient returns a client listing and watching the metadata of objects of apiSchema if it is cached in
+// metadata-only mode, or nil otherwise. Other requests, such as getting full objects, go through client.
+//
+// Types indexing fields beyond metadata need their full objects to extract them, so nil is returned and their cache
+// only keeps the indexed fields instead, see metadataOnlyTransform.
+func (s *Store) metadataOnlyClient(apiSchema *types.APISchema, namespace string, client dynamic.ResourceInterface) dynamic.ResourceInterface {
+	if !s.metadataOnlyTypes.Has(apiSchema.ID) {
+		return nil
+	}
+	if len(s.fieldsBeyondMetadata(apiSchema)) > 0 {
+		return nil
+	}
+	getter, ok := s.clientGetter.(MetadataClientGetter)
+	if !ok {
+		logrus.Warnf("sqlproxy: no metadata client available, caching full objects of %s", apiSchema.ID)
+		return nil
+	}
+	return &metadataclient.Client{
+		ResourceInterface: client,
+		Metadata:          getter.MetadataClient().Resource(attributes.GVR(apiSchema)).Namespace(namespace),
+		GVK:               attributes.GVK(apiSchema),
+	}
+}
+
+// metadataOnlyTransform returns transform, followed by the extraction of the metadata and indexed fields of objects if
+// apiSchema is cached in metadata-only mode but indexes fields beyond metadata. Otherwise transform is returned as is.
+func (s *Store) metadataOnlyTransform(apiSchema *types.APISchema, transform cache.TransformFunc) cache.TransformFunc {
+	if !s.metadataOnlyTypes.Has(apiSchema.ID) {
+		return transform
+	}
+	paths := s.pathsBeyondMetadata(apiSchema)
+	if len(paths) == 0 {
+		return transform
+	}
+	if _, logged := s.metadataOnlyExtracted.LoadOrStore(apiSchema.ID, true); !logged {
+		logrus.Infof("sqlproxy: %s indexes fields not found in metadata (%s), caching them along with metadata", apiSchema.ID, strings.Join(slices.Sorted(maps.Keys(paths)), ", "))
+	}
+	return extractFieldsTransform(transform, slices.Collect(maps.Values(paths)))
+}
+
+// fieldsBeyondMetadata returns the sorted names of the indexed fields of apiSchema which are read from parts of objects
+// other than their metadata, see pathsBeyondMetadata
+func (s *Store) fieldsBeyondMetadata(apiSchema *types.APISchema) []string {
+	return slices.Sorted(maps.Keys(s.pathsBeyondMetadata(apiSchema)))
+}
+
+// pathsBeyondMetadata returns the paths of the indexed fields of apiSchema which are read from parts of objects other
+// than their metadata, and would always be empty in metadata-only mode, by field name. Fields copied from other types
+// by denormalization rules don't depend on the object and are ignored.
+func (s *Store) pathsBeyondMetadata(apiSchema *types.APISchema) map[string][]string {
+	gvk := attributes.GVK(apiSchema)
+	fields, _ := getFieldAndColInfo(apiSchema, gvk)
+	for name, field := range getFieldForGVK(gvk) {
+		fields[name] = field
+	}
+	copied := s.getDenormalizations().copied[gvk]
+	result := map[string][]string{}
+	for name, field := range fields {
+		jsonPath, ok := field.(*informer.JSONPathField)
+		if !ok || len(jsonPath.Path) == 0 || jsonPath.Path[0] == "metadata" || name == "id" || copied.Has(name) {
+			continue
+		}
+		result[name] = jsonPath.Path
+	}
+	return result
+}
+
+// extractedKeys are the top-level keys of objects always kept by extractFieldsTransform
+var extractedKeys = []string{"apiVersion", "kind", "metadata", "id"}
+
+// extractFieldsTransform returns a cache.TransformFunc which runs transform (if not nil) and then replaces objects by
+// their extractedKeys and the values at paths, so that the rest of the objects, eg. the data of secrets, isn't cached
+func extractFieldsTransform(transform cache.TransformFunc, paths [][]string) cache.TransformFunc {
+	return func(raw any) (any, error) {
+		if transform != nil {
+			var err error
+			if raw, err = transform(raw); err != nil {
+				return raw, err
+			}
+		}
+		obj, ok := raw.(*unstructured.Unstructured)
+		if !ok {
+			return raw, nil
+		}
+		extracted := make(map[string]any, len(extractedKeys)+len(paths))
+		for _, key := range extractedKeys {
+			if value, ok := obj.Object[key]; ok {
+				extracted[key] = value
+			}
+		}
+		for _, path := range paths {
+			copyPath(extracted, obj.Object, path)
+		}
+		obj.Object = extracted
+		return obj, nil
+	}
+}
+
+// copyPath copies the value at path in src to dst. Values which are not maps, such as lists of objects whose fields
+// are indexed, are copied whole.
+func copyPath(dst, src map[string]any, path []string) {
+	for i, key := range path {
+		value, ok := src[key]
+		if !ok {
+			return
+		}
+		next, isMap := value.(map[string]any)
+		if !isMap || i == len(path)-1 {
+			dst[key] = value
+			return
+		}
+		child, ok := dst[key].(map[string]any)
+		if !ok {
+			child = map[string]any{}
+			dst[key] = child
+		}
+		dst, src = child, next
+	}
+}
@@ -0,0 +1,228 @@
+package sqlproxy
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/rancher/apiserver/pkg/types"
+	"github.com/rancher/steve/pkg/attributes"
+	"github.com/rancher/steve/pkg/resources/common"
+	"github.com/rancher/steve/pkg/sqlcache/informer"
+	"github.com/rancher/steve/pkg/sqlcache/informer/factory"
+	"github.com/rancher/steve/pkg/stores/sqlpartition/listprocessor"
+	"github.com/rancher/steve/pkg/stores/sqlproxy/metadataclient"
+	"github.com/rancher/steve/pkg/stores/sqlproxy/tablelistconvert"
+	"github.com/rancher/wrangler/v3/pkg/schemas"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/metadata"
+	"k8s.io/client-go/metadata/fake"
+	"k8s.io/client-go/tools/cache"
+)
+
+type metadataClientGetter struct {
+	*MockClientGetter
+	metadata metadata.Interface
+}
+
+func (m *metadataClientGetter) MetadataClient() metadata.Interface {
+	return m.metadata
+}
+
+func TestMetadataOnlyTypesFromEnv(t *testing.T) {
+	t.Setenv(MetadataOnlyTypesEnvVar, "secret, configmap,,event")
+	assert.Equal(t, sets.New("secret", "configmap", "event"), metadataOnlyTypesFromEnv())
+}
+
+func TestMetadataOnlyClient(t *testing.T) {
+	apiSchema := &types.APISchema{Schema: &schemas.Schema{ID: "configmap"}}
+	attributes.SetGVR(apiSchema, schema.GroupVersionResource{Version: "v1", Resource: "configmaps"})
+	attributes.SetGVK(apiSchema, schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"})
+	client := NewMockResourceInterface(gomock.NewController(t))
+	getter := &metadataClientGetter{
+		MockClientGetter: NewMockClientGetter(gomock.NewController(t)),
+		metadata:         fake.NewSimpleMetadataClient(runtime.NewScheme()),
+	}
+
+	s := &Store{clientGetter: getter}
+	assert.Nil(t, s.metadataOnlyClient(apiSchema, "", client))
+
+	s.metadataOnlyTypes = sets.New("configmap")
+	result, ok := s.metadataOnlyClient(apiSchema, "", client).(*metadataclient.Client)
+	if assert.True(t, ok) {
+		assert.Equal(t, client, result.ResourceInterface)
+		assert.Equal(t, schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"}, result.GVK)
+	}
+
+	// without a metadata client, full objects are cached
+	s.clientGetter = getter.MockClientGetter
+	assert.Nil(t, s.metadataOnlyClient(apiSchema, "", client))
+}
+
+func TestMetadataOnlyClientRejectsFieldsBeyondMetadata(t *testing.T) {
+	apiSchema := &types.APISchema{Schema: &schemas.Schema{ID: "secret"}}
+	attributes.SetGVR(apiSchema, schema.GroupVersionResource{Version: "v1", Resource: "secrets"})
+	attributes.SetGVK(apiSchema, secretGVK)
+	getter := &metadataClientGetter{
+		MockClientGetter: NewMockClientGetter(gomock.NewController(t)),
+		metadata:         fake.NewSimpleMetadataClient(runtime.NewScheme()),
+	}
+
+	s := &Store{clientGetter: getter, metadataOnlyTypes: sets.New("secret")}
+	assert.Nil(t, s.metadataOnlyClient(apiSchema, "", NewMockResourceInterface(gomock.NewController(t))))
+}
+
+func TestMetadataOnlyTransform(t *testing.T) {
+	apiSchema := &types.APISchema{Schema: &schemas.Schema{ID: "event"}}
+	attributes.SetGVK(apiSchema, schema.GroupVersionKind{Version: "v1", Kind: "Event"})
+	transform := func(raw any) (any, error) {
+		obj := raw.(*unstructured.Unstructured)
+		obj.Object["_type"] = obj.Object["type"]
+		return obj, nil
+	}
+	newEvent := func() *unstructured.Unstructured {
+		return &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "v1",
+			"kind":       "Event",
+			"metadata":   map[string]interface{}{"name": "pod.1", "namespace": "default"},
+			"type":       "Normal",
+			"reason":     "Scheduled",
+			"message":    "Successfully assigned default/pod",
+			"involvedObject": map[string]interface{}{
+				"kind":      "Pod",
+				"name":      "pod",
+				"namespace": "default",
+				"uid":       "1234",
+			},
+			"source": map[string]interface{}{"component": "default-scheduler"},
+		}}
+	}
+
+	s := &Store{}
+	obj, err := s.metadataOnlyTransform(apiSchema, transform)(newEvent())
+	require.NoError(t, err)
+	assert.Equal(t, "Normal", obj.(*unstructured.Unstructured).Object["type"], "types not in metadata-only mode are kept whole")
+
+	s.metadataOnlyTypes = sets.New("event")
+	obj, err = s.metadataOnlyTransform(apiSchema, transform)(newEvent())
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "Event",
+		"metadata":   map[string]interface{}{"name": "pod.1", "namespace": "default"},
+		"_type":      "Normal",
+		"reason":     "Scheduled",
+		"message":    "Successfully assigned default/pod",
+		"involvedObject": map[string]interface{}{
+			"kind": "Pod",
+			"uid":  "1234",
+		},
+	}, obj.(*unstructured.Unstructured).Object)
+}
+
+func TestCopyPath(t *testing.T) {
+	src := map[string]interface{}{
+		"spec": map[string]interface{}{
+			"taints":   []interface{}{map[string]interface{}{"key": "a", "effect": "NoSchedule"}},
+			"podCIDR":  "10.0.0.0/24",
+			"replicas": int64(2),
+		},
+	}
+	dst := map[string]interface{}{}
+	copyPath(dst, src, []string{"spec", "taints", "key"})
+	copyPath(dst, src, []string{"spec", "replicas"})
+	copyPath(dst, src, []string{"status", "phase"})
+	assert.Equal(t, map[string]interface{}{
+		"spec": map[string]interface{}{
+			// lists are copied whole, as their items' fields are indexed
+			"taints":   []interface{}{map[string]interface{}{"key": "a", "effect": "NoSchedule"}},
+			"replicas": int64(2),
+		},
+	}, dst)
+}
+
+func TestFieldsBeyondMetadata(t *testing.T) {
+	newSchema := func(gvk schema.GroupVersionKind, columns ...string) *types.APISchema {
+		var colDefs []common.ColumnDefinition
+		for _, column := range columns {
+			colDefs = append(colDefs, common.ColumnDefinition{Field: column})
+		}
+		apiSchema := &types.APISchema{Schema: &schemas.Schema{Attributes: map[string]interface{}{"columns": colDefs}}}
+		attributes.SetGVK(apiSchema, gvk)
+		return apiSchema
+	}
+	s := &Store{}
+
+	assert.Empty(t, s.fieldsBeyondMetadata(newSchema(schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"}, "$.metadata.fields[1]")))
+	assert.Equal(t, []string{"spec.replicas"}, s.fieldsBeyondMetadata(newSchema(schema.GroupVersionKind{Group: "example.com", Version: "v1", Kind: "Widget"}, "$.metadata.fields[1]", "$.spec.replicas")))
+	// fields copied from projects don't depend on the secret
+	assert.Equal(t, []string{"_type"}, s.fieldsBeyondMetadata(newSchema(secretGVK)))
+}
+
+func TestListByPartitionsMetadataOnlySecretsByType(t *testing.T) {
+	cg := NewMockClientGetter(gomock.NewController(t))
+	cf := NewMockCacheFactory(gomock.NewController(t))
+	ri := NewMockResourceInterface(gomock.NewController(t))
+	bloi := NewMockByOptionsLister(gomock.NewController(t))
+	tb := NewMockTransformBuilder(gomock.NewController(t))
+	sc := NewMockSchemaCollection(gomock.NewController(t))
+	secretCache := &factory.Cache{ByOptionsLister: &informer.Informer{ByOptionsLister: bloi}}
+	projectCache := &factory.Cache{}
+	s := &Store{
+		ctx:            context.Background(),
+		namespaceCache: &factory.Cache{ByOptionsLister: bloi},
+		clientGetter: &metadataClientGetter{
+			MockClientGetter: cg,
+			metadata:         fake.NewSimpleMetadataClient(runtime.NewScheme()),
+		},
+		cacheFactory:      cf,
+		transformBuilder:  tb,
+		schemas:           sc,
+		metadataOnlyTypes: sets.New("secret"),
+	}
+
+	apiSchema := &types.APISchema{Schema: &schemas.Schema{ID: "secret", Attributes: map[string]interface{}{
+		"verbs": []string{"list", "watch"},
+	}}}
+	attributes.SetGVK(apiSchema, secretGVK)
+	attributes.SetNamespaced(apiSchema, true)
+	req := &types.APIRequest{Request: &http.Request{URL: &url.URL{RawQuery: "filter=_type=Opaque"}}}
+	setupContext(req)
+	opts, err := listprocessor.ParseQuery(req, "")
+	require.NoError(t, err)
+
+	// secrets depend on projects, which are cached as usual
+	sc.EXPECT().ByGVK(mcioProjectGVK).Return("")
+	cg.EXPECT().TableAdminClient(nil, &mcioProjectSchema, "", gomock.Any()).Return(ri, nil)
+	tb.EXPECT().GetTransformFunc(mcioProjectGVK, gomock.Any(), gomock.Any(), gomock.Any()).Return(nil)
+	cf.EXPECT().CacheFor(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), mcioProjectGVK, gomock.Any(), gomock.Any()).Return(projectCache, nil)
+	cf.EXPECT().DoneWithCache(projectCache)
+
+	// secrets index _type, so their full objects are listed and only their metadata and _type are cached
+	cg.EXPECT().TableAdminClient(req, apiSchema, "", gomock.Any()).Return(ri, nil)
+	tb.EXPECT().GetTransformFunc(secretGVK, gomock.Any(), gomock.Any(), gomock.Any()).Return(nil)
+	cf.EXPECT().CacheFor(gomock.Any(), gomock.Cond(func(fields map[string]informer.IndexedField) bool {
+		_, ok := fields["_type"]
+		return ok
+	}), gomock.Any(), gomock.Any(), gomock.Cond(func(transform cache.TransformFunc) bool {
+		obj, err := transform(&unstructured.Unstructured{Object: map[string]interface{}{"_type": "Opaque", "data": map[string]interface{}{"key": "dmFsdWU="}}})
+		return err == nil && assert.ObjectsAreEqual(map[string]interface{}{"_type": "Opaque"}, obj.(*unstructured.Unstructured).Object)
+	}), &tablelistconvert.Client{ResourceInterface: ri}, secretGVK, true, gomock.Any()).Return(secretCache, nil)
+	cf.EXPECT().DoneWithCache(secretCache)
+
+	secret := unstructured.Unstructured{Object: map[string]interface{}{"_type": "Opaque"}}
+	secret.SetName("opaque")
+	bloi.EXPECT().ListByOptions(gomock.Any(), &opts, gomock.Any(), "").Return(&unstructured.UnstructuredList{Items: []unstructured.Unstructured{secret}}, 1, nil, "", nil)
+
+	list, total, _, _, err := s.ListByPartitions(req, apiSchema, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 1, total)
+	assert.Equal(t, []unstructured.Unstructured{secret}, list.Items)
+}
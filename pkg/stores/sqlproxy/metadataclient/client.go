@@ -0,0 +1,88 @@
+/*
+Package metadataclient provides a client that lists and watches objects through the metadata API, returning
+*Unstructured objects which only contain the apiVersion, kind and metadata of the objects. It is used to cache very large
+resource types without holding their contents.
+*/
+package metadataclient
+
+import (
+	"context"
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	k8sWatch "k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/metadata"
+)
+
+type Client struct {
+	// ResourceInterface serves all requests other than List and Watch, eg. to get full objects
+	dynamic.ResourceInterface
+	// Metadata lists and watches the metadata of objects
+	Metadata metadata.ResourceInterface
+	// GVK is set as the apiVersion and kind of the returned objects
+	GVK schema.GroupVersionKind
+}
+
+var _ dynamic.ResourceInterface = (*Client)(nil)
+
+// List returns the metadata of objects as an *UnstructuredList
+func (c *Client) List(ctx context.Context, opts metav1.ListOptions) (*unstructured.UnstructuredList, error) {
+	list, err := c.Metadata.List(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	result := &unstructured.UnstructuredList{Items: make([]unstructured.Unstructured, 0, len(list.Items))}
+	result.SetGroupVersionKind(c.GVK.GroupVersion().WithKind(c.GVK.Kind + "List"))
+	result.SetResourceVersion(list.ResourceVersion)
+	result.SetContinue(list.Continue)
+	result.SetRemainingItemCount(list.RemainingItemCount)
+	for i := range list.Items {
+		obj, err := c.toUnstructured(&list.Items[i])
+		if err != nil {
+			return nil, err
+		}
+		result.Items = append(result.Items, *obj)
+	}
+	return result, nil
+}
+
+// Watch returns the metadata of objects in watch events as *Unstructured objects. Other events, such as errors, are
+// passed as they are.
+func (c *Client) Watch(ctx context.Context, opts metav1.ListOptions) (k8sWatch.Interface, error) {
+	w, err := c.Metadata.Watch(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	return k8sWatch.Filter(w, func(e k8sWatch.Event) (k8sWatch.Event, bool) {
+		partial, ok := e.Object.(*metav1.PartialObjectMetadata)
+		if !ok {
+			return e, true
+		}
+		obj, err := c.toUnstructured(partial)
+		if err != nil {
+			return k8sWatch.Event{
+				Type:   k8sWatch.Error,
+				Object: &metav1.Status{Status: metav1.StatusFailure, Message: err.Error()},
+			}, true
+		}
+		e.Object = obj
+		return e, true
+	}), nil
+}
+
+// toUnstructured converts the metadata of an object, leaving out managed fields which are often bigger than the rest
+func (c *Client) toUnstructured(partial *metav1.PartialObjectMetadata) (*unstructured.Unstructured, error) {
+	objectMeta := partial.ObjectMeta
+	objectMeta.ManagedFields = nil
+	m, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&objectMeta)
+	if err != nil {
+		return nil, fmt.Errorf("converting metadata of %s: %w", partial.GetName(), err)
+	}
+	obj := &unstructured.Unstructured{Object: map[string]any{"metadata": m}}
+	obj.SetGroupVersionKind(c.GVK)
+	return obj, nil
+}
@@ -0,0 +1,64 @@
+package metadataclient
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	k8sWatch "k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/metadata/fake"
+)
+
+func TestClient(t *testing.T) {
+	gvr := schema.GroupVersionResource{Version: "v1", Resource: "secrets"}
+	gvk := schema.GroupVersionKind{Version: "v1", Kind: "Secret"}
+	scheme := runtime.NewScheme()
+	require.NoError(t, metav1.AddMetaToScheme(scheme))
+	secret := &metav1.PartialObjectMetadata{
+		TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: "Secret"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            "token",
+			Namespace:       "default",
+			Labels:          map[string]string{"app": "web"},
+			ResourceVersion: "10",
+			ManagedFields:   []metav1.ManagedFieldsEntry{{Manager: "kubectl"}},
+		},
+	}
+	metadataClient := fake.NewSimpleMetadataClient(scheme, secret)
+	client := &Client{Metadata: metadataClient.Resource(gvr), GVK: gvk}
+
+	t.Run("list", func(t *testing.T) {
+		list, err := client.List(context.Background(), metav1.ListOptions{})
+		require.NoError(t, err)
+		require.Len(t, list.Items, 1)
+		obj := list.Items[0]
+		assert.Equal(t, gvk, obj.GroupVersionKind())
+		assert.Equal(t, "default", obj.GetNamespace())
+		assert.Equal(t, "token", obj.GetName())
+		assert.Equal(t, map[string]string{"app": "web"}, obj.GetLabels())
+		assert.Equal(t, "10", obj.GetResourceVersion())
+		assert.Empty(t, obj.GetManagedFields())
+		// only the metadata is kept
+		assert.Len(t, obj.Object, 3)
+	})
+
+	t.Run("watch", func(t *testing.T) {
+		w, err := client.Watch(context.Background(), metav1.ListOptions{})
+		require.NoError(t, err)
+		defer w.Stop()
+		updated := secret.DeepCopy()
+		updated.ResourceVersion = "11"
+		require.NoError(t, metadataClient.Tracker().Update(gvr, updated, "default"))
+		event := <-w.ResultChan()
+		assert.Equal(t, k8sWatch.Modified, event.Type)
+		obj, ok := event.Object.(*unstructured.Unstructured)
+		require.True(t, ok)
+		assert.Equal(t, gvk, obj.GroupVersionKind())
+		assert.Equal(t, "11", obj.GetResourceVersion())
+	})
+}
@@ -0,0 +1,26 @@
+package sqlproxy
+
+import (
+	"github.com/rancher/steve/pkg/sqlcache/informer/factory"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+)
+
+// NamespaceScoper is implemented by CacheFactories which can restrict the informers of some types to a list of
+// namespaces, in which case they need clients for each namespace
+type NamespaceScoper interface {
+	NamespacesFor(gvk schema.GroupVersionKind) []string
+}
+
+// namespacedCacheClient is a cache client which also provides clients for single namespaces, implementing
+// factory.NamespaceClientGetter
+type namespacedCacheClient struct {
+	dynamic.ResourceInterface
+	forNamespace func(namespace string) (dynamic.ResourceInterface, error)
+}
+
+var _ factory.NamespaceClientGetter = (*namespacedCacheClient)(nil)
+
+func (c *namespacedCacheClient) ClientForNamespace(namespace string) (dynamic.ResourceInterface, error) {
+	return c.forNamespace(namespace)
+}
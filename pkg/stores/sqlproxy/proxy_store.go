@@ -9,9 +9,11 @@ import (
 	"io"
 	"io/ioutil"
 	"net/http"
+	"os"
 	"regexp"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/pkg/errors"
 	"github.com/rancher/apiserver/pkg/apierror"
@@ -32,6 +34,7 @@ import (
 
 	"github.com/rancher/steve/pkg/attributes"
 	controllerschema "github.com/rancher/steve/pkg/controllers/schema"
+	"github.com/rancher/steve/pkg/ratelimit"
 	"github.com/rancher/steve/pkg/resources/common"
 	"github.com/rancher/steve/pkg/resources/virtual"
 	virtualCommon "github.com/rancher/steve/pkg/resources/virtual/common"
@@ -60,6 +63,8 @@ const (
 	watchTimeoutEnv            = "CATTLE_WATCH_TIMEOUT_SECONDS"
 	errNamespaceRequired       = "metadata.namespace or apiOp.namespace are required"
 	errResourceVersionRequired = "metadata.resourceVersion is required for update"
+	// watchBookmarkInterval is how often idle watches receive a bookmark with the latest revision
+	watchBookmarkInterval = time.Minute
 )
 
 var (
@@ -266,88 +271,30 @@ var (
 		"id":                  &informer.JSONPathField{Path: []string{"id"}},
 		"metadata.state.name": &informer.JSONPathField{Path: []string{"metadata", "state", "name"}},
 	}
-	namespaceGVK             = schema.GroupVersionKind{Group: "", Version: "v1", Kind: "Namespace"}
-	mcioProjectGvk           = schema.GroupVersionKind{Group: "management.cattle.io", Version: "v3", Kind: "Project"}
-	pcioClusterGvk           = schema.GroupVersionKind{Group: "provisioning.cattle.io", Version: "v1", Kind: "Cluster"}
-	namespaceProjectLabelDep = sqltypes.ExternalLabelDependency{
-		SourceGVK:            gvkKey("", "v1", "Namespace"),
-		SourceLabelName:      "field.cattle.io/projectId",
-		TargetGVK:            gvkKey("management.cattle.io", "v3", "Project"),
-		TargetKeyFieldName:   "metadata.name",
-		TargetFinalFieldName: "spec.displayName",
-	}
-	namespaceUpdates = sqltypes.ExternalGVKUpdates{
-		AffectedGVK:               namespaceGVK,
-		ExternalDependencies:      nil,
-		ExternalLabelDependencies: []sqltypes.ExternalLabelDependency{namespaceProjectLabelDep},
-	}
-
-	secretGVK                    = schema.GroupVersionKind{Group: "", Version: "v1", Kind: "Secret"}
-	secretProjectLabelDisplayDep = sqltypes.ExternalLabelDependency{
-		SourceGVK:            gvkKey("", "v1", "Secret"),
-		SourceLabelName:      "management.cattle.io/project-scoped-secret",
-		TargetGVK:            gvkKey("management.cattle.io", "v3", "Project"),
-		TargetKeyFieldName:   "metadata.name",
-		TargetFinalFieldName: "spec.displayName",
-	}
-	secretProjectLabelClusterDep = sqltypes.ExternalLabelDependency{
-		SourceGVK:            gvkKey("", "v1", "Secret"),
-		SourceLabelName:      "management.cattle.io/project-scoped-secret",
-		TargetGVK:            gvkKey("management.cattle.io", "v3", "Project"),
-		TargetKeyFieldName:   "metadata.name",
-		TargetFinalFieldName: "spec.clusterName",
-	}
-	secretUpdates = sqltypes.ExternalGVKUpdates{
-		AffectedGVK:               secretGVK,
-		ExternalDependencies:      nil,
-		ExternalLabelDependencies: []sqltypes.ExternalLabelDependency{secretProjectLabelDisplayDep, secretProjectLabelClusterDep},
-	}
-
-	// Now sort provisioned.cattle.io.clusters based on their associated mgmt.cattle.io spec values
-	// We might need to pull in the `memoryRaw` fields as well
-	// Remember to index these fields in the database.
-	provisionedClusterDependencies = func() []sqltypes.ExternalDependency {
-		fields := []string{
-			"status.allocatable.cpu",
-			"status.allocatable.cpuRaw",
-			"status.allocatable.memory",
-			"status.allocatable.memoryRaw",
-			"status.allocatable.pods",
-			"status.requested.cpu",
-			"status.requested.cpuRaw",
-			"status.requested.memory",
-			"status.requested.memoryRaw",
-			"status.requested.pods",
-		}
-		x := make([]sqltypes.ExternalDependency, len(fields))
-		for i, field := range fields {
-			x[i] = sqltypes.ExternalDependency{
-				SourceGVK:            gvkKey("provisioning.cattle.io", "v1", "Cluster"),
-				SourceFieldName:      "status.clusterName",
-				TargetGVK:            gvkKey("management.cattle.io", "v3", "Cluster"),
-				TargetKeyFieldName:   "id",
-				TargetFinalFieldName: field,
-			}
-		}
-		return x
-	}()
-	pcioClusterUpdates = sqltypes.ExternalGVKUpdates{
-		AffectedGVK:               pcioClusterGvk,
-		ExternalDependencies:      provisionedClusterDependencies,
-		ExternalLabelDependencies: nil,
-	}
-
-	externalGVKDependencies = sqltypes.ExternalGVKDependency{
-		mcioProjectGvk: &namespaceUpdates,
-		pcioClusterGvk: &pcioClusterUpdates,
-		secretGVK:      &secretUpdates,
+	namespaceGVK = schema.GroupVersionKind{Group: "", Version: "v1", Kind: "Namespace"}
+
+	secretGVK         = schema.GroupVersionKind{Group: "", Version: "v1", Kind: "Secret"}
+	pcioClusterGVK    = schema.GroupVersionKind{Group: "provisioning.cattle.io", Version: "v1", Kind: "Cluster"}
+	mgmtClusterGVK    = schema.GroupVersionKind{Group: "management.cattle.io", Version: "v3", Kind: "Cluster"}
+	mcioProjectGVK    = schema.GroupVersionKind{Group: "management.cattle.io", Version: "v3", Kind: "Project"}
+	mcioProjectSchema = types.APISchema{
+		Schema: &schemas.Schema{
+			Attributes: map[string]interface{}{
+				"group":    "management.cattle.io",
+				"version":  "v3",
+				"kind":     "Project",
+				"resource": "projects",
+				"verbs":    []string{"get", "list", "watch"},
+			},
+		},
 	}
-
-	selfGVKDependencies = sqltypes.ExternalGVKDependency{
-		// When a namespace is updated, we need to pull in changes from mcio into the namespaces table
-		namespaceGVK:   &namespaceUpdates,
-		pcioClusterGvk: &pcioClusterUpdates,
-		secretGVK:      &secretUpdates,
+	// requiredDependencies are the denormalization dependencies which can't be skipped when their schema isn't
+	// found, by GVK. Those with a fallback schema are cached with it, the others fail the request.
+	requiredDependencies = map[schema.GroupVersionKind]map[schema.GroupVersionKind]*types.APISchema{
+		// v1.secrets depend on management.cattle.io.projects
+		secretGVK: {mcioProjectGVK: &mcioProjectSchema},
+		// provisioning.cattle.io.clusters depend on management.cattle.io.clusters
+		pcioClusterGVK: {mgmtClusterGVK: nil},
 	}
 )
 
@@ -381,7 +328,7 @@ type SchemaCollection interface {
 type Cache interface {
 	// AugmentList takes a list of resources, and for some of them,
 	// adds related data to each item in the list
-	AugmentList(ctx context.Context, list *unstructured.UnstructuredList, childGVK schema.GroupVersionKind, childSchemaName string, useSelectors bool, accessList accesscontrol.AccessListByVerb) error
+	AugmentList(ctx context.Context, list *unstructured.UnstructuredList, assoc sqltypes.Association, accessList accesscontrol.AccessListByVerb) error
 
 	// ListByOptions returns objects according to the specified list options and partitions.
 	// Specifically:
@@ -425,6 +372,14 @@ type Store struct {
 	columnSetter     SchemaColumnSetter
 	transformBuilder TransformBuilder
 	schemas          SchemaCollection
+	denormalizations *denormalizations
+	// metadataOnlyTypes are the schema IDs whose cache only holds the metadata of objects
+	metadataOnlyTypes sets.Set[string]
+	// metadataOnlyExtracted are the metadataOnlyTypes whose indexed fields beyond metadata are extracted from full
+	// objects, by schema ID
+	metadataOnlyExtracted sync.Map
+	// queries queues the SQL queries of lists fairly between users, nil to run them all at once
+	queries *ratelimit.FairQueue
 
 	watchers *Watchers
 }
@@ -446,8 +401,25 @@ func NewProxyStore(ctx context.Context, c SchemaColumnSetter, clientGetter Clien
 		columnSetter:     c,
 		transformBuilder: virtual.NewTransformBuilder(scache),
 		schemas:          schemas,
+		denormalizations: defaultDenormalizations,
 		watchers:         newWatchers(),
 	}
+	store.metadataOnlyTypes = metadataOnlyTypesFromEnv()
+
+	var err error
+	if store.queries, err = ratelimit.SQLQueueFromEnv(); err != nil {
+		return nil, err
+	}
+
+	if path := os.Getenv(DenormalizationRulesEnvVar); path != "" {
+		rules, err := LoadDenormalizationRules(path)
+		if err != nil {
+			return nil, err
+		}
+		if store.denormalizations, err = newDenormalizations(append(append([]DenormalizationRule{}, defaultDenormalizationRules...), rules.Rules...)); err != nil {
+			return nil, err
+		}
+	}
 
 	if factory == nil {
 		var err error
@@ -521,6 +493,9 @@ func (s *Store) initializeNamespaceCache() error {
 	gvk := attributes.GVK(nsSchema)
 	fields, cols := getFieldAndColInfo(nsSchema, gvk)
 	// get any type-specific fields that steve is interested in (merge into map)
+	for k, v := range s.getDenormalizations().fields[gvk] {
+		fields[k] = v
+	}
 	for k, v := range getFieldForGVK(gvk) {
 		fields[k] = v
 	}
@@ -532,8 +507,8 @@ func (s *Store) initializeNamespaceCache() error {
 	tableClient := &tablelistconvert.Client{ResourceInterface: client}
 	nsInformer, err := s.cacheFactory.CacheFor(s.ctx,
 		fields,
-		externalGVKDependencies[gvk],
-		selfGVKDependencies[gvk],
+		s.getDenormalizations().external[gvk],
+		s.getDenormalizations().self[gvk],
 		transformFunc,
 		tableClient,
 		gvk,
@@ -547,6 +522,14 @@ func (s *Store) initializeNamespaceCache() error {
 	return nil
 }
 
+// getDenormalizations returns the denormalization rules in use, falling back to the built-in ones
+func (s *Store) getDenormalizations() *denormalizations {
+	if s.denormalizations == nil {
+		return defaultDenormalizations
+	}
+	return s.denormalizations
+}
+
 func getFieldForGVK(gvk schema.GroupVersionKind) map[string]informer.IndexedField {
 	fields := make(map[string]informer.IndexedField)
 	// Add common fields
@@ -795,6 +778,7 @@ func (s *Store) watch(apiOp *types.APIRequest, schema *types.APISchema, w types.
 				Namespace: idNamespace,
 				Selector:  selector,
 			},
+			BookmarkInterval: watchBookmarkInterval,
 		}
 		err := inf.ByOptionsLister.Watch(ctx, opts, result)
 		if err != nil {
@@ -1051,58 +1035,81 @@ func (s *Store) ListByPartitions(apiOp *types.APIRequest, apiSchema *types.APISc
 		}
 	}
 
-	list, total, summary, continueToken, err = inf.ListByOptions(apiOp.Context(), &opts, partitions, apiOp.Namespace)
+	list, total, summary, continueToken, err = s.queuedListByOptions(apiOp, inf, &opts, partitions)
 	if err != nil {
-		if errors.Is(err, informer.ErrInvalidColumn) {
+		if errors.Is(err, ratelimit.ErrQueueFull) {
+			apiOp.Response.Header().Set("Retry-After", "1")
+			err = apierror.NewAPIError(ratelimit.TooManyRequests, err.Error())
+		} else if errors.Is(err, informer.ErrInvalidColumn) {
 			err = apierror.NewAPIError(validation.InvalidBodyContent, err.Error())
 		} else if errors.Is(err, informer.ErrUnknownRevision) {
 			err = apierror.NewAPIError(validation.ErrorCode{Code: err.Error(), Status: http.StatusBadRequest}, err.Error())
+		} else if errors.Is(err, informer.ErrTooOld) {
+			// the changes are no longer available, the client has to list everything again
+			err = apierror.NewAPIError(validation.ErrorCode{Code: err.Error(), Status: http.StatusGone}, err.Error())
 		} else {
 			err = fmt.Errorf("listbyoptions %v: %w", gvk, err)
 		}
 	} else if opts.IncludeAssociatedData {
-		err = s.AugmentRelationships(ctx, gvk, list, apiOp)
+		err = s.AugmentRelationships(ctx, gvk, list, apiOp, opts.AssociatedData)
 	}
 
 	return
 }
 
-func (s *Store) AugmentRelationships(ctx context.Context, gvk schema.GroupVersionKind, list *unstructured.UnstructuredList, apiOp *types.APIRequest) error {
-	type GVKWithSchemaName struct {
-		gvk          schema.GroupVersionKind
-		schemaName   string
-		useSelectors bool
-	}
-	podGVK := schema.GroupVersionKind{Group: "", Version: "v1", Kind: "Pod"}
-	jobGVK := schema.GroupVersionKind{Group: "batch", Version: "v1", Kind: "Job"}
-	dependentChildInfoFromParentGVK := map[schema.GroupVersionKind]GVKWithSchemaName{
-		schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"}:  {podGVK, "pod", true},
-		schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "DaemonSet"}:   {podGVK, "pod", true},
-		schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "StatefulSet"}: {podGVK, "pod", true},
-		schema.GroupVersionKind{Group: "batch", Version: "v1", Kind: "Job"}:        {podGVK, "pod", true},
-		schema.GroupVersionKind{Group: "batch", Version: "v1", Kind: "CronJob"}:    {jobGVK, "batch.job", false},
-	}
-	childInfo, ok := dependentChildInfoFromParentGVK[gvk]
-	if !ok {
+// queuedListByOptions runs the query of a list once the fair queue of SQL queries lets the user of apiOp run it. Lists
+// waiting for a revision wait before they are queued, so that they don't hold a slot of the queue meanwhile.
+func (s *Store) queuedListByOptions(apiOp *types.APIRequest, inf *factory.Cache, opts *sqltypes.ListOptions, partitions []partition.Partition) (*unstructured.UnstructuredList, int, *types.APISummary, string, error) {
+	if s.queries != nil && opts.WaitForRevision > 0 {
+		if err := inf.WaitForRevision(apiOp.Context(), opts.Revision, opts.WaitForRevision); err != nil {
+			return nil, 0, nil, "", err
+		}
+	}
+	var name string
+	if user, ok := request.UserFrom(apiOp.Context()); ok {
+		name = user.GetName()
+	}
+	release, err := s.queries.Acquire(apiOp.Context(), name)
+	if err != nil {
+		return nil, 0, nil, "", err
+	}
+	defer release()
+	return inf.ListByOptions(apiOp.Context(), opts, partitions, apiOp.Namespace)
+}
+
+// AugmentRelationships embeds the children selected by opts in the metadata.associatedData of the items of list
+func (s *Store) AugmentRelationships(ctx context.Context, gvk schema.GroupVersionKind, list *unstructured.UnstructuredList, apiOp *types.APIRequest, opts sqltypes.AssociatedDataOptions) error {
+	associations := associationsFor(gvk, opts.Children)
+	if len(associations) == 0 {
 		logrus.Warnf("No associatedData defined for GVK %s", gvk)
 		return nil
 	}
-	schemas1 := apiOp.Schemas
-	schemas2 := schemas1.Schemas
-	dependentSchema, ok := schemas2[childInfo.schemaName]
-	if !ok {
-		// trace log because this is expected behaviour in most cases -
-		// there must be a reason why the user has read access to the parent resource only
-		logrus.Tracef("no read-access for resource %s", childInfo.schemaName)
-		return nil
+	for _, assoc := range associations {
+		dependentSchema, ok := apiOp.Schemas.Schemas[assoc.ChildSchemaName]
+		if !ok {
+			// trace log because this is expected behaviour in most cases -
+			// there must be a reason why the user has read access to the parent resource only
+			logrus.Tracef("no read-access for resource %s", assoc.ChildSchemaName)
+			continue
+		}
+		assoc.ChildGVK = attributes.GVK(dependentSchema)
+		assoc.Fields = opts.Fields
+		assoc.Limit = opts.Limit
+		if err := s.augmentWith(ctx, list, apiOp, dependentSchema, assoc); err != nil {
+			return err
+		}
 	}
-	childResourceInf, doneCache, err := s.cacheForWithDeps(ctx, apiOp, dependentSchema)
+	return nil
+}
+
+func (s *Store) augmentWith(ctx context.Context, list *unstructured.UnstructuredList, apiOp *types.APIRequest, childSchema *types.APISchema, assoc sqltypes.Association) error {
+	childResourceInf, doneCache, err := s.cacheForWithDeps(ctx, apiOp, childSchema)
 	if err != nil {
 		return err
 	}
 	defer doneCache()
-	accessList := accesscontrol.GetAccessListMap(dependentSchema)
-	return childResourceInf.AugmentList(ctx, list, childInfo.gvk, childInfo.schemaName, childInfo.useSelectors, accessList)
+	accessList := accesscontrol.GetAccessListMap(childSchema)
+	return childResourceInf.AugmentList(ctx, list, assoc, accessList)
 }
 
 // WatchByPartitions returns a channel of events for a list or resource belonging to any of the specified partitions
@@ -1163,48 +1170,35 @@ func (s *Store) cacheForWithDeps(ctx context.Context, apiOp *types.APIRequest, a
 	}
 
 	gvk := attributes.GVK(apiSchema)
-	// provisioning.cattle.io.clusters depends on information from management.cattle.io.clusters
-	// so we must initialize this one as well
-	if gvk == pcioClusterGvk {
-		var mgmtSchema *types.APISchema
-		if id := s.schemas.ByGVK(schema.GroupVersionKind{
-			Group:   "management.cattle.io",
-			Version: "v3",
-			Kind:    "Cluster",
-		}); id != "" {
-			mgmtSchema = s.schemas.Schema(id)
+	// types with denormalization rules depend on information from the types they reference,
+	// so we must initialize those as well
+	for _, depGVK := range s.getDenormalizations().dependencies[gvk] {
+		var depSchema *types.APISchema
+		if id := s.schemas.ByGVK(depGVK); id != "" {
+			depSchema = s.schemas.Schema(id)
 		}
-
-		if mgmtSchema == nil {
-			return nil, nil, fmt.Errorf("management cluster schema not found")
+		if depSchema == nil {
+			fallback, required := requiredDependencies[gvk][depGVK]
+			switch {
+			case fallback != nil:
+				depSchema = fallback
+			case required:
+				doneCache()
+				return nil, nil, fmt.Errorf("schema for %v, needed by %v, not found", depGVK, gvk)
+			default:
+				// the referenced type isn't installed, so there is nothing to copy from it
+				logrus.Warnf("schema for %v, needed by %v, not found: its fields will be empty", depGVK, gvk)
+				continue
+			}
 		}
 
-		mgmtClusterInf, err := s.cacheFor(ctx, nil, mgmtSchema)
-		if err != nil {
-			return nil, nil, err
-		}
-		doneCacheFns = append(doneCacheFns, func() {
-			s.cacheFactory.DoneWithCache(mgmtClusterInf)
-		})
-	} else if gvk == secretGVK {
-		mcioProjectSchema := types.APISchema{
-			Schema: &schemas.Schema{
-				Attributes: map[string]interface{}{
-					"group":    "management.cattle.io",
-					"version":  "v3",
-					"kind":     "Project",
-					"resource": "projects",
-					"verbs":    []string{"get", "list", "watch"},
-				},
-			},
-		}
-		// v1.secrets depend on management.cattle.io.projects
-		mcioProjectInf, err := s.cacheFor(ctx, nil, &mcioProjectSchema)
+		depInf, err := s.cacheFor(ctx, nil, depSchema)
 		if err != nil {
+			doneCache()
 			return nil, nil, err
 		}
 		doneCacheFns = append(doneCacheFns, func() {
-			s.cacheFactory.DoneWithCache(mcioProjectInf)
+			s.cacheFactory.DoneWithCache(depInf)
 		})
 	}
 
@@ -1224,9 +1218,7 @@ func (s *Store) cacheFor(ctx context.Context, apiOp *types.APIRequest, apiSchema
 	if !canList(apiSchema) {
 		return nil, apierror.NewAPIError(validation.MethodNotAllowed, fmt.Sprintf("resource %s is not a listable resource", apiSchema.ID))
 	}
-	// warnings from inside the informer are discarded
-	buffer := WarningBuffer{}
-	client, err := s.clientGetter.TableAdminClient(apiOp, apiSchema, "", &buffer)
+	cacheClient, err := s.cacheClient(apiOp, apiSchema, "")
 	if err != nil {
 		return nil, err
 	}
@@ -1235,21 +1227,48 @@ func (s *Store) cacheFor(ctx context.Context, apiOp *types.APIRequest, apiSchema
 	// TODO: All this field information is only needed when `s.cf.CacheFor` needs to build the tables.
 	// We should instead pass in a function to return the needed field info, rather than calculate it every time.
 	fields, cols := getFieldAndColInfo(apiSchema, gvk)
-	// Merge type-specific fields into map
-	for k, v := range getFieldForGVK(gvk) {
+	typeFields := getFieldForGVK(gvk)
+	ns := attributes.Namespaced(apiSchema)
+	if scoper, ok := s.cacheFactory.(NamespaceScoper); ok && ns && len(scoper.NamespacesFor(gvk)) > 0 {
+		cacheClient = &namespacedCacheClient{
+			ResourceInterface: cacheClient,
+			forNamespace: func(namespace string) (dynamic.ResourceInterface, error) {
+				return s.cacheClient(apiOp, apiSchema, namespace)
+			},
+		}
+	}
+	// Merge fields needed by denormalization rules, then type-specific fields into map
+	for k, v := range s.getDenormalizations().fields[gvk] {
+		fields[k] = v
+	}
+	for k, v := range typeFields {
 		fields[k] = v
 	}
 
 	transformFunc := s.transformBuilder.GetTransformFunc(gvk, cols, attributes.IsCRD(apiSchema), attributes.CRDJSONPathParsers(apiSchema))
-	tableClient := &tablelistconvert.Client{ResourceInterface: client}
-	ns := attributes.Namespaced(apiSchema)
-	inf, err := s.cacheFactory.CacheFor(ctx, fields, externalGVKDependencies[gvk], selfGVKDependencies[gvk], transformFunc, tableClient, gvk, ns, controllerschema.IsListWatchable(apiSchema))
+	transformFunc = s.metadataOnlyTransform(apiSchema, transformFunc)
+	inf, err := s.cacheFactory.CacheFor(ctx, fields, s.getDenormalizations().external[gvk], s.getDenormalizations().self[gvk], transformFunc, cacheClient, gvk, ns, controllerschema.IsListWatchable(apiSchema))
 	if err != nil {
 		return nil, fmt.Errorf("cachefor %v: %w", gvk, err)
 	}
 	return inf, nil
 }
 
+// cacheClient returns the client filling the cache of apiSchema with the objects of namespace, or of all namespaces
+// if empty
+func (s *Store) cacheClient(apiOp *types.APIRequest, apiSchema *types.APISchema, namespace string) (dynamic.ResourceInterface, error) {
+	// warnings from inside the informer are discarded
+	buffer := WarningBuffer{}
+	client, err := s.clientGetter.TableAdminClient(apiOp, apiSchema, namespace, &buffer)
+	if err != nil {
+		return nil, err
+	}
+	if metadataClient := s.metadataOnlyClient(apiSchema, namespace, client); metadataClient != nil {
+		return metadataClient, nil
+	}
+	return &tablelistconvert.Client{ResourceInterface: client}, nil
+}
+
 func canList(schema *types.APISchema) bool {
 	for _, verb := range attributes.Verbs(schema) {
 		if verb == "list" {
@@ -18,6 +18,7 @@ import (
 	"github.com/rancher/steve/pkg/accesscontrol"
 	"github.com/rancher/steve/pkg/attributes"
 	"github.com/rancher/steve/pkg/client"
+	"github.com/rancher/steve/pkg/ratelimit"
 	"github.com/rancher/steve/pkg/resources/common"
 	"github.com/rancher/steve/pkg/sqlcache/informer"
 	"github.com/rancher/steve/pkg/sqlcache/informer/factory"
@@ -28,6 +29,7 @@ import (
 	"github.com/rancher/wrangler/v3/pkg/schemas"
 	"github.com/rancher/wrangler/v3/pkg/schemas/validation"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	"go.uber.org/mock/gomock"
 
@@ -828,7 +830,7 @@ func TestAugmentRelationships(t *testing.T) {
 
 			setupContext(req)
 			attributes.SetGVK(schema, gvk)
-			err := s.AugmentRelationships(req.Context(), gvk, &originalList, nil)
+			err := s.AugmentRelationships(req.Context(), gvk, &originalList, nil, sqltypes.AssociatedDataOptions{})
 			assert.Nil(t, err)
 			assert.Equal(t, augmentedList.Items, originalItems)
 		},
@@ -923,8 +925,9 @@ func TestAugmentRelationships(t *testing.T) {
 				true).Return(c, nil)
 			tb.EXPECT().GetTransformFunc(podGVK, gomock.Any(), false, nil).Return(func(obj interface{}) (interface{}, error) { return obj, nil })
 			cf.EXPECT().DoneWithCache(c)
-			bloi.EXPECT().AugmentList(ctx, &originalList, gomock.Any(), gomock.Any(), true, gomock.Any()).Return(nil)
-			err := s.AugmentRelationships(ctx, gvk, &originalList, apiOp)
+			assoc := sqltypes.Association{ChildGVK: podGVK, ChildSchemaName: "pod", Type: sqltypes.AssociationBySelectorRelationship}
+			bloi.EXPECT().AugmentList(ctx, &originalList, assoc, gomock.Any()).Return(nil)
+			err := s.AugmentRelationships(ctx, gvk, &originalList, apiOp, sqltypes.AssociatedDataOptions{})
 			assert.Nil(t, err)
 		},
 	})
@@ -1343,6 +1346,86 @@ func receiveUntil(wc chan watch.Event, d time.Duration) error {
 	}
 }
 
+func TestCacheForWithDeps(t *testing.T) {
+	schemaFor := func(gvk schema.GroupVersionKind) *types.APISchema {
+		apiSchema := &types.APISchema{Schema: &schemas.Schema{Attributes: map[string]interface{}{
+			"verbs": []string{"list", "watch"},
+		}}}
+		attributes.SetGVK(apiSchema, gvk)
+		return apiSchema
+	}
+
+	t.Run("missing management cluster schema fails", func(t *testing.T) {
+		sc := NewMockSchemaCollection(gomock.NewController(t))
+		s := &Store{ctx: context.Background(), schemas: sc}
+		sc.EXPECT().ByGVK(mgmtClusterGVK).Return("")
+
+		_, _, err := s.cacheForWithDeps(context.Background(), nil, schemaFor(pcioClusterGVK))
+		assert.ErrorContains(t, err, "not found")
+	})
+
+	t.Run("missing project schema falls back to a built-in one for secrets", func(t *testing.T) {
+		sc := NewMockSchemaCollection(gomock.NewController(t))
+		cg := NewMockClientGetter(gomock.NewController(t))
+		s := &Store{ctx: context.Background(), schemas: sc, clientGetter: cg}
+		sc.EXPECT().ByGVK(mcioProjectGVK).Return("")
+		projectsErr := errors.New("projects client")
+		cg.EXPECT().TableAdminClient(nil, &mcioProjectSchema, "", gomock.Any()).Return(nil, projectsErr)
+
+		_, _, err := s.cacheForWithDeps(context.Background(), nil, schemaFor(secretGVK))
+		assert.ErrorIs(t, err, projectsErr)
+	})
+
+	t.Run("missing schema of a configured rule is skipped", func(t *testing.T) {
+		source := schema.GroupVersionKind{Group: "example.com", Version: "v1", Kind: "Widget"}
+		target := schema.GroupVersionKind{Group: "example.com", Version: "v1", Kind: "Gadget"}
+		d, err := newDenormalizations([]DenormalizationRule{{
+			DenormalizationGVK: DenormalizationGVK{Group: source.Group, Version: source.Version, Kind: source.Kind},
+			Hops: []DenormalizationHop{{
+				Field:  "spec.gadget",
+				Target: DenormalizationGVK{Group: target.Group, Version: target.Version, Kind: target.Kind},
+				Key:    "metadata.name",
+			}},
+			Fields: []string{"spec.color"},
+		}})
+		require.NoError(t, err)
+		sc := NewMockSchemaCollection(gomock.NewController(t))
+		cg := NewMockClientGetter(gomock.NewController(t))
+		s := &Store{ctx: context.Background(), schemas: sc, clientGetter: cg, denormalizations: d}
+		sc.EXPECT().ByGVK(target).Return("")
+		widgetSchema := schemaFor(source)
+		widgetsErr := errors.New("widgets client")
+		cg.EXPECT().TableAdminClient(nil, widgetSchema, "", gomock.Any()).Return(nil, widgetsErr)
+
+		_, _, err = s.cacheForWithDeps(context.Background(), nil, widgetSchema)
+		assert.ErrorIs(t, err, widgetsErr)
+	})
+}
+
+func TestQueuedListByOptions(t *testing.T) {
+	queue := ratelimit.NewFairQueue("test", ratelimit.QueueConfig{Concurrency: 1})
+	s := &Store{queries: queue}
+	bloi := NewMockByOptionsLister(gomock.NewController(t))
+	inf := &factory.Cache{ByOptionsLister: bloi}
+	req, err := http.NewRequest(http.MethodGet, "/v1/pods?revision=5&waitForRevision=1s", nil)
+	require.NoError(t, err)
+	apiOp := &types.APIRequest{Request: req.WithContext(krequest.WithUser(req.Context(), &user.DefaultInfo{Name: "alice"}))}
+	opts := &sqltypes.ListOptions{Revision: "5", WaitForRevision: time.Second}
+
+	bloi.EXPECT().WaitForRevision(gomock.Any(), "5", time.Second).DoAndReturn(func(ctx context.Context, _ string, _ time.Duration) error {
+		// the slot is free while waiting for the revision
+		ctx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+		defer cancel()
+		release, err := queue.Acquire(ctx, "bob")
+		require.NoError(t, err)
+		release()
+		return nil
+	})
+	bloi.EXPECT().ListByOptions(gomock.Any(), opts, nil, "").Return(&unstructured.UnstructuredList{}, 0, nil, "", nil)
+	_, _, _, _, err = s.queuedListByOptions(apiOp, inf, opts, nil)
+	assert.NoError(t, err)
+}
+
 func TestCreate(t *testing.T) {
 	type input struct {
 		apiOp  *types.APIRequest